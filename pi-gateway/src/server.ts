@@ -6,6 +6,14 @@ import { fileURLToPath } from 'node:url';
 
 import { PiRpcClient } from './pi-rpc-client.js';
 
+// toolsTokenFor returns the token the Pi extension uses to execute tools for a person.
+// A per-person token scoped to tools:execute (NOTES_TOOLS_TOKEN_<PERSON>) keeps a session
+// from acting as anyone else; the admin NOTES_TOKEN is the fallback.
+function toolsTokenFor(person: string): string {
+  const scoped = (process.env[`NOTES_TOOLS_TOKEN_${person.toUpperCase()}`] || '').trim();
+  return scoped || process.env.NOTES_TOKEN || '';
+}
+
 function maybeLoadServerDotEnv(): void {
   // In production, systemd injects env vars via EnvironmentFile.
   // For local runs (e.g. `npm start`), load ../server/.env so tools can authenticate back to the Go server.
@@ -202,7 +210,7 @@ async function getOrStartSessionClient(person: string, runtimeSessionId: string)
     env: {
       // Tool extension will use these.
       NOTES_SERVER_URL: process.env.NOTES_SERVER_URL || 'http://127.0.0.1:8080',
-      NOTES_TOKEN: process.env.NOTES_TOKEN || '',
+      NOTES_TOKEN: toolsTokenFor(person),
       NOTES_PERSON: person,
     },
   });
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"archive/zip"
+	"flag"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"notes-editor/internal/auth"
+	"notes-editor/internal/backup"
+	"notes-editor/internal/config"
+	"notes-editor/internal/importer"
+	"notes-editor/internal/vault"
+)
+
+const commandUsage = `usage: server [command] [flags]
+
+Without a command the HTTP server is started.
+
+Commands:
+  import           import notes from Obsidian, a markdown folder or Google Keep
+  migrate-secrets  move secrets from .env into the encrypted secrets store
+  restore-backup   decrypt, verify and extract an encrypted backup archive
+  restore-vault    restore one person's vault from a vault backup ZIP
+`
+
+// runCommand dispatches a CLI subcommand and returns the process exit code.
+func runCommand(args []string) int {
+	switch args[0] {
+	case "import":
+		return runImport(args[1:], os.Stdout, os.Stderr)
+	case "migrate-secrets":
+		return runMigrateSecrets(args[1:], os.Stdout, os.Stderr)
+	case "restore-backup":
+		return runRestoreBackup(args[1:], os.Stdout, os.Stderr)
+	case "restore-vault":
+		return runRestoreVault(args[1:], os.Stdout, os.Stderr)
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, commandUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], commandUsage)
+		return 2
+	}
+}
+
+func runMigrateSecrets(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("migrate-secrets", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(stderr, "migrate-secrets: %v\n", err)
+		return 1
+	}
+	moved, err := cfg.MigrateSecrets()
+	if err != nil {
+		fmt.Fprintf(stderr, "migrate-secrets: %v\n", err)
+		return 1
+	}
+	if len(moved) == 0 {
+		fmt.Fprintln(stdout, "no secrets left in .env")
+		return 0
+	}
+	for _, key := range moved {
+		fmt.Fprintf(stdout, "moved %s\n", key)
+	}
+	fmt.Fprintf(stdout, "secrets stored in %s\n", cfg.SecretsPath())
+	return 0
+}
+
+func runRestoreBackup(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("restore-backup", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	archive := fs.String("archive", "", "path to the encrypted backup archive (required)")
+	dest := fs.String("dest", "", "empty directory to extract into (required unless -verify-only)")
+	passphraseEnv := fs.String("passphrase-env", "BACKUP_PASSPHRASE", "environment variable holding the passphrase")
+	verifyOnly := fs.Bool("verify-only", false, "only decrypt and verify checksums")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *archive == "" || (*dest == "" && !*verifyOnly) {
+		fs.Usage()
+		return 2
+	}
+
+	// Same precedence as config.Load: the environment wins over the secrets
+	// store, which wins over .env.
+	if err := config.LoadEnvironment(); err != nil {
+		fmt.Fprintf(stderr, "restore-backup: %v\n", err)
+		return 1
+	}
+	passphrase := os.Getenv(strings.TrimSpace(*passphraseEnv))
+	if passphrase == "" {
+		fmt.Fprintf(stderr, "restore-backup: %s is not set\n", *passphraseEnv)
+		return 1
+	}
+
+	var (
+		manifest backup.Manifest
+		err      error
+	)
+	if *verifyOnly {
+		manifest, err = backup.VerifyFile(*archive, passphrase)
+	} else {
+		manifest, err = backup.Restore(*archive, passphrase, *dest)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "restore-backup: %v\n", err)
+		return 1
+	}
+
+	var total int64
+	for _, f := range manifest.Files {
+		total += f.Size
+	}
+	fmt.Fprintf(stdout, "backup created %s: %d files, %d bytes verified\n",
+		manifest.CreatedAt.Format("2006-01-02 15:04:05 MST"), len(manifest.Files), total)
+	if !*verifyOnly {
+		fmt.Fprintf(stdout, "extracted to %s (vault/, sleep.db, agent.db, agent-sessions/)\n", *dest)
+	}
+	return 0
+}
+
+func runRestoreVault(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("restore-vault", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	archive := fs.String("zip", "", "path to a vault backup ZIP (required)")
+	person := fs.String("person", "", "person whose vault is restored (required)")
+	apply := fs.Bool("apply", false, "apply the changes and commit them; default is a dry run")
+	deleteMissing := fs.Bool("delete-missing", false, "delete files that are not in the archive")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *archive == "" || *person == "" {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(stderr, "restore-vault: %v\n", err)
+		return 1
+	}
+	auth.SetValidPersons(cfg.ValidPersons)
+	if !auth.IsValidPerson(*person) {
+		fmt.Fprintf(stderr, "restore-vault: invalid person %q\n", *person)
+		return 1
+	}
+
+	zr, err := zip.OpenReader(*archive)
+	if err != nil {
+		fmt.Fprintf(stderr, "restore-vault: %v\n", err)
+		return 1
+	}
+	defer zr.Close()
+
+	store := vault.NewStore(cfg.NotesRoot)
+	plan, err := store.PlanRestore(*person, &zr.Reader, *deleteMissing)
+	if err != nil {
+		fmt.Fprintf(stderr, "restore-vault: %v\n", err)
+		return 1
+	}
+
+	for _, c := range plan.Changes {
+		fmt.Fprintf(stdout, "%-6s %s\n", c.Action, c.Path)
+	}
+	added, modified, deleted := plan.Counts()
+	fmt.Fprintf(stdout, "%d added, %d modified, %d deleted, %d unchanged\n", added, modified, deleted, plan.Unchanged)
+	if !*apply || len(plan.Changes) == 0 {
+		if len(plan.Changes) > 0 {
+			fmt.Fprintln(stdout, "dry run: re-run with -apply to restore")
+		}
+		return 0
+	}
+
+	if err := store.ApplyRestore(plan); err != nil {
+		fmt.Fprintf(stderr, "restore-vault: %v\n", err)
+		return 1
+	}
+	message := plan.CommitMessage(filepath.Base(*archive))
+	committed, err := vault.NewGit(cfg.NotesRoot).CommitPaths(message, plan.RootPaths())
+	if err != nil {
+		fmt.Fprintf(stderr, "restore-vault: files restored but commit failed: %v\n", err)
+		return 1
+	}
+	if committed {
+		fmt.Fprintf(stdout, "committed: %s\n", message)
+	}
+	return 0
+}
+
+func runImport(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "", "obsidian, markdown or keep (required)")
+	source := fs.String("source", "", "export ZIP or extracted folder (required)")
+	person := fs.String("person", "", "person whose vault receives the notes (required)")
+	target := fs.String("target", "", "vault folder for imported notes (default imports/<format>-<date>)")
+	noDaily := fs.Bool("no-daily", false, "do not map date-named notes into daily/")
+	apply := fs.Bool("apply", false, "write the files and commit them; default is a dry run")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *format == "" || *source == "" || *person == "" {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(stderr, "import: %v\n", err)
+		return 1
+	}
+	auth.SetValidPersons(cfg.ValidPersons)
+	if !auth.IsValidPerson(*person) {
+		fmt.Fprintf(stderr, "import: invalid person %q\n", *person)
+		return 1
+	}
+
+	info, err := os.Stat(*source)
+	if err != nil {
+		fmt.Fprintf(stderr, "import: %v\n", err)
+		return 1
+	}
+	var src iofs.FS
+	if info.IsDir() {
+		src = os.DirFS(*source)
+	} else {
+		zr, err := zip.OpenReader(*source)
+		if err != nil {
+			fmt.Fprintf(stderr, "import: %v\n", err)
+			return 1
+		}
+		defer zr.Close()
+		src = zr
+	}
+
+	store := vault.NewStore(cfg.NotesRoot)
+	plan, err := importer.Build(store, src, importer.Options{
+		Format:    *format,
+		Person:    *person,
+		TargetDir: *target,
+		SkipDaily: *noDaily,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "import: %v\n", err)
+		return 1
+	}
+
+	for _, f := range plan.Files {
+		fmt.Fprintf(stdout, "%-10s %s\n", f.Kind, f.Path)
+	}
+	for _, sk := range plan.Skipped {
+		fmt.Fprintf(stdout, "skipped    %s: %s\n", sk.Source, sk.Reason)
+	}
+	fmt.Fprintf(stdout, "%d notes, %d attachments, %d daily notes created, %d merged\n",
+		plan.Notes, plan.Attachments, plan.DailyCreated, plan.DailyMerged)
+	if !*apply {
+		fmt.Fprintln(stdout, "dry run: re-run with -apply to import")
+		return 0
+	}
+
+	if err := importer.Apply(store, plan); err != nil {
+		fmt.Fprintf(stderr, "import: %v\n", err)
+		return 1
+	}
+	message := plan.CommitMessage()
+	committed, err := vault.NewGit(cfg.NotesRoot).CommitPaths(message, plan.RootPaths())
+	if err != nil {
+		fmt.Fprintf(stderr, "import: files written but commit failed: %v\n", err)
+		return 1
+	}
+	if committed {
+		fmt.Fprintf(stdout, "committed: %s\n", message)
+	}
+	return 0
+}
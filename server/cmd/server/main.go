@@ -15,6 +15,11 @@ import (
 )
 
 func main() {
+	// Subcommands (e.g. restore-backup) run without starting the HTTP server.
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -25,13 +30,28 @@ func main() {
 	srv := api.NewServer(cfg)
 	router := api.NewRouter(srv)
 
-	// Create HTTP server
-	httpServer := &http.Server{
-		Addr:         cfg.ServerAddr,
-		Handler:      router,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 0, // Disable for streaming
-		IdleTimeout:  120 * time.Second,
+	tlsConfig, err := srv.TLSConfig()
+	if err != nil {
+		log.Fatalf("Failed to set up TLS: %v", err)
+	}
+
+	// Create HTTP server. With TLS it redirects to HTTPS instead.
+	httpServer := newHTTPServer(cfg.ServerAddr, router)
+	servers := []*http.Server{httpServer}
+	if tlsConfig != nil {
+		httpsServer := newHTTPServer(cfg.TLS.Addr, router)
+		httpsServer.TLSConfig = tlsConfig
+		servers = append(servers, httpsServer)
+		if cfg.TLS.RedirectHTTP {
+			httpServer.Handler = api.HTTPSRedirect(cfg.TLS.Addr, router)
+		}
+
+		go func() {
+			log.Printf("HTTPS server starting on %s (%s)", cfg.TLS.Addr, cfg.TLS.Mode)
+			if err := httpsServer.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("HTTPS server failed: %v", err)
+			}
+		}()
 	}
 
 	// Start server in goroutine
@@ -53,9 +73,21 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := httpServer.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Fatalf("Server forced to shutdown: %v", err)
+		}
 	}
 
 	log.Println("Server stopped")
 }
+
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      handler,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 0, // Disable for streaming
+		IdleTimeout:  120 * time.Second,
+	}
+}
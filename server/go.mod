@@ -10,6 +10,19 @@ require (
 )
 
 require (
+	github.com/go-webauthn/webauthn v0.9.4
 	github.com/mattn/go-sqlite3 v1.14.34
+	github.com/yuin/goldmark v1.7.8
+	golang.org/x/crypto v0.22.0
 	golang.org/x/net v0.24.0
+	golang.org/x/sys v0.19.0
+)
+
+require (
+	github.com/fxamacker/cbor/v2 v2.5.0 // indirect
+	github.com/go-webauthn/x v0.1.5 // indirect
+	github.com/golang-jwt/jwt/v5 v5.2.0 // indirect
+	github.com/google/go-tpm v0.9.0 // indirect
+	github.com/mitchellh/mapstructure v1.5.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
 )
@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"notes-editor/internal/vault"
 )
@@ -18,10 +19,31 @@ const (
 
 var multiDash = regexp.MustCompile(`-+`)
 
+// Values of the on_busy front matter key.
+const (
+	OnBusyQueue = "queue"
+	OnBusySkip  = "skip"
+)
+
 // ActionMetadata represents parsed metadata from action front matter.
 type ActionMetadata struct {
 	RequiresConfirmation bool `json:"requires_confirmation"`
 	MaxSteps             int  `json:"max_steps,omitempty"`
+	// Schedule is a cron expression for running the action automatically.
+	Schedule string `json:"schedule,omitempty"`
+	// Output is the vault file scheduled runs append their results to.
+	Output string `json:"output,omitempty"`
+	// OnBusy decides whether a scheduled run waits for a busy session
+	// ("queue", the default) or is skipped ("skip").
+	OnBusy string `json:"on_busy,omitempty"`
+	// Triggers are the event kinds that run the action.
+	Triggers []string `json:"triggers,omitempty"`
+	// Watch limits file_created triggers to these folders.
+	Watch []string `json:"watch,omitempty"`
+	// Debounce delays a triggered run until events stopped for this long.
+	Debounce string `json:"debounce,omitempty"`
+	// Parameters are the inputs the prompt template expects, in declaration order.
+	Parameters []ActionParameter `json:"parameters,omitempty"`
 }
 
 // Action represents one action file available to run.
@@ -149,7 +171,7 @@ func parseActionContent(content string) (ActionMetadata, string, error) {
 		}
 
 		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
+		value := unquoteFrontMatter(strings.TrimSpace(parts[1]))
 		switch key {
 		case "requires_confirmation":
 			parsed, err := strconv.ParseBool(value)
@@ -163,12 +185,86 @@ func parseActionContent(content string) (ActionMetadata, string, error) {
 				return meta, "", fmt.Errorf("invalid max_steps value")
 			}
 			meta.MaxSteps = parsed
+		case "schedule":
+			if _, err := ParseSchedule(value); err != nil {
+				return meta, "", fmt.Errorf("invalid schedule value: %w", err)
+			}
+			meta.Schedule = value
+		case "output":
+			if err := vault.ValidatePath(value); err != nil || strings.HasPrefix(value, ".") {
+				return meta, "", fmt.Errorf("invalid output value")
+			}
+			meta.Output = filepath.ToSlash(filepath.Clean(value))
+		case "on_busy":
+			if value != OnBusyQueue && value != OnBusySkip {
+				return meta, "", fmt.Errorf("invalid on_busy value")
+			}
+			meta.OnBusy = value
+		case "trigger":
+			for _, kind := range strings.Split(value, ",") {
+				kind = strings.TrimSpace(kind)
+				if !isEventKind(kind) {
+					return meta, "", fmt.Errorf("invalid trigger value %q", kind)
+				}
+				meta.Triggers = append(meta.Triggers, kind)
+			}
+		case "watch":
+			for _, folder := range strings.Split(value, ",") {
+				folder = strings.TrimSpace(folder)
+				if err := vault.ValidatePath(folder); err != nil {
+					return meta, "", fmt.Errorf("invalid watch value %q", folder)
+				}
+				meta.Watch = append(meta.Watch, filepath.ToSlash(filepath.Clean(folder)))
+			}
+		case "debounce":
+			if d, err := time.ParseDuration(value); err != nil || d < 0 {
+				return meta, "", fmt.Errorf("invalid debounce value")
+			}
+			meta.Debounce = value
+		default:
+			name, ok := strings.CutPrefix(key, paramFrontMatterKey)
+			if !ok {
+				continue
+			}
+			param, err := parseActionParameter(name, value)
+			if err != nil {
+				return meta, "", err
+			}
+			for _, existing := range meta.Parameters {
+				if existing.Name == name {
+					return meta, "", fmt.Errorf("duplicate parameter %q", name)
+				}
+			}
+			meta.Parameters = append(meta.Parameters, param)
+		}
+	}
+
+	if len(meta.Parameters) > maxActionParams {
+		return meta, "", fmt.Errorf("too many parameters (max %d)", maxActionParams)
+	}
+	// Scheduled and triggered runs have nobody to fill in the form or confirm.
+	if meta.Schedule != "" || len(meta.Triggers) > 0 {
+		if meta.RequiresConfirmation {
+			return meta, "", fmt.Errorf("requires_confirmation cannot be combined with schedule or trigger")
+		}
+		for _, param := range meta.Parameters {
+			if param.Required && param.Default == "" {
+				return meta, "", fmt.Errorf("parameter %q needs a default for scheduled or triggered runs", param.Name)
+			}
 		}
 	}
 
 	return meta, strings.TrimSpace(body), nil
 }
 
+// unquoteFrontMatter strips matching single or double quotes from a value.
+func unquoteFrontMatter(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
 func isActionFile(name string) bool {
 	return strings.HasSuffix(name, ".prompt.md") || strings.HasSuffix(name, ".md")
 }
@@ -75,3 +75,34 @@ Sensitive action`
 		t.Fatal("expected confirmation error")
 	}
 }
+
+func TestParseActionContentSchedule(t *testing.T) {
+	content := `---
+schedule: "0 7 * * 1-5"
+output: journal/briefings.md
+on_busy: skip
+---
+Morning briefing.`
+
+	meta, _, err := parseActionContent(content)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if meta.Schedule != "0 7 * * 1-5" || meta.Output != "journal/briefings.md" || meta.OnBusy != OnBusySkip {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+
+	meta, _, err = parseActionContent("---\ntrigger: file_created, capture\nwatch: inbox/, projects\ndebounce: 30s\n---\nFile it.")
+	if err != nil {
+		t.Fatalf("parse triggers failed: %v", err)
+	}
+	if len(meta.Triggers) != 2 || meta.Triggers[1] != EventCapture || len(meta.Watch) != 2 || meta.Watch[0] != "inbox" || meta.Debounce != "30s" {
+		t.Fatalf("unexpected trigger metadata: %+v", meta)
+	}
+
+	for _, line := range []string{"schedule: every morning", "output: ../elsewhere.md", "on_busy: wait", "trigger: file_deleted", "watch: ../x", "debounce: soon", "schedule: 0 7 * * *\nrequires_confirmation: true", "trigger: capture\nrequires_confirmation: true"} {
+		if _, _, err := parseActionContent("---\n" + line + "\n---\nx"); err == nil {
+			t.Errorf("expected error for %q", line)
+		}
+	}
+}
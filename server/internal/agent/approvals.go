@@ -0,0 +1,225 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"notes-editor/internal/claude"
+)
+
+// Tool policies decide what happens when the agent calls a tool.
+const (
+	// ToolPolicyAuto runs the tool immediately.
+	ToolPolicyAuto = "auto"
+	// ToolPolicyAsk pauses the run until the person approves or denies the call.
+	ToolPolicyAsk = "ask"
+	// ToolPolicyDeny rejects every call of the tool.
+	ToolPolicyDeny = "deny"
+
+	defaultApprovalTimeout = 5 * time.Minute
+)
+
+var (
+	// ErrToolDenied is returned for tool calls blocked by policy or by the person.
+	ErrToolDenied = errors.New("tool call denied")
+	// ErrApprovalNotFound is returned when no pending approval matches.
+	ErrApprovalNotFound = errors.New("approval not found")
+)
+
+type pendingApproval struct {
+	id       string
+	person   string
+	runID    string
+	decision chan bool
+}
+
+// AuthorizeToolCall applies the person's tool policy to one call. With the ask
+// policy it emits an approval_required event into the run's stream and waits
+// until the person decides, the approval times out or the run stops. A denial
+// or timeout aborts the run.
+func (s *Service) AuthorizeToolCall(person, runID, tool string, args map[string]any) error {
+	policy, err := s.toolPolicy(person, tool)
+	if err != nil {
+		return fmt.Errorf("%w: read tool policy: %v", ErrToolDenied, err)
+	}
+	switch policy {
+	case ToolPolicyAuto:
+		return nil
+	case ToolPolicyDeny:
+		return fmt.Errorf("%w: %s is disabled by the person's tool policy", ErrToolDenied, tool)
+	}
+
+	run, runID := s.runForApproval(person, runID)
+	if run == nil {
+		return fmt.Errorf("%w: %s needs approval, which requires a streaming chat", ErrToolDenied, tool)
+	}
+	if run.unattended {
+		return fmt.Errorf("%w: %s needs approval, which scheduled and triggered runs cannot get", ErrToolDenied, tool)
+	}
+
+	approval := &pendingApproval{
+		id:       uuid.New().String(),
+		person:   person,
+		runID:    runID,
+		decision: make(chan bool, 1),
+	}
+	s.mu.Lock()
+	s.approvals[approval.id] = approval
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.approvals, approval.id)
+		s.mu.Unlock()
+	}()
+
+	run.notify(StreamEvent{
+		Type:       "approval_required",
+		ApprovalID: approval.id,
+		Tool:       tool,
+		Args:       args,
+		Message:    fmt.Sprintf("Allow %s?", tool),
+	})
+
+	timer := time.NewTimer(s.settings().approvalTimeout)
+	defer timer.Stop()
+
+	resolved := func(ok bool, message string) {
+		run.notify(StreamEvent{
+			Type:       "approval_resolved",
+			ApprovalID: approval.id,
+			Tool:       tool,
+			OK:         ok,
+			Message:    message,
+		})
+	}
+	select {
+	case approved := <-approval.decision:
+		if approved {
+			resolved(true, "Approved")
+			return nil
+		}
+		resolved(false, "Denied")
+		run.stop(fmt.Sprintf("Tool call %s denied", tool))
+		return fmt.Errorf("%w: %s was denied", ErrToolDenied, tool)
+	case <-timer.C:
+		resolved(false, "Approval timed out")
+		run.stop(fmt.Sprintf("Approval for %s timed out", tool))
+		return fmt.Errorf("%w: approval for %s timed out", ErrToolDenied, tool)
+	case <-run.cancel:
+		return fmt.Errorf("%w: run stopped while waiting for approval", ErrToolDenied)
+	}
+}
+
+// ResolveApproval approves or denies a pending tool call.
+func (s *Service) ResolveApproval(person, approvalID string, approve bool) error {
+	s.mu.Lock()
+	approval, ok := s.approvals[approvalID]
+	if ok && approval.person == person {
+		// Only the first decision counts.
+		delete(s.approvals, approvalID)
+	}
+	s.mu.Unlock()
+	if !ok || approval.person != person {
+		return ErrApprovalNotFound
+	}
+	approval.decision <- approve
+	return nil
+}
+
+// runForApproval finds the run a tool call belongs to. The gateway sidecar does
+// not know run IDs, so an empty runID matches the person's only active run.
+func (s *Service) runForApproval(person, runID string) (*runControl, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if runID != "" {
+		run, ok := s.activeRuns[runID]
+		if !ok || run.person != person {
+			return nil, ""
+		}
+		return run, runID
+	}
+	var match *runControl
+	var matchID string
+	for id, run := range s.activeRuns {
+		if run.person != person {
+			continue
+		}
+		if match != nil {
+			return nil, ""
+		}
+		match, matchID = run, id
+	}
+	return match, matchID
+}
+
+func (s *Service) toolPolicy(person, tool string) (string, error) {
+	policies, err := s.toolPolicies.get(person)
+	if err != nil {
+		return "", err
+	}
+	if policy, ok := policies[tool]; ok {
+		return policy, nil
+	}
+	return ToolPolicyAuto, nil
+}
+
+// normalizeToolPolicies validates a policy update. Unknown tools and policies
+// are rejected; auto entries are dropped since auto is the default.
+func normalizeToolPolicies(policies map[string]string) (map[string]string, error) {
+	known := knownToolNames()
+	out := make(map[string]string, len(policies))
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		tool := strings.TrimSpace(name)
+		policy := strings.ToLower(strings.TrimSpace(policies[name]))
+		if !known[tool] {
+			return nil, fmt.Errorf("unknown tool %q", name)
+		}
+		switch policy {
+		case "", ToolPolicyAuto:
+		case ToolPolicyAsk, ToolPolicyDeny:
+			out[tool] = policy
+		default:
+			return nil, fmt.Errorf("invalid policy %q for %s", policies[name], tool)
+		}
+	}
+	return out, nil
+}
+
+func knownToolNames() map[string]bool {
+	known := make(map[string]bool, len(claude.ToolDefinitions))
+	for _, def := range claude.ToolDefinitions {
+		if name, ok := def["name"].(string); ok {
+			known[name] = true
+		}
+	}
+	return known
+}
+
+// notify injects a service-generated event into the run's stream.
+func (r *runControl) notify(event StreamEvent) {
+	select {
+	case r.notices <- event:
+	case <-r.cancel:
+	}
+}
+
+// stop cancels the run; reason becomes the run's terminal error message.
+func (r *runControl) stop(reason string) {
+	r.once.Do(func() {
+		r.stopReason = reason
+		close(r.cancel)
+		if r.streamCancel != nil {
+			r.streamCancel()
+		}
+	})
+}
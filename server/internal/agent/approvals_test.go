@@ -0,0 +1,187 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"notes-editor/internal/vault"
+)
+
+func newApprovalService(t *testing.T, timeout time.Duration) (*Service, chan StreamEvent) {
+	t.Helper()
+	upstream := make(chan StreamEvent)
+	svc := NewServiceWithRuntimesAndOptions(vault.NewStore(t.TempDir()), map[string]Runtime{
+		RuntimeModeAnthropicAPIKey: &stubRuntime{
+			mode:       RuntimeModeAnthropicAPIKey,
+			available:  true,
+			streamResp: &RuntimeStream{Events: upstream},
+		},
+		RuntimeModeGatewaySubscription: &stubRuntime{mode: RuntimeModeGatewaySubscription, available: false},
+	}, ServiceOptions{ApprovalTimeout: timeout, ToolPoliciesPath: filepath.Join(t.TempDir(), "agent-tool-policies.json")})
+	policies := map[string]string{"run_bash": "ask", "write_file": "deny"}
+	if _, err := svc.SaveConfig("sebastian", ConfigUpdate{ToolPolicies: policies}); err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+	return svc, upstream
+}
+
+func waitForEvent(t *testing.T, events <-chan StreamEvent, eventType string) StreamEvent {
+	t.Helper()
+	for event := range events {
+		if event.Type == eventType {
+			return event
+		}
+	}
+	t.Fatalf("stream ended before a %s event", eventType)
+	return StreamEvent{}
+}
+
+func TestToolPoliciesConfig(t *testing.T) {
+	svc, _ := newApprovalService(t, 0)
+	cfg, err := svc.GetConfig("sebastian")
+	if err != nil {
+		t.Fatalf("GetConfig() error = %v", err)
+	}
+	if len(cfg.ToolPolicies) != 2 || cfg.ToolPolicies["run_bash"] != ToolPolicyAsk || cfg.ToolPolicies["write_file"] != ToolPolicyDeny {
+		t.Fatalf("tool policies = %v", cfg.ToolPolicies)
+	}
+
+	for _, policies := range []map[string]string{
+		{"rm_rf": "ask"},
+		{"run_bash": "sometimes"},
+	} {
+		if _, err := svc.SaveConfig("sebastian", ConfigUpdate{ToolPolicies: policies}); err == nil {
+			t.Errorf("SaveConfig(%v) should fail", policies)
+		}
+	}
+
+	// Auto is the default and is not stored.
+	cfg, err = svc.SaveConfig("sebastian", ConfigUpdate{ToolPolicies: map[string]string{"run_bash": "auto"}})
+	if err != nil || len(cfg.ToolPolicies) != 0 {
+		t.Fatalf("SaveConfig(auto) = %v, %v", cfg, err)
+	}
+}
+
+func TestToolPoliciesStayOutOfTheVault(t *testing.T) {
+	svc, _ := newApprovalService(t, 0)
+	if content, err := svc.store.ReadFile("sebastian", internalConfigPath); err != nil || strings.Contains(content, "run_bash") {
+		t.Fatalf("vault config = %q, %v", content, err)
+	}
+	// The agent can write the vault config, but that does not change policies.
+	if err := svc.store.WriteFile("sebastian", internalConfigPath, `{"tool_policies": {}}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AuthorizeToolCall("sebastian", "", "write_file", nil); !errors.Is(err, ErrToolDenied) {
+		t.Fatalf("deny policy error = %v", err)
+	}
+}
+
+func TestAuthorizeToolCallPolicies(t *testing.T) {
+	svc, _ := newApprovalService(t, 0)
+	if err := svc.AuthorizeToolCall("sebastian", "", "read_file", nil); err != nil {
+		t.Fatalf("auto policy error = %v", err)
+	}
+	if err := svc.AuthorizeToolCall("sebastian", "", "write_file", nil); !errors.Is(err, ErrToolDenied) {
+		t.Fatalf("deny policy error = %v", err)
+	}
+	// Asking needs a streaming run to surface the question.
+	if err := svc.AuthorizeToolCall("sebastian", "missing-run", "run_bash", nil); !errors.Is(err, ErrToolDenied) {
+		t.Fatalf("ask without run error = %v", err)
+	}
+	if err := svc.AuthorizeToolCall("petra", "", "run_bash", nil); err != nil {
+		t.Fatalf("other person's policy error = %v", err)
+	}
+}
+
+func TestAuthorizeToolCallWaitsForApproval(t *testing.T) {
+	svc, upstream := newApprovalService(t, time.Minute)
+	run, err := svc.ChatStream(context.Background(), "sebastian", ChatRequest{SessionID: "s-ask", Message: "clean up"})
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- svc.AuthorizeToolCall("sebastian", run.RunID, "run_bash", map[string]any{"command": "ls"})
+	}()
+
+	required := waitForEvent(t, run.Events, "approval_required")
+	if required.ApprovalID == "" || required.Tool != "run_bash" || required.Args["command"] != "ls" {
+		t.Fatalf("approval_required = %+v", required)
+	}
+	// A settings reload keeps the pending approval.
+	svc.Reconfigure(nil, nil, "", ServiceOptions{ApprovalTimeout: time.Minute})
+	if err := svc.ResolveApproval("petra", required.ApprovalID, true); !errors.Is(err, ErrApprovalNotFound) {
+		t.Fatalf("ResolveApproval(other person) error = %v", err)
+	}
+	if err := svc.ResolveApproval("sebastian", required.ApprovalID, true); err != nil {
+		t.Fatalf("ResolveApproval() error = %v", err)
+	}
+	if err := <-result; err != nil {
+		t.Fatalf("AuthorizeToolCall() error = %v", err)
+	}
+	if resolved := waitForEvent(t, run.Events, "approval_resolved"); !resolved.OK {
+		t.Fatalf("approval_resolved = %+v", resolved)
+	}
+	if err := svc.ResolveApproval("sebastian", required.ApprovalID, false); !errors.Is(err, ErrApprovalNotFound) {
+		t.Fatalf("second ResolveApproval() error = %v", err)
+	}
+
+	upstream <- StreamEvent{Type: "text", Delta: "Done."}
+	close(upstream)
+	waitForEvent(t, run.Events, "done")
+}
+
+func TestAuthorizeToolCallDeniesUnattendedRuns(t *testing.T) {
+	svc, upstream := newApprovalService(t, time.Minute)
+	run, err := svc.ChatStream(context.Background(), "sebastian", ChatRequest{SessionID: "s-scheduled", Message: "clean up", Unattended: true})
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	if err := svc.AuthorizeToolCall("sebastian", run.RunID, "run_bash", nil); !errors.Is(err, ErrToolDenied) {
+		t.Fatalf("ask in unattended run error = %v", err)
+	}
+	if err := svc.AuthorizeToolCall("sebastian", run.RunID, "read_file", nil); err != nil {
+		t.Fatalf("auto in unattended run error = %v", err)
+	}
+	close(upstream)
+	waitForEvent(t, run.Events, "done")
+}
+
+func TestAuthorizeToolCallDenialAbortsRun(t *testing.T) {
+	for name, timeout := range map[string]time.Duration{"denied": time.Minute, "timed out": 20 * time.Millisecond} {
+		t.Run(name, func(t *testing.T) {
+			svc, _ := newApprovalService(t, timeout)
+			run, err := svc.ChatStream(context.Background(), "sebastian", ChatRequest{SessionID: "s-deny", Message: "clean up"})
+			if err != nil {
+				t.Fatalf("ChatStream() error = %v", err)
+			}
+
+			// The gateway sidecar calls tools without a run ID.
+			result := make(chan error, 1)
+			go func() {
+				result <- svc.AuthorizeToolCall("sebastian", "", "run_bash", map[string]any{"command": "rm -rf /"})
+			}()
+			required := waitForEvent(t, run.Events, "approval_required")
+			if timeout == time.Minute {
+				if err := svc.ResolveApproval("sebastian", required.ApprovalID, false); err != nil {
+					t.Fatalf("ResolveApproval() error = %v", err)
+				}
+			}
+			if err := <-result; !errors.Is(err, ErrToolDenied) {
+				t.Fatalf("AuthorizeToolCall() error = %v", err)
+			}
+			if resolved := waitForEvent(t, run.Events, "approval_resolved"); resolved.OK {
+				t.Fatalf("approval_resolved = %+v", resolved)
+			}
+			if failure := waitForEvent(t, run.Events, "error"); failure.Message == "" || failure.Message == "Run cancelled" {
+				t.Fatalf("terminal error = %+v", failure)
+			}
+			waitForEvent(t, run.Events, "done")
+		})
+	}
+}
@@ -42,7 +42,7 @@ func newTestService(t *testing.T, rt *multiStreamRuntime) *Service {
 		RuntimeModeAnthropicAPIKey:     rt,
 		RuntimeModeGatewaySubscription: &stubRuntime{mode: RuntimeModeGatewaySubscription, available: false},
 	})
-	svc.maxRunDuration = 30 * time.Second
+	svc.settings().maxRunDuration = 30 * time.Second
 	return svc
 }
 
@@ -22,12 +22,16 @@ type Config struct {
 	PromptPath  string `json:"prompt_path"`
 	ActionsPath string `json:"actions_path"`
 	Prompt      string `json:"prompt"`
+	// ToolPolicies maps tool names to ask or deny; unlisted tools run automatically.
+	ToolPolicies map[string]string `json:"tool_policies"`
 }
 
 // ConfigUpdate is the mutable subset of agent config.
 type ConfigUpdate struct {
 	RuntimeMode *string `json:"runtime_mode,omitempty"`
 	Prompt      *string `json:"prompt,omitempty"`
+	// ToolPolicies replaces all tool policies when set.
+	ToolPolicies map[string]string `json:"tool_policies,omitempty"`
 }
 
 type persistedConfig struct {
@@ -56,11 +60,16 @@ func (s *Service) getConfig(person string) (*Config, error) {
 		}
 	}
 
+	policies, err := s.toolPolicies.get(person)
+	if err != nil {
+		return nil, err
+	}
 	return &Config{
-		RuntimeMode: pc.RuntimeMode,
-		PromptPath:  defaultPromptPath,
-		ActionsPath: defaultActionsPath,
-		Prompt:      prompt,
+		RuntimeMode:  pc.RuntimeMode,
+		PromptPath:   defaultPromptPath,
+		ActionsPath:  defaultActionsPath,
+		Prompt:       prompt,
+		ToolPolicies: policies,
 	}, nil
 }
 
@@ -77,6 +86,12 @@ func (s *Service) saveConfig(person string, update ConfigUpdate) (*Config, error
 		}
 		pc.RuntimeMode = mode
 	}
+	var policies map[string]string
+	if update.ToolPolicies != nil {
+		if policies, err = normalizeToolPolicies(update.ToolPolicies); err != nil {
+			return nil, err
+		}
+	}
 	data, err := json.MarshalIndent(pc, "", "  ")
 	if err != nil {
 		return nil, err
@@ -84,6 +99,11 @@ func (s *Service) saveConfig(person string, update ConfigUpdate) (*Config, error
 	if err := s.store.WriteFile(person, internalConfigPath, string(data)); err != nil {
 		return nil, err
 	}
+	if policies != nil {
+		if err := s.toolPolicies.set(person, policies); err != nil {
+			return nil, err
+		}
+	}
 
 	if update.Prompt != nil {
 		if err := s.store.WriteFile(person, defaultPromptPath, *update.Prompt); err != nil {
@@ -15,16 +15,17 @@ type UsageSnapshot struct {
 
 // StreamEvent is the canonical v2 NDJSON event schema for agent streaming.
 type StreamEvent struct {
-	Type      string         `json:"type"`
-	SessionID string         `json:"session_id,omitempty"`
-	RunID     string         `json:"run_id,omitempty"`
-	Seq       int            `json:"seq,omitempty"`
-	TS        time.Time      `json:"ts,omitempty"`
-	Delta     string         `json:"delta,omitempty"`
-	Tool      string         `json:"tool,omitempty"`
-	Args      map[string]any `json:"args,omitempty"`
-	OK        bool           `json:"ok,omitempty"`
-	Summary   string         `json:"summary,omitempty"`
-	Message   string         `json:"message,omitempty"`
-	Usage     *UsageSnapshot `json:"usage,omitempty"`
+	Type       string         `json:"type"`
+	SessionID  string         `json:"session_id,omitempty"`
+	RunID      string         `json:"run_id,omitempty"`
+	ApprovalID string         `json:"approval_id,omitempty"`
+	Seq        int            `json:"seq,omitempty"`
+	TS         time.Time      `json:"ts,omitempty"`
+	Delta      string         `json:"delta,omitempty"`
+	Tool       string         `json:"tool,omitempty"`
+	Args       map[string]any `json:"args,omitempty"`
+	OK         bool           `json:"ok,omitempty"`
+	Summary    string         `json:"summary,omitempty"`
+	Message    string         `json:"message,omitempty"`
+	Usage      *UsageSnapshot `json:"usage,omitempty"`
 }
@@ -0,0 +1,187 @@
+package agent
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"notes-editor/internal/vault"
+)
+
+// Parameter types an action can declare.
+const (
+	ParamString = "string"
+	ParamEnum   = "enum"
+	ParamDate   = "date"
+	ParamFile   = "file"
+)
+
+const (
+	maxActionParams     = 20
+	maxParamValueBytes  = 4 * 1024
+	paramDateLayout     = "2006-01-02"
+	paramDefaultToday   = "today"
+	paramFrontMatterKey = "param."
+)
+
+var (
+	paramNamePattern  = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	paramEnumPattern  = regexp.MustCompile(`^enum\((.*)\)$`)
+	paramPlaceholders = regexp.MustCompile(`\{\{\s*([a-z][a-z0-9_]*)\s*\}\}`)
+)
+
+// ActionParameter is one input of a parameterized action. Clients render the
+// parameters as a form; values replace {{name}} placeholders in the prompt.
+type ActionParameter struct {
+	Name     string   `json:"name"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Options  []string `json:"options,omitempty"`
+	Default  string   `json:"default,omitempty"`
+}
+
+// parseActionParameter parses a "param.<name>: <type>[?] [= <default>]" line.
+// Types are string, date, file and enum(a, b, c); a trailing ? makes the
+// parameter optional. Date defaults may be "today".
+func parseActionParameter(name, value string) (ActionParameter, error) {
+	param := ActionParameter{Name: name, Label: paramLabel(name), Required: true}
+	if !paramNamePattern.MatchString(name) {
+		return param, fmt.Errorf("invalid parameter name %q", name)
+	}
+
+	spec, def, hasDefault := strings.Cut(value, "=")
+	spec = strings.TrimSpace(spec)
+	if strings.HasSuffix(spec, "?") {
+		param.Required = false
+		spec = strings.TrimSpace(strings.TrimSuffix(spec, "?"))
+	}
+	if m := paramEnumPattern.FindStringSubmatch(spec); m != nil {
+		param.Type = ParamEnum
+		for _, option := range strings.Split(m[1], ",") {
+			if option = strings.TrimSpace(option); option != "" {
+				param.Options = append(param.Options, option)
+			}
+		}
+		if len(param.Options) == 0 {
+			return param, fmt.Errorf("parameter %q: enum needs options", name)
+		}
+	} else {
+		switch spec {
+		case ParamString, ParamDate, ParamFile:
+			param.Type = spec
+		default:
+			return param, fmt.Errorf("parameter %q: invalid type %q", name, spec)
+		}
+	}
+
+	if hasDefault {
+		param.Default = unquoteFrontMatter(strings.TrimSpace(def))
+		if param.Type == ParamDate && param.Default == paramDefaultToday {
+			return param, nil
+		}
+		if _, err := normalizeParamValue(param, param.Default); err != nil {
+			return param, fmt.Errorf("parameter %q: invalid default: %w", name, err)
+		}
+	}
+	return param, nil
+}
+
+// paramLabel turns a parameter name into a form label ("due_date" -> "Due date").
+func paramLabel(name string) string {
+	label := strings.ReplaceAll(name, "_", " ")
+	if label == "" {
+		return label
+	}
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+// normalizeParamValue checks a value against the parameter type without
+// touching the vault.
+func normalizeParamValue(param ActionParameter, value string) (string, error) {
+	if len(value) > maxParamValueBytes {
+		return "", fmt.Errorf("value exceeds %d bytes", maxParamValueBytes)
+	}
+	switch param.Type {
+	case ParamEnum:
+		for _, option := range param.Options {
+			if value == option {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("must be one of %s", strings.Join(param.Options, ", "))
+	case ParamDate:
+		if _, err := time.Parse(paramDateLayout, value); err != nil {
+			return "", fmt.Errorf("must be a date (YYYY-MM-DD)")
+		}
+	case ParamFile:
+		if err := vault.ValidatePath(value); err != nil {
+			return "", fmt.Errorf("invalid file path")
+		}
+		return filepath.ToSlash(filepath.Clean(value)), nil
+	}
+	return value, nil
+}
+
+// bindParameters validates the submitted values against the action's
+// parameters and fills in defaults. File parameters must name an existing file
+// the agent may read.
+func (s *Service) bindParameters(person string, params []ActionParameter, values map[string]string, now time.Time) (map[string]string, error) {
+	declared := make(map[string]bool, len(params))
+	for _, param := range params {
+		declared[param.Name] = true
+	}
+	for name := range values {
+		if !declared[name] {
+			return nil, fmt.Errorf("unknown parameter %q", name)
+		}
+	}
+
+	bound := make(map[string]string, len(params))
+	for _, param := range params {
+		value := strings.TrimSpace(values[param.Name])
+		if value == "" {
+			value = param.Default
+			if param.Type == ParamDate && value == paramDefaultToday {
+				value = now.Format(paramDateLayout)
+			}
+		}
+		if value == "" {
+			if param.Required {
+				return nil, fmt.Errorf("parameter %q is required", param.Name)
+			}
+			bound[param.Name] = ""
+			continue
+		}
+
+		normalized, err := normalizeParamValue(param, value)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %q: %w", param.Name, err)
+		}
+		if param.Type == ParamFile {
+			if err := s.store.AgentCanAccess(person, normalized); err != nil {
+				return nil, fmt.Errorf("parameter %q: %w", param.Name, err)
+			}
+			exists, err := s.store.FileExists(person, normalized)
+			if err != nil || !exists {
+				return nil, fmt.Errorf("parameter %q: file not found", param.Name)
+			}
+		}
+		bound[param.Name] = normalized
+	}
+	return bound, nil
+}
+
+// renderPrompt replaces {{name}} placeholders of bound parameters. Other
+// placeholders are left alone.
+func renderPrompt(prompt string, values map[string]string) string {
+	return paramPlaceholders.ReplaceAllStringFunc(prompt, func(match string) string {
+		name := paramPlaceholders.FindStringSubmatch(match)[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return match
+	})
+}
@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"notes-editor/internal/vault"
+)
+
+func TestParseActionContentParameters(t *testing.T) {
+	content := `---
+param.project: enum(work, private) = work
+param.since: date? = today
+param.note: file
+param.focus: string?
+---
+Review {{project}} since {{ since }} using {{note}}.`
+
+	meta, _, err := parseActionContent(content)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(meta.Parameters) != 4 {
+		t.Fatalf("parameters = %+v", meta.Parameters)
+	}
+	project := meta.Parameters[0]
+	if project.Type != ParamEnum || len(project.Options) != 2 || project.Default != "work" || !project.Required || project.Label != "Project" {
+		t.Errorf("project = %+v", project)
+	}
+	if since := meta.Parameters[1]; since.Type != ParamDate || since.Required || since.Default != "today" {
+		t.Errorf("since = %+v", since)
+	}
+	if note := meta.Parameters[2]; note.Type != ParamFile || !note.Required {
+		t.Errorf("note = %+v", note)
+	}
+
+	for _, frontMatter := range []string{
+		"param.Project: string",
+		"param.x: number",
+		"param.x: enum()",
+		"param.x: enum(a, b) = c",
+		"param.x: date = tomorrow",
+		"param.x: file = ../secret.md",
+		"param.x: string\nparam.x: date",
+		"schedule: \"@daily\"\nparam.x: string",
+	} {
+		if _, _, err := parseActionContent("---\n" + frontMatter + "\n---\nx"); err == nil {
+			t.Errorf("expected error for %q", frontMatter)
+		}
+	}
+	if _, _, err := parseActionContent("---\nschedule: \"@daily\"\nparam.x: string = all\n---\nx"); err != nil {
+		t.Errorf("scheduled action with defaults: %v", err)
+	}
+}
+
+func TestResolveMessageRendersParameters(t *testing.T) {
+	root := t.TempDir()
+	person := "sebastian"
+	actionsDir := filepath.Join(root, person, "agent", "actions")
+	if err := os.MkdirAll(filepath.Join(root, person, "projects"), 0755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	if err := os.MkdirAll(actionsDir, 0755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, person, "projects", "roof.md"), []byte("# Roof"), 0644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	action := `---
+param.project: enum(work, private) = work
+param.since: date? = today
+param.note: file
+param.focus: string?
+---
+Review {{project}} since {{ since }} using {{note}}. Focus: {{focus}}. Keep {{literal}}.`
+	if err := os.WriteFile(filepath.Join(actionsDir, "Review.prompt.md"), []byte(action), 0644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	svc := NewService(nil, vault.NewStore(root))
+
+	resolved, err := svc.resolveMessage(person, ChatRequest{ActionID: "review", Params: map[string]string{
+		"project": "private",
+		"note":    "./projects/roof.md",
+	}})
+	if err != nil {
+		t.Fatalf("resolveMessage() error = %v", err)
+	}
+	today := time.Now().Format("2006-01-02")
+	want := "Review private since " + today + " using projects/roof.md. Focus: . Keep {{literal}}."
+	if resolved.Text != want {
+		t.Fatalf("prompt = %q, want %q", resolved.Text, want)
+	}
+
+	for _, params := range []map[string]string{
+		{},
+		{"note": "projects/missing.md"},
+		{"note": "projects/roof.md", "project": "hobby"},
+		{"note": "projects/roof.md", "since": "19.10.2026"},
+		{"note": "projects/roof.md", "extra": "x"},
+	} {
+		if _, err := svc.resolveMessage(person, ChatRequest{ActionID: "review", Params: params}); err == nil {
+			t.Errorf("expected error for %v", params)
+		} else if !strings.Contains(err.Error(), "parameter") {
+			t.Errorf("error for %v = %v", params, err)
+		}
+	}
+}
@@ -25,6 +25,11 @@ type piSessionContentPart struct {
 	Text string `json:"text,omitempty"`
 }
 
+// GatewaySessionDir returns the directory where the pi gateway stores session files.
+func GatewaySessionDir() string {
+	return gatewaySessionDir()
+}
+
 func gatewaySessionDir() string {
 	sessionDir := strings.TrimSpace(os.Getenv("PI_GATEWAY_PI_SESSION_DIR"))
 	if sessionDir != "" {
@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRunEventBacklog bounds the events kept per run for reattaching clients.
+	maxRunEventBacklog = 5000
+	// finishedRunRetention keeps a finished run's events around so a client that
+	// reconnects after the run ended still receives the final events.
+	finishedRunRetention = 5 * time.Minute
+)
+
+var (
+	// ErrRunNotFound is returned when a run does not exist, belongs to another
+	// person or finished too long ago to reattach.
+	ErrRunNotFound = errors.New("run not found")
+	// ErrRunEventsExpired is returned when the requested events were dropped from
+	// the run's backlog.
+	ErrRunEventsExpired = errors.New("run events no longer available")
+)
+
+// runEventLog buffers a run's stream events so clients can reattach.
+type runEventLog struct {
+	mu      sync.Mutex
+	events  []StreamEvent
+	dropped int // seq of the newest event dropped from the backlog
+	closed  bool
+	wake    chan struct{}
+}
+
+func newRunEventLog() *runEventLog {
+	return &runEventLog{wake: make(chan struct{})}
+}
+
+func (l *runEventLog) append(event StreamEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+	if over := len(l.events) - maxRunEventBacklog; over > 0 {
+		l.dropped = l.events[over-1].Seq
+		l.events = append([]StreamEvent(nil), l.events[over:]...)
+	}
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+func (l *runEventLog) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	l.closed = true
+	close(l.wake)
+}
+
+func (l *runEventLog) lastSeq() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.events) == 0 {
+		return l.dropped
+	}
+	return l.events[len(l.events)-1].Seq
+}
+
+// since returns the buffered events after afterSeq, whether the run has ended
+// and a channel that is closed when more events arrive.
+func (l *runEventLog) since(afterSeq int) ([]StreamEvent, bool, <-chan struct{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if afterSeq < l.dropped {
+		return nil, false, nil, fmt.Errorf("%w: events up to seq %d were dropped", ErrRunEventsExpired, l.dropped)
+	}
+	start := len(l.events)
+	for i, event := range l.events {
+		if event.Seq > afterSeq {
+			start = i
+			break
+		}
+	}
+	events := append([]StreamEvent(nil), l.events[start:]...)
+	return events, l.closed, l.wake, nil
+}
+
+// AttachRun streams a run's events after afterSeq, followed by live events until
+// the run ends or ctx is cancelled. It works for active runs and for runs that
+// finished within the last few minutes.
+func (s *Service) AttachRun(ctx context.Context, person, runID string, afterSeq int) (*StreamRun, error) {
+	s.mu.Lock()
+	s.pruneFinishedRunsLocked(time.Now().UTC())
+	run, ok := s.activeRuns[runID]
+	if !ok {
+		run, ok = s.finishedRuns[runID]
+	}
+	s.mu.Unlock()
+	if !ok || run.person != person {
+		return nil, ErrRunNotFound
+	}
+	if _, _, _, err := run.events.since(afterSeq); err != nil {
+		return nil, err
+	}
+
+	out := make(chan StreamEvent, 100)
+	go func() {
+		defer close(out)
+		next := afterSeq
+		for {
+			events, closed, wake, err := run.events.since(next)
+			if err != nil {
+				// The reader fell further behind than the backlog holds.
+				select {
+				case out <- StreamEvent{Type: "error", RunID: runID, Message: err.Error(), TS: time.Now().UTC()}:
+				case <-ctx.Done():
+				}
+				return
+			}
+			for _, event := range events {
+				select {
+				case out <- event:
+					next = event.Seq
+				case <-ctx.Done():
+					return
+				}
+			}
+			if len(events) > 0 {
+				continue
+			}
+			if closed {
+				return
+			}
+			select {
+			case <-wake:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return &StreamRun{
+		RunID:  runID,
+		Events: out,
+	}, nil
+}
+
+func (s *Service) pruneFinishedRunsLocked(now time.Time) {
+	for runID, run := range s.finishedRuns {
+		if now.Sub(run.finishedAt) > finishedRunRetention {
+			delete(s.finishedRuns, runID)
+		}
+	}
+}
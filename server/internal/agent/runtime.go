@@ -21,6 +21,8 @@ type RuntimeChatRequest struct {
 	Message   string
 	// MaxToolCalls is an optional runtime hint (0 means runtime default).
 	MaxToolCalls int
+	// RunID identifies the agent run for tool call auditing.
+	RunID string
 }
 
 // RuntimeChatResponse is the normalized non-stream chat response from runtimes.
@@ -38,6 +38,7 @@ func (r *AnthropicRuntime) Chat(person string, req RuntimeChatRequest) (*Runtime
 	resp, err := r.claude.Chat(person, claude.ChatRequest{
 		SessionID: req.SessionID,
 		Message:   req.Message,
+		RunID:     req.RunID,
 	})
 	if err != nil {
 		return nil, err
@@ -61,6 +62,7 @@ func (r *AnthropicRuntime) ChatStream(_ context.Context, person string, req Runt
 	upstream, err := r.claude.ChatStream(person, claude.ChatRequest{
 		SessionID: req.SessionID,
 		Message:   req.Message,
+		RunID:     req.RunID,
 	})
 	if err != nil {
 		return nil, err
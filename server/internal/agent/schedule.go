@@ -0,0 +1,177 @@
+package agent
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed five-field cron expression: minute, hour, day of month,
+// month and day of week. Fields accept *, lists, ranges and steps (e.g.
+// "*/15", "1-5", "0,30"); day of week also accepts names (mon-fri). The
+// macros @hourly, @daily, @weekly and @monthly are supported.
+type Schedule struct {
+	spec    string
+	minutes uint64
+	hours   uint64
+	days    uint64
+	months  uint64
+	weekday uint64
+	// daysStar and weekdayStar record unrestricted day fields.
+	daysStar    bool
+	weekdayStar bool
+}
+
+var scheduleMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+var weekdayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// ParseSchedule parses a cron expression.
+func ParseSchedule(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	expr := spec
+	if macro, ok := scheduleMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q: expected 5 fields", spec)
+	}
+
+	s := &Schedule{spec: spec}
+	var err error
+	if s.minutes, _, err = parseScheduleField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("schedule %q: minute: %w", spec, err)
+	}
+	if s.hours, _, err = parseScheduleField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("schedule %q: hour: %w", spec, err)
+	}
+	if s.days, s.daysStar, err = parseScheduleField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("schedule %q: day of month: %w", spec, err)
+	}
+	if s.months, _, err = parseScheduleField(fields[3], 1, 12, nil); err != nil {
+		return nil, fmt.Errorf("schedule %q: month: %w", spec, err)
+	}
+	if s.weekday, s.weekdayStar, err = parseScheduleField(fields[4], 0, 7, weekdayNames); err != nil {
+		return nil, fmt.Errorf("schedule %q: day of week: %w", spec, err)
+	}
+	// 7 is Sunday, too.
+	if s.weekday&(1<<7) != 0 {
+		s.weekday |= 1
+	}
+	return s, nil
+}
+
+// String returns the expression the schedule was parsed from.
+func (s *Schedule) String() string {
+	return s.spec
+}
+
+// Matches reports whether the schedule fires in the minute of t.
+func (s *Schedule) Matches(t time.Time) bool {
+	return s.minutes&(1<<uint(t.Minute())) != 0 && s.hours&(1<<uint(t.Hour())) != 0 &&
+		s.months&(1<<uint(t.Month())) != 0 && s.dayMatches(t)
+}
+
+// Next returns the first minute after t in which the schedule fires, or the
+// zero time if there is none within five years (e.g. "0 0 31 2 *").
+func (s *Schedule) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := next.AddDate(5, 0, 0)
+	for next.Before(limit) {
+		y, m, d := next.Date()
+		loc := next.Location()
+		switch {
+		case s.months&(1<<uint(m)) == 0:
+			next = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(next):
+			next = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case s.hours&(1<<uint(next.Hour())) == 0:
+			next = time.Date(y, m, d, next.Hour()+1, 0, 0, 0, loc)
+		case s.minutes&(1<<uint(next.Minute())) == 0:
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches reports whether the day of t is selected. Like cron, a restricted
+// day of month and day of week match if either does.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dayMatch := s.days&(1<<uint(t.Day())) != 0
+	weekdayMatch := s.weekday&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.daysStar && s.weekdayStar:
+		return true
+	case s.daysStar:
+		return weekdayMatch
+	case s.weekdayStar:
+		return dayMatch
+	default:
+		return dayMatch || weekdayMatch
+	}
+}
+
+// parseScheduleField parses one cron field into a bit set. star reports an
+// unrestricted field ("*" or "*/1").
+func parseScheduleField(field string, min, max int, names map[string]int) (bits uint64, star bool, err error) {
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, false, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		lo, hi := min, max
+		switch {
+		case rangePart == "*":
+			star = star || step == 1
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			if lo, err = parseScheduleValue(from, min, max, names); err != nil {
+				return 0, false, err
+			}
+			if hi, err = parseScheduleValue(to, min, max, names); err != nil {
+				return 0, false, err
+			}
+			if lo > hi {
+				return 0, false, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			if lo, err = parseScheduleValue(rangePart, min, max, names); err != nil {
+				return 0, false, err
+			}
+			if !hasStep {
+				hi = lo
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, star, nil
+}
+
+func parseScheduleValue(value string, min, max int, names map[string]int) (int, error) {
+	if n, ok := names[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("value %q out of range %d-%d", value, min, max)
+	}
+	return n, nil
+}
@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	for _, spec := range []string{"0 7 * * 1-5", "*/15 * * * *", "30 18 * * sun", "0 0 1,15 * *", "@daily", "0 9 * * mon-fri"} {
+		if _, err := ParseSchedule(spec); err != nil {
+			t.Errorf("ParseSchedule(%q) error = %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "0 24 * * *", "0 0 0 * *", "0 0 * 13 *", "*/0 * * * *", "5-1 * * * *", "0 0 * * funday"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) should fail", spec)
+		}
+	}
+}
+
+func TestScheduleMatchesAndNext(t *testing.T) {
+	weekdays, err := ParseSchedule("0 7 * * 1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2026-10-16 is a Friday.
+	friday := time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC)
+	if !weekdays.Matches(friday) {
+		t.Error("expected Friday 07:00 to match")
+	}
+	if weekdays.Matches(friday.Add(time.Minute)) || weekdays.Matches(friday.AddDate(0, 0, 1)) {
+		t.Error("expected 07:01 and Saturday not to match")
+	}
+	if next := weekdays.Next(friday); !next.Equal(time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("Next(Friday) = %v, want Monday 07:00", next)
+	}
+
+	sunday, _ := ParseSchedule("30 18 * * 7")
+	if next := sunday.Next(friday); !next.Equal(time.Date(2026, 10, 18, 18, 30, 0, 0, time.UTC)) {
+		t.Errorf("Next(Sunday) = %v", next)
+	}
+
+	// A restricted day of month and day of week match if either does.
+	either, _ := ParseSchedule("0 0 1 * mon")
+	if !either.Matches(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)) || !either.Matches(time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)) {
+		t.Error("expected the 1st and Mondays to match")
+	}
+
+	never, _ := ParseSchedule("0 0 31 2 *")
+	if next := never.Next(friday); !next.IsZero() {
+		t.Errorf("Next(Feb 31) = %v, want zero", next)
+	}
+}
@@ -0,0 +1,531 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// scheduleStatePath holds a person's scheduled session and run history.
+	scheduleStatePath = "agent/schedule.json"
+	// defaultScheduleOutputDir receives results of actions without an output file.
+	defaultScheduleOutputDir = "agent/scheduled"
+	maxScheduledRunHistory   = 200
+	// maxQueuedRunAge drops queued runs whose session stayed busy this long.
+	maxQueuedRunAge = 6 * time.Hour
+)
+
+// Scheduled run statuses.
+const (
+	ScheduledRunOK      = "ok"
+	ScheduledRunError   = "error"
+	ScheduledRunSkipped = "skipped"
+)
+
+// ScheduledRun records one scheduled action run.
+type ScheduledRun struct {
+	ActionID    string     `json:"action_id"`
+	Label       string     `json:"label"`
+	ScheduledAt time.Time  `json:"scheduled_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  time.Time  `json:"finished_at"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	Output      string     `json:"output,omitempty"`
+	RunID       string     `json:"run_id,omitempty"`
+	SessionID   string     `json:"session_id,omitempty"`
+	// Trigger is the event kind of a triggered run, with the files it named.
+	Trigger string   `json:"trigger,omitempty"`
+	Paths   []string `json:"paths,omitempty"`
+}
+
+// ScheduledAction is an action with a schedule or triggers. NextRunAt is set
+// for schedules; Queued also covers triggered runs waiting for their debounce.
+type ScheduledAction struct {
+	Action
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+	Queued    bool       `json:"queued"`
+}
+
+// ScheduleStatus lists a person's scheduled actions and recent runs.
+type ScheduleStatus struct {
+	SessionID string            `json:"session_id,omitempty"`
+	Actions   []ScheduledAction `json:"actions"`
+	Runs      []ScheduledRun    `json:"runs"`
+}
+
+type scheduleState struct {
+	SessionID string         `json:"session_id,omitempty"`
+	Runs      []ScheduledRun `json:"runs"`
+}
+
+type queuedRun struct {
+	action      Action
+	scheduledAt time.Time
+	// trigger is set for runs caused by events rather than the schedule.
+	trigger *triggerEvent
+	// started is set while the worker runs it; its events are then fixed.
+	started bool
+}
+
+// SchedulerOptions wires a Scheduler to the server.
+type SchedulerOptions struct {
+	// Service returns the current agent service; it changes when settings reload.
+	Service func() *Service
+	// Persons returns the persons whose actions are scheduled.
+	Persons func() []string
+	// AfterRun is called after a run changed the person's vault. Optional.
+	AfterRun func(person string)
+	// VaultLock is held for writing while run output and history are written.
+	// Optional.
+	VaultLock *sync.RWMutex
+}
+
+// Scheduler runs actions whose front matter has a schedule or triggers. Each
+// person's actions run one at a time in a dedicated agent session.
+type Scheduler struct {
+	opts SchedulerOptions
+
+	mu       sync.Mutex
+	started  bool
+	stopping chan struct{}
+	lastTick time.Time
+	queues   map[string][]queuedRun
+	running  map[string]bool
+	// current is the ID of the action each person's worker is running.
+	current map[string]string
+	// runs maps the IDs of agent runs in progress to their action IDs.
+	runs    map[string]string
+	pending map[string]*pendingTrigger
+
+	now func() time.Time
+}
+
+// NewScheduler creates a scheduler. Call Start to run it.
+func NewScheduler(opts SchedulerOptions) *Scheduler {
+	return &Scheduler{
+		opts:     opts,
+		stopping: make(chan struct{}),
+		queues:   make(map[string][]queuedRun),
+		running:  make(map[string]bool),
+		current:  make(map[string]string),
+		runs:     make(map[string]string),
+		pending:  make(map[string]*pendingTrigger),
+		now:      time.Now,
+	}
+}
+
+// Start launches the scheduling loop. Runs missed while the server was down are
+// not caught up.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	s.lastTick = s.now().Truncate(time.Minute)
+	go s.loop()
+}
+
+// Stop terminates the scheduling loop. Runs in progress finish.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		return
+	}
+	select {
+	case <-s.stopping:
+	default:
+		close(s.stopping)
+	}
+}
+
+func (s *Scheduler) loop() {
+	for {
+		now := s.now()
+		wait := now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+		select {
+		case <-s.stopping:
+			return
+		case <-time.After(wait):
+			s.tick(s.now())
+		}
+	}
+}
+
+// tick queues the actions due in the minutes since the last tick and starts a
+// worker for every person with queued runs.
+func (s *Scheduler) tick(now time.Time) {
+	now = now.Truncate(time.Minute)
+	s.mu.Lock()
+	from := s.lastTick
+	if from.IsZero() || now.Sub(from) > time.Hour {
+		from = now.Add(-time.Minute)
+	}
+	s.lastTick = now
+	s.mu.Unlock()
+
+	svc := s.opts.Service()
+	if svc == nil {
+		return
+	}
+	for _, person := range s.opts.Persons() {
+		actions, err := svc.ListActions(person)
+		if err != nil {
+			log.Printf("scheduler: list actions for %s: %v", person, err)
+			continue
+		}
+		for _, action := range actions {
+			schedule, err := ParseSchedule(action.Metadata.Schedule)
+			if action.Metadata.Schedule == "" || err != nil {
+				continue
+			}
+			for t := from.Add(time.Minute); !t.After(now); t = t.Add(time.Minute) {
+				if schedule.Matches(t) {
+					s.enqueue(person, queuedRun{action: action, scheduledAt: t})
+					break
+				}
+			}
+		}
+		s.startWorker(person)
+	}
+}
+
+// enqueue adds a run unless the same action is already waiting for the same
+// reason. Triggered runs waiting for the same action take over the new paths;
+// a started run is no longer waiting.
+func (s *Scheduler) enqueue(person string, run queuedRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, queued := range s.queues[person] {
+		if queued.started || queued.action.ID != run.action.ID || (queued.trigger == nil) != (run.trigger == nil) {
+			continue
+		}
+		if run.trigger != nil {
+			for _, p := range run.trigger.paths {
+				queued.trigger.addPath(p)
+			}
+		}
+		return
+	}
+	s.queues[person] = append(s.queues[person], run)
+}
+
+func (s *Scheduler) startWorker(person string) {
+	s.mu.Lock()
+	if s.running[person] || len(s.queues[person]) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	s.running[person] = true
+	s.mu.Unlock()
+
+	go s.work(person)
+}
+
+// work runs the person's queued actions in order. When the session is busy,
+// runs stay queued until the next tick, except those of actions that ask to be
+// skipped.
+func (s *Scheduler) work(person string) {
+	defer func() {
+		s.mu.Lock()
+		s.running[person] = false
+		s.mu.Unlock()
+	}()
+
+	for {
+		s.mu.Lock()
+		if len(s.queues[person]) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		s.queues[person][0].started = true
+		next := s.queues[person][0]
+		s.current[person] = next.action.ID
+		s.mu.Unlock()
+
+		record, busy := s.run(person, next)
+		s.mu.Lock()
+		delete(s.current, person)
+		if busy {
+			// The run stays queued for the next tick and may take new paths.
+			s.queues[person][0].started = false
+		}
+		s.mu.Unlock()
+		if busy {
+			s.skipBusy(person)
+			return
+		}
+		s.dequeue(person, next)
+		s.finish(person, record)
+	}
+}
+
+// skipBusy records and drops the queued runs that should not wait for a busy
+// session.
+func (s *Scheduler) skipBusy(person string) {
+	s.mu.Lock()
+	var skipped, kept []queuedRun
+	for _, queued := range s.queues[person] {
+		if queued.action.Metadata.OnBusy == OnBusySkip || s.now().Sub(queued.scheduledAt) >= maxQueuedRunAge {
+			skipped = append(skipped, queued)
+		} else {
+			kept = append(kept, queued)
+		}
+	}
+	s.queues[person] = kept
+	s.mu.Unlock()
+
+	for _, queued := range skipped {
+		record := newScheduledRun(queued)
+		record.FinishedAt = s.now()
+		record.Status = ScheduledRunSkipped
+		record.Error = ErrSessionBusy.Error()
+		s.finish(person, record)
+	}
+}
+
+func (s *Scheduler) dequeue(person string, run queuedRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queue := s.queues[person]
+	for i, queued := range queue {
+		if queued.action.ID == run.action.ID && queued.trigger == run.trigger {
+			s.queues[person] = append(queue[:i:i], queue[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Scheduler) finish(person string, record ScheduledRun) {
+	if err := s.recordRun(person, record); err != nil {
+		log.Printf("scheduler: record run for %s: %v", person, err)
+	}
+	if s.opts.AfterRun != nil {
+		s.opts.AfterRun(person)
+	}
+}
+
+// run executes one scheduled action in the person's scheduled session. busy
+// reports that the session had another active run.
+func (s *Scheduler) run(person string, queued queuedRun) (record ScheduledRun, busy bool) {
+	record = newScheduledRun(queued)
+	svc := s.opts.Service()
+	if svc == nil {
+		record.Status = ScheduledRunError
+		record.Error = "agent service unavailable"
+		record.FinishedAt = s.now()
+		return record, false
+	}
+	state, err := s.loadState(svc, person)
+	if err != nil {
+		record.Status = ScheduledRunError
+		record.Error = err.Error()
+		record.FinishedAt = s.now()
+		return record, false
+	}
+
+	started := s.now()
+	record.StartedAt = &started
+	record.SessionID = state.SessionID
+	message := "Scheduled run for " + queued.scheduledAt.Format("Monday, 2006-01-02 15:04") + "."
+	if queued.trigger != nil {
+		message = triggerMessage(queued.trigger, queued.scheduledAt)
+	}
+	// Register the run before it starts so its first writes are recognized.
+	runID := uuid.New().String()
+	s.mu.Lock()
+	s.runs[runID] = queued.action.ID
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.runs, runID)
+		s.mu.Unlock()
+	}()
+	stream, err := svc.ChatStream(context.Background(), person, ChatRequest{
+		SessionID:  state.SessionID,
+		ActionID:   queued.action.ID,
+		Message:    message,
+		Unattended: true,
+		RunID:      runID,
+	})
+	if err != nil {
+		record.FinishedAt = s.now()
+		record.StartedAt = nil
+		if IsSessionBusy(err) {
+			return record, true
+		}
+		record.Status = ScheduledRunError
+		record.Error = err.Error()
+		return record, false
+	}
+	record.RunID = stream.RunID
+
+	var text strings.Builder
+	var errs []string
+	for event := range stream.Events {
+		switch event.Type {
+		case "text":
+			text.WriteString(event.Delta)
+		case "error":
+			errs = append(errs, event.Message)
+		case "done":
+			if event.SessionID != "" {
+				record.SessionID = event.SessionID
+			}
+		}
+	}
+	record.FinishedAt = s.now()
+	record.Status = ScheduledRunOK
+	if len(errs) > 0 {
+		record.Status = ScheduledRunError
+		record.Error = strings.Join(errs, "; ")
+	}
+
+	output := outputPath(queued.action)
+	if response := strings.TrimSpace(text.String()); response != "" {
+		entry := fmt.Sprintf("## %s\n\n%s\n\n", queued.scheduledAt.Format("2006-01-02 15:04"), response)
+		unlock := s.lockVault()
+		err := svc.store.AppendFile(person, output, entry)
+		unlock()
+		if err != nil {
+			record.Status = ScheduledRunError
+			if record.Error != "" {
+				record.Error += "; "
+			}
+			record.Error += "write output: " + err.Error()
+		} else {
+			record.Output = output
+		}
+	}
+	return record, false
+}
+
+func newScheduledRun(queued queuedRun) ScheduledRun {
+	record := ScheduledRun{
+		ActionID:    queued.action.ID,
+		Label:       queued.action.Label,
+		ScheduledAt: queued.scheduledAt,
+	}
+	if queued.trigger != nil {
+		record.Trigger = queued.trigger.kind
+		record.Paths = append([]string(nil), queued.trigger.paths...)
+	}
+	return record
+}
+
+// outputPath returns the vault file an action's results are appended to.
+func outputPath(action Action) string {
+	if action.Metadata.Output != "" {
+		return action.Metadata.Output
+	}
+	return path.Join(defaultScheduleOutputDir, action.ID+".md")
+}
+
+// lockVault takes the vault lock, if any, and returns its release.
+func (s *Scheduler) lockVault() func() {
+	if s.opts.VaultLock == nil {
+		return func() {}
+	}
+	s.opts.VaultLock.Lock()
+	return s.opts.VaultLock.Unlock
+}
+
+// recordRun appends a run to the person's history and remembers its session.
+func (s *Scheduler) recordRun(person string, record ScheduledRun) error {
+	svc := s.opts.Service()
+	if svc == nil {
+		return nil
+	}
+	defer s.lockVault()()
+	state, err := s.loadState(svc, person)
+	if err != nil {
+		return err
+	}
+	if record.SessionID != "" {
+		state.SessionID = record.SessionID
+	}
+	state.Runs = append(state.Runs, record)
+	if len(state.Runs) > maxScheduledRunHistory {
+		state.Runs = state.Runs[len(state.Runs)-maxScheduledRunHistory:]
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return svc.store.WriteFile(person, scheduleStatePath, string(data))
+}
+
+func (s *Scheduler) loadState(svc *Service, person string) (*scheduleState, error) {
+	state := &scheduleState{}
+	content, err := svc.store.ReadFile(person, scheduleStatePath)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(content), state); err != nil {
+		return nil, fmt.Errorf("%s: %w", scheduleStatePath, err)
+	}
+	return state, nil
+}
+
+// Status returns the person's scheduled and triggered actions, newest runs first.
+func (s *Scheduler) Status(person string) (*ScheduleStatus, error) {
+	status := &ScheduleStatus{Actions: []ScheduledAction{}, Runs: []ScheduledRun{}}
+	svc := s.opts.Service()
+	if svc == nil {
+		return status, nil
+	}
+	actions, err := svc.ListActions(person)
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.loadState(svc, person)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	queued := make(map[string]bool)
+	for _, run := range s.queues[person] {
+		queued[run.action.ID] = true
+	}
+	for _, action := range actions {
+		if _, ok := s.pending[person+"::"+action.ID]; ok {
+			queued[action.ID] = true
+		}
+	}
+	s.mu.Unlock()
+
+	now := s.now()
+	for _, action := range actions {
+		if action.Metadata.Schedule == "" && len(action.Metadata.Triggers) == 0 {
+			continue
+		}
+		scheduled := ScheduledAction{Action: action, Queued: queued[action.ID]}
+		if schedule, err := ParseSchedule(action.Metadata.Schedule); action.Metadata.Schedule != "" && err == nil {
+			if next := schedule.Next(now); !next.IsZero() {
+				scheduled.NextRunAt = &next
+			}
+		}
+		status.Actions = append(status.Actions, scheduled)
+	}
+	status.SessionID = state.SessionID
+	for i := len(state.Runs) - 1; i >= 0; i-- {
+		status.Runs = append(status.Runs, state.Runs[i])
+	}
+	return status, nil
+}
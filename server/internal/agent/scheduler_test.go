@@ -0,0 +1,196 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"notes-editor/internal/claude"
+	"notes-editor/internal/vault"
+)
+
+// scheduleRuntime answers every streamed request with one text reply.
+type scheduleRuntime struct {
+	mu       sync.Mutex
+	requests []RuntimeChatRequest
+}
+
+func (r *scheduleRuntime) Mode() string { return RuntimeModeAnthropicAPIKey }
+
+func (r *scheduleRuntime) Available() bool { return true }
+
+func (r *scheduleRuntime) Chat(_ string, _ RuntimeChatRequest) (*RuntimeChatResponse, error) {
+	return nil, nil
+}
+
+func (r *scheduleRuntime) ChatStream(_ context.Context, _ string, req RuntimeChatRequest) (*RuntimeStream, error) {
+	r.mu.Lock()
+	r.requests = append(r.requests, req)
+	r.mu.Unlock()
+	events := make(chan StreamEvent, 3)
+	events <- StreamEvent{Type: "text", Delta: "Three meetings today."}
+	events <- StreamEvent{Type: "done", SessionID: "scheduled-session"}
+	close(events)
+	return &RuntimeStream{Events: events}, nil
+}
+
+func (r *scheduleRuntime) ClearSession(_ string) error { return nil }
+
+func (r *scheduleRuntime) GetHistory(_ string) ([]claude.ChatMessage, error) { return nil, nil }
+
+func newTestScheduler(t *testing.T, actions map[string]string) (*Scheduler, *Service, *scheduleRuntime, string) {
+	t.Helper()
+	t.Setenv("PI_GATEWAY_PI_SESSION_DIR", t.TempDir())
+	root := t.TempDir()
+	actionsDir := filepath.Join(root, "sebastian", "agent", "actions")
+	if err := os.MkdirAll(actionsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range actions {
+		if err := os.WriteFile(filepath.Join(actionsDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rt := &scheduleRuntime{}
+	svc := NewServiceWithRuntimes(vault.NewStore(root), map[string]Runtime{
+		RuntimeModeAnthropicAPIKey:     rt,
+		RuntimeModeGatewaySubscription: &stubRuntime{mode: RuntimeModeGatewaySubscription},
+	})
+	scheduler := NewScheduler(SchedulerOptions{
+		Service: func() *Service { return svc },
+		Persons: func() []string { return []string{"sebastian"} },
+	})
+	return scheduler, svc, rt, root
+}
+
+// waitIdle waits until the person's worker finished.
+func waitIdle(t *testing.T, s *Scheduler, person string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		running := s.running[person]
+		s.mu.Unlock()
+		if !running {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("scheduler worker did not finish")
+}
+
+func TestSchedulerRunsDueActions(t *testing.T) {
+	scheduler, _, rt, root := newTestScheduler(t, map[string]string{
+		"Morning Briefing.prompt.md": "---\nschedule: \"0 7 * * 1-5\"\noutput: journal/briefings.md\n---\nSummarize my day.",
+		"Weekly.prompt.md":           "---\nschedule: \"0 18 * * sun\"\n---\nSummarize my week.",
+		"Manual.prompt.md":           "Only on demand.",
+	})
+
+	// 2026-10-19 is a Monday.
+	monday := time.Date(2026, 10, 19, 7, 0, 0, 0, time.Local)
+	scheduler.lastTick = monday.Add(-time.Minute)
+	scheduler.tick(monday)
+	waitIdle(t, scheduler, "sebastian")
+
+	if len(rt.requests) != 1 || !strings.HasPrefix(rt.requests[0].Message, "Summarize my day.") {
+		t.Fatalf("requests = %+v", rt.requests)
+	}
+	output, err := os.ReadFile(filepath.Join(root, "sebastian", "journal", "briefings.md"))
+	if err != nil || !strings.Contains(string(output), "## 2026-10-19 07:00\n\nThree meetings today.") {
+		t.Fatalf("output = %q, %v", output, err)
+	}
+
+	// The next run reuses the dedicated session.
+	scheduler.tick(monday.AddDate(0, 0, 1))
+	waitIdle(t, scheduler, "sebastian")
+	if len(rt.requests) != 2 || rt.requests[1].SessionID != "scheduled-session" {
+		t.Fatalf("second request = %+v", rt.requests)
+	}
+
+	status, err := scheduler.Status("sebastian")
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if len(status.Actions) != 2 || status.SessionID != "scheduled-session" {
+		t.Fatalf("status = %+v", status)
+	}
+	if len(status.Runs) != 2 || status.Runs[0].Status != ScheduledRunOK || status.Runs[0].Output != "journal/briefings.md" {
+		t.Fatalf("runs = %+v", status.Runs)
+	}
+}
+
+func TestSchedulerWritesUnderVaultLock(t *testing.T) {
+	scheduler, _, _, root := newTestScheduler(t, map[string]string{
+		"Morning Briefing.prompt.md": "---\nschedule: \"0 7 * * *\"\noutput: journal/briefings.md\n---\nSummarize my day.",
+	})
+	var vaultLock sync.RWMutex
+	scheduler.opts.VaultLock = &vaultLock
+
+	vaultLock.Lock()
+	at := time.Date(2026, 10, 19, 7, 0, 0, 0, time.Local)
+	scheduler.lastTick = at.Add(-time.Minute)
+	scheduler.tick(at)
+	time.Sleep(50 * time.Millisecond)
+	if _, err := os.Stat(filepath.Join(root, "sebastian", "journal", "briefings.md")); !os.IsNotExist(err) {
+		t.Fatalf("output written while the vault was locked: %v", err)
+	}
+	vaultLock.Unlock()
+	waitIdle(t, scheduler, "sebastian")
+
+	if _, err := os.Stat(filepath.Join(root, "sebastian", "journal", "briefings.md")); err != nil {
+		t.Fatalf("output: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "sebastian", scheduleStatePath)); err != nil {
+		t.Fatalf("run history: %v", err)
+	}
+}
+
+func TestSchedulerQueuesOrSkipsBusySessions(t *testing.T) {
+	scheduler, svc, rt, root := newTestScheduler(t, map[string]string{
+		"Queued.prompt.md":  "---\nschedule: \"0 7 * * *\"\n---\nQueued action.",
+		"Skipped.prompt.md": "---\nschedule: \"0 7 * * *\"\non_busy: skip\n---\nSkipped action.",
+	})
+	state, _ := json.Marshal(scheduleState{SessionID: "scheduled-session"})
+	if err := os.WriteFile(filepath.Join(root, "sebastian", "agent", "schedule.json"), state, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.tryBeginSessionRun("sebastian", "scheduled-session", "chat-run"); err != nil {
+		t.Fatal(err)
+	}
+
+	morning := time.Date(2026, 10, 19, 7, 0, 0, 0, time.Local)
+	scheduler.now = func() time.Time { return morning }
+	scheduler.lastTick = morning.Add(-time.Minute)
+	scheduler.tick(morning)
+	waitIdle(t, scheduler, "sebastian")
+	if len(rt.requests) != 0 {
+		t.Fatalf("ran while busy: %+v", rt.requests)
+	}
+	status, _ := scheduler.Status("sebastian")
+	if !status.Actions[0].Queued || status.Actions[1].Queued || len(status.Runs) != 1 || status.Runs[0].Status != ScheduledRunSkipped {
+		t.Fatalf("status while busy = %+v", status)
+	}
+
+	// Once the session is free, the queued run goes ahead.
+	svc.endSessionRun("sebastian", "scheduled-session", "chat-run")
+	scheduler.tick(morning.Add(time.Minute))
+	waitIdle(t, scheduler, "sebastian")
+
+	status, _ = scheduler.Status("sebastian")
+	if len(rt.requests) != 1 || len(status.Runs) != 2 {
+		t.Fatalf("requests = %+v, runs = %+v", rt.requests, status.Runs)
+	}
+	statuses := map[string]string{}
+	for _, run := range status.Runs {
+		statuses[run.ActionID] = run.Status
+	}
+	if statuses["queued"] != ScheduledRunOK || statuses["skipped"] != ScheduledRunSkipped {
+		t.Fatalf("statuses = %v", statuses)
+	}
+}
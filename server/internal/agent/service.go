@@ -5,12 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -28,7 +30,10 @@ const (
 	maxStepsLimitStatusFmt  = "Action max_steps=%d applied for this run"
 	toolCallLimitStatusFmt  = "Run exceeded max tool calls (%d)"
 	defaultActionStepsLimit = 0
-	conversationItemsDir    = "agent/sessions"
+	// conversationItemsDir held one <id>.items.json file per session before the
+	// session database; they are imported on first use.
+	conversationItemsDir = "agent/sessions"
+	legacyItemsSuffix    = ".items.json"
 )
 
 var ErrSessionBusy = errors.New("session already has an active run")
@@ -38,6 +43,13 @@ type ServiceOptions struct {
 	MaxRunDuration  time.Duration
 	MaxToolCalls    int
 	AllowPiFallback *bool
+	// Sessions persists sessions and conversations. Nil keeps them in memory.
+	Sessions *SessionStore
+	// ApprovalTimeout bounds how long a tool call waits for the person's approval.
+	ApprovalTimeout time.Duration
+	// ToolPoliciesPath is the local file, outside the vault, holding the
+	// persons' tool policies. Empty keeps them in memory.
+	ToolPoliciesPath string
 }
 
 // ChatRequest is the request body for agent chat endpoints.
@@ -46,6 +58,14 @@ type ChatRequest struct {
 	Message   string `json:"message"`
 	ActionID  string `json:"action_id,omitempty"`
 	Confirm   bool   `json:"confirm,omitempty"`
+	// Params are the values for the action's parameters.
+	Params map[string]string `json:"params,omitempty"`
+	// Unattended marks runs nobody watches, such as scheduled ones: tool calls
+	// that need approval are denied instead of waiting for it.
+	Unattended bool `json:"-"`
+	// RunID, if set, is used as the run's ID instead of a generated one, so
+	// callers can recognize the run's writes before the stream starts.
+	RunID string `json:"-"`
 }
 
 // ChatResponse is the non-streaming response body for agent chat endpoint.
@@ -69,6 +89,11 @@ type runControl struct {
 	cancel       chan struct{}
 	streamCancel context.CancelFunc
 	once         sync.Once
+	stopReason   string
+	notices      chan StreamEvent
+	events       *runEventLog
+	finishedAt   time.Time
+	unattended   bool
 
 	itemsMu      sync.Mutex
 	runItems     []ConversationItem
@@ -86,22 +111,26 @@ type ActiveRunSummary struct {
 	SessionID string    `json:"session_id,omitempty"`
 	StartedAt time.Time `json:"started_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// LastSeq is the seq of the newest event, for reattaching with after_seq.
+	LastSeq int `json:"last_seq"`
 }
 
 // Service orchestrates agent requests.
 type Service struct {
-	store           *vault.Store
-	maxRunDuration  time.Duration
-	maxToolCalls    int
-	allowPiFallback bool
-
-	mu                      sync.Mutex
-	activeRuns              map[string]*runControl
-	activeSessionRun        map[string]string
-	sessionRecordsByPerson  map[string]map[string]*sessionRecord
-	sessionSequenceByPerson map[string]int
-	conversationsByPerson   map[string]map[string][]ConversationItem
-	runtimes                map[string]Runtime
+	store *vault.Store
+	// current holds the runtimes and limits; Reconfigure replaces them.
+	current atomic.Pointer[serviceSettings]
+
+	mu               sync.Mutex
+	activeRuns       map[string]*runControl
+	finishedRuns     map[string]*runControl
+	activeSessionRun map[string]string
+	approvals        map[string]*pendingApproval
+
+	sessions       *SessionStore
+	toolPolicies   *toolPolicyStore
+	legacyMu       sync.Mutex
+	legacyImported map[string]bool
 }
 
 // NewService creates an agent service.
@@ -115,11 +144,23 @@ func NewService(claudeSvc *claude.Service, store *vault.Store) *Service {
 
 // NewServiceWithOptions creates a runtime-wired service.
 func NewServiceWithOptions(claudeSvc *claude.Service, store *vault.Store, linkedinSvc *linkedin.Service, piGatewayURL string, options ServiceOptions) *Service {
+	return NewServiceWithRuntimesAndOptions(store, newRuntimes(claudeSvc, store, linkedinSvc, piGatewayURL), options)
+}
+
+// Reconfigure rewires the service's runtimes and limits in place, e.g. after
+// settings changed. Active runs, pending approvals and sessions are kept; runs
+// already started finish on their runtime. options.Sessions and
+// options.ToolPoliciesPath are ignored.
+func (s *Service) Reconfigure(claudeSvc *claude.Service, linkedinSvc *linkedin.Service, piGatewayURL string, options ServiceOptions) {
+	s.current.Store(newServiceSettings(newRuntimes(claudeSvc, s.store, linkedinSvc, piGatewayURL), options))
+}
+
+func newRuntimes(claudeSvc *claude.Service, store *vault.Store, linkedinSvc *linkedin.Service, piGatewayURL string) map[string]Runtime {
 	piRuntime := NewPiGatewayRuntime(piGatewayURL).WithDependencies(store, linkedinSvc)
-	return NewServiceWithRuntimesAndOptions(store, map[string]Runtime{
+	return map[string]Runtime{
 		RuntimeModeAnthropicAPIKey:     NewAnthropicRuntime(claudeSvc),
 		RuntimeModeGatewaySubscription: piRuntime,
-	}, options)
+	}
 }
 
 // NewServiceWithRuntimes creates an agent service with explicit runtime map.
@@ -129,31 +170,63 @@ func NewServiceWithRuntimes(store *vault.Store, runtimes map[string]Runtime) *Se
 
 // NewServiceWithRuntimesAndOptions creates an agent service with explicit runtime map and options.
 func NewServiceWithRuntimesAndOptions(store *vault.Store, runtimes map[string]Runtime, options ServiceOptions) *Service {
-	maxDuration := options.MaxRunDuration
-	if maxDuration <= 0 {
-		maxDuration = defaultMaxRunDuration
+	sessions := options.Sessions
+	if sessions == nil {
+		var err error
+		if sessions, err = OpenSessionStore(""); err != nil {
+			log.Printf("agent: in-memory session store unavailable, sessions are not kept: %v", err)
+		}
+	}
+
+	s := &Service{
+		store:            store,
+		activeRuns:       make(map[string]*runControl),
+		finishedRuns:     make(map[string]*runControl),
+		activeSessionRun: make(map[string]string),
+		approvals:        make(map[string]*pendingApproval),
+		sessions:         sessions,
+		toolPolicies:     newToolPolicyStore(options.ToolPoliciesPath),
+		legacyImported:   make(map[string]bool),
 	}
-	maxToolCalls := options.MaxToolCalls
-	if maxToolCalls <= 0 {
-		maxToolCalls = defaultMaxToolCalls
+	s.current.Store(newServiceSettings(runtimes, options))
+	return s
+}
+
+// serviceSettings are the runtimes and limits a settings reload replaces.
+type serviceSettings struct {
+	runtimes        map[string]Runtime
+	maxRunDuration  time.Duration
+	maxToolCalls    int
+	allowPiFallback bool
+	approvalTimeout time.Duration
+}
+
+func newServiceSettings(runtimes map[string]Runtime, options ServiceOptions) *serviceSettings {
+	settings := &serviceSettings{
+		runtimes:        runtimes,
+		maxRunDuration:  options.MaxRunDuration,
+		maxToolCalls:    options.MaxToolCalls,
+		allowPiFallback: true,
+		approvalTimeout: options.ApprovalTimeout,
+	}
+	if settings.maxRunDuration <= 0 {
+		settings.maxRunDuration = defaultMaxRunDuration
+	}
+	if settings.maxToolCalls <= 0 {
+		settings.maxToolCalls = defaultMaxToolCalls
 	}
-	allowFallback := true
 	if options.AllowPiFallback != nil {
-		allowFallback = *options.AllowPiFallback
+		settings.allowPiFallback = *options.AllowPiFallback
 	}
-
-	return &Service{
-		store:                   store,
-		maxRunDuration:          maxDuration,
-		maxToolCalls:            maxToolCalls,
-		allowPiFallback:         allowFallback,
-		activeRuns:              make(map[string]*runControl),
-		activeSessionRun:        make(map[string]string),
-		sessionRecordsByPerson:  make(map[string]map[string]*sessionRecord),
-		sessionSequenceByPerson: make(map[string]int),
-		conversationsByPerson:   make(map[string]map[string][]ConversationItem),
-		runtimes:                runtimes,
+	if settings.approvalTimeout <= 0 {
+		settings.approvalTimeout = defaultApprovalTimeout
 	}
+	return settings
+}
+
+// settings returns the current runtimes and limits.
+func (s *Service) settings() *serviceSettings {
+	return s.current.Load()
 }
 
 // IsSessionBusy reports whether err indicates session concurrency conflict.
@@ -175,7 +248,10 @@ func (s *Service) Chat(person string, req ChatRequest) (*ChatResponse, error) {
 	}
 	usedRuntime := runtime
 
-	runID := uuid.New().String()
+	runID := req.RunID
+	if runID == "" {
+		runID = uuid.New().String()
+	}
 	if err := s.tryBeginSessionRun(person, req.SessionID, runID); err != nil {
 		return nil, err
 	}
@@ -185,16 +261,18 @@ func (s *Service) Chat(person string, req ChatRequest) (*ChatResponse, error) {
 		SessionID:    req.SessionID,
 		Message:      resolved.Text,
 		MaxToolCalls: toolLimit,
+		RunID:        runID,
 	})
 	if err != nil {
 		if !s.shouldAttemptPiFallback(selectedMode, err) {
 			return nil, err
 		}
-		fallbackRuntime := s.runtimes[RuntimeModeAnthropicAPIKey]
+		fallbackRuntime := s.settings().runtimes[RuntimeModeAnthropicAPIKey]
 		resp, err = fallbackRuntime.Chat(person, RuntimeChatRequest{
 			SessionID:    req.SessionID,
 			Message:      resolved.Text,
 			MaxToolCalls: toolLimit,
+			RunID:        runID,
 		})
 		if err != nil {
 			return nil, err
@@ -225,7 +303,10 @@ func (s *Service) ChatStream(ctx context.Context, person string, req ChatRequest
 	}
 	usedRuntime := runtime
 
-	runID := uuid.New().String()
+	runID := req.RunID
+	if runID == "" {
+		runID = uuid.New().String()
+	}
 	if err := s.tryBeginSessionRun(person, req.SessionID, runID); err != nil {
 		return nil, err
 	}
@@ -237,14 +318,16 @@ func (s *Service) ChatStream(ctx context.Context, person string, req ChatRequest
 		SessionID:    req.SessionID,
 		Message:      resolved.Text,
 		MaxToolCalls: toolLimit,
+		RunID:        runID,
 	})
 	if err != nil {
 		if s.shouldAttemptPiFallback(selectedMode, err) {
-			anthropic := s.runtimes[RuntimeModeAnthropicAPIKey]
+			anthropic := s.settings().runtimes[RuntimeModeAnthropicAPIKey]
 			upstream, err = anthropic.ChatStream(streamCtx, person, RuntimeChatRequest{
 				SessionID:    req.SessionID,
 				Message:      resolved.Text,
 				MaxToolCalls: toolLimit,
+				RunID:        runID,
 			})
 			if err == nil {
 				usedRuntime = anthropic
@@ -258,7 +341,7 @@ func (s *Service) ChatStream(ctx context.Context, person string, req ChatRequest
 		}
 	}
 
-	run := s.registerRun(runID, person, req.SessionID, streamCancel)
+	run := s.registerRun(runID, person, req.SessionID, req.Unattended, streamCancel)
 	out := make(chan StreamEvent, 100)
 
 	go func() {
@@ -291,9 +374,7 @@ func (s *Service) ChatStream(ctx context.Context, person string, req ChatRequest
 				event.TS = time.Now().UTC()
 			}
 			seq++
-			if event.Seq == 0 {
-				event.Seq = seq
-			}
+			event.Seq = seq
 			// Persist assistant text in-order before non-text timeline items.
 			if event.Type != "text" {
 				if _, ok := conversationItemFromStreamEvent(event); ok && assistantText.Len() > 0 {
@@ -313,6 +394,7 @@ func (s *Service) ChatStream(ctx context.Context, person string, req ChatRequest
 				}
 			}
 			s.touchRunEvent(runID)
+			run.events.append(event)
 			out <- event
 			if item, ok := conversationItemFromStreamEvent(event); ok {
 				runItems = append(runItems, item)
@@ -384,7 +466,7 @@ func (s *Service) ChatStream(ctx context.Context, person string, req ChatRequest
 			})
 		}
 
-		timer := time.NewTimer(s.maxRunDuration)
+		timer := time.NewTimer(s.settings().maxRunDuration)
 		defer timer.Stop()
 
 		sawDone := false
@@ -396,19 +478,25 @@ func (s *Service) ChatStream(ctx context.Context, person string, req ChatRequest
 			select {
 			case <-run.cancel:
 				streamCancel()
-				emitTerminal("Run cancelled")
+				// Deliver notices such as a denied approval before the terminal events.
+				for pending := true; pending; {
+					select {
+					case event := <-run.notices:
+						emit(event)
+					default:
+						pending = false
+					}
+				}
+				emitTerminal(run.stopReason)
 				s.drainStream(upstream.Events)
 				return
 			case <-timer.C:
-				run.once.Do(func() {
-					close(run.cancel)
-					if run.streamCancel != nil {
-						run.streamCancel()
-					}
-				})
+				run.stop("Run timed out")
 				emitTerminal("Run timed out")
 				s.drainStream(upstream.Events)
 				return
+			case event := <-run.notices:
+				emit(event)
 			case event, ok := <-upstream.Events:
 				if !ok {
 					if !sawDone {
@@ -499,19 +587,24 @@ func (s *Service) resolveMessage(person string, req ChatRequest) (*resolvedMessa
 	if action.Metadata.RequiresConfirmation && !req.Confirm {
 		return nil, fmt.Errorf("action requires confirmation")
 	}
+	values, err := s.bindParameters(person, action.Metadata.Parameters, req.Params, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	prompt := renderPrompt(action.Prompt, values)
 
 	msg := strings.TrimSpace(req.Message)
 	if msg == "" {
-		if strings.TrimSpace(action.Prompt) == "" {
+		if strings.TrimSpace(prompt) == "" {
 			return nil, fmt.Errorf("action prompt is empty")
 		}
 		return &resolvedMessage{
-			Text:           action.Prompt,
+			Text:           prompt,
 			ActionMaxSteps: action.Metadata.MaxSteps,
 		}, nil
 	}
 	return &resolvedMessage{
-		Text:           action.Prompt + "\n\nAdditional context:\n" + msg,
+		Text:           prompt + "\n\nAdditional context:\n" + msg,
 		ActionMaxSteps: action.Metadata.MaxSteps,
 	}, nil
 }
@@ -536,8 +629,7 @@ func (s *Service) ClearSession(person, sessionID string) error {
 			return err
 		}
 	}
-	s.removeSessionRecord(person, sessionID)
-	s.removeStoredConversation(person, sessionID)
+	s.removeSession(person, sessionID)
 	return nil
 }
 
@@ -548,16 +640,12 @@ func (s *Service) GetConversationHistory(person, sessionID string) ([]Conversati
 		return nil, fmt.Errorf("session_id is required")
 	}
 
-	items, foundDurable, err := s.readStoredConversationFile(person, sessionID)
+	s.importLegacyConversations(person)
+	items, err := s.sessions.items(person, sessionID)
 	if err != nil {
 		return nil, err
 	}
-	if foundDurable {
-		s.setStoredConversationCache(person, sessionID, items)
-	} else {
-		items = s.getStoredConversation(person, sessionID)
-	}
-	if !foundDurable && len(items) == 0 {
+	if len(items) == 0 {
 		runtime, err := s.runtimeForSession(person, sessionID)
 		if err != nil {
 			return nil, err
@@ -635,12 +723,7 @@ func (s *Service) StopRun(person, runID string) bool {
 	if !ok || run.person != person {
 		return false
 	}
-	run.once.Do(func() {
-		close(run.cancel)
-		if run.streamCancel != nil {
-			run.streamCancel()
-		}
-	})
+	run.stop("Run cancelled")
 	return true
 }
 
@@ -659,6 +742,7 @@ func (s *Service) ListActiveRuns(person string) []ActiveRunSummary {
 			SessionID: run.sessionID,
 			StartedAt: run.startedAt,
 			UpdatedAt: run.updatedAt,
+			LastSeq:   run.events.lastSeq(),
 		})
 	}
 	sort.Slice(out, func(i, j int) bool {
@@ -667,107 +751,24 @@ func (s *Service) ListActiveRuns(person string) []ActiveRunSummary {
 	return out
 }
 
-func (s *Service) getStoredConversation(person, sessionID string) []ConversationItem {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	personSessions := s.conversationsByPerson[person]
-	if personSessions == nil {
-		return nil
-	}
-	items := personSessions[sessionID]
-	if len(items) == 0 {
-		return nil
-	}
-	out := make([]ConversationItem, len(items))
-	copy(out, items)
-	return out
-}
-
-func (s *Service) setStoredConversationCache(person, sessionID string, items []ConversationItem) {
-	sessionID = strings.TrimSpace(sessionID)
-	if sessionID == "" {
-		return
-	}
-
-	copied := make([]ConversationItem, len(items))
-	copy(copied, items)
-
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	personSessions := s.conversationsByPerson[person]
-	if len(copied) == 0 {
-		if personSessions != nil {
-			delete(personSessions, sessionID)
-			if len(personSessions) == 0 {
-				delete(s.conversationsByPerson, person)
-			}
-		}
-		return
-	}
-	if personSessions == nil {
-		personSessions = make(map[string][]ConversationItem)
-		s.conversationsByPerson[person] = personSessions
-	}
-	personSessions[sessionID] = copied
-}
-
 func (s *Service) replaceStoredConversation(person, sessionID string, items []ConversationItem) {
 	sessionID = strings.TrimSpace(sessionID)
 	if sessionID == "" {
 		return
 	}
-
-	s.setStoredConversationCache(person, sessionID, items)
-	_ = s.writeStoredConversationFile(person, sessionID, items)
-}
-
-func (s *Service) appendStoredConversation(person, sessionID string, items []ConversationItem) {
-	if len(items) == 0 {
-		return
-	}
-
-	sessionID = strings.TrimSpace(sessionID)
-	if sessionID == "" {
-		return
+	if err := s.sessions.replaceItems(person, sessionID, items); err != nil {
+		log.Printf("agent: store conversation %s for %s: %v", sessionID, person, err)
 	}
-
-	copiedItems := make([]ConversationItem, len(items))
-	copy(copiedItems, items)
-
-	s.mu.Lock()
-	personSessions := s.conversationsByPerson[person]
-	if personSessions == nil {
-		personSessions = make(map[string][]ConversationItem)
-		s.conversationsByPerson[person] = personSessions
-	}
-	personSessions[sessionID] = append(personSessions[sessionID], copiedItems...)
-	full := make([]ConversationItem, len(personSessions[sessionID]))
-	copy(full, personSessions[sessionID])
-	s.mu.Unlock()
-
-	_ = s.writeStoredConversationFile(person, sessionID, full)
 }
 
-func (s *Service) removeStoredConversation(person, sessionID string) {
+func (s *Service) appendStoredConversation(person, sessionID string, items []ConversationItem) {
 	sessionID = strings.TrimSpace(sessionID)
-	if sessionID == "" {
+	if len(items) == 0 || sessionID == "" {
 		return
 	}
-
-	s.mu.Lock()
-	personSessions := s.conversationsByPerson[person]
-	if personSessions == nil {
-		s.mu.Unlock()
-		_ = s.deleteStoredConversationFile(person, sessionID)
-		return
+	if err := s.sessions.appendItems(person, sessionID, items); err != nil {
+		log.Printf("agent: store conversation %s for %s: %v", sessionID, person, err)
 	}
-	delete(personSessions, sessionID)
-	if len(personSessions) == 0 {
-		delete(s.conversationsByPerson, person)
-	}
-	s.mu.Unlock()
-
-	_ = s.deleteStoredConversationFile(person, sessionID)
 }
 
 func (s *Service) readStoredConversationFile(person, sessionID string) ([]ConversationItem, bool, error) {
@@ -795,17 +796,6 @@ func (s *Service) readStoredConversationFile(person, sessionID string) ([]Conver
 	return items, true, nil
 }
 
-func (s *Service) writeStoredConversationFile(person, sessionID string, items []ConversationItem) error {
-	if s.store == nil {
-		return nil
-	}
-	data, err := json.MarshalIndent(items, "", "  ")
-	if err != nil {
-		return err
-	}
-	return s.store.WriteFile(person, storedConversationFilePath(sessionID), string(data))
-}
-
 func (s *Service) deleteStoredConversationFile(person, sessionID string) error {
 	if s.store == nil {
 		return nil
@@ -815,11 +805,11 @@ func (s *Service) deleteStoredConversationFile(person, sessionID string) error {
 
 func storedConversationFilePath(sessionID string) string {
 	safeID := url.PathEscape(strings.TrimSpace(sessionID))
-	filename := safeID + ".items.json"
+	filename := safeID + legacyItemsSuffix
 	return filepath.ToSlash(filepath.Join(conversationItemsDir, filename))
 }
 
-func (s *Service) registerRun(runID, person, sessionID string, streamCancel context.CancelFunc) *runControl {
+func (s *Service) registerRun(runID, person, sessionID string, unattended bool, streamCancel context.CancelFunc) *runControl {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	now := time.Now().UTC()
@@ -830,7 +820,11 @@ func (s *Service) registerRun(runID, person, sessionID string, streamCancel cont
 		updatedAt:    now,
 		cancel:       make(chan struct{}),
 		streamCancel: streamCancel,
+		notices:      make(chan StreamEvent, 8),
+		events:       newRunEventLog(),
+		unattended:   unattended,
 	}
+	s.pruneFinishedRunsLocked(now)
 	s.activeRuns[runID] = run
 	return run
 }
@@ -838,7 +832,14 @@ func (s *Service) registerRun(runID, person, sessionID string, streamCancel cont
 func (s *Service) unregisterRun(runID string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	run, ok := s.activeRuns[runID]
+	if !ok {
+		return
+	}
 	delete(s.activeRuns, runID)
+	run.finishedAt = time.Now().UTC()
+	run.events.close()
+	s.finishedRuns[runID] = run
 }
 
 func (s *Service) touchRunEvent(runID string) {
@@ -923,7 +924,7 @@ func (s *Service) selectRuntime(person string) (Runtime, string, string, error)
 func (s *Service) selectRuntimeForMode(mode string) (Runtime, string, error) {
 	switch mode {
 	case "", RuntimeModeAnthropicAPIKey:
-		runtime := s.runtimes[RuntimeModeAnthropicAPIKey]
+		runtime := s.settings().runtimes[RuntimeModeAnthropicAPIKey]
 		if runtime == nil || !runtime.Available() {
 			return nil, "", &RuntimeUnavailableError{
 				Mode:   RuntimeModeAnthropicAPIKey,
@@ -932,13 +933,13 @@ func (s *Service) selectRuntimeForMode(mode string) (Runtime, string, error) {
 		}
 		return runtime, "", nil
 	case RuntimeModeGatewaySubscription:
-		piRuntime := s.runtimes[RuntimeModeGatewaySubscription]
+		piRuntime := s.settings().runtimes[RuntimeModeGatewaySubscription]
 		if piRuntime != nil && piRuntime.Available() {
 			return piRuntime, "", nil
 		}
 
-		if s.allowPiFallback {
-			anthropicRuntime := s.runtimes[RuntimeModeAnthropicAPIKey]
+		if s.settings().allowPiFallback {
+			anthropicRuntime := s.settings().runtimes[RuntimeModeAnthropicAPIKey]
 			if anthropicRuntime != nil && anthropicRuntime.Available() {
 				return anthropicRuntime, piFallbackStatus, nil
 			}
@@ -955,7 +956,7 @@ func (s *Service) selectRuntimeForMode(mode string) (Runtime, string, error) {
 
 func (s *Service) runtimeForSession(person, sessionID string) (Runtime, error) {
 	if mode, ok := s.runtimeModeForSession(person, sessionID); ok {
-		runtime := s.runtimes[mode]
+		runtime := s.settings().runtimes[mode]
 		if runtime != nil && runtime.Available() {
 			return runtime, nil
 		}
@@ -973,10 +974,10 @@ func (s *Service) runtimeForSession(person, sessionID string) (Runtime, error) {
 }
 
 func (s *Service) shouldAttemptPiFallback(selectedMode string, err error) bool {
-	if selectedMode != RuntimeModeGatewaySubscription || !s.allowPiFallback {
+	if selectedMode != RuntimeModeGatewaySubscription || !s.settings().allowPiFallback {
 		return false
 	}
-	anthropic := s.runtimes[RuntimeModeAnthropicAPIKey]
+	anthropic := s.settings().runtimes[RuntimeModeAnthropicAPIKey]
 	if anthropic == nil || !anthropic.Available() {
 		return false
 	}
@@ -991,7 +992,7 @@ func (s *Service) drainStream(events <-chan StreamEvent) {
 }
 
 func (s *Service) effectiveToolLimit(actionMaxSteps int) int {
-	limit := s.maxToolCalls
+	limit := s.settings().maxToolCalls
 	if actionMaxSteps > defaultActionStepsLimit && (limit == 0 || actionMaxSteps < limit) {
 		limit = actionMaxSteps
 	}
@@ -3,6 +3,7 @@ package agent
 import (
 	"context"
 	"errors"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -145,7 +146,7 @@ func TestSelectRuntimePiUnavailableWithoutFallback(t *testing.T) {
 		RuntimeModeAnthropicAPIKey:     &stubRuntime{mode: RuntimeModeAnthropicAPIKey, available: false},
 		RuntimeModeGatewaySubscription: &stubRuntime{mode: RuntimeModeGatewaySubscription, available: false},
 	})
-	svc.allowPiFallback = false
+	svc.settings().allowPiFallback = false
 
 	_, _, err := svc.selectRuntimeForMode(RuntimeModeGatewaySubscription)
 	if err == nil {
@@ -171,7 +172,7 @@ func TestChatStreamRejectsConcurrentRunsForSameSession(t *testing.T) {
 		RuntimeModeAnthropicAPIKey:     anthropic,
 		RuntimeModeGatewaySubscription: &stubRuntime{mode: RuntimeModeGatewaySubscription, available: false},
 	})
-	svc.maxRunDuration = 5 * time.Second
+	svc.settings().maxRunDuration = 5 * time.Second
 
 	run1, err := svc.ChatStream(context.Background(), "sebastian", ChatRequest{
 		SessionID: "shared-session",
@@ -613,7 +614,7 @@ func TestChatStreamTimeoutCancelsUpstreamStreamContext(t *testing.T) {
 		RuntimeModeAnthropicAPIKey:     runtime,
 		RuntimeModeGatewaySubscription: &stubRuntime{mode: RuntimeModeGatewaySubscription, available: false},
 	})
-	svc.maxRunDuration = 50 * time.Millisecond
+	svc.settings().maxRunDuration = 50 * time.Millisecond
 
 	run, err := svc.ChatStream(context.Background(), "sebastian", ChatRequest{
 		SessionID: "cancel-prop-timeout",
@@ -648,17 +649,12 @@ func TestStoredConversationItemsRoundTrip(t *testing.T) {
 		{Type: ConversationItemToolCall, Tool: "read_file", Args: map[string]any{"path": "daily.md"}},
 		{Type: ConversationItemToolResult, Tool: "read_file", OK: true, Summary: "ok"},
 	}
-	if err := svc.writeStoredConversationFile("sebastian", "roundtrip", want); err != nil {
-		t.Fatalf("write stored conversation failed: %v", err)
-	}
+	svc.appendStoredConversation("sebastian", "roundtrip", want)
 
-	got, found, err := svc.readStoredConversationFile("sebastian", "roundtrip")
+	got, err := svc.sessions.items("sebastian", "roundtrip")
 	if err != nil {
 		t.Fatalf("read stored conversation failed: %v", err)
 	}
-	if !found {
-		t.Fatal("expected stored conversation file to be found")
-	}
 	if len(got) != len(want) {
 		t.Fatalf("expected %d items, got %d", len(want), len(got))
 	}
@@ -673,6 +669,16 @@ func TestStoredConversationItemsRoundTrip(t *testing.T) {
 func TestGetConversationHistoryPrefersDurableTimeline(t *testing.T) {
 	root := t.TempDir()
 	store := vault.NewStore(root)
+	dbPath := filepath.Join(t.TempDir(), "agent.db")
+	openSessions := func() *SessionStore {
+		t.Helper()
+		sessions, err := OpenSessionStore(dbPath)
+		if err != nil {
+			t.Fatalf("open session store: %v", err)
+		}
+		t.Cleanup(func() { sessions.Close() })
+		return sessions
+	}
 
 	upstream := make(chan StreamEvent, 8)
 	upstream <- StreamEvent{Type: "text", Delta: "First part."}
@@ -682,7 +688,7 @@ func TestGetConversationHistoryPrefersDurableTimeline(t *testing.T) {
 	upstream <- StreamEvent{Type: "done", SessionID: "persisted-session"}
 	close(upstream)
 
-	writerSvc := NewServiceWithRuntimes(store, map[string]Runtime{
+	writerSvc := NewServiceWithRuntimesAndOptions(store, map[string]Runtime{
 		RuntimeModeAnthropicAPIKey: &stubRuntime{
 			mode:      RuntimeModeAnthropicAPIKey,
 			available: true,
@@ -690,7 +696,7 @@ func TestGetConversationHistoryPrefersDurableTimeline(t *testing.T) {
 				Events: upstream,
 			},
 		},
-	})
+	}, ServiceOptions{Sessions: openSessions()})
 
 	run, err := writerSvc.ChatStream(context.Background(), "sebastian", ChatRequest{
 		SessionID: "persisted-session",
@@ -702,7 +708,8 @@ func TestGetConversationHistoryPrefersDurableTimeline(t *testing.T) {
 	for range run.Events {
 	}
 
-	readerSvc := NewServiceWithRuntimes(store, map[string]Runtime{
+	// A restarted server opens the same database.
+	readerSvc := NewServiceWithRuntimesAndOptions(store, map[string]Runtime{
 		RuntimeModeAnthropicAPIKey: &stubRuntime{
 			mode:      RuntimeModeAnthropicAPIKey,
 			available: true,
@@ -713,7 +720,7 @@ func TestGetConversationHistoryPrefersDurableTimeline(t *testing.T) {
 				},
 			},
 		},
-	})
+	}, ServiceOptions{Sessions: openSessions()})
 
 	history, err := readerSvc.GetConversationHistory("sebastian", "persisted-session")
 	if err != nil {
@@ -760,3 +767,95 @@ func TestGetConversationHistoryFallsBackToLegacyChatMessagesWhenNoDurableFile(t
 		t.Fatalf("expected message-only fallback items, got %#v", items)
 	}
 }
+
+func TestAttachRunReplaysBacklogAndFollowsLiveEvents(t *testing.T) {
+	upstream := make(chan StreamEvent)
+	runtime := &stubRuntime{
+		mode:       RuntimeModeAnthropicAPIKey,
+		available:  true,
+		streamResp: &RuntimeStream{Events: upstream},
+	}
+	svc := NewServiceWithRuntimes(vault.NewStore(t.TempDir()), map[string]Runtime{
+		RuntimeModeAnthropicAPIKey:     runtime,
+		RuntimeModeGatewaySubscription: &stubRuntime{mode: RuntimeModeGatewaySubscription, available: false},
+	})
+
+	run, err := svc.ChatStream(context.Background(), "sebastian", ChatRequest{SessionID: "s-attach", Message: "hello"})
+	if err != nil {
+		t.Fatalf("chat stream failed: %v", err)
+	}
+	// The original client disconnects after the start event.
+	if event := <-run.Events; event.Type != "start" || event.Seq != 1 {
+		t.Fatalf("first event = %+v", event)
+	}
+	go func() {
+		for range run.Events {
+		}
+	}()
+	upstream <- StreamEvent{Type: "text", Delta: "Hel"}
+	upstream <- StreamEvent{Type: "text", Delta: "lo"}
+
+	if _, err := svc.AttachRun(context.Background(), "petra", run.RunID, 0); !errors.Is(err, ErrRunNotFound) {
+		t.Fatalf("AttachRun(other person) error = %v", err)
+	}
+
+	attached, err := svc.AttachRun(context.Background(), "sebastian", run.RunID, 1)
+	if err != nil {
+		t.Fatalf("AttachRun() error = %v", err)
+	}
+	var deltas []string
+	lastSeq := 1
+	for len(deltas) < 2 {
+		event := <-attached.Events
+		if event.Seq <= lastSeq {
+			t.Fatalf("event %+v out of order after seq %d", event, lastSeq)
+		}
+		lastSeq = event.Seq
+		if event.Type == "text" {
+			deltas = append(deltas, event.Delta)
+		}
+	}
+	if strings.Join(deltas, "") != "Hello" {
+		t.Fatalf("replayed deltas = %v", deltas)
+	}
+	if runs := svc.ListActiveRuns("sebastian"); len(runs) != 1 || runs[0].LastSeq != lastSeq {
+		t.Fatalf("active runs = %+v, want last seq %d", runs, lastSeq)
+	}
+
+	upstream <- StreamEvent{Type: "done", SessionID: "s-attach"}
+	close(upstream)
+	var live []StreamEvent
+	for event := range attached.Events {
+		live = append(live, event)
+	}
+	if len(live) != 1 || live[0].Type != "done" || live[0].Seq != lastSeq+1 {
+		t.Fatalf("live events = %+v", live)
+	}
+
+	// A finished run can still be replayed for a while.
+	replay, err := svc.AttachRun(context.Background(), "sebastian", run.RunID, 0)
+	if err != nil {
+		t.Fatalf("AttachRun(finished) error = %v", err)
+	}
+	count := 0
+	for range replay.Events {
+		count++
+	}
+	if count != lastSeq+1 {
+		t.Fatalf("replayed %d events, want %d", count, lastSeq+1)
+	}
+}
+
+func TestRunEventLogDropsOldestEvents(t *testing.T) {
+	log := newRunEventLog()
+	for seq := 1; seq <= maxRunEventBacklog+10; seq++ {
+		log.append(StreamEvent{Type: "text", Seq: seq})
+	}
+	if _, _, _, err := log.since(5); !errors.Is(err, ErrRunEventsExpired) {
+		t.Fatalf("since(5) error = %v", err)
+	}
+	events, closed, _, err := log.since(maxRunEventBacklog + 8)
+	if err != nil || closed || len(events) != 2 || events[0].Seq != maxRunEventBacklog+9 {
+		t.Fatalf("since() = %d events, closed=%v, err=%v", len(events), closed, err)
+	}
+}
@@ -2,6 +2,8 @@ package agent
 
 import (
 	"fmt"
+	"log"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
@@ -20,8 +22,11 @@ const (
 type sessionRecord struct {
 	SessionID   string
 	Person      string
+	Seq         int
 	Name        string
 	RuntimeMode string
+	Pinned      bool
+	Archived    bool
 	CreatedAt   time.Time
 	LastUsedAt  time.Time
 }
@@ -34,6 +39,18 @@ type SessionSummary struct {
 	LastUsedAt   time.Time `json:"last_used_at"`
 	MessageCount int       `json:"message_count"`
 	LastPreview  string    `json:"last_preview,omitempty"`
+	Pinned       bool      `json:"pinned"`
+	Archived     bool      `json:"archived"`
+	// Snippet shows where a search matched the conversation.
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// SessionPage is one page of a session listing.
+type SessionPage struct {
+	Sessions   []SessionSummary `json:"sessions"`
+	Total      int              `json:"total"`
+	Offset     int              `json:"offset"`
+	NextOffset *int             `json:"next_offset,omitempty"`
 }
 
 func (s *Service) touchSession(person, sessionID, initialMessage, runtimeMode string) {
@@ -41,116 +58,84 @@ func (s *Service) touchSession(person, sessionID, initialMessage, runtimeMode st
 	if sessionID == "" {
 		return
 	}
-
-	now := time.Now().UTC()
-
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	personSessions := s.sessionRecordsByPerson[person]
-	if personSessions == nil {
-		personSessions = make(map[string]*sessionRecord)
-		s.sessionRecordsByPerson[person] = personSessions
-	}
-
-	if existing, ok := personSessions[sessionID]; ok {
-		existing.LastUsedAt = now
-		if existing.RuntimeMode == "" {
-			existing.RuntimeMode = runtimeMode
-		}
-		return
-	}
-
-	seq := s.sessionSequenceByPerson[person] + 1
-	s.sessionSequenceByPerson[person] = seq
-
-	personSessions[sessionID] = &sessionRecord{
-		SessionID:   sessionID,
-		Person:      person,
-		Name:        buildSessionName(initialMessage, seq),
-		RuntimeMode: runtimeMode,
-		CreatedAt:   now,
-		LastUsedAt:  now,
+	if err := s.sessions.touch(person, sessionID, initialMessage, runtimeMode, time.Now().UTC()); err != nil {
+		log.Printf("agent: record session %s for %s: %v", sessionID, person, err)
 	}
 }
 
-func (s *Service) removeSessionRecord(person, sessionID string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	personSessions := s.sessionRecordsByPerson[person]
-	if personSessions == nil {
-		return
-	}
-	delete(personSessions, sessionID)
-	if len(personSessions) == 0 {
-		delete(s.sessionRecordsByPerson, person)
+func (s *Service) removeSession(person, sessionID string) {
+	if err := s.sessions.remove(person, sessionID); err != nil {
+		log.Printf("agent: remove session %s for %s: %v", sessionID, person, err)
 	}
+	_ = s.deleteStoredConversationFile(person, sessionID)
 }
 
 func (s *Service) runtimeModeForSession(person, sessionID string) (string, bool) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	personSessions := s.sessionRecordsByPerson[person]
-	if personSessions == nil {
-		return "", false
-	}
-	record, ok := personSessions[sessionID]
-	if !ok || record.RuntimeMode == "" {
+	record, err := s.sessions.session(person, sessionID)
+	if err != nil || record.RuntimeMode == "" {
 		return "", false
 	}
 	return record.RuntimeMode, true
 }
 
+// ListSessions returns all of the person's sessions, archived ones included.
 func (s *Service) ListSessions(person string) ([]SessionSummary, error) {
+	page, err := s.QuerySessions(person, SessionQuery{Archived: SessionFilterAll})
+	if err != nil {
+		return nil, err
+	}
+	return page.Sessions, nil
+}
+
+// QuerySessions returns one page of the person's sessions, pinned first and
+// then newest first, optionally filtered by a full-text search.
+func (s *Service) QuerySessions(person string, query SessionQuery) (*SessionPage, error) {
+	s.importLegacyConversations(person)
 	s.hydrateGatewayRecoveredSessions(person)
 
-	s.mu.Lock()
-	personSessions := s.sessionRecordsByPerson[person]
-	records := make([]*sessionRecord, 0, len(personSessions))
-	for _, rec := range personSessions {
-		copyRec := *rec
-		records = append(records, &copyRec)
+	records, total, err := s.sessions.list(person, query)
+	if err != nil {
+		return nil, err
 	}
-	s.mu.Unlock()
-
-	sort.Slice(records, func(i, j int) bool {
-		if records[i].CreatedAt.Equal(records[j].CreatedAt) {
-			return records[i].LastUsedAt.After(records[j].LastUsedAt)
-		}
-		return records[i].CreatedAt.After(records[j].CreatedAt)
-	})
 
-	summaries := make([]SessionSummary, 0, len(records))
+	page := &SessionPage{
+		Sessions: make([]SessionSummary, 0, len(records)),
+		Total:    total,
+		Offset:   query.Offset,
+	}
+	if query.Limit > 0 && query.Offset+len(records) < total {
+		next := query.Offset + len(records)
+		page.NextOffset = &next
+	}
 	for _, rec := range records {
 		summary := SessionSummary{
 			SessionID:  rec.SessionID,
 			Name:       rec.Name,
 			CreatedAt:  rec.CreatedAt,
 			LastUsedAt: rec.LastUsedAt,
+			Pinned:     rec.Pinned,
+			Archived:   rec.Archived,
+			Snippet:    rec.Snippet,
 		}
 
-		items := s.getStoredConversation(person, rec.SessionID)
-		if len(items) > 0 {
-			msgCount := 0
-			for _, item := range items {
-				if item.Type == ConversationItemMessage {
-					msgCount++
-				}
-			}
-			summary.MessageCount = msgCount
-			summary.LastPreview = historyPreviewItems(items)
-			summaries = append(summaries, summary)
+		if rec.MessageCount > 0 {
+			summary.MessageCount = rec.MessageCount
+			summary.LastPreview = historyPreviewItems([]ConversationItem{{
+				Type:    ConversationItemMessage,
+				Role:    "assistant",
+				Content: rec.LastAssistant,
+			}})
+			page.Sessions = append(page.Sessions, summary)
 			continue
 		}
 
-		runtime := s.runtimes[rec.RuntimeMode]
+		runtime := s.settings().runtimes[rec.RuntimeMode]
 		if runtime == nil || !runtime.Available() {
-			summaries = append(summaries, summary)
+			page.Sessions = append(page.Sessions, summary)
 			continue
 		}
 
 		var history []claude.ChatMessage
-		var err error
 		if rec.RuntimeMode == RuntimeModeGatewaySubscription {
 			if piRuntime, ok := runtime.(*PiGatewayRuntime); ok {
 				history, err = piRuntime.GetHistoryForPerson(person, rec.SessionID)
@@ -165,29 +150,49 @@ func (s *Service) ListSessions(person string) ([]SessionSummary, error) {
 			summary.LastPreview = historyPreview(history)
 		}
 
-		summaries = append(summaries, summary)
+		page.Sessions = append(page.Sessions, summary)
 	}
-	return summaries, nil
+	return page, nil
+}
+
+// UpdateSession renames, pins or archives a session.
+func (s *Service) UpdateSession(person, sessionID string, update SessionUpdate) (*SessionSummary, error) {
+	sessionID = strings.TrimSpace(sessionID)
+	if sessionID == "" {
+		return nil, fmt.Errorf("session_id is required")
+	}
+	if update.Name != nil {
+		name := normalizeSessionText(*update.Name)
+		if name == "" {
+			return nil, fmt.Errorf("name must not be empty")
+		}
+		if len(name) > maxSessionNameLen {
+			return nil, fmt.Errorf("name exceeds %d characters", maxSessionNameLen)
+		}
+		update.Name = &name
+	}
+	if err := s.sessions.update(person, sessionID, update); err != nil {
+		return nil, err
+	}
+	record, err := s.sessions.session(person, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionSummary{
+		SessionID:  record.SessionID,
+		Name:       record.Name,
+		CreatedAt:  record.CreatedAt,
+		LastUsedAt: record.LastUsedAt,
+		Pinned:     record.Pinned,
+		Archived:   record.Archived,
+	}, nil
 }
 
 func (s *Service) hydrateGatewayRecoveredSessions(person string) {
-	runtime := s.runtimes[RuntimeModeGatewaySubscription]
+	runtime := s.settings().runtimes[RuntimeModeGatewaySubscription]
 	piRuntime, _ := runtime.(*PiGatewayRuntime)
 
 	recovered := listGatewayRuntimeSessionFiles(person)
-	if len(recovered) == 0 {
-		return
-	}
-
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	personSessions := s.sessionRecordsByPerson[person]
-	if personSessions == nil {
-		personSessions = make(map[string]*sessionRecord)
-		s.sessionRecordsByPerson[person] = personSessions
-	}
-
 	for _, rec := range recovered {
 		history, err := readGatewaySessionHistory(person, rec.SessionID)
 		if err != nil || len(history) == 0 {
@@ -199,19 +204,66 @@ func (s *Service) hydrateGatewayRecoveredSessions(person string) {
 				sessionID = mappedSessionID
 			}
 		}
-		if _, exists := personSessions[sessionID]; exists {
+		if err := s.sessions.addRecovered(person, sessionID, "", RuntimeModeGatewaySubscription, rec.Timestamp); err != nil {
+			log.Printf("agent: recover gateway session %s for %s: %v", sessionID, person, err)
+		}
+	}
+}
+
+// importLegacyConversations moves conversations stored as
+// agent/sessions/<id>.items.json before the session database existed into it.
+// It runs once per person.
+func (s *Service) importLegacyConversations(person string) {
+	s.legacyMu.Lock()
+	defer s.legacyMu.Unlock()
+	if s.legacyImported[person] || s.store == nil {
+		return
+	}
+	s.legacyImported[person] = true
+
+	entries, err := s.store.ListDir(person, conversationItemsDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir || !strings.HasSuffix(entry.Name, legacyItemsSuffix) {
+			continue
+		}
+		sessionID, err := url.PathUnescape(strings.TrimSuffix(entry.Name, legacyItemsSuffix))
+		if err != nil || strings.TrimSpace(sessionID) == "" {
+			continue
+		}
+		imported, err := s.sessions.hasItems(person, sessionID)
+		if err != nil {
+			log.Printf("agent: import session %s for %s: %v", sessionID, person, err)
 			continue
 		}
-		seq := s.sessionSequenceByPerson[person] + 1
-		s.sessionSequenceByPerson[person] = seq
-		personSessions[sessionID] = &sessionRecord{
-			SessionID:   sessionID,
-			Person:      person,
-			Name:        buildSessionName("", seq),
-			RuntimeMode: RuntimeModeGatewaySubscription,
-			CreatedAt:   rec.Timestamp,
-			LastUsedAt:  rec.Timestamp,
+		if !imported {
+			items, _, err := s.readStoredConversationFile(person, sessionID)
+			if err != nil {
+				log.Printf("agent: import session %s for %s: %v", sessionID, person, err)
+				continue
+			}
+			firstMessage, createdAt := "", time.Now().UTC()
+			for _, item := range items {
+				if item.Type == ConversationItemMessage && item.Role == "user" {
+					firstMessage = item.Content
+					if !item.TS.IsZero() {
+						createdAt = item.TS
+					}
+					break
+				}
+			}
+			if err := s.sessions.addRecovered(person, sessionID, firstMessage, "", createdAt); err != nil {
+				log.Printf("agent: import session %s for %s: %v", sessionID, person, err)
+				continue
+			}
+			if err := s.sessions.replaceItems(person, sessionID, items); err != nil {
+				log.Printf("agent: import session %s for %s: %v", sessionID, person, err)
+				continue
+			}
 		}
+		_ = s.deleteStoredConversationFile(person, sessionID)
 	}
 }
 
@@ -266,7 +318,7 @@ func listGatewayRuntimeSessionFiles(person string) []recoveredRuntimeSession {
 
 func (s *Service) ClearAllSessions(person string) error {
 	var firstErr error
-	if runtime := s.runtimes[RuntimeModeGatewaySubscription]; runtime != nil && runtime.Available() {
+	if runtime := s.settings().runtimes[RuntimeModeGatewaySubscription]; runtime != nil && runtime.Available() {
 		if piRuntime, ok := runtime.(*PiGatewayRuntime); ok {
 			if err := piRuntime.ClearAllForPerson(person); err != nil {
 				firstErr = err
@@ -274,20 +326,14 @@ func (s *Service) ClearAllSessions(person string) error {
 		}
 	}
 
-	s.mu.Lock()
-	personSessions := s.sessionRecordsByPerson[person]
-	records := make([]sessionRecord, 0, len(personSessions))
-	for _, rec := range personSessions {
-		records = append(records, *rec)
+	s.importLegacyConversations(person)
+	records, err := s.sessions.removeAll(person)
+	if err != nil && firstErr == nil {
+		firstErr = err
 	}
-	delete(s.sessionRecordsByPerson, person)
-	delete(s.conversationsByPerson, person)
-	s.mu.Unlock()
 
 	for _, rec := range records {
-		s.removeStoredConversation(person, rec.SessionID)
-
-		runtime := s.runtimes[rec.RuntimeMode]
+		runtime := s.settings().runtimes[rec.RuntimeMode]
 		if runtime == nil || !runtime.Available() {
 			continue
 		}
@@ -194,15 +194,16 @@ func TestListSessionsOrdersByCreatedAtDescending(t *testing.T) {
 	svc.touchSession("petra", "older", "older", RuntimeModeAnthropicAPIKey)
 	svc.touchSession("petra", "newer", "newer", RuntimeModeAnthropicAPIKey)
 
-	svc.mu.Lock()
-	older := svc.sessionRecordsByPerson["petra"]["older"]
-	newer := svc.sessionRecordsByPerson["petra"]["newer"]
 	base := time.Now().UTC()
-	older.CreatedAt = base.Add(-2 * time.Hour)
-	older.LastUsedAt = base.Add(2 * time.Hour)
-	newer.CreatedAt = base.Add(-1 * time.Hour)
-	newer.LastUsedAt = base.Add(-3 * time.Hour)
-	svc.mu.Unlock()
+	setTimes := func(sessionID string, createdAt, lastUsedAt time.Time) {
+		t.Helper()
+		if _, err := svc.sessions.db.Exec("UPDATE agent_sessions SET created_at_utc = ?, last_used_at_utc = ? WHERE session_id = ?",
+			formatSessionTime(createdAt), formatSessionTime(lastUsedAt), sessionID); err != nil {
+			t.Fatalf("update session times: %v", err)
+		}
+	}
+	setTimes("older", base.Add(-2*time.Hour), base.Add(2*time.Hour))
+	setTimes("newer", base.Add(-1*time.Hour), base.Add(-3*time.Hour))
 
 	sessions, err := svc.ListSessions("petra")
 	if err != nil {
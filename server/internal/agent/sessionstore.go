@@ -0,0 +1,547 @@
+package agent
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Values of SessionQuery.Archived.
+const (
+	SessionFilterActive   = "active"
+	SessionFilterArchived = "archived"
+	SessionFilterAll      = "all"
+)
+
+// sessionTimeLayout has a fixed width so stored timestamps sort as text.
+const sessionTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// ErrSessionNotFound is returned for sessions the person does not have.
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionStore persists agent session metadata and conversation items in
+// SQLite so names, sequence numbers and runtime modes survive restarts. Message
+// text is indexed for full-text search. A nil store keeps nothing.
+type SessionStore struct {
+	db *sql.DB
+}
+
+// SessionQuery filters and pages a session listing.
+type SessionQuery struct {
+	// Search matches session names and message text.
+	Search string
+	// Archived is "active" (default), "archived" or "all".
+	Archived string
+	// Limit caps the page size; zero returns every match.
+	Limit  int
+	Offset int
+}
+
+// SessionUpdate changes session metadata. Nil fields are left alone.
+type SessionUpdate struct {
+	Name     *string `json:"name,omitempty"`
+	Pinned   *bool   `json:"pinned,omitempty"`
+	Archived *bool   `json:"archived,omitempty"`
+}
+
+// storedSession is one session row with its derived message statistics.
+type storedSession struct {
+	sessionRecord
+	MessageCount  int
+	LastAssistant string
+	Snippet       string
+}
+
+// OpenSessionStore opens or creates the session database at dbPath. An empty
+// path keeps the database in memory.
+func OpenSessionStore(dbPath string) (*SessionStore, error) {
+	dsn := ":memory:"
+	if dbPath != "" {
+		if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+			return nil, err
+		}
+		dsn = dbPath
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	st := &SessionStore{db: db}
+	if err := st.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return st, nil
+}
+
+// Close closes the database.
+func (st *SessionStore) Close() error {
+	if st == nil || st.db == nil {
+		return nil
+	}
+	return st.db.Close()
+}
+
+// Snapshot writes a consistent copy of the database to dst, e.g. for backups.
+func (st *SessionStore) Snapshot(dst string) error {
+	if st == nil {
+		return errors.New("no session database")
+	}
+	_, err := st.db.Exec("VACUUM INTO ?", dst)
+	return err
+}
+
+func (st *SessionStore) init() error {
+	stmts := []string{
+		"PRAGMA journal_mode=WAL;",
+		"PRAGMA busy_timeout=5000;",
+		`CREATE TABLE IF NOT EXISTS agent_sessions (
+			person TEXT NOT NULL,
+			session_id TEXT NOT NULL,
+			seq INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			runtime_mode TEXT NOT NULL DEFAULT '',
+			pinned INTEGER NOT NULL DEFAULT 0,
+			archived INTEGER NOT NULL DEFAULT 0,
+			created_at_utc TEXT NOT NULL,
+			last_used_at_utc TEXT NOT NULL,
+			PRIMARY KEY (person, session_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS agent_session_seq (
+			person TEXT PRIMARY KEY,
+			seq INTEGER NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS agent_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			person TEXT NOT NULL,
+			session_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			role TEXT NOT NULL DEFAULT '',
+			item_json TEXT NOT NULL
+		);`,
+		"CREATE INDEX IF NOT EXISTS idx_agent_items_session ON agent_items(person, session_id, id);",
+		// rowid matches agent_items.id.
+		`CREATE VIRTUAL TABLE IF NOT EXISTS agent_items_fts USING fts4(
+			content, person, session_id,
+			notindexed=person, notindexed=session_id, tokenize=unicode61
+		);`,
+	}
+	for _, stmt := range stmts {
+		if _, err := st.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// touch creates the session on first use and updates its last use afterwards.
+func (st *SessionStore) touch(person, sessionID, initialMessage, runtimeMode string, now time.Time) error {
+	if st == nil {
+		return nil
+	}
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE agent_sessions SET last_used_at_utc = ?,
+		runtime_mode = CASE WHEN runtime_mode = '' THEN ? ELSE runtime_mode END
+		WHERE person = ? AND session_id = ?`,
+		formatSessionTime(now), runtimeMode, person, sessionID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if err := insertSession(tx, person, sessionID, initialMessage, runtimeMode, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// addRecovered records a session found outside the database, e.g. a gateway
+// session file, unless it is already known.
+func (st *SessionStore) addRecovered(person, sessionID, initialMessage, runtimeMode string, at time.Time) error {
+	if st == nil {
+		return nil
+	}
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRow("SELECT 1 FROM agent_sessions WHERE person = ? AND session_id = ?", person, sessionID).Scan(&exists)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err := insertSession(tx, person, sessionID, initialMessage, runtimeMode, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertSession(tx *sql.Tx, person, sessionID, initialMessage, runtimeMode string, at time.Time) error {
+	if _, err := tx.Exec(`INSERT INTO agent_session_seq (person, seq) VALUES (?, 1)
+		ON CONFLICT(person) DO UPDATE SET seq = seq + 1`, person); err != nil {
+		return err
+	}
+	var seq int
+	if err := tx.QueryRow("SELECT seq FROM agent_session_seq WHERE person = ?", person).Scan(&seq); err != nil {
+		return err
+	}
+	ts := formatSessionTime(at)
+	_, err := tx.Exec(`INSERT INTO agent_sessions
+		(person, session_id, seq, name, runtime_mode, created_at_utc, last_used_at_utc)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		person, sessionID, seq, buildSessionName(initialMessage, seq), runtimeMode, ts, ts)
+	return err
+}
+
+// session returns one session.
+func (st *SessionStore) session(person, sessionID string) (*sessionRecord, error) {
+	if st == nil {
+		return nil, ErrSessionNotFound
+	}
+	rows, err := st.db.Query(sessionSelect+" WHERE s.person = ? AND s.session_id = ?", person, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := scanSessions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, ErrSessionNotFound
+	}
+	return &sessions[0].sessionRecord, nil
+}
+
+const sessionSelect = `SELECT s.session_id, s.person, s.seq, s.name, s.runtime_mode, s.pinned, s.archived,
+	s.created_at_utc, s.last_used_at_utc,
+	(SELECT COUNT(*) FROM agent_items i
+		WHERE i.person = s.person AND i.session_id = s.session_id AND i.type = 'message'),
+	COALESCE((SELECT i.item_json FROM agent_items i
+		WHERE i.person = s.person AND i.session_id = s.session_id AND i.type = 'message' AND i.role = 'assistant'
+		ORDER BY i.id DESC LIMIT 1), '')
+	FROM agent_sessions s`
+
+// list returns one page of the person's sessions, pinned first and then newest
+// first, with the total number of matches.
+func (st *SessionStore) list(person string, query SessionQuery) ([]storedSession, int, error) {
+	if st == nil {
+		return nil, 0, nil
+	}
+	where := " WHERE s.person = ?"
+	args := []any{person}
+	switch query.Archived {
+	case SessionFilterAll:
+	case SessionFilterArchived:
+		where += " AND s.archived = 1"
+	default:
+		where += " AND s.archived = 0"
+	}
+	match := ftsQuery(query.Search)
+	if strings.TrimSpace(query.Search) != "" {
+		where += ` AND (s.name LIKE ? ESCAPE '\'`
+		args = append(args, "%"+escapeLike(strings.TrimSpace(query.Search))+"%")
+		if match != "" {
+			where += " OR s.session_id IN (SELECT session_id FROM agent_items_fts WHERE agent_items_fts MATCH ? AND person = ?)"
+			args = append(args, match, person)
+		}
+		where += ")"
+	}
+
+	var total int
+	if err := st.db.QueryRow("SELECT COUNT(*) FROM agent_sessions s"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order := " ORDER BY s.pinned DESC, s.created_at_utc DESC, s.last_used_at_utc DESC"
+	pageArgs := append([]any(nil), args...)
+	if query.Limit > 0 {
+		order += " LIMIT ? OFFSET ?"
+		pageArgs = append(pageArgs, query.Limit, query.Offset)
+	}
+	rows, err := st.db.Query(sessionSelect+where+order, pageArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	sessions, err := scanSessions(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if match != "" {
+		for i := range sessions {
+			var snippet string
+			err := st.db.QueryRow(`SELECT snippet(agent_items_fts, '**', '**', '…', 0, 16) FROM agent_items_fts
+				WHERE agent_items_fts MATCH ? AND person = ? AND session_id = ? LIMIT 1`,
+				match, person, sessions[i].SessionID).Scan(&snippet)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return nil, 0, err
+			}
+			sessions[i].Snippet = normalizeSessionText(snippet)
+		}
+	}
+	return sessions, total, nil
+}
+
+func scanSessions(rows *sql.Rows) ([]storedSession, error) {
+	defer rows.Close()
+	var out []storedSession
+	for rows.Next() {
+		var rec storedSession
+		var created, lastUsed, lastAssistant string
+		if err := rows.Scan(&rec.SessionID, &rec.Person, &rec.Seq, &rec.Name, &rec.RuntimeMode, &rec.Pinned, &rec.Archived,
+			&created, &lastUsed, &rec.MessageCount, &lastAssistant); err != nil {
+			return nil, err
+		}
+		rec.CreatedAt = parseSessionTime(created)
+		rec.LastUsedAt = parseSessionTime(lastUsed)
+		if lastAssistant != "" {
+			var item ConversationItem
+			if err := json.Unmarshal([]byte(lastAssistant), &item); err == nil {
+				rec.LastAssistant = item.Content
+			}
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// update changes the name, pinned or archived state of a session.
+func (st *SessionStore) update(person, sessionID string, update SessionUpdate) error {
+	if st == nil {
+		return ErrSessionNotFound
+	}
+	sets := []string{}
+	args := []any{}
+	if update.Name != nil {
+		sets = append(sets, "name = ?")
+		args = append(args, *update.Name)
+	}
+	if update.Pinned != nil {
+		sets = append(sets, "pinned = ?")
+		args = append(args, *update.Pinned)
+	}
+	if update.Archived != nil {
+		sets = append(sets, "archived = ?")
+		args = append(args, *update.Archived)
+	}
+	if len(sets) == 0 {
+		_, err := st.session(person, sessionID)
+		return err
+	}
+	args = append(args, person, sessionID)
+	res, err := st.db.Exec("UPDATE agent_sessions SET "+strings.Join(sets, ", ")+" WHERE person = ? AND session_id = ?", args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// items returns the stored conversation items of a session in order.
+func (st *SessionStore) items(person, sessionID string) ([]ConversationItem, error) {
+	if st == nil {
+		return nil, nil
+	}
+	rows, err := st.db.Query("SELECT item_json FROM agent_items WHERE person = ? AND session_id = ? ORDER BY id",
+		person, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ConversationItem
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var item ConversationItem
+		if err := json.Unmarshal([]byte(data), &item); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+// hasItems reports whether any items are stored for the session.
+func (st *SessionStore) hasItems(person, sessionID string) (bool, error) {
+	if st == nil {
+		return false, nil
+	}
+	var exists int
+	err := st.db.QueryRow("SELECT 1 FROM agent_items WHERE person = ? AND session_id = ? LIMIT 1", person, sessionID).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// appendItems adds items to the end of a session's conversation.
+func (st *SessionStore) appendItems(person, sessionID string, items []ConversationItem) error {
+	if st == nil {
+		return nil
+	}
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := insertItems(tx, person, sessionID, items); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// replaceItems swaps a session's whole conversation.
+func (st *SessionStore) replaceItems(person, sessionID string, items []ConversationItem) error {
+	if st == nil {
+		return nil
+	}
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := deleteItems(tx, "person = ? AND session_id = ?", person, sessionID); err != nil {
+		return err
+	}
+	if err := insertItems(tx, person, sessionID, items); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertItems(tx *sql.Tx, person, sessionID string, items []ConversationItem) error {
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		res, err := tx.Exec("INSERT INTO agent_items (person, session_id, type, role, item_json) VALUES (?, ?, ?, ?, ?)",
+			person, sessionID, item.Type, item.Role, string(data))
+		if err != nil {
+			return err
+		}
+		if item.Type != ConversationItemMessage || strings.TrimSpace(item.Content) == "" {
+			continue
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO agent_items_fts (rowid, content, person, session_id) VALUES (?, ?, ?, ?)",
+			id, item.Content, person, sessionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteItems(tx *sql.Tx, where string, args ...any) error {
+	if _, err := tx.Exec("DELETE FROM agent_items_fts WHERE rowid IN (SELECT id FROM agent_items WHERE "+where+")", args...); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DELETE FROM agent_items WHERE "+where, args...)
+	return err
+}
+
+// remove deletes a session and its conversation.
+func (st *SessionStore) remove(person, sessionID string) error {
+	if st == nil {
+		return nil
+	}
+	tx, err := st.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := deleteItems(tx, "person = ? AND session_id = ?", person, sessionID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM agent_sessions WHERE person = ? AND session_id = ?", person, sessionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// removeAll deletes all of a person's sessions and returns what was removed.
+// The sequence keeps counting so new sessions get fresh default names.
+func (st *SessionStore) removeAll(person string) ([]sessionRecord, error) {
+	if st == nil {
+		return nil, nil
+	}
+	sessions, _, err := st.list(person, SessionQuery{Archived: SessionFilterAll})
+	if err != nil {
+		return nil, err
+	}
+	tx, err := st.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := deleteItems(tx, "person = ?", person); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM agent_sessions WHERE person = ?", person); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	out := make([]sessionRecord, 0, len(sessions))
+	for _, session := range sessions {
+		out = append(out, session.sessionRecord)
+	}
+	return out, nil
+}
+
+func formatSessionTime(t time.Time) string {
+	return t.UTC().Format(sessionTimeLayout)
+}
+
+func parseSessionTime(value string) time.Time {
+	t, err := time.Parse(sessionTimeLayout, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// ftsQuery turns free text into an FTS query that matches every word as a
+// prefix. Operators and punctuation are dropped.
+func ftsQuery(search string) string {
+	words := strings.FieldsFunc(search, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, `"`+word+`*"`)
+	}
+	return strings.Join(terms, " ")
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,171 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"notes-editor/internal/vault"
+)
+
+func newSessionStoreService(t *testing.T, dbPath string) *Service {
+	t.Helper()
+	t.Setenv("PI_GATEWAY_PI_SESSION_DIR", t.TempDir())
+	sessions, err := OpenSessionStore(dbPath)
+	if err != nil {
+		t.Fatalf("OpenSessionStore() error = %v", err)
+	}
+	t.Cleanup(func() { sessions.Close() })
+	return NewServiceWithRuntimesAndOptions(vault.NewStore(t.TempDir()), map[string]Runtime{
+		RuntimeModeAnthropicAPIKey: &stubRuntime{mode: RuntimeModeAnthropicAPIKey, available: false},
+	}, ServiceOptions{Sessions: sessions})
+}
+
+func TestSessionStoreSurvivesRestart(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "agent.db")
+	svc := newSessionStoreService(t, dbPath)
+	svc.touchSession("petra", "first", "", RuntimeModeGatewaySubscription)
+	svc.touchSession("petra", "second", "Plan the garden", RuntimeModeAnthropicAPIKey)
+	svc.appendStoredConversation("petra", "second", []ConversationItem{
+		{Type: ConversationItemMessage, Role: "user", Content: "Plan the garden"},
+		{Type: ConversationItemMessage, Role: "assistant", Content: "Start with tomatoes."},
+	})
+
+	restarted := newSessionStoreService(t, dbPath)
+	sessions, err := restarted.ListSessions("petra")
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("sessions = %+v", sessions)
+	}
+	names := map[string]string{}
+	for _, session := range sessions {
+		names[session.SessionID] = session.Name
+	}
+	if names["first"] != "Session 1" || names["second"] != "Plan the garden" {
+		t.Fatalf("names = %v", names)
+	}
+	if mode, ok := restarted.runtimeModeForSession("petra", "first"); !ok || mode != RuntimeModeGatewaySubscription {
+		t.Fatalf("runtime mode = %q, %v", mode, ok)
+	}
+
+	// The sequence continues after a restart.
+	restarted.touchSession("petra", "third", "", RuntimeModeAnthropicAPIKey)
+	record, err := restarted.sessions.session("petra", "third")
+	if err != nil || record.Name != "Session 3" {
+		t.Fatalf("third session = %+v, %v", record, err)
+	}
+}
+
+func TestQuerySessionsSearchPinArchiveAndPaging(t *testing.T) {
+	svc := newSessionStoreService(t, "")
+	for _, id := range []string{"a", "b", "c", "d"} {
+		svc.touchSession("sebastian", id, "Chat "+id, RuntimeModeAnthropicAPIKey)
+	}
+	svc.appendStoredConversation("sebastian", "b", []ConversationItem{
+		{Type: ConversationItemMessage, Role: "user", Content: "Wie lange braucht der Sauerteig?"},
+		{Type: ConversationItemMessage, Role: "assistant", Content: "Der Sauerteig braucht etwa zwölf Stunden."},
+	})
+	svc.touchSession("petra", "p", "Sauerteig", RuntimeModeAnthropicAPIKey)
+
+	pinned, archived := true, true
+	name := "  Bread   baking "
+	if _, err := svc.UpdateSession("sebastian", "b", SessionUpdate{Name: &name, Pinned: &pinned}); err != nil {
+		t.Fatalf("UpdateSession() error = %v", err)
+	}
+	if _, err := svc.UpdateSession("sebastian", "c", SessionUpdate{Archived: &archived}); err != nil {
+		t.Fatalf("UpdateSession() error = %v", err)
+	}
+	if _, err := svc.UpdateSession("sebastian", "p", SessionUpdate{Pinned: &pinned}); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("UpdateSession(other person's session) error = %v", err)
+	}
+
+	page, err := svc.QuerySessions("sebastian", SessionQuery{Limit: 2})
+	if err != nil {
+		t.Fatalf("QuerySessions() error = %v", err)
+	}
+	if page.Total != 3 || len(page.Sessions) != 2 || page.NextOffset == nil || *page.NextOffset != 2 {
+		t.Fatalf("first page = %+v", page)
+	}
+	first := page.Sessions[0]
+	if first.SessionID != "b" || first.Name != "Bread baking" || !first.Pinned || first.MessageCount != 2 ||
+		first.LastPreview != "Der Sauerteig braucht etwa zwölf Stunden." {
+		t.Fatalf("pinned session = %+v", first)
+	}
+	page, err = svc.QuerySessions("sebastian", SessionQuery{Limit: 2, Offset: 2})
+	if err != nil || len(page.Sessions) != 1 || page.NextOffset != nil {
+		t.Fatalf("second page = %+v, %v", page, err)
+	}
+
+	page, err = svc.QuerySessions("sebastian", SessionQuery{Archived: SessionFilterArchived})
+	if err != nil || len(page.Sessions) != 1 || page.Sessions[0].SessionID != "c" || !page.Sessions[0].Archived {
+		t.Fatalf("archived = %+v, %v", page, err)
+	}
+
+	page, err = svc.QuerySessions("sebastian", SessionQuery{Search: "sauert zwölf"})
+	if err != nil || len(page.Sessions) != 1 || page.Sessions[0].SessionID != "b" {
+		t.Fatalf("search = %+v, %v", page, err)
+	}
+	if !strings.Contains(page.Sessions[0].Snippet, "**") {
+		t.Errorf("snippet = %q", page.Sessions[0].Snippet)
+	}
+	page, err = svc.QuerySessions("sebastian", SessionQuery{Search: "chat d"})
+	if err != nil || len(page.Sessions) != 1 || page.Sessions[0].SessionID != "d" {
+		t.Fatalf("name search = %+v, %v", page, err)
+	}
+	if page, err = svc.QuerySessions("sebastian", SessionQuery{Search: `"OR*`}); err != nil || page.Total != 0 {
+		t.Fatalf("search with operators = %+v, %v", page, err)
+	}
+}
+
+func TestLegacyConversationFilesAreImported(t *testing.T) {
+	svc := newSessionStoreService(t, "")
+	legacy := `[{"type":"message","role":"user","content":"Old question","ts":"2026-03-01T10:00:00Z"},` +
+		`{"type":"tool_call","tool":"read_file"},` +
+		`{"type":"message","role":"assistant","content":"Old answer"}]`
+	if err := svc.store.WriteFile("sebastian", storedConversationFilePath("old-session"), legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := svc.ListSessions("sebastian")
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Name != "Old question" || sessions[0].MessageCount != 2 ||
+		sessions[0].CreatedAt.Format("2006-01-02") != "2026-03-01" {
+		t.Fatalf("sessions = %+v", sessions)
+	}
+	items, err := svc.GetConversationHistory("sebastian", "old-session")
+	if err != nil || len(items) != 3 || items[1].Tool != "read_file" {
+		t.Fatalf("items = %+v, %v", items, err)
+	}
+	path := filepath.Join(svc.store.RootPath(), "sebastian", filepath.FromSlash(storedConversationFilePath("old-session")))
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("legacy file kept after import: %v", err)
+	}
+}
+
+func TestServiceWithoutSessionStore(t *testing.T) {
+	t.Setenv("PI_GATEWAY_PI_SESSION_DIR", t.TempDir())
+	svc := NewServiceWithRuntimes(vault.NewStore(t.TempDir()), map[string]Runtime{
+		RuntimeModeAnthropicAPIKey: &stubRuntime{mode: RuntimeModeAnthropicAPIKey, available: false},
+	})
+	// The fallback when no session database can be opened keeps nothing.
+	svc.sessions = nil
+
+	svc.touchSession("petra", "first", "Plan the garden", RuntimeModeAnthropicAPIKey)
+	svc.appendStoredConversation("petra", "first", []ConversationItem{{Type: ConversationItemMessage, Role: "user", Content: "hi"}})
+	if sessions, err := svc.ListSessions("petra"); err != nil || len(sessions) != 0 {
+		t.Fatalf("ListSessions() = %+v, %v", sessions, err)
+	}
+	name := "Garden"
+	if _, err := svc.UpdateSession("petra", "first", SessionUpdate{Name: &name}); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("UpdateSession() error = %v, want ErrSessionNotFound", err)
+	}
+	if err := svc.ClearAllSessions("petra"); err != nil {
+		t.Fatalf("ClearAllSessions() error = %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"notes-editor/internal/fileutil"
+)
+
+// toolPolicyStore keeps each person's tool policies in a local file outside the
+// synced vault, where the agent's file tools cannot change them. Without a path
+// the policies live in memory.
+type toolPolicyStore struct {
+	mu     sync.Mutex
+	path   string
+	memory map[string]map[string]string
+}
+
+func newToolPolicyStore(path string) *toolPolicyStore {
+	return &toolPolicyStore{path: path, memory: make(map[string]map[string]string)}
+}
+
+// get returns the person's policies; unlisted tools run automatically.
+func (t *toolPolicyStore) get(person string) (map[string]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	all, err := t.load()
+	if err != nil {
+		return nil, err
+	}
+	policies := make(map[string]string, len(all[person]))
+	for tool, policy := range all[person] {
+		policies[tool] = policy
+	}
+	return policies, nil
+}
+
+// set replaces the person's policies.
+func (t *toolPolicyStore) set(person string, policies map[string]string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	all, err := t.load()
+	if err != nil {
+		return err
+	}
+	if len(policies) == 0 {
+		delete(all, person)
+	} else {
+		all[person] = policies
+	}
+	return t.save(all)
+}
+
+func (t *toolPolicyStore) load() (map[string]map[string]string, error) {
+	if t.path == "" {
+		return t.memory, nil
+	}
+	all := make(map[string]map[string]string)
+	data, err := os.ReadFile(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+func (t *toolPolicyStore) save(all map[string]map[string]string) error {
+	if t.path == "" {
+		t.memory = all
+		return nil
+	}
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteAtomic(t.path, append(data, '\n'), 0600)
+}
@@ -0,0 +1,209 @@
+package agent
+
+import (
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"notes-editor/internal/claude"
+)
+
+// Event kinds that can trigger actions.
+const (
+	// EventFileCreated fires when a new file is written to a person's vault.
+	EventFileCreated = "file_created"
+	// EventDailyCreated fires when a daily note is created.
+	EventDailyCreated = "daily_created"
+	// EventCapture fires when an entry or todo is captured into a note.
+	EventCapture = "capture"
+	// EventSleepLogged fires when a sleep entry is added.
+	EventSleepLogged = "sleep_logged"
+)
+
+// defaultTriggerDebounce batches bursts of events into one run.
+const defaultTriggerDebounce = 10 * time.Second
+
+// maxTriggerPaths bounds the paths listed in one triggered prompt.
+const maxTriggerPaths = 50
+
+// Event is a vault change that may trigger actions.
+type Event struct {
+	Kind string
+	// Person owns the change; empty means every person (e.g. the shared sleep log).
+	Person string
+	// Path is the person-relative file the event is about, if any.
+	Path string
+	// Source identifies the writer: an agent run ID, claude.AgentWriteSource
+	// for agent writes outside a known run, or empty for the person's own edits.
+	Source string
+}
+
+func isEventKind(kind string) bool {
+	switch kind {
+	case EventFileCreated, EventDailyCreated, EventCapture, EventSleepLogged:
+		return true
+	}
+	return false
+}
+
+// triggerEvent collects the events of one debounced triggered run.
+type triggerEvent struct {
+	kind  string
+	paths []string
+}
+
+func (t *triggerEvent) addPath(p string) {
+	if p == "" || len(t.paths) >= maxTriggerPaths {
+		return
+	}
+	for _, existing := range t.paths {
+		if existing == p {
+			return
+		}
+	}
+	t.paths = append(t.paths, p)
+}
+
+// pendingTrigger is a triggered run waiting for its debounce to pass.
+type pendingTrigger struct {
+	action Action
+	event  *triggerEvent
+	timer  *time.Timer
+}
+
+// Notify reports a vault change. Actions triggered by it run after their
+// debounce, in the person's scheduled session. It does not block and may be
+// called with the server lock held.
+func (s *Scheduler) Notify(event Event) {
+	// Snapshot the running actions now: the event may come from one of them.
+	s.mu.Lock()
+	own := ownWrites{running: make(map[string]string, len(s.current)), source: s.runs[event.Source]}
+	for person, actionID := range s.current {
+		own.running[person] = actionID
+	}
+	s.mu.Unlock()
+	go s.notify(event, own)
+}
+
+// ownWrites identifies the actions an event may come from.
+type ownWrites struct {
+	// running maps each person to the action their worker was running.
+	running map[string]string
+	// source is the action whose scheduled run made the change, if known.
+	source string
+}
+
+func (s *Scheduler) notify(event Event, own ownWrites) {
+	svc := s.opts.Service()
+	if svc == nil {
+		return
+	}
+	persons := []string{event.Person}
+	if event.Person == "" {
+		persons = s.opts.Persons()
+	}
+	for _, person := range persons {
+		actions, err := svc.ListActions(person)
+		if err != nil {
+			log.Printf("triggers: list actions for %s: %v", person, err)
+			continue
+		}
+		for _, action := range actions {
+			if triggeredBy(action, event) && !isOwnWrite(action, event, own.source, own.running[person]) {
+				s.debounce(person, action, event)
+			}
+		}
+	}
+}
+
+// triggeredBy reports whether the action listens to the event.
+func triggeredBy(action Action, event Event) bool {
+	listens := false
+	for _, kind := range action.Metadata.Triggers {
+		if kind == event.Kind {
+			listens = true
+		}
+	}
+	if !listens {
+		return false
+	}
+	if event.Kind != EventFileCreated {
+		return true
+	}
+	// The agent's own files (sessions, run history, default outputs) never count.
+	p := path.Clean(event.Path)
+	if p == "agent" || strings.HasPrefix(p, "agent/") {
+		return false
+	}
+	if len(action.Metadata.Watch) == 0 {
+		return true
+	}
+	for _, folder := range action.Metadata.Watch {
+		if folder == "." || p == folder || strings.HasPrefix(p, folder+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// isOwnWrite reports events an action caused itself: writes by its runs and
+// writes to its output file. Agent writes not tied to a known run count as
+// the running action's; the person's own writes never do.
+func isOwnWrite(action Action, event Event, sourceActionID, runningActionID string) bool {
+	if event.Path != "" && path.Clean(event.Path) == outputPath(action) {
+		return true
+	}
+	if sourceActionID != "" {
+		return sourceActionID == action.ID
+	}
+	return event.Source == claude.AgentWriteSource && runningActionID == action.ID
+}
+
+// debounce schedules a triggered run, pushing it back while events keep coming.
+func (s *Scheduler) debounce(person string, action Action, event Event) {
+	delay := defaultTriggerDebounce
+	if action.Metadata.Debounce != "" {
+		if d, err := time.ParseDuration(action.Metadata.Debounce); err == nil {
+			delay = d
+		}
+	}
+
+	key := person + "::" + action.ID
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if pending, ok := s.pending[key]; ok {
+		pending.event.addPath(event.Path)
+		pending.timer.Reset(delay)
+		return
+	}
+	pending := &pendingTrigger{action: action, event: &triggerEvent{kind: event.Kind}}
+	pending.event.addPath(event.Path)
+	pending.timer = time.AfterFunc(delay, func() { s.fireTrigger(person, key) })
+	s.pending[key] = pending
+}
+
+func (s *Scheduler) fireTrigger(person, key string) {
+	s.mu.Lock()
+	pending, ok := s.pending[key]
+	delete(s.pending, key)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	s.enqueue(person, queuedRun{action: pending.action, scheduledAt: s.now(), trigger: pending.event})
+	s.startWorker(person)
+}
+
+// triggerMessage describes the events of a triggered run for the prompt.
+func triggerMessage(trigger *triggerEvent, at time.Time) string {
+	var b strings.Builder
+	b.WriteString("Triggered by " + trigger.kind + " at " + at.Format("Monday, 2006-01-02 15:04") + ".")
+	if len(trigger.paths) > 0 {
+		b.WriteString("\nFiles:")
+		for _, p := range trigger.paths {
+			b.WriteString("\n- " + p)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,156 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"notes-editor/internal/claude"
+)
+
+// waitRequests waits until the runtime received n requests.
+func waitRequests(t *testing.T, rt *scheduleRuntime, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		rt.mu.Lock()
+		got := len(rt.requests)
+		rt.mu.Unlock()
+		if got >= n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("runtime did not receive %d requests", n)
+}
+
+func TestSchedulerRunsTriggeredActions(t *testing.T) {
+	scheduler, _, rt, _ := newTestScheduler(t, map[string]string{
+		"Inbox.prompt.md": "---\ntrigger: file_created\nwatch: inbox\ndebounce: 50ms\n---\nFile the new notes.",
+	})
+
+	scheduler.Notify(Event{Kind: EventFileCreated, Person: "sebastian", Path: "inbox/a.md"})
+	scheduler.Notify(Event{Kind: EventFileCreated, Person: "sebastian", Path: "inbox/b.md"})
+	waitRequests(t, rt, 1)
+	waitIdle(t, scheduler, "sebastian")
+
+	message := rt.requests[0].Message
+	if !strings.HasPrefix(message, "File the new notes.") || !strings.Contains(message, "Triggered by file_created") ||
+		!strings.Contains(message, "\n- inbox/a.md") || !strings.Contains(message, "\n- inbox/b.md") {
+		t.Fatalf("message = %q", message)
+	}
+	status, err := scheduler.Status("sebastian")
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if len(status.Actions) != 1 || len(status.Runs) != 1 || status.Runs[0].Trigger != EventFileCreated || len(status.Runs[0].Paths) != 2 {
+		t.Fatalf("status = %+v", status)
+	}
+}
+
+func TestTriggeredBy(t *testing.T) {
+	action := Action{ID: "inbox", Metadata: ActionMetadata{Triggers: []string{EventFileCreated, EventCapture}, Watch: []string{"inbox"}}}
+	tests := []struct {
+		event Event
+		want  bool
+	}{
+		{Event{Kind: EventFileCreated, Path: "inbox/a.md"}, true},
+		{Event{Kind: EventFileCreated, Path: "inboxes/a.md"}, false},
+		{Event{Kind: EventFileCreated, Path: "notes/a.md"}, false},
+		{Event{Kind: EventCapture, Path: "daily/2026-10-19.md"}, true},
+		{Event{Kind: EventSleepLogged}, false},
+	}
+	for _, tt := range tests {
+		if got := triggeredBy(action, tt.event); got != tt.want {
+			t.Errorf("triggeredBy(%+v) = %v, want %v", tt.event, got, tt.want)
+		}
+	}
+
+	all := Action{ID: "all", Metadata: ActionMetadata{Triggers: []string{EventFileCreated}}}
+	if !triggeredBy(all, Event{Kind: EventFileCreated, Path: "notes/a.md"}) {
+		t.Error("action without watch folders should see every file")
+	}
+	if triggeredBy(all, Event{Kind: EventFileCreated, Path: "agent/scheduled/all.md"}) {
+		t.Error("agent files must not trigger actions")
+	}
+}
+
+func TestSchedulerIgnoresOwnWrites(t *testing.T) {
+	scheduler, _, _, _ := newTestScheduler(t, map[string]string{
+		"Journal.prompt.md": "---\ntrigger: file_created\noutput: journal/log.md\ndebounce: 1h\n---\nSummarize.",
+	})
+	running := ownWrites{running: map[string]string{"sebastian": "journal"}}
+
+	// Writes to the action's output file and writes by its runs are ignored.
+	scheduler.notify(Event{Kind: EventFileCreated, Person: "sebastian", Path: "journal/log.md"}, ownWrites{})
+	scheduler.notify(Event{Kind: EventFileCreated, Person: "sebastian", Path: "notes/a.md", Source: "run-1"}, ownWrites{source: "journal"})
+	scheduler.notify(Event{Kind: EventFileCreated, Person: "sebastian", Path: "notes/a.md", Source: claude.AgentWriteSource}, running)
+	scheduler.mu.Lock()
+	pending := len(scheduler.pending)
+	scheduler.mu.Unlock()
+	if pending != 0 {
+		t.Fatalf("pending triggers = %d, want 0", pending)
+	}
+
+	// The person's own writes count even while the action runs.
+	scheduler.notify(Event{Kind: EventFileCreated, Person: "sebastian", Path: "notes/a.md"}, running)
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	if len(scheduler.pending) != 1 {
+		t.Fatalf("pending triggers = %d, want 1", len(scheduler.pending))
+	}
+	for _, p := range scheduler.pending {
+		p.timer.Stop()
+	}
+}
+
+func TestIsOwnWrite(t *testing.T) {
+	action := Action{ID: "journal"}
+	tests := []struct {
+		name            string
+		source          string
+		sourceAction    string
+		runningActionID string
+		want            bool
+	}{
+		{"person while running", "", "", "journal", false},
+		{"own run", "run-1", "journal", "", true},
+		{"other action's run", "run-2", "other", "journal", false},
+		{"interactive chat while running", "chat-run", "", "journal", false},
+		{"unknown agent run while running", claude.AgentWriteSource, "", "journal", true},
+		{"unknown agent run while idle", claude.AgentWriteSource, "", "", false},
+	}
+	for _, tt := range tests {
+		event := Event{Kind: EventFileCreated, Path: "notes/a.md", Source: tt.source}
+		if got := isOwnWrite(action, event, tt.sourceAction, tt.runningActionID); got != tt.want {
+			t.Errorf("%s: isOwnWrite() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEnqueueDoesNotMergeIntoStartedRuns(t *testing.T) {
+	scheduler := NewScheduler(SchedulerOptions{})
+	action := Action{ID: "inbox"}
+	scheduler.enqueue("sebastian", queuedRun{action: action, trigger: &triggerEvent{kind: EventFileCreated, paths: []string{"inbox/a.md"}}})
+	scheduler.queues["sebastian"][0].started = true
+
+	scheduler.enqueue("sebastian", queuedRun{action: action, trigger: &triggerEvent{kind: EventFileCreated, paths: []string{"inbox/b.md"}}})
+	scheduler.enqueue("sebastian", queuedRun{action: action, trigger: &triggerEvent{kind: EventFileCreated, paths: []string{"inbox/c.md"}}})
+
+	queue := scheduler.queues["sebastian"]
+	if len(queue) != 2 {
+		t.Fatalf("queue length = %d, want 2", len(queue))
+	}
+	if got := queue[0].trigger.paths; len(got) != 1 {
+		t.Fatalf("started run paths = %v", got)
+	}
+	if got := queue[1].trigger.paths; len(got) != 2 || got[0] != "inbox/b.md" || got[1] != "inbox/c.md" {
+		t.Fatalf("waiting run paths = %v", got)
+	}
+
+	// Dequeuing the started run keeps the paths that arrived meanwhile.
+	scheduler.dequeue("sebastian", queue[0])
+	if queue := scheduler.queues["sebastian"]; len(queue) != 1 || len(queue[0].trigger.paths) != 2 {
+		t.Fatalf("queue after dequeue = %+v", queue)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -14,21 +15,43 @@ import (
 	"notes-editor/internal/claude"
 )
 
+// AgentApprovalRequest approves or denies a pending tool call.
+type AgentApprovalRequest struct {
+	ApprovalID string `json:"approval_id"`
+	Approve    bool   `json:"approve"`
+}
+
 // StopRunRequest is the request body for stopping an active agent run.
 type StopRunRequest struct {
 	RunID string `json:"run_id"`
 }
 
+// Page sizes for GET /api/agent/sessions.
+const (
+	defaultAgentSessionsLimit = 100
+	maxAgentSessionsLimit     = 500
+)
+
+// AgentSessionUpdateRequest changes a session's name, pinned or archived state.
+type AgentSessionUpdateRequest struct {
+	SessionID string `json:"session_id"`
+	agent.SessionUpdate
+}
+
 // AgentActionRunRequest controls action execution behavior.
 type AgentActionRunRequest struct {
 	SessionID string `json:"session_id,omitempty"`
 	Message   string `json:"message,omitempty"`
 	Confirm   bool   `json:"confirm,omitempty"`
+	// Params fills in the action's declared parameters.
+	Params map[string]string `json:"params,omitempty"`
 }
 
 type AgentToolExecuteRequest struct {
 	Tool string         `json:"tool"`
 	Args map[string]any `json:"args"`
+	// RunID optionally ties the call to an agent run in the audit log.
+	RunID string `json:"run_id,omitempty"`
 }
 
 type AgentToolExecuteResponse struct {
@@ -124,27 +147,84 @@ func (s *Server) handleAgentChatStream(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	for event := range run.Events {
+	if !writeAgentEvents(w, flusher, run.Events) {
+		go func() {
+			for range run.Events {
+			}
+		}()
+	}
+}
+
+// handleAgentRunStream reattaches to a run's event stream. It replays buffered
+// events after after_seq and then follows the run until it ends.
+func (s *Server) handleAgentRunStream(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+
+	agentSvc := s.getAgent()
+	if agentSvc == nil {
+		writeBadRequest(w, "Agent service not configured")
+		return
+	}
+
+	afterSeq := 0
+	if raw := r.URL.Query().Get("after_seq"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			writeBadRequest(w, "Invalid after_seq")
+			return
+		}
+		afterSeq = n
+	}
+
+	run, err := agentSvc.AttachRun(r.Context(), person, chi.URLParam(r, "id"), afterSeq)
+	if err != nil {
+		switch {
+		case errors.Is(err, agent.ErrRunNotFound):
+			writeNotFound(w, "Run not found")
+		case errors.Is(err, agent.ErrRunEventsExpired):
+			writeError(w, http.StatusGone, err.Error())
+		default:
+			writeBadRequest(w, err.Error())
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Transfer-Encoding", "chunked")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeBadRequest(w, "Streaming not supported")
+		return
+	}
+
+	// The attached stream stops with the request context, so there is nothing
+	// to drain when the client goes away.
+	writeAgentEvents(w, flusher, run.Events)
+}
+
+// writeAgentEvents writes events as NDJSON lines until the channel closes. It
+// returns false when the client went away.
+func writeAgentEvents(w http.ResponseWriter, flusher http.Flusher, events <-chan agent.StreamEvent) bool {
+	for event := range events {
 		data, err := json.Marshal(event)
 		if err != nil {
 			continue
 		}
 		if _, err := w.Write(data); err != nil {
-			go func() {
-				for range run.Events {
-				}
-			}()
-			return
+			return false
 		}
 		if _, err := w.Write([]byte("\n")); err != nil {
-			go func() {
-				for range run.Events {
-				}
-			}()
-			return
+			return false
 		}
 		flusher.Flush()
 	}
+	return true
 }
 
 // handleAgentSessionClear clears a chat session.
@@ -242,15 +322,73 @@ func (s *Server) handleAgentSessionsList(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	sessions, err := agentSvc.ListSessions(person)
+	q := r.URL.Query()
+	query := agent.SessionQuery{
+		Search:   q.Get("q"),
+		Archived: q.Get("archived"),
+		Limit:    defaultAgentSessionsLimit,
+	}
+	switch query.Archived {
+	case "", agent.SessionFilterActive, agent.SessionFilterArchived, agent.SessionFilterAll:
+	default:
+		writeBadRequest(w, "Invalid archived filter")
+		return
+	}
+	for key, dst := range map[string]*int{"limit": &query.Limit, "offset": &query.Offset} {
+		if raw := q.Get(key); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				writeBadRequest(w, "Invalid "+key)
+				return
+			}
+			*dst = n
+		}
+	}
+	if query.Limit <= 0 || query.Limit > maxAgentSessionsLimit {
+		query.Limit = maxAgentSessionsLimit
+	}
+
+	page, err := agentSvc.QuerySessions(person, query)
 	if err != nil {
 		writeBadRequest(w, err.Error())
 		return
 	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// handleAgentSessionUpdate renames, pins or archives a session.
+func (s *Server) handleAgentSessionUpdate(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
 
-	writeJSON(w, http.StatusOK, map[string]any{
-		"sessions": sessions,
-	})
+	agentSvc := s.getAgent()
+	if agentSvc == nil {
+		writeBadRequest(w, "Agent service not configured")
+		return
+	}
+
+	var req AgentSessionUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, "Invalid request body")
+		return
+	}
+	if req.SessionID == "" {
+		writeBadRequest(w, "Session ID is required")
+		return
+	}
+
+	session, err := agentSvc.UpdateSession(person, req.SessionID, req.SessionUpdate)
+	if err != nil {
+		if errors.Is(err, agent.ErrSessionNotFound) {
+			writeNotFound(w, err.Error())
+			return
+		}
+		writeBadRequest(w, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
 }
 
 // handleAgentActiveRunsList returns person-scoped currently running agent streams.
@@ -350,6 +488,40 @@ func (s *Server) handleAgentStopRun(w http.ResponseWriter, r *http.Request) {
 	writeSuccess(w, "Run stopped")
 }
 
+// handleAgentApproval approves or denies a tool call waiting for approval.
+func (s *Server) handleAgentApproval(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+
+	agentSvc := s.getAgent()
+	if agentSvc == nil {
+		writeBadRequest(w, "Agent service not configured")
+		return
+	}
+
+	var req AgentApprovalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, "Invalid request body")
+		return
+	}
+	if req.ApprovalID == "" {
+		writeBadRequest(w, "Approval ID is required")
+		return
+	}
+
+	if err := agentSvc.ResolveApproval(person, req.ApprovalID, req.Approve); err != nil {
+		writeNotFound(w, "Approval not found")
+		return
+	}
+	if req.Approve {
+		writeSuccess(w, "Tool call approved")
+		return
+	}
+	writeSuccess(w, "Tool call denied")
+}
+
 // handleAgentConfigGet returns per-person agent config including prompt content.
 func (s *Server) handleAgentConfigGet(w http.ResponseWriter, r *http.Request) {
 	person, ok := requirePerson(w, r)
@@ -418,6 +590,21 @@ func (s *Server) handleAgentActionsList(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// handleAgentSchedule returns the person's scheduled actions and their recent runs.
+func (s *Server) handleAgentSchedule(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := s.scheduler.Status(person)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
 // handleAgentToolExecute executes a canonical tool call in a person-scoped context.
 // Intended for the local gateway sidecar to delegate tool execution back to the Go server.
 func (s *Server) handleAgentToolExecute(w http.ResponseWriter, r *http.Request) {
@@ -439,7 +626,18 @@ func (s *Server) handleAgentToolExecute(w http.ResponseWriter, r *http.Request)
 		req.Args = map[string]any{}
 	}
 
+	s.mu.RLock()
+	sandboxPolicy, sleepLog := bashSandboxPolicy(s.config), s.agentSleepLog()
+	s.mu.RUnlock()
+
 	toolExec := claude.NewToolExecutor(s.store, s.getLinkedIn(), person)
+	toolExec.Observe(req.RunID, auditToolObserver(s.audit))
+	if agentSvc := s.getAgent(); agentSvc != nil {
+		toolExec.RequireApproval(agentSvc.AuthorizeToolCall)
+	}
+	toolExec.Sandbox(sandboxPolicy)
+	toolExec.Synchronize(&s.mu, s.syncMgr.TriggerPush)
+	toolExec.AccessSleepLog(sleepLog)
 	content, err := toolExec.ExecuteTool(req.Tool, req.Args)
 	if err != nil {
 		writeJSON(w, http.StatusOK, AgentToolExecuteResponse{
@@ -487,6 +685,7 @@ func (s *Server) handleAgentActionRun(w http.ResponseWriter, r *http.Request) {
 		ActionID:  id,
 		Message:   req.Message,
 		Confirm:   req.Confirm,
+		Params:    req.Params,
 	})
 	if err != nil {
 		if errors.Is(err, agent.ErrSessionBusy) {
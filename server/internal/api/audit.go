@@ -0,0 +1,237 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"notes-editor/internal/audit"
+	"notes-editor/internal/auth"
+	"notes-editor/internal/claude"
+)
+
+// maxAuditPeek bounds how much of a JSON request body is inspected for the
+// audited target path.
+const maxAuditPeek = 64 << 10
+
+// auditFields are the request body fields the audit log records.
+type auditFields struct {
+	Path   string `json:"path"`
+	ID     string `json:"id"`
+	Person string `json:"person"`
+	// Values holds settings changes; only the keys are recorded.
+	Values map[string]json.RawMessage `json:"values"`
+}
+
+// auditCallerKey holds the *context.Context that AuditCallerMiddleware fills with
+// the authenticated request context.
+type auditCallerKey struct{}
+
+// AuditMiddleware records every mutating request (anything but GET, HEAD and
+// OPTIONS) with its caller, route, target and status. A nil log disables it.
+//
+// It runs before authentication so rejected requests are recorded too; the caller
+// is taken from AuditCallerMiddleware further down the chain.
+func AuditMiddleware(auditLog *audit.Log) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if auditLog == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isSafeMethod(r.Method) || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			fields := peekAuditFields(r)
+			wrapped := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+			caller := r.Context()
+			next.ServeHTTP(wrapped, r.WithContext(context.WithValue(r.Context(), auditCallerKey{}, &caller)))
+
+			entry := audit.Entry{
+				Kind:       audit.KindRequest,
+				Person:     auth.PersonFromContext(caller),
+				UserAgent:  r.UserAgent(),
+				Method:     r.Method,
+				Route:      r.URL.Path,
+				Target:     fields.Path,
+				Status:     wrapped.status,
+				DurationMS: time.Since(start).Milliseconds(),
+			}
+			// Requests rejected before routing finished only matched a catch-all.
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" && !strings.HasSuffix(rctx.RoutePattern(), "*") {
+				entry.Route = rctx.RoutePattern()
+			}
+			// A person named in the body is only trusted from an authenticated caller,
+			// e.g. the admin creating a token. Otherwise, as for a login, it is the
+			// claimed name and recorded as the target.
+			claimed := ""
+			if p, ok := auth.PrincipalFromContext(caller); ok {
+				entry.Client = clientName(p)
+				if entry.Person == "" {
+					entry.Person = fields.Person
+				}
+			} else {
+				claimed = fields.Person
+			}
+			if entry.Target == "" {
+				entry.Target = firstNonEmpty(r.URL.Query().Get("path"), fields.ID, chi.URLParam(r, "id"), settingKeys(fields.Values), claimed)
+			}
+			if err := auditLog.Record(entry); err != nil {
+				log.Printf("audit: %v", err)
+			}
+		})
+	}
+}
+
+// AuditCallerMiddleware passes the authenticated caller back to AuditMiddleware.
+// It belongs after AuthMiddleware and PersonMiddleware.
+func AuditCallerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setAuditCaller(r, r.Context())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setAuditCaller records ctx, which carries the authenticated principal and
+// person, as the caller of r for AuditMiddleware.
+func setAuditCaller(r *http.Request, ctx context.Context) {
+	if caller, ok := r.Context().Value(auditCallerKey{}).(*context.Context); ok {
+		*caller = ctx
+	}
+}
+
+// peekAuditFields reads the start of a JSON body for the fields worth auditing and
+// restores the body for the handler.
+func peekAuditFields(r *http.Request) auditFields {
+	var fields auditFields
+	if r.Body == nil || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return fields
+	}
+	buf, err := io.ReadAll(io.LimitReader(r.Body, maxAuditPeek))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+	if err != nil || len(buf) >= maxAuditPeek {
+		return fields
+	}
+	_ = json.Unmarshal(buf, &fields)
+	return fields
+}
+
+// clientName names the credential behind a request, for the audit log and
+// per-client rate limits.
+func clientName(p auth.Principal) string {
+	switch {
+	case p.SessionID != "":
+		return "session:" + p.SessionID
+	case p.TokenID != "":
+		return "token:" + p.TokenID
+	case p.IsAdmin():
+		return "admin"
+	}
+	return ""
+}
+
+// settingKeys lists changed setting names, never their values.
+func settingKeys(values map[string]json.RawMessage) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// auditToolObserver records agent tool calls. It returns nil when auditing is
+// disabled.
+func auditToolObserver(auditLog *audit.Log) claude.ToolObserver {
+	if auditLog == nil {
+		return nil
+	}
+	return func(call claude.ToolCall) {
+		entry := audit.Entry{
+			Kind:       audit.KindTool,
+			Person:     call.Person,
+			Client:     "agent",
+			Route:      call.Tool,
+			Target:     call.Path,
+			RunID:      call.RunID,
+			DurationMS: call.Duration.Milliseconds(),
+		}
+		if call.Err != nil {
+			entry.Error = call.Err.Error()
+		}
+		if err := auditLog.Record(entry); err != nil {
+			log.Printf("audit: %v", err)
+		}
+	}
+}
+
+// handleAuditLog queries the audit log, newest first. Persons only see their own
+// entries; admins may filter by ?person=. Other filters: kind, route, run_id,
+// since and until (RFC 3339) and limit.
+func (s *Server) handleAuditLog(w http.ResponseWriter, r *http.Request) {
+	if s.audit == nil {
+		writeError(w, http.StatusServiceUnavailable, "Audit log not available")
+		return
+	}
+
+	q := r.URL.Query()
+	filter := audit.Filter{
+		Person: q.Get("person"),
+		Kind:   q.Get("kind"),
+		Route:  q.Get("route"),
+		RunID:  q.Get("run_id"),
+	}
+	if p, _ := auth.PrincipalFromContext(r.Context()); !p.IsAdmin() {
+		filter.Person = p.Person
+	}
+	for key, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if raw := q.Get(key); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				writeBadRequest(w, "Invalid "+key)
+				return
+			}
+			*dst = t
+		}
+	}
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			writeBadRequest(w, "Invalid limit")
+			return
+		}
+		filter.Limit = n
+	}
+
+	entries, err := s.audit.Query(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"entries": entries,
+	})
+}
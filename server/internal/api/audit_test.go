@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"notes-editor/internal/audit"
+	"notes-editor/internal/claude"
+)
+
+func queryAudit(t *testing.T, router http.Handler, req *http.Request) []audit.Entry {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("audit query: %d %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Entries []audit.Entry `json:"entries"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return resp.Entries
+}
+
+func TestAuditLog_MutatingRequests(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, makeRequest(t, "POST", "/api/files/save", `{"path":"notes/new.md","content":"hi"}`, "sebastian"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("save: %d %s", rec.Code, rec.Body.String())
+	}
+	// Reads are not audited.
+	router.ServeHTTP(httptest.NewRecorder(), makeRequest(t, "GET", "/api/files/read?path=notes/new.md", "", "sebastian"))
+
+	petra := createScopedToken(t, router, "petra", `["sleep","account"]`)
+	router.ServeHTTP(httptest.NewRecorder(), personTokenRequest("POST", "/api/settings/env", `{"content":"X=1"}`, petra.Token, ""))
+
+	// Requests rejected by authentication are audited too.
+	unauthenticated := httptest.NewRequest("POST", "/api/files/delete", nil)
+	unauthenticated.Header.Set("Authorization", "Bearer wrong")
+	router.ServeHTTP(httptest.NewRecorder(), unauthenticated)
+
+	entries := queryAudit(t, router, makeRequest(t, "GET", "/api/auth/audit", "", ""))
+	var save, denied, rejected *audit.Entry
+	for i, e := range entries {
+		switch e.Route {
+		case "/api/files/save":
+			save = &entries[i]
+		case "/api/settings/env":
+			denied = &entries[i]
+		case "/api/files/delete":
+			rejected = &entries[i]
+		case "/api/files/read":
+			t.Error("GET request was audited")
+		}
+	}
+	if save == nil || save.Person != "sebastian" || save.Client != "admin" || save.Target != "notes/new.md" || save.Outcome != audit.OutcomeOK {
+		t.Errorf("save entry = %+v", save)
+	}
+	if denied == nil || denied.Person != "petra" || denied.Client != "token:"+petra.ID || denied.Status != http.StatusForbidden {
+		t.Errorf("denied entry = %+v", denied)
+	}
+	if rejected == nil || rejected.Client != "" || rejected.Status != http.StatusUnauthorized {
+		t.Errorf("rejected entry = %+v", rejected)
+	}
+
+	// Persons only see their own entries.
+	for _, e := range queryAudit(t, router, personTokenRequest("GET", "/api/auth/audit?person=sebastian", "", petra.Token, "")) {
+		if e.Person != "petra" {
+			t.Errorf("petra saw %+v", e)
+		}
+	}
+}
+
+func TestAuditLog_Logins(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+	setPassword(t, router, "sebastian", "correct horse battery")
+
+	// The name in a login request is only a claim until the password checks out.
+	login := func(person, password string) int {
+		req := httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(`{"person":"`+person+`","password":"`+password+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := login("petra", "guessed password"); code != http.StatusUnauthorized {
+		t.Fatalf("failed login: %d", code)
+	}
+	if code := login("sebastian", "correct horse battery"); code != http.StatusOK {
+		t.Fatalf("login: %d", code)
+	}
+
+	entries := queryAudit(t, router, makeRequest(t, "GET", "/api/auth/audit?route=/api/auth/login", "", ""))
+	if len(entries) != 2 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	ok, failed := entries[0], entries[1]
+	if failed.Person != "" || failed.Client != "" || failed.Target != "petra" || failed.Status != http.StatusUnauthorized {
+		t.Errorf("failed login entry = %+v", failed)
+	}
+	if ok.Person != "sebastian" || !strings.HasPrefix(ok.Client, "session:") || ok.Status != http.StatusOK {
+		t.Errorf("login entry = %+v", ok)
+	}
+}
+
+func TestAuditLog_ToolCalls(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	exec := claude.NewToolExecutor(srv.store, nil, "sebastian")
+	exec.Observe("run-42", auditToolObserver(srv.audit))
+	exec.ExecuteTool("write_file", map[string]any{"path": "notes/agent.md", "content": "x"})
+	exec.ExecuteTool("read_file", map[string]any{"path": "notes/missing.md"})
+
+	entries := queryAudit(t, router, makeRequest(t, "GET", "/api/auth/audit?run_id=run-42", "", ""))
+	if len(entries) != 2 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	if entries[0].Route != "read_file" || entries[0].Outcome != audit.OutcomeError || entries[0].Kind != audit.KindTool {
+		t.Errorf("read entry = %+v", entries[0])
+	}
+	if entries[1].Route != "write_file" || entries[1].Target != "notes/agent.md" || entries[1].Outcome != audit.OutcomeOK {
+		t.Errorf("write entry = %+v", entries[1])
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"notes-editor/internal/agent"
+	"notes-editor/internal/backup"
+)
+
+// backupOptions builds the backup manager configuration from the server config.
+// It reads s.config without locking; call it during setup or with s.mu held.
+func (s *Server) backupOptions() backup.Options {
+	opts := backup.Options{
+		Dir:        s.config.Backup.Dir,
+		Passphrase: s.config.Backup.Passphrase,
+		Interval:   s.config.Backup.Interval,
+		Keep:       s.config.Backup.Keep,
+		MaxAge:     s.config.Backup.MaxAge,
+		Sources: backup.Sources{
+			VaultRoot:  s.config.NotesRoot,
+			SessionDir: agent.GatewaySessionDir(),
+		},
+		VaultLock: &s.mu,
+	}
+	if s.sleepStore != nil {
+		opts.SnapshotSleepDB = s.sleepStore.Snapshot
+	}
+	if s.agentSessions != nil {
+		opts.SnapshotAgentDB = s.agentSessions.Snapshot
+	}
+	return opts
+}
+
+// handleListBackups returns the scheduler status and the archives in the backup directory.
+func (s *Server) handleListBackups(w http.ResponseWriter, r *http.Request) {
+	status := s.backups.Status()
+	backups := []backup.Info{}
+	if status.Enabled {
+		list, err := s.backups.List()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to list backups: "+err.Error())
+			return
+		}
+		backups = list
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":  status,
+		"backups": backups,
+	})
+}
+
+// handleRunBackup writes a backup archive immediately.
+func (s *Server) handleRunBackup(w http.ResponseWriter, r *http.Request) {
+	info, err := s.backups.Run()
+	if err != nil {
+		if errors.Is(err, backup.ErrNotConfigured) {
+			writeBadRequest(w, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Backup failed: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"backup":  info,
+	})
+}
+
+// VerifyBackupRequest represents a request to verify a backup archive.
+type VerifyBackupRequest struct {
+	Name string `json:"name"`
+}
+
+// handleVerifyBackup decrypts a backup archive and checks it against its manifest.
+func (s *Server) handleVerifyBackup(w http.ResponseWriter, r *http.Request) {
+	var req VerifyBackupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, "Invalid request body")
+		return
+	}
+	if req.Name == "" {
+		writeBadRequest(w, "Name is required")
+		return
+	}
+
+	result, err := s.backups.Verify(req.Name)
+	if err != nil {
+		switch {
+		case errors.Is(err, backup.ErrNotFound):
+			writeNotFound(w, "Backup not found")
+		case errors.Is(err, backup.ErrInvalidName), errors.Is(err, backup.ErrNotConfigured):
+			writeBadRequest(w, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
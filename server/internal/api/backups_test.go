@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"notes-editor/internal/backup"
+)
+
+func TestBackupEndpoints(t *testing.T) {
+	srv, vaultRoot, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	t.Run("run without configuration returns 400", func(t *testing.T) {
+		req := makeRequest(t, "POST", "/api/settings/backups/run", "", "")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d; body = %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+		}
+	})
+
+	srv.config.Backup.Dir = filepath.Join(vaultRoot, ".backups")
+	srv.config.Backup.Passphrase = "backup-secret"
+	srv.config.Backup.Keep = 5
+	srv.backups.SetOptions(srv.backupOptions())
+
+	var name string
+	t.Run("run writes an archive", func(t *testing.T) {
+		req := makeRequest(t, "POST", "/api/settings/backups/run", "", "")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d; body = %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var resp struct {
+			Backup backup.Info `json:"backup"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if resp.Backup.Name == "" || resp.Backup.Size == 0 {
+			t.Fatalf("unexpected backup info: %#v", resp.Backup)
+		}
+		name = resp.Backup.Name
+	})
+
+	t.Run("list returns the archive", func(t *testing.T) {
+		req := makeRequest(t, "GET", "/api/settings/backups", "", "")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+		var resp struct {
+			Status  backup.Status `json:"status"`
+			Backups []backup.Info `json:"backups"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !resp.Status.Enabled || len(resp.Backups) != 1 || resp.Backups[0].Name != name {
+			t.Fatalf("unexpected list response: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("verify checks the archive", func(t *testing.T) {
+		req := makeRequest(t, "POST", "/api/settings/backups/verify", `{"name":"`+name+`"}`, "")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d; body = %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var result backup.VerifyResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !result.OK || result.Files == 0 {
+			t.Fatalf("unexpected verify result: %#v", result)
+		}
+	})
+
+	t.Run("verify rejects path names", func(t *testing.T) {
+		req := makeRequest(t, "POST", "/api/settings/backups/verify", `{"name":"../.env"}`, "")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+}
@@ -26,7 +26,7 @@ func (s *Server) handleGetDaily(w http.ResponseWriter, r *http.Request) {
 	content, path, created, err := s.daily.GetOrCreateDaily(person, time.Now())
 	s.mu.Unlock()
 	if err != nil {
-		writeBadRequest(w, err.Error())
+		writeStoreError(w, err)
 		return
 	}
 
@@ -69,7 +69,7 @@ func (s *Server) handleSaveDaily(w http.ResponseWriter, r *http.Request) {
 	s.mu.Lock()
 	if err := s.store.WriteFile(person, req.Path, req.Content); err != nil {
 		s.mu.Unlock()
-		writeBadRequest(w, err.Error())
+		writeStoreError(w, err)
 		return
 	}
 	s.mu.Unlock()
@@ -112,7 +112,7 @@ func (s *Server) handleAppendDaily(w http.ResponseWriter, r *http.Request) {
 	s.mu.Lock()
 	if err := s.daily.AppendEntry(person, req.Path, req.Text, req.Pinned); err != nil {
 		s.mu.Unlock()
-		writeBadRequest(w, err.Error())
+		writeStoreError(w, err)
 		return
 	}
 	s.mu.Unlock()
@@ -148,7 +148,7 @@ func (s *Server) handleClearPinned(w http.ResponseWriter, r *http.Request) {
 	s.mu.Lock()
 	if err := s.daily.ClearAllPinned(person, req.Path); err != nil {
 		s.mu.Unlock()
-		writeBadRequest(w, err.Error())
+		writeStoreError(w, err)
 		return
 	}
 	s.mu.Unlock()
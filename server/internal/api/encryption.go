@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"notes-editor/internal/auth"
+	"notes-editor/internal/config"
+	"notes-editor/internal/vault"
+)
+
+// applyEncryptedFolders installs ENCRYPTED_FOLDERS and the unlock timeout on the
+// store. An invalid spec keeps the previous folders, so encrypted files are
+// never written back in plaintext by a typo.
+func applyEncryptedFolders(store *vault.Store, cfg *config.Config) {
+	store.SetUnlockTimeout(cfg.UnlockTimeout)
+	folders, err := vault.ParseEncryptedFolders(cfg.EncryptedFolders)
+	if err != nil {
+		log.Printf("encrypted folders unchanged: %v", err)
+		return
+	}
+	store.SetEncryptedFolders(folders)
+}
+
+// canDecrypt reports whether the caller may read files in unlocked encrypted
+// folders. Unlocking needs the account scope, so a token without it, such as a
+// read-only token for a script, sees the folders as locked even while its
+// person's login session has them unlocked.
+func canDecrypt(r *http.Request) bool {
+	p, ok := auth.PrincipalFromContext(r.Context())
+	return ok && (p.SessionID != "" || p.HasScope(auth.ScopeAccount))
+}
+
+// historyWarning is returned when setup encrypted existing files: encrypting
+// them does not remove the versions already committed.
+const historyWarning = "Earlier versions of the encrypted files are still readable in the git history. Rewrite the history (e.g. with git filter-repo) and force-push to remove them."
+
+// EncryptionPassphraseRequest carries the passphrase of a person's encrypted folders.
+type EncryptionPassphraseRequest struct {
+	Passphrase string `json:"passphrase"`
+}
+
+// handleEncryptionStatus returns the person's encrypted folders and lock state.
+func (s *Server) handleEncryptionStatus(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.store.EncryptionStatus(person))
+}
+
+// handleEncryptionSetup chooses the passphrase and encrypts the plaintext files
+// already in the person's encrypted folders.
+func (s *Server) handleEncryptionSetup(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+	var req EncryptionPassphraseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, "Invalid request body")
+		return
+	}
+
+	s.mu.Lock()
+	encrypted, err := s.store.SetupEncryption(person, req.Passphrase)
+	s.mu.Unlock()
+	if err != nil {
+		if errors.Is(err, vault.ErrEncryptionSetUp) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeStoreError(w, err)
+		return
+	}
+
+	s.syncMgr.TriggerPush("Set up encryption")
+
+	resp := map[string]any{
+		"encrypted": encrypted,
+		"status":    s.store.EncryptionStatus(person),
+	}
+	if len(encrypted) > 0 {
+		resp["warning"] = historyWarning
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleEncryptionUnlock unlocks the person's encrypted folders.
+func (s *Server) handleEncryptionUnlock(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+	var req EncryptionPassphraseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if err := s.store.Unlock(person, req.Passphrase); err != nil {
+		if errors.Is(err, vault.ErrWrongPassphrase) {
+			writeForbidden(w, err.Error())
+			return
+		}
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.store.EncryptionStatus(person))
+}
+
+// handleEncryptionLock locks the person's encrypted folders.
+func (s *Server) handleEncryptionLock(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+	s.store.Lock(person)
+	writeJSON(w, http.StatusOK, s.store.EncryptionStatus(person))
+}
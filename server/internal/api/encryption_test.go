@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"notes-editor/internal/vault"
+)
+
+func TestEncryptedFolders(t *testing.T) {
+	srv, vaultRoot, cleanup := setupTestServer(t)
+	defer cleanup()
+	srv.config.EncryptedFolders = "sebastian=health"
+	applyEncryptedFolders(srv.store, srv.config)
+	router := NewRouter(srv)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, makeRequest(t, method, path, body, "sebastian"))
+		return rec
+	}
+
+	if rec := do("POST", "/api/files/save", `{"path":"health/blood.md","content":"ferritin"}`); rec.Code != http.StatusLocked {
+		t.Fatalf("save before setup: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do("POST", "/api/encryption/setup", `{"passphrase":"short"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("setup with short passphrase: %d %s", rec.Code, rec.Body.String())
+	}
+	os.MkdirAll(filepath.Join(vaultRoot, "sebastian", "health"), 0755)
+	os.WriteFile(filepath.Join(vaultRoot, "sebastian", "health", "old.md"), []byte("old"), 0644)
+	rec := do("POST", "/api/encryption/setup", `{"passphrase":"correct horse"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("setup: %d %s", rec.Code, rec.Body.String())
+	}
+	var setup struct {
+		Encrypted []string `json:"encrypted"`
+		Warning   string   `json:"warning"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &setup)
+	if len(setup.Encrypted) != 1 || !strings.Contains(setup.Warning, "git history") {
+		t.Fatalf("setup response = %s", rec.Body.String())
+	}
+	if rec := do("POST", "/api/encryption/setup", `{"passphrase":"correct horse"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("second setup: %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := do("POST", "/api/files/save", `{"path":"health/blood.md","content":"ferritin"}`); rec.Code != http.StatusOK {
+		t.Fatalf("save: %d %s", rec.Code, rec.Body.String())
+	}
+	raw, err := os.ReadFile(filepath.Join(vaultRoot, "sebastian", "health", "blood.md"+vault.EncryptedSuffix))
+	if err != nil || strings.Contains(string(raw), "ferritin") {
+		t.Fatalf("stored file = %q, %v", raw, err)
+	}
+	if rec := do("GET", "/api/files/read?path=health/blood.md", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "ferritin") {
+		t.Fatalf("read: %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := do("POST", "/api/encryption/lock", ""); rec.Code != http.StatusOK {
+		t.Fatalf("lock: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do("GET", "/api/files/read?path=health/blood.md", ""); rec.Code != http.StatusLocked {
+		t.Fatalf("read while locked: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do("POST", "/api/encryption/unlock", `{"passphrase":"wrong horse"}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("unlock with wrong passphrase: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do("POST", "/api/encryption/unlock", `{"passphrase":"correct horse"}`); rec.Code != http.StatusOK {
+		t.Fatalf("unlock: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = do("GET", "/api/encryption", "")
+	var status vault.EncryptionStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("status: %v %s", err, rec.Body.String())
+	}
+	if !status.SetUp || !status.Unlocked || len(status.Folders) != 1 || status.Folders[0].Path != "health" {
+		t.Fatalf("status = %+v", status)
+	}
+
+	// A read-only token can neither unlock nor read what the unlock exposed.
+	readOnly := createScopedToken(t, router, "sebastian", `["vault:read"]`)
+	withToken := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, personTokenRequest(method, path, body, readOnly.Token, ""))
+		return rec
+	}
+	if rec := withToken("POST", "/api/encryption/unlock", `{"passphrase":"correct horse"}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("unlock with read token: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := withToken("GET", "/api/files/read?path=health/blood.md", ""); rec.Code != http.StatusLocked {
+		t.Fatalf("read with read token: %d %s", rec.Code, rec.Body.String())
+	}
+	os.WriteFile(filepath.Join(vaultRoot, "sebastian", "health", "stray.md"), []byte("pulled"), 0644)
+	if rec := withToken("GET", "/api/files/export?path=health/stray.md&format=print", ""); rec.Code != http.StatusLocked {
+		t.Fatalf("export with read token: %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -44,6 +44,11 @@ func writeUnauthorized(w http.ResponseWriter) {
 	writeError(w, http.StatusUnauthorized, "Unauthorized")
 }
 
+// writeForbidden writes a 403 Forbidden error.
+func writeForbidden(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusForbidden, message)
+}
+
 // writeNotFound writes a 404 Not Found error.
 func writeNotFound(w http.ResponseWriter, message string) {
 	writeError(w, http.StatusNotFound, message)
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"notes-editor/internal/export"
+	"notes-editor/internal/vault"
+)
+
+// handleExport renders notes of the selected person for sharing or printing. The
+// selection is either path (a note or folder) or from/to (a range of daily notes);
+// format is html (a ZIP site), print (one HTML page for print-to-PDF) or epub.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = export.FormatHTML
+	}
+	var contentType, ext string
+	switch format {
+	case export.FormatHTML:
+		contentType, ext = "application/zip", ".zip"
+	case export.FormatPrint:
+		contentType, ext = "text/html; charset=utf-8", ".html"
+	case export.FormatEPUB:
+		contentType, ext = "application/epub+zip", ".epub"
+	default:
+		writeBadRequest(w, export.ErrUnknownFormat.Error())
+		return
+	}
+	sel := export.Selection{Path: q.Get("path"), From: q.Get("from"), To: q.Get("to")}
+	if sel.From != "" && sel.To == "" {
+		sel.To = sel.From
+	}
+
+	var buf bytes.Buffer
+	s.mu.RLock()
+	collection, err := export.Collect(s.store, person, sel)
+	if err == nil && !canDecrypt(r) {
+		for _, p := range collection.Paths() {
+			if s.store.IsEncrypted(person, p) {
+				err = vault.ErrLocked
+				break
+			}
+		}
+	}
+	if err == nil {
+		if title := strings.TrimSpace(q.Get("title")); title != "" {
+			collection.Title = title
+		}
+		switch format {
+		case export.FormatHTML:
+			err = collection.WriteHTMLSite(&buf)
+		case export.FormatPrint:
+			err = collection.WritePrintHTML(&buf)
+		case export.FormatEPUB:
+			err = collection.WriteEPUB(&buf)
+		}
+	}
+	s.mu.RUnlock()
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist), errors.Is(err, export.ErrNoNotes):
+			writeNotFound(w, err.Error())
+		case errors.Is(err, vault.ErrPathEscape), errors.Is(err, vault.ErrAbsolutePath),
+			errors.Is(err, export.ErrEmptySelection), errors.Is(err, export.ErrBothSelections),
+			errors.Is(err, export.ErrInvalidRange), errors.Is(err, export.ErrTooLarge):
+			writeBadRequest(w, err.Error())
+		case errors.Is(err, vault.ErrLocked), errors.Is(err, vault.ErrEncryptionNotSetUp):
+			writeStoreError(w, err)
+		default:
+			writeError(w, http.StatusInternalServerError, "Export failed: "+err.Error())
+		}
+		return
+	}
+
+	filename := fmt.Sprintf("%s-export-%s%s", person, time.Now().Format("20060102-150405"), ext)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
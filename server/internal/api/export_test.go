@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	srv, vaultRoot, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	os.WriteFile(filepath.Join(vaultRoot, "sebastian", "daily", "2026-07-01.md"), []byte("# 2026-07-01\n\nArrived in Rome\n"), 0644)
+
+	t.Run("print export of a daily range", func(t *testing.T) {
+		req := makeRequest(t, "GET", "/api/files/export?format=print&from=2026-07-01&to=2026-07-31&title=Rome", "", "sebastian")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d; body = %s", rec.Code, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Fatalf("Content-Type = %q", ct)
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, "<title>Rome</title>") || !strings.Contains(body, "Arrived in Rome") {
+			t.Fatalf("unexpected body:\n%s", body)
+		}
+	})
+
+	t.Run("epub export of a note", func(t *testing.T) {
+		req := makeRequest(t, "GET", "/api/files/export?format=epub&path=notes/secret.md", "", "sebastian")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d; body = %s", rec.Code, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/epub+zip" {
+			t.Fatalf("Content-Type = %q", ct)
+		}
+	})
+
+	t.Run("cannot export another person's notes", func(t *testing.T) {
+		req := makeRequest(t, "GET", "/api/files/export?path=../petra/notes/secret.md", "", "sebastian")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("missing selection and unknown format", func(t *testing.T) {
+		for _, path := range []string{"/api/files/export", "/api/files/export?format=pdf&path=notes"} {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, makeRequest(t, "GET", path, "", "sebastian"))
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("%s: status = %d, want %d", path, rec.Code, http.StatusBadRequest)
+			}
+		}
+	})
+}
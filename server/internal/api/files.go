@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"os"
 	"time"
+
+	"notes-editor/internal/vault"
 )
 
 // handleListFiles lists files in a directory.
@@ -29,7 +31,7 @@ func (s *Server) handleListFiles(w http.ResponseWriter, r *http.Request) {
 			writeNotFound(w, "Directory not found")
 			return
 		}
-		writeBadRequest(w, err.Error())
+		writeStoreError(w, err)
 		return
 	}
 
@@ -53,6 +55,11 @@ func (s *Server) handleReadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !canDecrypt(r) && s.store.IsEncrypted(person, path) {
+		writeStoreError(w, vault.ErrLocked)
+		return
+	}
+
 	s.mu.RLock()
 	content, err := s.store.ReadFile(person, path)
 	s.mu.RUnlock()
@@ -61,7 +68,7 @@ func (s *Server) handleReadFile(w http.ResponseWriter, r *http.Request) {
 			writeNotFound(w, "File not found")
 			return
 		}
-		writeBadRequest(w, err.Error())
+		writeStoreError(w, err)
 		return
 	}
 
@@ -99,7 +106,7 @@ func (s *Server) handleCreateFile(w http.ResponseWriter, r *http.Request) {
 	exists, err := s.store.FileExists(person, req.Path)
 	if err != nil {
 		s.mu.Unlock()
-		writeBadRequest(w, err.Error())
+		writeStoreError(w, err)
 		return
 	}
 	if exists {
@@ -111,7 +118,7 @@ func (s *Server) handleCreateFile(w http.ResponseWriter, r *http.Request) {
 	// Create empty file
 	if err := s.store.WriteFile(person, req.Path, ""); err != nil {
 		s.mu.Unlock()
-		writeBadRequest(w, err.Error())
+		writeStoreError(w, err)
 		return
 	}
 	s.mu.Unlock()
@@ -148,7 +155,7 @@ func (s *Server) handleSaveFile(w http.ResponseWriter, r *http.Request) {
 	s.mu.Lock()
 	if err := s.store.WriteFile(person, req.Path, req.Content); err != nil {
 		s.mu.Unlock()
-		writeBadRequest(w, err.Error())
+		writeStoreError(w, err)
 		return
 	}
 	s.mu.Unlock()
@@ -184,7 +191,7 @@ func (s *Server) handleDeleteFile(w http.ResponseWriter, r *http.Request) {
 	s.mu.Lock()
 	if err := s.store.DeleteFile(person, req.Path); err != nil {
 		s.mu.Unlock()
-		writeBadRequest(w, err.Error())
+		writeStoreError(w, err)
 		return
 	}
 	s.mu.Unlock()
@@ -225,7 +232,7 @@ func (s *Server) handleUnpinEntry(w http.ResponseWriter, r *http.Request) {
 	s.mu.Lock()
 	if err := s.daily.UnpinEntry(person, req.Path, req.Line); err != nil {
 		s.mu.Unlock()
-		writeBadRequest(w, err.Error())
+		writeStoreError(w, err)
 		return
 	}
 	s.mu.Unlock()
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"archive/zip"
+	"net/http"
+	"os"
+	"strconv"
+
+	"notes-editor/internal/importer"
+)
+
+// ImportResponse describes a planned or applied import.
+type ImportResponse struct {
+	importer.Report
+	DryRun    bool   `json:"dry_run"`
+	Committed bool   `json:"committed"`
+	Message   string `json:"message,omitempty"`
+	PushError string `json:"push_error,omitempty"`
+}
+
+// handleImport imports notes from another tool into the selected person's vault.
+// The ZIP export is sent as the raw request body or as the "file" field of a
+// multipart form. format is obsidian, markdown or keep; target sets the destination
+// folder and daily=false keeps date-named notes out of daily/. Without
+// dry_run=false only the import report is returned.
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	opts := importer.Options{
+		Format:    q.Get("format"),
+		Person:    person,
+		TargetDir: q.Get("target"),
+	}
+	dryRun := true
+	if raw := q.Get("dry_run"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeBadRequest(w, "Invalid dry_run value")
+			return
+		}
+		dryRun = parsed
+	}
+	if raw := q.Get("daily"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeBadRequest(w, "Invalid daily value")
+			return
+		}
+		opts.SkipDaily = !parsed
+	}
+	switch opts.Format {
+	case importer.FormatObsidian, importer.FormatMarkdown, importer.FormatKeep:
+	default:
+		writeBadRequest(w, importer.ErrUnknownFormat.Error())
+		return
+	}
+
+	archivePath, _, err := s.spoolUpload(w, r, "import-*.zip")
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	defer os.Remove(archivePath)
+
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		writeBadRequest(w, "Upload is not a valid ZIP archive")
+		return
+	}
+	defer zr.Close()
+
+	if dryRun {
+		s.mu.RLock()
+		plan, err := importer.Build(s.store, zr, opts)
+		s.mu.RUnlock()
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, ImportResponse{Report: plan.Report, DryRun: true})
+		return
+	}
+
+	s.mu.Lock()
+	plan, err := importer.Build(s.store, zr, opts)
+	if err != nil {
+		s.mu.Unlock()
+		writeBadRequest(w, err.Error())
+		return
+	}
+	if err := importer.Apply(s.store, plan); err != nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusInternalServerError, "Import failed: "+err.Error())
+		return
+	}
+	resp := ImportResponse{Report: plan.Report, Message: plan.CommitMessage()}
+	committed, commitErr := s.git.CommitPaths(resp.Message, plan.RootPaths())
+	var pushErr error
+	if commitErr == nil && committed {
+		pushErr = s.git.Push()
+	}
+	s.mu.Unlock()
+
+	if commitErr != nil {
+		writeError(w, http.StatusInternalServerError, "Files imported but commit failed: "+commitErr.Error())
+		return
+	}
+	resp.Committed = committed
+	if committed {
+		s.syncMgr.RecordManualPush(pushErr)
+		if pushErr != nil {
+			resp.PushError = pushErr.Error()
+		}
+	}
+	s.indexMgr.TriggerReindex("import")
+
+	writeJSON(w, http.StatusOK, resp)
+}
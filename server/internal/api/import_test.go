@@ -0,0 +1,99 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func importRequest(t *testing.T, query string, archive []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", "takeout.zip")
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	part.Write(archive)
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/api/settings/import"+query, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer test-token-123")
+	req.Header.Set("X-Notes-Person", "sebastian")
+	return req
+}
+
+func TestImport(t *testing.T) {
+	srv, vaultRoot, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	runGit(t, vaultRoot, "init")
+	runGit(t, vaultRoot, "config", "user.email", "test@example.com")
+	runGit(t, vaultRoot, "config", "user.name", "Test User")
+	runGit(t, vaultRoot, "add", ".")
+	runGit(t, vaultRoot, "commit", "-m", "initial")
+
+	archive := zipBytes(t, map[string]string{
+		"Takeout/Keep/Shopping.json": `{"title": "Shopping", "listContent": [{"text": "Eggs", "isChecked": false}], "userEditedTimestampUsec": 1}`,
+	})
+
+	t.Run("dry run returns the report without writing", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, importRequest(t, "?format=keep&target=keep", archive))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d; body = %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var resp ImportResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !resp.DryRun || resp.Notes != 1 || resp.TargetDir != "keep" {
+			t.Fatalf("unexpected dry run response: %s", rec.Body.String())
+		}
+		if _, err := os.Stat(filepath.Join(vaultRoot, "sebastian", "keep")); !os.IsNotExist(err) {
+			t.Fatalf("dry run must not write files")
+		}
+	})
+
+	t.Run("apply writes notes and report in one commit", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, importRequest(t, "?format=keep&target=keep&dry_run=false", archive))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d; body = %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var resp ImportResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if resp.DryRun || !resp.Committed {
+			t.Fatalf("unexpected apply response: %s", rec.Body.String())
+		}
+
+		content, _ := os.ReadFile(filepath.Join(vaultRoot, "sebastian", "keep", "Shopping.md"))
+		if !strings.Contains(string(content), "- [ ] Eggs") {
+			t.Fatalf("Shopping.md = %q", content)
+		}
+		files := runGit(t, vaultRoot, "show", "--name-only", "--format=%s", "HEAD")
+		if !strings.HasPrefix(files, "Import 1 notes from keep into sebastian/keep") ||
+			!strings.Contains(files, "sebastian/keep/Shopping.md") ||
+			!strings.Contains(files, "sebastian/keep/IMPORT-REPORT.md") {
+			t.Fatalf("unexpected commit:\n%s", files)
+		}
+	})
+
+	t.Run("rejects unknown formats", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, importRequest(t, "?format=evernote", archive))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+}
@@ -0,0 +1,610 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+
+	"notes-editor/internal/auth"
+	"notes-editor/internal/config"
+)
+
+const (
+	sessionCookieName = "notes_session"
+	// csrfCookieName holds the session's CSRF token where the web client can read it
+	// after a reload; it must be echoed in csrfHeaderName on unsafe requests.
+	csrfCookieName = "notes_csrf"
+	csrfHeaderName = "X-CSRF-Token"
+
+	// ceremonyTTL bounds how long a passkey prompt may stay open.
+	ceremonyTTL = 5 * time.Minute
+	// maxPasskeyBody bounds a WebAuthn response body.
+	maxPasskeyBody = 64 << 10
+)
+
+var (
+	dummyPasswordHash     string
+	dummyPasswordHashOnce sync.Once
+)
+
+// verifyDummyPassword spends the same time as a real password check so failed
+// logins do not reveal whether a person has a password.
+func verifyDummyPassword(password string) {
+	dummyPasswordHashOnce.Do(func() {
+		dummyPasswordHash, _ = auth.HashPassword("no password set for this person")
+	})
+	auth.VerifyPassword(password, dummyPasswordHash)
+}
+
+// PasswordLoginRequest logs a person in with a password.
+type PasswordLoginRequest struct {
+	Person   string `json:"person"`
+	Password string `json:"password"`
+}
+
+// LoginResponse is returned after a successful login. The session itself is in an
+// HTTP-only cookie.
+type LoginResponse struct {
+	Person    string    `json:"person"`
+	CSRFToken string    `json:"csrf_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SetPasswordRequest sets or changes the selected person's password.
+type SetPasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// PasskeyBeginRequest starts a passkey registration.
+type PasskeyBeginRequest struct {
+	Name string `json:"name"`
+}
+
+// PasskeyOptionsResponse carries the options for navigator.credentials.create/get.
+// The ceremony ID must be passed to the matching finish endpoint.
+type PasskeyOptionsResponse struct {
+	Ceremony string `json:"ceremony"`
+	Options  any    `json:"options"`
+}
+
+// PasskeyInfo describes a registered passkey.
+type PasskeyInfo struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// LoginMethodsResponse lists the selected person's login methods.
+type LoginMethodsResponse struct {
+	Person          string        `json:"person"`
+	HasPassword     bool          `json:"has_password"`
+	PasskeysEnabled bool          `json:"passkeys_enabled"`
+	Passkeys        []PasskeyInfo `json:"passkeys"`
+}
+
+// DeletePasskeyRequest removes a passkey.
+type DeletePasskeyRequest struct {
+	ID string `json:"id"`
+}
+
+// SessionInfo describes a login session.
+type SessionInfo struct {
+	auth.Session
+	Current bool `json:"current"`
+}
+
+// RevokeSessionRequest ends a login session.
+type RevokeSessionRequest struct {
+	ID string `json:"id"`
+}
+
+// ceremony is a pending passkey registration or login.
+type ceremony struct {
+	person  string
+	name    string
+	data    webauthn.SessionData
+	expires time.Time
+}
+
+// ceremonyStore keeps pending passkey ceremonies in memory; they only live for
+// the few seconds an authenticator prompt is open.
+type ceremonyStore struct {
+	mu    sync.Mutex
+	items map[string]ceremony
+}
+
+func newCeremonyStore() *ceremonyStore {
+	return &ceremonyStore{items: make(map[string]ceremony)}
+}
+
+func (c *ceremonyStore) put(cer ceremony) (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	id := base64.RawURLEncoding.EncodeToString(buf)
+	now := time.Now()
+	cer.expires = now.Add(ceremonyTTL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range c.items {
+		if now.After(v.expires) {
+			delete(c.items, k)
+		}
+	}
+	c.items[id] = cer
+	return id, nil
+}
+
+// take removes and returns a pending ceremony. Each ceremony can be finished once.
+func (c *ceremonyStore) take(id string) (ceremony, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cer, ok := c.items[id]
+	delete(c.items, id)
+	if !ok || time.Now().After(cer.expires) {
+		return ceremony{}, false
+	}
+	return cer, true
+}
+
+// newWebAuthn returns the passkey relying party, or nil when passkeys are not configured.
+func newWebAuthn(cfg config.LoginConfig) *webauthn.WebAuthn {
+	if cfg.WebAuthnRPID == "" || len(cfg.WebAuthnOrigins) == 0 {
+		return nil
+	}
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthnRPID,
+		RPDisplayName: cfg.WebAuthnRPName,
+		RPOrigins:     cfg.WebAuthnOrigins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: ceremonyTTL, TimeoutUVD: ceremonyTTL},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: ceremonyTTL, TimeoutUVD: ceremonyTTL},
+		},
+	})
+	if err != nil {
+		log.Printf("passkeys disabled: %v", err)
+		return nil
+	}
+	return wa
+}
+
+// requestIsHTTPS reports whether the client reached us over TLS, directly or via a proxy.
+func requestIsHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func setSessionCookies(w http.ResponseWriter, r *http.Request, token, csrf string, expires time.Time) {
+	secure := requestIsHTTPS(r)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    csrf,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func clearSessionCookies(w http.ResponseWriter, r *http.Request) {
+	for _, name := range []string{sessionCookieName, csrfCookieName} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name == sessionCookieName,
+			Secure:   requestIsHTTPS(r),
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+}
+
+// startSession creates a login session for person and sets its cookies.
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, person, method string) {
+	sess, token, err := s.sessions.Create(person, method, r.UserAgent(), remoteHost(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	markAuthenticated(r)
+	setAuditCaller(r, auth.WithPerson(auth.WithPrincipal(r.Context(), auth.Principal{
+		Role:      auth.RolePerson,
+		Person:    person,
+		Scopes:    auth.PersonScopes,
+		SessionID: sess.ID,
+	}), person))
+	setSessionCookies(w, r, token, sess.CSRFToken, sess.ExpiresAt)
+	writeJSON(w, http.StatusOK, LoginResponse{Person: person, CSRFToken: sess.CSRFToken, ExpiresAt: sess.ExpiresAt})
+}
+
+// handlePasswordLogin starts a session for a person with a valid password. It is
+// unauthenticated.
+func (s *Server) handlePasswordLogin(w http.ResponseWriter, r *http.Request) {
+	if s.sessions == nil {
+		writeBadRequest(w, "Login sessions are not available")
+		return
+	}
+	var req PasswordLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, "Invalid request body")
+		return
+	}
+
+	var login auth.Login
+	var err error
+	if auth.IsValidPerson(req.Person) {
+		login, err = s.logins.Get(req.Person)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if !login.HasPassword() {
+		verifyDummyPassword(req.Password)
+		writeError(w, http.StatusUnauthorized, "Invalid person or password")
+		return
+	}
+	ok, err := auth.VerifyPassword(req.Password, login.PasswordHash)
+	if err != nil {
+		log.Printf("password check failed for person=%s: %v", req.Person, err)
+	}
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Invalid person or password")
+		return
+	}
+	s.startSession(w, r, req.Person, "password")
+}
+
+// handlePasskeyLoginBegin starts a discoverable passkey login. It is unauthenticated.
+func (s *Server) handlePasskeyLoginBegin(w http.ResponseWriter, r *http.Request) {
+	if s.webauthn == nil || s.sessions == nil {
+		writeBadRequest(w, "Passkeys are not configured")
+		return
+	}
+	assertion, data, err := s.webauthn.BeginDiscoverableLogin()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	id, err := s.ceremonies.put(ceremony{data: *data})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, PasskeyOptionsResponse{Ceremony: id, Options: assertion})
+}
+
+// handlePasskeyLoginFinish verifies the authenticator's assertion and starts a
+// session for the passkey's person. It is unauthenticated.
+func (s *Server) handlePasskeyLoginFinish(w http.ResponseWriter, r *http.Request) {
+	if s.webauthn == nil || s.sessions == nil {
+		writeBadRequest(w, "Passkeys are not configured")
+		return
+	}
+	cer, ok := s.ceremonies.take(r.URL.Query().Get("ceremony"))
+	if !ok || cer.person != "" {
+		writeBadRequest(w, "Unknown or expired passkey ceremony")
+		return
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBody(http.MaxBytesReader(w, r.Body, maxPasskeyBody))
+	if err != nil {
+		writeBadRequest(w, "Invalid passkey response")
+		return
+	}
+
+	var person string
+	cred, err := s.webauthn.ValidateDiscoverableLogin(func(_, userHandle []byte) (webauthn.User, error) {
+		login, found, err := s.logins.FindByWebAuthnID(userHandle)
+		if err != nil {
+			return nil, err
+		}
+		if !found || !auth.IsValidPerson(login.Person) {
+			return nil, errors.New("unknown passkey")
+		}
+		person = login.Person
+		return login.WebAuthn(), nil
+	}, cer.data, parsed)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "Passkey not accepted")
+		return
+	}
+
+	if err := s.logins.RecordPasskeyUse(person, *cred); err != nil {
+		log.Printf("recording passkey use for person=%s failed: %v", person, err)
+	}
+	s.startSession(w, r, person, "passkey")
+}
+
+// handleLogout ends the current login session and clears its cookies.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	p, _ := auth.PrincipalFromContext(r.Context())
+	if p.SessionID != "" && s.sessions != nil {
+		if err := s.sessions.Revoke(p.Person, p.SessionID); err != nil && !errors.Is(err, auth.ErrSessionNotFound) {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	clearSessionCookies(w, r)
+	writeSuccess(w, "Logged out")
+}
+
+// handleListSessions lists the selected person's login sessions.
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+	if s.sessions == nil {
+		writeJSON(w, http.StatusOK, []SessionInfo{})
+		return
+	}
+	sessions, err := s.sessions.List(person)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	p, _ := auth.PrincipalFromContext(r.Context())
+	out := make([]SessionInfo, 0, len(sessions))
+	for _, sess := range sessions {
+		out = append(out, SessionInfo{Session: sess, Current: sess.ID == p.SessionID})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleRevokeSession logs out one of the selected person's sessions remotely.
+func (s *Server) handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+	var req RevokeSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, "Invalid request body")
+		return
+	}
+	if req.ID == "" {
+		writeBadRequest(w, "ID is required")
+		return
+	}
+	if s.sessions == nil {
+		writeNotFound(w, "Session not found")
+		return
+	}
+	err := s.sessions.Revoke(person, req.ID)
+	if errors.Is(err, auth.ErrSessionNotFound) {
+		writeNotFound(w, "Session not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeSuccess(w, "Session revoked")
+}
+
+// handleLoginMethods lists the selected person's password and passkey status.
+func (s *Server) handleLoginMethods(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+	login, err := s.logins.Get(person)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp := LoginMethodsResponse{
+		Person:          person,
+		HasPassword:     login.HasPassword(),
+		PasskeysEnabled: s.webauthn != nil,
+		Passkeys:        make([]PasskeyInfo, 0, len(login.Passkeys)),
+	}
+	for _, pk := range login.Passkeys {
+		resp.Passkeys = append(resp.Passkeys, PasskeyInfo{ID: pk.ID, Name: pk.Name, CreatedAt: pk.CreatedAt, LastUsedAt: pk.LastUsedAt})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleSetPassword sets or changes the selected person's password. Changing an
+// existing password requires the current one unless the admin token is used. Other
+// sessions of the person are logged out.
+func (s *Server) handleSetPassword(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+	var req SetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, "Invalid request body")
+		return
+	}
+
+	login, err := s.logins.Get(person)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	p, _ := auth.PrincipalFromContext(r.Context())
+	if login.HasPassword() && !p.IsAdmin() {
+		if ok, _ := auth.VerifyPassword(req.CurrentPassword, login.PasswordHash); !ok {
+			writeForbidden(w, "Current password is incorrect")
+			return
+		}
+	}
+	hash, err := auth.HashPassword(req.NewPassword)
+	if errors.Is(err, auth.ErrPasswordTooShort) {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := s.logins.SetPassword(person, hash); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if s.sessions != nil {
+		if err := s.sessions.RevokeAll(person, p.SessionID); err != nil {
+			log.Printf("revoking sessions after password change for person=%s failed: %v", person, err)
+		}
+	}
+
+	writeSuccess(w, "Password updated")
+}
+
+// handlePasskeyRegisterBegin starts registering a passkey for the selected person.
+func (s *Server) handlePasskeyRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+	if s.webauthn == nil {
+		writeBadRequest(w, "Passkeys are not configured")
+		return
+	}
+	var req PasskeyBeginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, "Invalid request body")
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if utf8.RuneCountInString(name) > maxTokenNameLength {
+		writeBadRequest(w, "Name is too long")
+		return
+	}
+
+	if _, err := s.logins.EnsureWebAuthnID(person); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	login, err := s.logins.Get(person)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	exclude := make([]protocol.CredentialDescriptor, 0, len(login.Passkeys))
+	for _, pk := range login.Passkeys {
+		exclude = append(exclude, pk.Credential.Descriptor())
+	}
+	creation, data, err := s.webauthn.BeginRegistration(login.WebAuthn(),
+		webauthn.WithExclusions(exclude),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	id, err := s.ceremonies.put(ceremony{person: person, name: name, data: *data})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, PasskeyOptionsResponse{Ceremony: id, Options: creation})
+}
+
+// handlePasskeyRegisterFinish verifies the authenticator's attestation and stores
+// the new passkey.
+func (s *Server) handlePasskeyRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+	if s.webauthn == nil {
+		writeBadRequest(w, "Passkeys are not configured")
+		return
+	}
+	cer, found := s.ceremonies.take(r.URL.Query().Get("ceremony"))
+	if !found || cer.person != person {
+		writeBadRequest(w, "Unknown or expired passkey ceremony")
+		return
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(http.MaxBytesReader(w, r.Body, maxPasskeyBody))
+	if err != nil {
+		writeBadRequest(w, "Invalid passkey response")
+		return
+	}
+	login, err := s.logins.Get(person)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	cred, err := s.webauthn.CreateCredential(login.WebAuthn(), cer.data, parsed)
+	if err != nil {
+		writeBadRequest(w, "Passkey not accepted")
+		return
+	}
+
+	pk, err := s.logins.AddPasskey(person, cer.name, *cred)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, PasskeyInfo{ID: pk.ID, Name: pk.Name, CreatedAt: pk.CreatedAt})
+}
+
+// handleDeletePasskey removes one of the selected person's passkeys.
+func (s *Server) handleDeletePasskey(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+	var req DeletePasskeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, "Invalid request body")
+		return
+	}
+	if req.ID == "" {
+		writeBadRequest(w, "ID is required")
+		return
+	}
+
+	err := s.logins.RemovePasskey(person, req.ID)
+	if errors.Is(err, auth.ErrPasskeyNotFound) {
+		writeNotFound(w, "Passkey not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeSuccess(w, "Passkey removed")
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"notes-editor/internal/config"
+)
+
+func setPassword(t *testing.T, router http.Handler, person, password string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, makeRequest(t, "POST", "/api/auth/password", `{"new_password":"`+password+`"}`, person))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("set password: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func passwordLogin(t *testing.T, router http.Handler, person, password string) (*httptest.ResponseRecorder, *http.Cookie, LoginResponse) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(`{"person":"`+person+`","password":"`+password+`"}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var resp LoginResponse
+	var session *http.Cookie
+	if rec.Code == http.StatusOK {
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == sessionCookieName {
+				session = c
+			}
+		}
+	}
+	return rec, session, resp
+}
+
+func sessionRequest(method, path, body string, cookie *http.Cookie, csrf string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.AddCookie(cookie)
+	if csrf != "" {
+		req.Header.Set(csrfHeaderName, csrf)
+	}
+	return req
+}
+
+func TestPasswordLogin_SessionCookie(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	setPassword(t, router, "sebastian", "correct horse battery")
+
+	if rec, _, _ := passwordLogin(t, router, "sebastian", "wrong horse battery"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong password: %d", rec.Code)
+	}
+	if rec, _, _ := passwordLogin(t, router, "petra", "correct horse battery"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("person without password: %d", rec.Code)
+	}
+
+	rec, cookie, login := passwordLogin(t, router, "sebastian", "correct horse battery")
+	if rec.Code != http.StatusOK || cookie == nil {
+		t.Fatalf("login: %d %s", rec.Code, rec.Body.String())
+	}
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode || login.CSRFToken == "" {
+		t.Fatalf("cookie %+v, response %+v", cookie, login)
+	}
+
+	// Reads work with the cookie alone; the person comes from the session.
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, sessionRequest("GET", "/api/files/read?path=notes/secret.md", "", cookie, ""))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Sebastian's Secret") {
+		t.Fatalf("session read: %d %s", rec.Code, rec.Body.String())
+	}
+	req := sessionRequest("GET", "/api/files/read?path=notes/secret.md", "", cookie, "")
+	req.Header.Set("X-Notes-Person", "petra")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("session cross-person read: %d", rec.Code)
+	}
+
+	// Writes need the CSRF token.
+	body := `{"path":"notes/new.md","content":"hi"}`
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, sessionRequest("POST", "/api/files/save", body, cookie, ""))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("write without CSRF token: %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, sessionRequest("POST", "/api/files/save", body, cookie, "wrong"))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("write with wrong CSRF token: %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, sessionRequest("POST", "/api/files/save", body, cookie, login.CSRFToken))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("write with CSRF token: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Logout ends the session.
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, sessionRequest("POST", "/api/auth/logout", "", cookie, login.CSRFToken))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("logout: %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, sessionRequest("GET", "/api/daily", "", cookie, ""))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("after logout: %d", rec.Code)
+	}
+}
+
+func TestSessions_ListAndRemoteLogout(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	setPassword(t, router, "petra", "petra's long password")
+	_, phone, phoneLogin := passwordLogin(t, router, "petra", "petra's long password")
+	_, laptop, _ := passwordLogin(t, router, "petra", "petra's long password")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, sessionRequest("GET", "/api/auth/sessions", "", phone, ""))
+	var sessions []SessionInfo
+	json.Unmarshal(rec.Body.Bytes(), &sessions)
+	if len(sessions) != 2 || strings.Contains(rec.Body.String(), "csrf") {
+		t.Fatalf("sessions: %s", rec.Body.String())
+	}
+	var laptopID string
+	for _, sess := range sessions {
+		if !sess.Current {
+			laptopID = sess.ID
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, sessionRequest("POST", "/api/auth/sessions/revoke", `{"id":"`+laptopID+`"}`, phone, phoneLogin.CSRFToken))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("revoke: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, sessionRequest("GET", "/api/daily", "", laptop, ""))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked session: %d", rec.Code)
+	}
+}
+
+func TestSetPassword_RequiresCurrentPassword(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	created := createToken(t, router, "sebastian")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, personTokenRequest("POST", "/api/auth/password", `{"new_password":"first password!"}`, created.Token, ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("first password: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, personTokenRequest("POST", "/api/auth/password", `{"new_password":"second password!"}`, created.Token, ""))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("change without current password: %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, personTokenRequest("POST", "/api/auth/password", `{"current_password":"first password!","new_password":"short"}`, created.Token, ""))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("short password: %d", rec.Code)
+	}
+}
+
+func TestPasskeys(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/api/auth/passkeys/login/begin", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("passkeys without config: %d", rec.Code)
+	}
+
+	srv.webauthn = newWebAuthn(config.LoginConfig{
+		WebAuthnRPID:    "notes.example",
+		WebAuthnRPName:  "Notes",
+		WebAuthnOrigins: []string{"https://notes.example"},
+	})
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, makeRequest(t, "POST", "/api/auth/passkeys/register/begin", `{"name":"laptop"}`, "sebastian"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("register begin: %d %s", rec.Code, rec.Body.String())
+	}
+	var begin struct {
+		Ceremony string `json:"ceremony"`
+		Options  struct {
+			PublicKey struct {
+				Challenge string `json:"challenge"`
+				RP        struct {
+					ID string `json:"id"`
+				} `json:"rp"`
+			} `json:"publicKey"`
+		} `json:"options"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &begin)
+	if begin.Ceremony == "" || begin.Options.PublicKey.Challenge == "" || begin.Options.PublicKey.RP.ID != "notes.example" {
+		t.Fatalf("register options: %s", rec.Body.String())
+	}
+
+	// A ceremony started for one person cannot be finished as another.
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, makeRequest(t, "POST", "/api/auth/passkeys/register/finish?ceremony="+begin.Ceremony, `{}`, "petra"))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "ceremony") {
+		t.Fatalf("cross-person finish: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/api/auth/passkeys/login/finish?ceremony=unknown", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown login ceremony: %d", rec.Code)
+	}
+}
@@ -12,8 +12,12 @@ import (
 	"notes-editor/internal/auth"
 )
 
-// AuthMiddleware validates the Bearer token from the Authorization header.
-func AuthMiddleware(token string) func(http.Handler) http.Handler {
+// AuthMiddleware validates the Bearer token from the Authorization header. The
+// legacy adminToken (NOTES_TOKEN) authenticates as admin; per-person tokens from
+// tokens authenticate as their person. Requests without an Authorization header
+// may authenticate with a login session cookie from sessions instead. tokens and
+// sessions may be nil.
+func AuthMiddleware(adminToken string, tokens *auth.Tokens, sessions *auth.Sessions) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Skip auth for OAuth callback
@@ -24,6 +28,10 @@ func AuthMiddleware(token string) func(http.Handler) http.Handler {
 
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
+				if sessions != nil {
+					authenticateSession(w, r, sessions, next)
+					return
+				}
 				writeUnauthorized(w)
 				return
 			}
@@ -35,20 +43,92 @@ func AuthMiddleware(token string) func(http.Handler) http.Handler {
 				return
 			}
 
-			if !auth.ValidateToken(parts[1], token) {
+			if auth.ValidateToken(parts[1], adminToken) {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{Role: auth.RoleAdmin}))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if tokens == nil {
+				writeUnauthorized(w)
+				return
+			}
+			tok, found, err := tokens.Verify(parts[1])
+			if err != nil {
+				log.Printf("token verification failed: %v", err)
+			}
+			if found && tok.IsAdmin() {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{Role: auth.RoleAdmin, TokenID: tok.ID}))
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !found || !auth.IsValidPerson(tok.Person) {
 				writeUnauthorized(w)
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			ctx := auth.WithPrincipal(r.Context(), auth.Principal{
+				Role:    auth.RolePerson,
+				Person:  tok.Person,
+				Scopes:  tok.EffectiveScopes(),
+				TokenID: tok.ID,
+			})
+			next.ServeHTTP(w, r.WithContext(auth.WithPerson(ctx, tok.Person)))
 		})
 	}
 }
 
+// authenticateSession serves r as the person of its session cookie. Unsafe methods
+// must echo the session's CSRF token in the X-CSRF-Token header.
+func authenticateSession(w http.ResponseWriter, r *http.Request, sessions *auth.Sessions, next http.Handler) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		writeUnauthorized(w)
+		return
+	}
+	sess, found, err := sessions.Lookup(cookie.Value)
+	if err != nil {
+		log.Printf("session lookup failed: %v", err)
+	}
+	if !found || !auth.IsValidPerson(sess.Person) {
+		writeUnauthorized(w)
+		return
+	}
+	if !isSafeMethod(r.Method) {
+		csrf := r.Header.Get(csrfHeaderName)
+		if csrf == "" || !auth.ValidateToken(csrf, sess.CSRFToken) {
+			writeForbidden(w, "Invalid CSRF token")
+			return
+		}
+	}
+
+	ctx := auth.WithPrincipal(r.Context(), auth.Principal{
+		Role:      auth.RolePerson,
+		Person:    sess.Person,
+		Scopes:    auth.PersonScopes,
+		SessionID: sess.ID,
+	})
+	next.ServeHTTP(w, r.WithContext(auth.WithPerson(ctx, sess.Person)))
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
 // PersonMiddleware extracts the X-Notes-Person header and adds it to context.
+// Callers with a per-person token are bound to their person: the header may be
+// omitted, and naming anyone else is rejected.
 func PersonMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		person := r.Header.Get("X-Notes-Person")
+		if p, ok := auth.PrincipalFromContext(r.Context()); ok && !p.IsAdmin() {
+			if person != "" && person != p.Person {
+				writeForbidden(w, "Token is not valid for this person")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
 		if person != "" {
 			if !auth.IsValidPerson(person) {
 				writeBadRequest(w, "Invalid person")
@@ -60,6 +140,24 @@ func PersonMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// RequireScope rejects callers that hold none of scopes. Admins pass every check.
+func RequireScope(scopes ...auth.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := auth.PrincipalFromContext(r.Context())
+			if ok {
+				for _, scope := range scopes {
+					if p.HasScope(scope) {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+			writeForbidden(w, "Token lacks the required scope")
+		})
+	}
+}
+
 // requirePerson is a helper that returns the person from context or writes an error.
 func requirePerson(w http.ResponseWriter, r *http.Request) (string, bool) {
 	person := auth.PersonFromContext(r.Context())
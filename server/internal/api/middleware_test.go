@@ -11,7 +11,7 @@ import (
 
 func TestAuthMiddleware(t *testing.T) {
 	const validToken = "secret-token-123"
-	middleware := AuthMiddleware(validToken)
+	middleware := AuthMiddleware(validToken, nil, nil)
 
 	// Dummy handler that returns 200 OK if reached
 	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -116,7 +116,7 @@ func TestAuthMiddleware(t *testing.T) {
 }
 
 func TestAuthMiddleware_SkipsLinkedInCallback(t *testing.T) {
-	middleware := AuthMiddleware("secret-token")
+	middleware := AuthMiddleware("secret-token", nil, nil)
 
 	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -0,0 +1,336 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"notes-editor/internal/auth"
+	"notes-editor/internal/export"
+	"notes-editor/internal/publish"
+)
+
+// publicCSP locks published pages down to their own inline styles and inlined images.
+const publicCSP = "default-src 'none'; img-src data:; style-src 'unsafe-inline'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+// PublishedNote is a published note in the publishing overview.
+type PublishedNote struct {
+	Path  string `json:"path"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// ShareLink is a share link in the publishing overview. Token and URL are only
+// returned when the link is created.
+type ShareLink struct {
+	ID        string    `json:"id"`
+	Token     string    `json:"token,omitempty"`
+	Path      string    `json:"path"`
+	URL       string    `json:"url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PublishStatusResponse lists the person's published notes and share links.
+type PublishStatusResponse struct {
+	Notes  []PublishedNote `json:"notes"`
+	Shares []ShareLink     `json:"shares"`
+}
+
+// CreateShareRequest creates a share link for one note.
+type CreateShareRequest struct {
+	Path string `json:"path"`
+}
+
+// RevokeShareRequest revokes a share link by ID or by its token.
+type RevokeShareRequest struct {
+	ID    string `json:"id"`
+	Token string `json:"token"`
+}
+
+func publicNoteURL(person, rel string) string {
+	return (&url.URL{Path: "/p/" + person + "/" + rel}).EscapedPath()
+}
+
+func publicShareURL(token string) string {
+	return "/p/s/" + token
+}
+
+func newShareLink(sh publish.Share, token string) ShareLink {
+	link := ShareLink{ID: sh.ID, Path: sh.Path, CreatedAt: sh.CreatedAt}
+	if token != "" {
+		link.Token = token
+		link.URL = publicShareURL(token)
+	}
+	return link
+}
+
+// handlePublishStatus lists what the selected person currently exposes publicly.
+func (s *Server) handlePublishStatus(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.RLock()
+	paths, err := s.published.Published(person)
+	var collection *export.Collection
+	if err == nil && len(paths) > 0 {
+		collection, err = export.CollectPaths(s.store, person, paths)
+	}
+	var shares []publish.Share
+	if err == nil {
+		shares, err = s.shares.List(person)
+	}
+	s.mu.RUnlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := PublishStatusResponse{Notes: make([]PublishedNote, 0, len(paths)), Shares: make([]ShareLink, 0, len(shares))}
+	for _, p := range paths {
+		resp.Notes = append(resp.Notes, PublishedNote{Path: p, Title: collection.PageTitle(p), URL: publicNoteURL(person, p)})
+	}
+	for _, sh := range shares {
+		resp.Shares = append(resp.Shares, newShareLink(sh, ""))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleCreateShare creates a revocable share link for a single note. Creating the
+// link is the explicit act of publishing that one note.
+func (s *Server) handleCreateShare(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, "Invalid request body")
+		return
+	}
+	path := strings.Trim(req.Path, "/")
+	if path == "" {
+		writeBadRequest(w, "Path is required")
+		return
+	}
+
+	s.mu.Lock()
+	if _, err := publish.ReadNote(s.store, person, path); err != nil {
+		s.mu.Unlock()
+		writeNotFound(w, "Note not found")
+		return
+	}
+	share, token, err := s.shares.Create(person, path)
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newShareLink(share, token))
+}
+
+// handleRevokeShare revokes one of the person's share links.
+func (s *Server) handleRevokeShare(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+
+	var req RevokeShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, "Invalid request body")
+		return
+	}
+	id := req.ID
+	if id == "" && req.Token != "" {
+		share, found, err := s.shares.Lookup(req.Token)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if found {
+			id = share.ID
+		}
+	}
+	if id == "" && req.Token == "" {
+		writeBadRequest(w, "ID or token is required")
+		return
+	}
+
+	err := s.shares.Revoke(person, id)
+	if errors.Is(err, publish.ErrShareNotFound) {
+		writeNotFound(w, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeSuccess(w, "Share link revoked")
+}
+
+// handlePublicIndex lists a person's published notes. It is unauthenticated.
+func (s *Server) handlePublicIndex(w http.ResponseWriter, r *http.Request) {
+	person := chi.URLParam(r, "person")
+	if !auth.IsValidPerson(person) {
+		writePublicNotFound(w)
+		return
+	}
+
+	var buf bytes.Buffer
+	s.mu.RLock()
+	paths, err := s.published.Published(person)
+	if err == nil && len(paths) > 0 {
+		var collection *export.Collection
+		collection, err = export.CollectPaths(s.store, person, paths)
+		if err == nil {
+			collection.Title = person
+			err = collection.WritePublicIndex(&buf, func(p string) string { return publicNoteURL(person, p) })
+		}
+	}
+	s.mu.RUnlock()
+	if err != nil {
+		log.Printf("public index failed for person=%s: %v", person, err)
+	}
+	if err != nil || len(paths) == 0 {
+		writePublicNotFound(w)
+		return
+	}
+	writePublicPage(w, buf.Bytes())
+}
+
+// handlePublicNote serves one published note. It is unauthenticated.
+func (s *Server) handlePublicNote(w http.ResponseWriter, r *http.Request) {
+	person := chi.URLParam(r, "person")
+	rel := chi.URLParam(r, "*")
+	if rel == "" {
+		s.handlePublicIndex(w, r)
+		return
+	}
+	if r.URL.RawPath != "" {
+		unescaped, err := url.PathUnescape(rel)
+		if err != nil {
+			writePublicNotFound(w)
+			return
+		}
+		rel = unescaped
+	}
+	if !auth.IsValidPerson(person) {
+		writePublicNotFound(w)
+		return
+	}
+
+	var buf bytes.Buffer
+	s.mu.RLock()
+	err := s.renderPublicNote(&buf, person, rel, "", publicNoteURL(person, ""))
+	s.mu.RUnlock()
+	if err != nil {
+		if !errors.Is(err, publish.ErrNotPublished) {
+			log.Printf("public note failed for person=%s: %v", person, err)
+		}
+		writePublicNotFound(w)
+		return
+	}
+	writePublicPage(w, buf.Bytes())
+}
+
+// handlePublicShare serves the note behind a share link. It is unauthenticated.
+func (s *Server) handlePublicShare(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	var buf bytes.Buffer
+	s.mu.RLock()
+	share, found, err := s.shares.Lookup(token)
+	if err == nil && !found {
+		err = publish.ErrNotPublished
+	}
+	if err == nil && !auth.IsValidPerson(share.Person) {
+		err = publish.ErrNotPublished
+	}
+	if err == nil {
+		err = s.renderPublicNote(&buf, share.Person, share.Path, token, "")
+	}
+	s.mu.RUnlock()
+	if err != nil {
+		if !errors.Is(err, publish.ErrNotPublished) {
+			log.Printf("public share failed: %v", err)
+		}
+		writePublicNotFound(w)
+		return
+	}
+	writePublicPage(w, buf.Bytes())
+}
+
+// renderPublicNote renders rel for the public site. Without a share token the note
+// must be published. Links only resolve to published notes, so nothing private can
+// be reached from a public page. Callers hold the vault read lock.
+func (s *Server) renderPublicNote(buf *bytes.Buffer, person, rel, shareToken, homeURL string) error {
+	if shareToken == "" {
+		if _, err := publish.ReadPublished(s.store, person, rel); err != nil {
+			return err
+		}
+	} else if _, err := publish.ReadNote(s.store, person, rel); err != nil {
+		return err
+	}
+
+	published, err := s.published.Published(person)
+	if err != nil {
+		return err
+	}
+	paths := published
+	isPublished := make(map[string]bool, len(published))
+	for _, p := range published {
+		isPublished[p] = true
+	}
+	if !isPublished[rel] {
+		paths = append([]string{rel}, published...)
+	}
+
+	collection, err := export.CollectPaths(s.store, person, paths)
+	if err != nil {
+		return err
+	}
+	collection.Title = person
+	if shareToken != "" {
+		collection.Title = "Shared note"
+	}
+	return collection.WritePublicPage(buf, rel, func(p string) string {
+		if p == rel && shareToken != "" {
+			return publicShareURL(shareToken)
+		}
+		if isPublished[p] {
+			return publicNoteURL(person, p)
+		}
+		return ""
+	}, homeURL)
+}
+
+func setPublicHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Security-Policy", publicCSP)
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+func writePublicPage(w http.ResponseWriter, body []byte) {
+	setPublicHeaders(w)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func writePublicNotFound(w http.ResponseWriter) {
+	setPublicHeaders(w)
+	http.Error(w, "Not found", http.StatusNotFound)
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPublishing(t *testing.T) {
+	srv, vaultRoot, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	write := func(rel, content string) {
+		full := filepath.Join(vaultRoot, "sebastian", rel)
+		os.MkdirAll(filepath.Dir(full), 0755)
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("notes/trip.md", "---\npublish: true\n---\n# Trip to Rome\n\n![colosseum](img/c.png) See [about](../public/about%20me.md), [secret](secret.md) and [[secret]].\n")
+	write("notes/img/c.png", "PNG")
+	write("public/about me.md", "# About me\n")
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	t.Run("published note is served without auth", func(t *testing.T) {
+		rec := get("/p/sebastian/notes/trip.md")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d; body = %s", rec.Code, rec.Body.String())
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, "Trip to Rome") || strings.Contains(body, "publish: true") {
+			t.Fatalf("unexpected body:\n%s", body)
+		}
+		if !strings.Contains(body, `href="/p/sebastian/public/about%20me.md"`) || !strings.Contains(body, `src="data:image/png;base64,`) {
+			t.Fatalf("links not resolved:\n%s", body)
+		}
+		if strings.Contains(body, "secret.md") {
+			t.Fatalf("link to a private note leaked:\n%s", body)
+		}
+		if csp := rec.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "default-src 'none'") {
+			t.Fatalf("missing CSP header: %q", csp)
+		}
+	})
+
+	t.Run("index lists only published notes", func(t *testing.T) {
+		rec := get("/p/sebastian/")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d", rec.Code)
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, "About me") || !strings.Contains(body, "Trip to Rome") || strings.Contains(body, "Secret") {
+			t.Fatalf("unexpected index:\n%s", body)
+		}
+	})
+
+	t.Run("private notes are never served", func(t *testing.T) {
+		for _, path := range []string{
+			"/p/sebastian/notes/secret.md",
+			"/p/sebastian/notes/img/c.png",
+			"/p/sebastian/../petra/notes/secret.md",
+			"/p/sebastian/%2e%2e/petra/notes/secret.md",
+			"/p/petra/notes/secret.md",
+			"/p/petra/",
+			"/p/nobody/notes/trip.md",
+		} {
+			rec := get(path)
+			if rec.Code == http.StatusOK || strings.Contains(rec.Body.String(), "Secret") {
+				t.Errorf("%s: status = %d, body = %s", path, rec.Code, rec.Body.String())
+			}
+		}
+	})
+
+	t.Run("share links can be created and revoked", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, makeRequest(t, "POST", "/api/publish/shares", `{"path":"notes/secret.md"}`, "sebastian"))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("create status = %d; body = %s", rec.Code, rec.Body.String())
+		}
+		var share ShareLink
+		if err := json.Unmarshal(rec.Body.Bytes(), &share); err != nil {
+			t.Fatal(err)
+		}
+
+		shared := get(share.URL)
+		if shared.Code != http.StatusOK || !strings.Contains(shared.Body.String(), "Sebastian") {
+			t.Fatalf("share status = %d; body = %s", shared.Code, shared.Body.String())
+		}
+		// Sharing one note does not publish it on the site.
+		if rec := get("/p/sebastian/notes/secret.md"); rec.Code != http.StatusNotFound {
+			t.Fatalf("shared note exposed on site: %d", rec.Code)
+		}
+
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, makeRequest(t, "GET", "/api/publish", "", "sebastian"))
+		var status PublishStatusResponse
+		json.Unmarshal(rec.Body.Bytes(), &status)
+		if len(status.Notes) != 2 || len(status.Shares) != 1 || status.Shares[0].ID != share.ID || status.Shares[0].Token != "" {
+			t.Fatalf("unexpected status: %s", rec.Body.String())
+		}
+
+		// Petra cannot revoke Sebastian's link.
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, makeRequest(t, "POST", "/api/publish/shares/revoke", `{"token":"`+share.Token+`"}`, "petra"))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("cross-person revoke status = %d", rec.Code)
+		}
+
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, makeRequest(t, "POST", "/api/publish/shares/revoke", `{"id":"`+share.ID+`"}`, "sebastian"))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("revoke status = %d; body = %s", rec.Code, rec.Body.String())
+		}
+		if rec := get(share.URL); rec.Code != http.StatusNotFound {
+			t.Fatalf("revoked share still served: %d", rec.Code)
+		}
+	})
+
+	t.Run("share requires an existing note", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, makeRequest(t, "POST", "/api/publish/shares", `{"path":"../petra/notes/secret.md"}`, "sebastian"))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("status = %d", rec.Code)
+		}
+	})
+}
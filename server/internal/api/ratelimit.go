@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"notes-editor/internal/auth"
+	"notes-editor/internal/config"
+	"notes-editor/internal/ratelimit"
+)
+
+// rateLimits holds the server's request limiters. Nil members are disabled.
+type rateLimits struct {
+	ip        *ratelimit.Limiter
+	client    *ratelimit.Limiter
+	expensive *ratelimit.Limiter
+	lockout   *ratelimit.Lockout
+}
+
+func newRateLimits(cfg config.RateLimitConfig) *rateLimits {
+	return &rateLimits{
+		ip:        ratelimit.NewLimiter(cfg.PerIP),
+		client:    ratelimit.NewLimiter(cfg.PerClient),
+		expensive: ratelimit.NewLimiter(cfg.Expensive),
+		lockout:   ratelimit.NewLockout(cfg.LockoutFailures, cfg.LockoutDuration),
+	}
+}
+
+// IPRateLimitMiddleware limits requests per remote IP address.
+func IPRateLimitMiddleware(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ok, wait := limiter.Allow(remoteHost(r)); !ok {
+				writeTooManyRequests(w, wait)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientRateLimitMiddleware limits requests per token or login session. It must
+// run after AuthMiddleware; unauthenticated requests are not counted.
+func ClientRateLimitMiddleware(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if p, ok := auth.PrincipalFromContext(r.Context()); ok {
+				if ok, wait := limiter.Allow(clientName(p)); !ok {
+					writeTooManyRequests(w, wait)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// lockoutAuthKey holds the *bool that markAuthenticated sets for
+// AuthLockoutMiddleware.
+type lockoutAuthKey struct{}
+
+// AuthLockoutMiddleware locks out a remote IP address after repeated 401
+// responses, i.e. invalid tokens, sessions, passwords or passkeys. Only a request
+// that authenticated clears the failures, so requests to routes that need no
+// credentials cannot be interleaved with guesses.
+func AuthLockoutMiddleware(lockout *ratelimit.Lockout) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if lockout == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := remoteHost(r)
+			if wait := lockout.Locked(host); wait > 0 {
+				writeTooManyRequests(w, wait)
+				return
+			}
+
+			wrapped := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+			authenticated := false
+			next.ServeHTTP(wrapped, r.WithContext(context.WithValue(r.Context(), lockoutAuthKey{}, &authenticated)))
+			switch {
+			case wrapped.status == http.StatusUnauthorized:
+				lockout.Fail(host)
+			case authenticated:
+				lockout.Reset(host)
+			}
+		})
+	}
+}
+
+// AuthSuccessMiddleware reports requests that passed AuthMiddleware to
+// AuthLockoutMiddleware. It belongs after AuthMiddleware.
+func AuthSuccessMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := auth.PrincipalFromContext(r.Context()); ok {
+			markAuthenticated(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// markAuthenticated tells AuthLockoutMiddleware that r presented valid
+// credentials.
+func markAuthenticated(r *http.Request) {
+	if authenticated, ok := r.Context().Value(lockoutAuthKey{}).(*bool); ok {
+		*authenticated = true
+	}
+}
+
+// writeTooManyRequests writes a 429 with Retry-After in whole seconds.
+func writeTooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeError(w, http.StatusTooManyRequests, "Too many requests")
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"notes-editor/internal/config"
+)
+
+func TestAuthLockout(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	srv.limits = newRateLimits(config.RateLimitConfig{LockoutFailures: 3, LockoutDuration: time.Minute})
+	router := NewRouter(srv)
+
+	badRequest := func() *http.Request {
+		req := httptest.NewRequest("GET", "/api/auth/whoami", nil)
+		req.Header.Set("Authorization", "Bearer wrong")
+		return req
+	}
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, badRequest())
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i, rec.Code)
+		}
+	}
+
+	// Locked out, even with the right token
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, makeRequest(t, "GET", "/api/auth/whoami", "", ""))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "60" {
+		t.Fatalf("Retry-After = %q", rec.Header().Get("Retry-After"))
+	}
+
+	// Login attempts from the same address are locked out too
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/api/auth/login", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("login: expected 429, got %d", rec.Code)
+	}
+
+	// Other addresses are not affected
+	req := makeRequest(t, "GET", "/api/auth/whoami", "", "")
+	req.RemoteAddr = "192.0.2.99:1234"
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("other address: expected 200, got %d", rec.Code)
+	}
+}
+
+func TestAuthLockout_OnlyAuthenticationResets(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	srv.limits = newRateLimits(config.RateLimitConfig{LockoutFailures: 3, LockoutDuration: time.Minute})
+	srv.webauthn = newWebAuthn(config.LoginConfig{
+		WebAuthnRPID:    "notes.example",
+		WebAuthnRPName:  "Notes",
+		WebAuthnOrigins: []string{"https://notes.example"},
+	})
+	router := NewRouter(srv)
+	setPassword(t, router, "sebastian", "correct horse battery")
+
+	login := func(addr, password string) int {
+		req := httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(`{"person":"sebastian","password":"`+password+`"}`))
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// A 200 from a route that needs no credentials does not clear failures.
+	const attacker = "192.0.2.10:1234"
+	for i := 0; i < 3; i++ {
+		if code := login(attacker, "wrong horse battery"); code != http.StatusUnauthorized {
+			t.Fatalf("guess %d: expected 401, got %d", i, code)
+		}
+		req := httptest.NewRequest("POST", "/api/auth/passkeys/login/begin", nil)
+		req.RemoteAddr = attacker
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if i < 2 && rec.Code != http.StatusOK {
+			t.Fatalf("passkey begin %d: expected 200, got %d", i, rec.Code)
+		}
+	}
+	if code := login(attacker, "correct horse battery"); code != http.StatusTooManyRequests {
+		t.Fatalf("after interleaved guesses: expected 429, got %d", code)
+	}
+
+	// A successful login does.
+	const user = "192.0.2.11:1234"
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 2; i++ {
+			if code := login(user, "wrong horse battery"); code != http.StatusUnauthorized {
+				t.Fatalf("round %d typo %d: expected 401, got %d", round, i, code)
+			}
+		}
+		if code := login(user, "correct horse battery"); code != http.StatusOK {
+			t.Fatalf("round %d login: expected 200, got %d", round, code)
+		}
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	t.Run("per IP", func(t *testing.T) {
+		srv, _, cleanup := setupTestServer(t)
+		defer cleanup()
+		srv.limits = newRateLimits(config.RateLimitConfig{PerIP: 2})
+		router := NewRouter(srv)
+
+		for i := 0; i < 2; i++ {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, makeRequest(t, "GET", "/api/auth/whoami", "", ""))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("request %d: expected 200, got %d", i, rec.Code)
+			}
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", "/index.html", nil))
+		if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+			t.Fatalf("expected 429 with Retry-After, got %d", rec.Code)
+		}
+	})
+
+	t.Run("per client", func(t *testing.T) {
+		srv, _, cleanup := setupTestServer(t)
+		defer cleanup()
+		srv.limits = newRateLimits(config.RateLimitConfig{PerClient: 2})
+		router := NewRouter(srv)
+		tok := createToken(t, router, "petra")
+
+		for i := 0; i < 2; i++ {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, personTokenRequest("GET", "/api/auth/whoami", "", tok.Token, ""))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("request %d: expected 200, got %d", i, rec.Code)
+			}
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, personTokenRequest("GET", "/api/auth/whoami", "", tok.Token, ""))
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected 429, got %d", rec.Code)
+		}
+
+		// The admin token has its own budget
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, makeRequest(t, "GET", "/api/auth/whoami", "", ""))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("admin: expected 200, got %d", rec.Code)
+		}
+	})
+
+	t.Run("expensive routes", func(t *testing.T) {
+		srv, _, cleanup := setupTestServer(t)
+		defer cleanup()
+		srv.limits = newRateLimits(config.RateLimitConfig{Expensive: 1})
+		router := NewRouter(srv)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, makeRequest(t, "GET", "/api/settings/vault-backup", "", "sebastian"))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, makeRequest(t, "GET", "/api/settings/vault-backup", "", "sebastian"))
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected 429, got %d", rec.Code)
+		}
+
+		// Cheap routes are not limited by the expensive budget
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, makeRequest(t, "GET", "/api/files/list?path=", "", "sebastian"))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("files: expected 200, got %d", rec.Code)
+		}
+	})
+}
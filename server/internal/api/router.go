@@ -1,6 +1,7 @@
 package api
 
 import (
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -9,12 +10,18 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
+	"github.com/go-webauthn/webauthn/webauthn"
 
 	"notes-editor/internal/agent"
+	"notes-editor/internal/audit"
 	"notes-editor/internal/auth"
+	"notes-editor/internal/backup"
+	"notes-editor/internal/certs"
 	"notes-editor/internal/claude"
 	"notes-editor/internal/config"
 	"notes-editor/internal/linkedin"
+	"notes-editor/internal/publish"
+	"notes-editor/internal/sandbox"
 	"notes-editor/internal/sleep"
 	"notes-editor/internal/vault"
 )
@@ -30,9 +37,22 @@ type Server struct {
 	indexMgr      *IndexManager
 	claude        *claude.Service
 	agent         *agent.Service
+	scheduler     *agent.Scheduler
 	linkedin      *linkedin.Service
 	sleepStore    *sleep.Store
+	agentSessions *agent.SessionStore
 	sleepMigrated bool
+	backups       *backup.Manager
+	shares        *publish.Shares
+	published     *publish.Index
+	tokens        *auth.Tokens
+	logins        *auth.Logins
+	sessions      *auth.Sessions
+	webauthn      *webauthn.WebAuthn
+	ceremonies    *ceremonyStore
+	audit         *audit.Log
+	limits        *rateLimits
+	localCA       *certs.LocalCA
 }
 
 // NewServer creates a new server with all dependencies.
@@ -40,10 +60,22 @@ func NewServer(cfg *config.Config) *Server {
 	auth.SetValidPersons(cfg.ValidPersons)
 
 	store := vault.NewStore(cfg.NotesRoot)
+	applySharedFolders(store, cfg.SharedFolders)
+	applyEncryptedFolders(store, cfg)
 	daily := vault.NewDaily(store)
 	git := vault.NewGit(cfg.NotesRoot)
 
-	linkedinSvc, claudeSvc, agentSvc := buildRuntimeServices(cfg, store)
+	auditLog, err := audit.Open(cfg.AuditDBPath(), cfg.AuditRetention)
+	if err != nil {
+		log.Printf("audit log disabled: %v", err)
+	}
+
+	agentSessions, err := agent.OpenSessionStore(cfg.AgentDBPath())
+	if err != nil {
+		log.Printf("agent session database unavailable, keeping sessions in memory: %v", err)
+	}
+
+	linkedinSvc, claudeSvc, agentSvc := buildRuntimeServices(cfg, store, auditToolObserver(auditLog), agentSessions, nil)
 
 	srv := &Server{
 		config:   cfg,
@@ -53,24 +85,83 @@ func NewServer(cfg *config.Config) *Server {
 		claude:   claudeSvc,
 		agent:    agentSvc,
 		linkedin: linkedinSvc,
+		audit:    auditLog,
+		limits:   newRateLimits(cfg.RateLimit),
+		localCA:  newLocalCA(cfg),
 	}
 
+	srv.agentSessions = agentSessions
 	if sleepStore, err := sleep.NewStore(sleepDBPath(cfg.NotesRoot)); err == nil {
 		srv.sleepStore = sleepStore
 	}
 
+	srv.shares = publish.NewShares(cfg.SharesPath())
+	srv.published = publish.NewIndex(store)
+	srv.tokens = auth.NewTokens(cfg.TokensPath())
+	srv.logins = auth.NewLogins(cfg.LoginsPath())
+	srv.webauthn = newWebAuthn(cfg.Login)
+	srv.ceremonies = newCeremonyStore()
+	if sessions, err := auth.NewSessions(cfg.SessionDBPath(), cfg.Login.SessionTTL); err == nil {
+		srv.sessions = sessions
+		if err := sessions.Prune(); err != nil {
+			log.Printf("pruning login sessions failed: %v", err)
+		}
+	} else {
+		log.Printf("login sessions disabled: %v", err)
+	}
+
+	srv.backups = backup.NewManager(srv.backupOptions())
+	srv.backups.Start()
+
 	// Background git sync (pull/push) for the vault. This avoids doing networked git
 	// operations in read handlers while still keeping clients reasonably up to date.
 	srv.syncMgr = NewSyncManager(&srv.mu, git)
 	srv.indexMgr = NewIndexManager(cfg.NotesRoot, cfg.ValidPersons)
 	srv.syncMgr.SetHooks(
-		func() { srv.indexMgr.TriggerReindex("sync pull success") },
+		func() {
+			store.MarkChanged()
+			srv.indexMgr.TriggerReindex("sync pull success")
+		},
 		func() { srv.indexMgr.TriggerReindex("sync push success") },
 	)
+	srv.configureAgentTools(claudeSvc)
+	if migrated, err := srv.shares.ImportLegacy(store); err != nil {
+		log.Printf("share link migration failed: %v", err)
+	} else if migrated {
+		log.Printf("moved share links out of the vault to %s; their tokens remain in git history", cfg.SharesPath())
+		srv.syncMgr.TriggerPush("Move share links out of the vault")
+	}
+	if migrated, err := srv.tokens.ImportLegacy(store); err != nil {
+		log.Printf("API token migration failed: %v", err)
+	} else if migrated {
+		log.Printf("moved API token hashes out of the vault to %s", cfg.TokensPath())
+		srv.syncMgr.TriggerPush("Move API tokens out of the vault")
+	}
+	if migrated, err := srv.logins.ImportLegacy(store); err != nil {
+		log.Printf("login migration failed: %v", err)
+	} else if migrated {
+		log.Printf("moved passwords and passkeys out of the vault to %s", cfg.LoginsPath())
+		srv.syncMgr.TriggerPush("Move logins out of the vault")
+	}
 	srv.syncMgr.Start()
 	srv.indexMgr.Start()
 	srv.indexMgr.TriggerReindex("startup")
 
+	srv.scheduler = agent.NewScheduler(agent.SchedulerOptions{
+		Service: srv.getAgent,
+		Persons: func() []string {
+			srv.mu.RLock()
+			defer srv.mu.RUnlock()
+			return append([]string(nil), srv.config.ValidPersons...)
+		},
+		AfterRun:  func(string) { srv.syncMgr.TriggerPush("Scheduled agent action") },
+		VaultLock: &srv.mu,
+	})
+	srv.scheduler.Start()
+	store.SetChangeHook(func(change vault.Change) {
+		srv.scheduler.Notify(agent.Event{Kind: change.Kind, Person: change.Person, Path: change.Path, Source: change.Source})
+	})
+
 	return srv
 }
 
@@ -81,101 +172,197 @@ func NewRouter(srv *Server) http.Handler {
 	// Global middleware (no auth)
 	r.Use(RecovererMiddleware)
 	r.Use(LoggingMiddleware)
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Notes-Person"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
-
-	// API routes with auth
+	r.Use(SecurityHeadersMiddleware(srv.config.Security))
+	r.Use(cors.Handler(corsOptions(srv.config.Security)))
+	r.Use(IPRateLimitMiddleware(srv.limits.ip))
+
+	// Cookie sessions and logins are only accepted from expected origins in
+	// strict mode.
+	originCheck := func(next http.Handler) http.Handler { return next }
+	if srv.config.Security.StrictOrigin {
+		originCheck = StrictOriginMiddleware(srv.config.Security.AllowedOrigins)
+	}
+
+	// Login routes (no auth; they create sessions)
+	r.Group(func(r chi.Router) {
+		r.Use(AuditMiddleware(srv.audit))
+		r.Use(AuthLockoutMiddleware(srv.limits.lockout))
+		r.Use(originCheck)
+		r.Post("/api/auth/login", srv.handlePasswordLogin)
+		r.Post("/api/auth/passkeys/login/begin", srv.handlePasskeyLoginBegin)
+		r.Post("/api/auth/passkeys/login/finish", srv.handlePasskeyLoginFinish)
+	})
+
+	// API routes with auth. Each group requires a token scope; the admin token and
+	// tokens with the admin scope pass every group.
 	r.Route("/api", func(r chi.Router) {
-		r.Use(AuthMiddleware(srv.config.NotesToken))
+		r.Use(AuditMiddleware(srv.audit))
+		r.Use(AuthLockoutMiddleware(srv.limits.lockout))
+		r.Use(originCheck)
+		r.Use(AuthMiddleware(srv.config.NotesToken, srv.tokens, srv.sessions))
+		r.Use(AuthSuccessMiddleware)
+		r.Use(ClientRateLimitMiddleware(srv.limits.client))
 		r.Use(PersonMiddleware)
-
-		// Daily note routes
-		r.Get("/daily", srv.handleGetDaily)
-		r.Post("/save", srv.handleSaveDaily)
-		r.Post("/append", srv.handleAppendDaily)
-		r.Post("/clear-pinned", srv.handleClearPinned)
-
-		// Git sync routes
-		r.Post("/sync", srv.handleSync)
-		r.Get("/sync/status", srv.handleSyncStatus)
-		r.Get("/sync/index-status", srv.handleIndexStatus)
-		r.Get("/git/status", srv.handleGitStatus)
-		r.Post("/git/commit", srv.handleGitCommit)
-		r.Post("/git/push", srv.handleGitPush)
-		r.Post("/git/pull", srv.handleGitPull)
-		r.Post("/git/commit-push", srv.handleGitCommitPush)
-		r.Post("/git/reset-clean", srv.handleGitResetClean)
-
-		// Todo routes
-		r.Post("/todos/add", srv.handleAddTodo)
-		r.Post("/todos/toggle", srv.handleToggleTodo)
-
-		// Sleep times routes
-		r.Get("/sleep-times", srv.handleGetSleepTimes)
-		r.Get("/sleep-times/summary", srv.handleGetSleepSummary)
-		r.Post("/sleep-times/append", srv.handleAppendSleepTime)
-		r.Post("/sleep-times/update", srv.handleUpdateSleepTime)
-		r.Post("/sleep-times/delete", srv.handleDeleteSleepTime)
-		r.Post("/sleep-times/export-markdown", srv.handleExportSleepMarkdown)
-
-		// File routes
-		r.Get("/files/list", srv.handleListFiles)
-		r.Get("/files/read", srv.handleReadFile)
-		r.Post("/files/create", srv.handleCreateFile)
-		r.Post("/files/save", srv.handleSaveFile)
-		r.Post("/files/delete", srv.handleDeleteFile)
-		r.Post("/files/unpin", srv.handleUnpinEntry)
-
-		// Claude routes
-		r.Post("/claude/chat", srv.handleClaudeChat)
-		r.Post("/claude/chat-stream", srv.handleClaudeChatStream)
-		r.Post("/claude/clear", srv.handleClaudeClear)
-		r.Get("/claude/history", srv.handleClaudeHistory)
-
-		// Agent routes
-		r.Post("/agent/chat", srv.handleAgentChat)
-		r.Post("/agent/chat-stream", srv.handleAgentChatStream)
-		r.Get("/agent/runs/active", srv.handleAgentActiveRunsList)
-		r.Get("/agent/sessions", srv.handleAgentSessionsList)
-		r.Post("/agent/sessions/export-markdown", srv.handleAgentSessionsExportMarkdown)
-		r.Post("/agent/sessions/clear", srv.handleAgentSessionsClearAll)
-		r.Post("/agent/session/clear", srv.handleAgentSessionClear)
-		r.Get("/agent/session/history", srv.handleAgentSessionHistory)
-		r.Post("/agent/stop", srv.handleAgentStopRun)
-		r.Get("/agent/config", srv.handleAgentConfigGet)
-		r.Post("/agent/config", srv.handleAgentConfigSave)
-		r.Get("/agent/actions", srv.handleAgentActionsList)
-		r.Post("/agent/actions/{id}/run", srv.handleAgentActionRun)
-		r.Post("/agent/tools/execute", srv.handleAgentToolExecute)
-		r.Get("/agent/gateway/health", srv.handleAgentGatewayHealth)
-
-		// Settings routes
-		r.Get("/settings/env", srv.handleGetEnv)
-		r.Post("/settings/env", srv.handleSetEnv)
-		r.Get("/settings/vault-backup", srv.handleDownloadVaultBackup)
+		r.Use(AuditCallerMiddleware)
+		// Chats, sync, backup downloads and passphrase checks have a separate,
+		// smaller budget.
+		expensive := ClientRateLimitMiddleware(srv.limits.expensive)
+
+		// Any authenticated caller
+		r.Get("/auth/whoami", srv.handleWhoAmI)
+		r.Post("/auth/logout", srv.handleLogout)
 		r.Get("/apk/download", srv.handleDownloadAPK)
 
-		// LinkedIn OAuth
+		// Account routes: tokens, sessions, password and passkeys
+		r.Group(func(r chi.Router) {
+			r.Use(RequireScope(auth.ScopeAccount))
+			r.Get("/auth/tokens", srv.handleListTokens)
+			r.Post("/auth/tokens", srv.handleCreateToken)
+			r.Post("/auth/tokens/revoke", srv.handleRevokeToken)
+			r.Get("/auth/audit", srv.handleAuditLog)
+			r.Get("/auth/sessions", srv.handleListSessions)
+			r.Post("/auth/sessions/revoke", srv.handleRevokeSession)
+			r.Get("/auth/login-methods", srv.handleLoginMethods)
+			r.Post("/auth/password", srv.handleSetPassword)
+			r.Post("/auth/passkeys/register/begin", srv.handlePasskeyRegisterBegin)
+			r.Post("/auth/passkeys/register/finish", srv.handlePasskeyRegisterFinish)
+			r.Post("/auth/passkeys/delete", srv.handleDeletePasskey)
+			r.With(expensive).Post("/encryption/setup", srv.handleEncryptionSetup)
+			r.With(expensive).Post("/encryption/unlock", srv.handleEncryptionUnlock)
+			r.Post("/encryption/lock", srv.handleEncryptionLock)
+		})
+
+		// Vault reads
+		r.Group(func(r chi.Router) {
+			r.Use(RequireScope(auth.ScopeVaultRead))
+			r.Get("/daily", srv.handleGetDaily)
+			r.Get("/sync/status", srv.handleSyncStatus)
+			r.Get("/sync/index-status", srv.handleIndexStatus)
+			r.Get("/git/status", srv.handleGitStatus)
+			r.Get("/files/list", srv.handleListFiles)
+			r.Get("/files/read", srv.handleReadFile)
+			r.Get("/files/export", srv.handleExport)
+			r.Get("/shared", srv.handleListSharedFolders)
+			r.Get("/shared/edits", srv.handleSharedEdits)
+			r.Get("/encryption", srv.handleEncryptionStatus)
+			r.Get("/publish", srv.handlePublishStatus)
+			r.With(expensive).Get("/settings/vault-backup", srv.handleDownloadVaultBackup)
+		})
+
+		// Quick capture: append-only tokens or full write access
+		r.Group(func(r chi.Router) {
+			r.Use(RequireScope(auth.ScopeVaultAppend, auth.ScopeVaultWrite))
+			r.Post("/append", srv.handleAppendDaily)
+			r.Post("/todos/add", srv.handleAddTodo)
+		})
+
+		// Vault writes
+		r.Group(func(r chi.Router) {
+			r.Use(RequireScope(auth.ScopeVaultWrite))
+			r.Post("/save", srv.handleSaveDaily)
+			r.Post("/clear-pinned", srv.handleClearPinned)
+			r.Post("/todos/toggle", srv.handleToggleTodo)
+			r.With(expensive).Post("/sync", srv.handleSync)
+			r.Post("/git/commit", srv.handleGitCommit)
+			r.Post("/git/push", srv.handleGitPush)
+			r.Post("/git/pull", srv.handleGitPull)
+			r.Post("/git/commit-push", srv.handleGitCommitPush)
+			r.Post("/files/create", srv.handleCreateFile)
+			r.Post("/files/save", srv.handleSaveFile)
+			r.Post("/files/delete", srv.handleDeleteFile)
+			r.Post("/files/unpin", srv.handleUnpinEntry)
+			r.Post("/publish/shares", srv.handleCreateShare)
+			r.Post("/publish/shares/revoke", srv.handleRevokeShare)
+			r.Post("/settings/vault-restore", srv.handleVaultRestore)
+			r.Post("/settings/import", srv.handleImport)
+		})
+
+		// Sleep times
+		r.Group(func(r chi.Router) {
+			r.Use(RequireScope(auth.ScopeSleep))
+			r.Get("/sleep-times", srv.handleGetSleepTimes)
+			r.Get("/sleep-times/summary", srv.handleGetSleepSummary)
+			r.Post("/sleep-times/append", srv.handleAppendSleepTime)
+			r.Post("/sleep-times/update", srv.handleUpdateSleepTime)
+			r.Post("/sleep-times/delete", srv.handleDeleteSleepTime)
+			r.Post("/sleep-times/export-markdown", srv.handleExportSleepMarkdown)
+		})
+
+		// Claude and agent chats
+		r.Group(func(r chi.Router) {
+			r.Use(RequireScope(auth.ScopeAgent))
+			r.With(expensive).Post("/claude/chat", srv.handleClaudeChat)
+			r.With(expensive).Post("/claude/chat-stream", srv.handleClaudeChatStream)
+			r.Post("/claude/clear", srv.handleClaudeClear)
+			r.Get("/claude/history", srv.handleClaudeHistory)
+			r.With(expensive).Post("/agent/chat", srv.handleAgentChat)
+			r.With(expensive).Post("/agent/chat-stream", srv.handleAgentChatStream)
+			r.Get("/agent/runs/active", srv.handleAgentActiveRunsList)
+			r.Get("/agent/runs/{id}/stream", srv.handleAgentRunStream)
+			r.Get("/agent/sessions", srv.handleAgentSessionsList)
+			r.Post("/agent/sessions/export-markdown", srv.handleAgentSessionsExportMarkdown)
+			r.Post("/agent/sessions/clear", srv.handleAgentSessionsClearAll)
+			r.Post("/agent/session/clear", srv.handleAgentSessionClear)
+			r.Post("/agent/session/update", srv.handleAgentSessionUpdate)
+			r.Get("/agent/session/history", srv.handleAgentSessionHistory)
+			r.Post("/agent/stop", srv.handleAgentStopRun)
+			r.Post("/agent/approval", srv.handleAgentApproval)
+			r.Get("/agent/config", srv.handleAgentConfigGet)
+			r.Post("/agent/config", srv.handleAgentConfigSave)
+			r.Get("/agent/actions", srv.handleAgentActionsList)
+			r.Get("/agent/schedule", srv.handleAgentSchedule)
+			r.With(expensive).Post("/agent/actions/{id}/run", srv.handleAgentActionRun)
+			r.Get("/linkedin/health", srv.handleLinkedInHealth)
+		})
+
+		// Agent tool execution (pi-gateway)
+		r.Group(func(r chi.Router) {
+			r.Use(RequireScope(auth.ScopeToolsExecute))
+			r.Post("/agent/tools/execute", srv.handleAgentToolExecute)
+		})
+		r.With(RequireScope(auth.ScopeAgent, auth.ScopeToolsExecute)).Get("/agent/gateway/health", srv.handleAgentGatewayHealth)
+
+		// Server-wide settings and whole-vault operations
+		r.Group(func(r chi.Router) {
+			r.Use(RequireScope(auth.ScopeAdmin))
+			r.Get("/settings", srv.handleGetSettings)
+			r.Post("/settings", srv.handleUpdateSettings)
+			r.Get("/settings/env", srv.handleGetEnv)
+			r.Post("/settings/env", srv.handleSetEnv)
+			r.Get("/settings/backups", srv.handleListBackups)
+			r.Post("/settings/backups/run", srv.handleRunBackup)
+			r.Post("/settings/backups/verify", srv.handleVerifyBackup)
+			r.Post("/git/reset-clean", srv.handleGitResetClean)
+		})
+
+		// LinkedIn OAuth (no auth; AuthMiddleware lets the callback through)
 		r.Get("/linkedin/oauth/callback", srv.handleLinkedInCallback)
-		r.Get("/linkedin/health", srv.handleLinkedInHealth)
 	})
 
+	// Published notes and share links (no auth)
+	r.Route("/p", func(r chi.Router) {
+		r.Get("/s/{token}", srv.handlePublicShare)
+		r.Get("/{person}", srv.handlePublicIndex)
+		r.Get("/{person}/*", srv.handlePublicNote)
+	})
+
+	// Local CA root certificate for devices (no auth)
+	r.Get("/tls/ca.crt", srv.handleCACert)
+
 	// Static file serving for web UI (no auth)
 	staticDir := srv.config.StaticDir
 	if staticDir == "" {
 		staticDir = "./static"
 	}
-	r.Get("/*", staticFileHandler(staticDir))
+	r.Get("/*", staticFileHandler(staticDir, pageSecurityHeaders(srv.config.Security)))
 
 	return r
 }
 
-func buildRuntimeServices(cfg *config.Config, store *vault.Store) (*linkedin.Service, *claude.Service, *agent.Service) {
+// buildRuntimeServices creates the LinkedIn, Claude and agent services from cfg.
+// An existing agent service is reconfigured in place so its active runs and
+// pending approvals survive a settings reload.
+func buildRuntimeServices(cfg *config.Config, store *vault.Store, toolObserver claude.ToolObserver, sessions *agent.SessionStore, agentSvc *agent.Service) (*linkedin.Service, *claude.Service, *agent.Service) {
 	var linkedinSvc *linkedin.Service
 	if cfg.LinkedIn.AccessToken != "" {
 		linkedinSvc = linkedin.NewService(&cfg.LinkedIn, cfg.NotesRoot)
@@ -184,21 +371,61 @@ func buildRuntimeServices(cfg *config.Config, store *vault.Store) (*linkedin.Ser
 	var claudeSvc *claude.Service
 	if cfg.AnthropicKey != "" {
 		claudeSvc = claude.NewService(cfg.AnthropicKey, cfg.ClaudeModel, store, linkedinSvc)
+		claudeSvc.SetToolObserver(toolObserver)
+		claudeSvc.SetBashSandbox(bashSandboxPolicy(cfg))
 	}
 	fallback := cfg.AgentEnablePiFallback
 	options := agent.ServiceOptions{
-		MaxRunDuration:  cfg.AgentMaxRunDuration,
-		MaxToolCalls:    cfg.AgentMaxToolCallsPerRun,
-		AllowPiFallback: &fallback,
+		MaxRunDuration:   cfg.AgentMaxRunDuration,
+		MaxToolCalls:     cfg.AgentMaxToolCallsPerRun,
+		AllowPiFallback:  &fallback,
+		Sessions:         sessions,
+		ApprovalTimeout:  cfg.AgentToolApprovalTimeout,
+		ToolPoliciesPath: cfg.AgentToolPoliciesPath(),
+	}
+	if agentSvc == nil {
+		agentSvc = agent.NewServiceWithOptions(claudeSvc, store, linkedinSvc, cfg.PiGatewayURL, options)
+	} else {
+		agentSvc.Reconfigure(claudeSvc, linkedinSvc, cfg.PiGatewayURL, options)
+	}
+	if claudeSvc != nil {
+		claudeSvc.SetToolApprover(agentSvc.AuthorizeToolCall)
 	}
-	agentSvc := agent.NewServiceWithOptions(claudeSvc, store, linkedinSvc, cfg.PiGatewayURL, options)
 
 	return linkedinSvc, claudeSvc, agentSvc
 }
 
+// configureAgentTools makes the agent's file tools take the vault lock and push
+// their changes like the file handlers do, and gives it the sleep log.
+func (s *Server) configureAgentTools(claudeSvc *claude.Service) {
+	if claudeSvc != nil {
+		claudeSvc.SetVaultSync(&s.mu, s.syncMgr.TriggerPush)
+		claudeSvc.SetSleepLog(s.agentSleepLog())
+	}
+}
+
+// bashSandboxPolicy parses BASH_SANDBOX. An invalid spec sandboxes everyone
+// rather than silently running commands on the host.
+func bashSandboxPolicy(cfg *config.Config) sandbox.Policy {
+	policy, err := sandbox.ParsePolicy(cfg.BashSandbox)
+	if err != nil {
+		log.Printf("invalid BASH_SANDBOX, sandboxing all persons: %v", err)
+		policy = sandbox.Policy{Default: sandbox.ModeOn}
+	}
+	policy.Limits = sandbox.Limits{
+		MemoryMB:   cfg.BashSandboxMemoryMB,
+		CPUSeconds: cfg.BashSandboxCPUSeconds,
+	}
+	return policy
+}
+
 // staticFileHandler serves static files and falls back to index.html for SPA routing.
-func staticFileHandler(staticDir string) http.HandlerFunc {
+// headers are added to every response.
+func staticFileHandler(staticDir string, headers http.Header) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		for key, values := range headers {
+			w.Header()[key] = values
+		}
 		path := strings.TrimPrefix(r.URL.Path, "/")
 		if path == "" {
 			path = "index.html"
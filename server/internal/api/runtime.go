@@ -10,6 +10,7 @@ import (
 
 	"notes-editor/internal/agent"
 	"notes-editor/internal/auth"
+	"notes-editor/internal/config"
 	"notes-editor/internal/linkedin"
 )
 
@@ -50,12 +51,31 @@ func (s *Server) reloadRuntimeServices() error {
 	if err := s.config.ReloadRuntimeSettings(); err != nil {
 		return err
 	}
-	auth.SetValidPersons(s.config.ValidPersons)
-
-	s.linkedin, s.claude, s.agent = buildRuntimeServices(s.config, s.store)
+	s.applySettingsReload(config.ReloadRuntime | config.ReloadPersons | config.ReloadSharedFolders | config.ReloadEncryption | config.ReloadBackup)
 	return nil
 }
 
+// applySettingsReload rebuilds the components affected by changed settings. The
+// caller must hold s.mu.
+func (s *Server) applySettingsReload(reload config.Reload) {
+	if reload.Has(config.ReloadPersons) {
+		auth.SetValidPersons(s.config.ValidPersons)
+	}
+	if reload.Has(config.ReloadSharedFolders) {
+		applySharedFolders(s.store, s.config.SharedFolders)
+	}
+	if reload.Has(config.ReloadEncryption) {
+		applyEncryptedFolders(s.store, s.config)
+	}
+	if reload.Has(config.ReloadRuntime) {
+		s.linkedin, s.claude, s.agent = buildRuntimeServices(s.config, s.store, auditToolObserver(s.audit), s.agentSessions, s.agent)
+		s.configureAgentTools(s.claude)
+	}
+	if reload.Has(config.ReloadBackup) {
+		s.backups.SetOptions(s.backupOptions())
+	}
+}
+
 func (s *Server) linkedinHealth(ctx context.Context) linkedinHealthSnapshot {
 	snapshot := linkedinHealthSnapshot{}
 
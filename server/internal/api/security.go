@@ -0,0 +1,119 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/cors"
+
+	"notes-editor/internal/config"
+)
+
+// corsOptions allows any origin with bearer tokens unless origins are
+// configured; only configured origins may send cookies.
+func corsOptions(cfg config.SecurityConfig) cors.Options {
+	opts := cors.Options{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-Notes-Person", csrfHeaderName},
+		ExposedHeaders: []string{"Retry-After"},
+		MaxAge:         300,
+	}
+	if len(cfg.AllowedOrigins) > 0 {
+		opts.AllowedOrigins = cfg.AllowedOrigins
+		opts.AllowCredentials = true
+	}
+	return opts
+}
+
+// SecurityHeadersMiddleware sets headers every response should carry, and
+// Strict-Transport-Security on HTTPS requests.
+func SecurityHeadersMiddleware(cfg config.SecurityConfig) func(http.Handler) http.Handler {
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(cfg.HSTSMaxAge.Seconds()), 10) + "; includeSubDomains"
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			// Share links carry their token in the path; don't leak it to linked sites.
+			h.Set("Referrer-Policy", "same-origin")
+			if hsts != "" && requestIsHTTPS(r) {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// pageSecurityHeaders returns the headers for web UI pages: the content
+// security policy with frame-ancestors, and the matching X-Frame-Options.
+func pageSecurityHeaders(cfg config.SecurityConfig) http.Header {
+	h := http.Header{}
+	ancestors := "'none'"
+	if len(cfg.FrameAncestors) > 0 {
+		ancestors = strings.Join(cfg.FrameAncestors, " ")
+	}
+	policy := "frame-ancestors " + ancestors
+	if cfg.ContentSecurityPolicy != "" {
+		policy = strings.TrimRight(strings.TrimSpace(cfg.ContentSecurityPolicy), ";") + "; " + policy
+	}
+	h.Set("Content-Security-Policy", policy)
+	switch ancestors {
+	case "'none'":
+		h.Set("X-Frame-Options", "DENY")
+	case "'self'":
+		h.Set("X-Frame-Options", "SAMEORIGIN")
+	}
+	return h
+}
+
+// StrictOriginMiddleware rejects requests without a bearer token, i.e. cookie
+// sessions and logins, that come from an origin other than the server itself
+// or allowed. Unsafe methods must name their origin; browsers always do.
+func StrictOriginMiddleware(allowed []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "" || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+			origin := requestOrigin(r)
+			if origin == "" && isSafeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if origin == "" || (origin != selfOrigin(r) && !slices.Contains(allowed, origin)) {
+				writeForbidden(w, "Origin not allowed")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestOrigin returns the Origin header, falling back to the origin of the
+// Referer. An opaque origin ("null") never matches.
+func requestOrigin(r *http.Request) string {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		return origin
+	}
+	ref, err := url.Parse(r.Referer())
+	if err != nil || ref.Scheme == "" || ref.Host == "" {
+		return ""
+	}
+	return ref.Scheme + "://" + ref.Host
+}
+
+// selfOrigin is the origin the server was reached at.
+func selfOrigin(r *http.Request) string {
+	scheme := "http"
+	if requestIsHTTPS(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"notes-editor/internal/config"
 )
@@ -419,3 +420,146 @@ func TestPersonRequiredForProtectedEndpoints(t *testing.T) {
 		})
 	}
 }
+
+func TestCORSOrigins(t *testing.T) {
+	preflight := func(router http.Handler, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", "/api/files/list", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("default allows bearer tokens from any origin", func(t *testing.T) {
+		srv, _, cleanup := setupTestServer(t)
+		defer cleanup()
+		rec := preflight(NewRouter(srv), "https://other.example")
+		if rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+			t.Fatalf("Allow-Origin = %q", rec.Header().Get("Access-Control-Allow-Origin"))
+		}
+		if rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+			t.Fatal("credentials must not be allowed for any origin")
+		}
+	})
+
+	t.Run("configured origins may send cookies", func(t *testing.T) {
+		srv, _, cleanup := setupTestServer(t)
+		defer cleanup()
+		srv.config.Security.AllowedOrigins = []string{"https://app.example"}
+		router := NewRouter(srv)
+
+		rec := preflight(router, "https://app.example")
+		if rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example" ||
+			rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+			t.Fatalf("unexpected CORS headers: %v", rec.Header())
+		}
+		rec = preflight(router, "https://other.example")
+		if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Fatalf("other origin allowed: %v", rec.Header())
+		}
+	})
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	staticDir := t.TempDir()
+	os.WriteFile(filepath.Join(staticDir, "index.html"), []byte("<html></html>"), 0644)
+	srv.config.StaticDir = staticDir
+	srv.config.Security = config.SecurityConfig{
+		ContentSecurityPolicy: config.DefaultContentSecurityPolicy,
+		HSTSMaxAge:            time.Hour,
+	}
+	router := NewRouter(srv)
+
+	req := httptest.NewRequest("GET", "/notes/today", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	h := rec.Header()
+	if csp := h.Get("Content-Security-Policy"); !strings.HasPrefix(csp, "default-src 'self'") || !strings.HasSuffix(csp, "; frame-ancestors 'none'") {
+		t.Fatalf("Content-Security-Policy = %q", csp)
+	}
+	if h.Get("X-Frame-Options") != "DENY" || h.Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatalf("unexpected headers: %v", h)
+	}
+	if h.Get("Strict-Transport-Security") != "max-age=3600; includeSubDomains" {
+		t.Fatalf("Strict-Transport-Security = %q", h.Get("Strict-Transport-Security"))
+	}
+
+	// No HSTS over plain HTTP, and API responses carry no page policy
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, makeRequest(t, "GET", "/api/auth/whoami", "", ""))
+	if rec.Header().Get("Strict-Transport-Security") != "" || rec.Header().Get("Content-Security-Policy") != "" {
+		t.Fatalf("unexpected headers: %v", rec.Header())
+	}
+}
+
+func TestStrictOrigin(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	srv.config.Security.StrictOrigin = true
+	srv.config.Security.AllowedOrigins = []string{"https://app.example"}
+	router := NewRouter(srv)
+	setPassword(t, router, "petra", "correct horse battery")
+
+	// Logins must name an expected origin
+	rec, _, _ := passwordLogin(t, router, "petra", "correct horse battery")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("login without origin: expected 403, got %d", rec.Code)
+	}
+	req := httptest.NewRequest("POST", "/api/auth/login", strings.NewReader(`{"person":"petra","password":"correct horse battery"}`))
+	req.Header.Set("Origin", "http://example.com")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("same-origin login: expected 200, got %d", rec.Code)
+	}
+	var login LoginResponse
+	json.Unmarshal(rec.Body.Bytes(), &login)
+	var session *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			session = c
+		}
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		origin string
+		want   int
+	}{
+		{"same-origin read", "GET", "", http.StatusOK},
+		{"cross-origin read", "GET", "https://evil.example", http.StatusForbidden},
+		{"allowed origin write", "POST", "https://app.example", http.StatusOK},
+		{"write without origin", "POST", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		path := "/api/auth/whoami"
+		if tt.method == "POST" {
+			path = "/api/todos/add"
+		}
+		req := sessionRequest(tt.method, path, `{"category":"work","text":"strict"}`, session, login.CSRFToken)
+		req.Header.Set("Content-Type", "application/json")
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Bearer tokens are not affected
+	req = makeRequest(t, "GET", "/api/auth/whoami", "", "")
+	req.Header.Set("Origin", "https://evil.example")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("bearer token: expected 200, got %d", rec.Code)
+	}
+}
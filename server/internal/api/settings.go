@@ -10,27 +10,22 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
+
+	"notes-editor/internal/config"
 )
 
-// handleGetEnv returns the contents of the .env file.
+// handleGetEnv returns the contents of the .env file with secret values masked.
 func (s *Server) handleGetEnv(w http.ResponseWriter, r *http.Request) {
-	envPath := filepath.Join(s.config.NotesRoot, "..", ".env")
-
-	content, err := os.ReadFile(envPath)
+	content, err := s.config.MaskedEnvFile()
 	if err != nil {
-		if os.IsNotExist(err) {
-			writeJSON(w, http.StatusOK, map[string]string{
-				"content": "",
-			})
-			return
-		}
 		writeBadRequest(w, err.Error())
 		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]string{
-		"content": string(content),
+		"content": content,
 	})
 }
 
@@ -39,7 +34,8 @@ type SetEnvRequest struct {
 	Content string `json:"content"`
 }
 
-// handleSetEnv updates the .env file contents.
+// handleSetEnv updates the .env file contents. Masked secrets keep their value;
+// new secret values are moved to the encrypted secrets store.
 func (s *Server) handleSetEnv(w http.ResponseWriter, r *http.Request) {
 	var req SetEnvRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -47,9 +43,7 @@ func (s *Server) handleSetEnv(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	envPath := filepath.Join(s.config.NotesRoot, "..", ".env")
-
-	if err := os.WriteFile(envPath, []byte(req.Content), 0644); err != nil {
+	if err := s.config.SaveEnvFile(req.Content); err != nil {
 		writeBadRequest(w, err.Error())
 		return
 	}
@@ -61,6 +55,68 @@ func (s *Server) handleSetEnv(w http.ResponseWriter, r *http.Request) {
 	writeSuccess(w, "Settings saved")
 }
 
+// UpdateSettingsRequest changes individual settings. A null value clears a
+// setting so its default applies.
+type UpdateSettingsRequest struct {
+	Values map[string]*string `json:"values"`
+}
+
+// UpdateSettingsResponse lists the changed settings that only apply after a
+// restart.
+type UpdateSettingsResponse struct {
+	Success         bool     `json:"success"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+// handleGetSettings returns every setting with its type and current value.
+// Secret values are never returned; is_set tells whether they are configured.
+func (s *Server) handleGetSettings(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	values, err := s.config.SettingValues()
+	s.mu.RUnlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"settings": values,
+	})
+}
+
+// handleUpdateSettings validates and applies individual settings, then rebuilds
+// only the components they affect.
+func (s *Server) handleUpdateSettings(w http.ResponseWriter, r *http.Request) {
+	var req UpdateSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, "Invalid request body")
+		return
+	}
+	if len(req.Values) == 0 {
+		writeBadRequest(w, "No settings given")
+		return
+	}
+
+	s.mu.Lock()
+	reload, err := s.config.UpdateSettings(req.Values)
+	if err == nil {
+		s.applySettingsReload(reload)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+
+	restart := make([]string, 0)
+	for key := range req.Values {
+		if setting, ok := config.LookupSetting(key); ok && setting.Reload.Has(config.ReloadRestart) {
+			restart = append(restart, key)
+		}
+	}
+	sort.Strings(restart)
+	writeJSON(w, http.StatusOK, UpdateSettingsResponse{Success: true, RestartRequired: restart})
+}
+
 // handleDownloadVaultBackup streams a ZIP backup for the selected person's vault.
 func (s *Server) handleDownloadVaultBackup(w http.ResponseWriter, r *http.Request) {
 	person, ok := requirePerson(w, r)
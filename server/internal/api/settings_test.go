@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"notes-editor/internal/config"
+)
+
+func TestSettingsAPI(t *testing.T) {
+	srv, vaultRoot, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	t.Setenv("SECRETS_KEY", base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef")))
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("CLAUDE_MODEL", "")
+	t.Setenv("SESSION_TTL", "")
+	envPath := filepath.Join(vaultRoot, "..", ".env")
+	os.WriteFile(envPath, []byte("NOTES_TOKEN=test-token-123\n"), 0600)
+
+	t.Run("update validates values", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, makeRequest(t, "POST", "/api/settings", `{"values":{"AGENT_MAX_TOOL_CALLS_PER_RUN":"many"}}`, ""))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("update stores secrets and reports restarts", func(t *testing.T) {
+		agentSvc := srv.getAgent()
+		rec := httptest.NewRecorder()
+		body := `{"values":{"ANTHROPIC_API_KEY":"sk-api-test","CLAUDE_MODEL":"claude-test","SESSION_TTL":"24h"}}`
+		router.ServeHTTP(rec, makeRequest(t, "POST", "/api/settings", body, ""))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp UpdateSettingsResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if strings.Join(resp.RestartRequired, ",") != "SESSION_TTL" {
+			t.Fatalf("restart_required = %v", resp.RestartRequired)
+		}
+		if srv.config.AnthropicKey != "sk-api-test" || srv.config.ClaudeModel != "claude-test" {
+			t.Fatalf("config not reloaded")
+		}
+		// The agent service is reconfigured, keeping its runs and approvals.
+		if srv.getAgent() != agentSvc {
+			t.Fatalf("agent service replaced on reload")
+		}
+		raw, _ := os.ReadFile(envPath)
+		if strings.Contains(string(raw), "sk-api-test") {
+			t.Fatalf("secret written to .env:\n%s", raw)
+		}
+	})
+
+	t.Run("read masks secrets", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, makeRequest(t, "GET", "/api/settings", "", ""))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		if strings.Contains(rec.Body.String(), "sk-api-test") || strings.Contains(rec.Body.String(), "test-token-123") {
+			t.Fatalf("secret returned: %s", rec.Body.String())
+		}
+		var resp struct {
+			Settings []config.SettingValue `json:"settings"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		found := false
+		for _, v := range resp.Settings {
+			if v.Key == "ANTHROPIC_API_KEY" {
+				found = v.IsSet && v.Secret && v.Source == "secrets"
+			}
+			if v.Key == "CLAUDE_MODEL" && v.Value != "claude-test" {
+				t.Fatalf("CLAUDE_MODEL = %q", v.Value)
+			}
+		}
+		if !found {
+			t.Fatal("ANTHROPIC_API_KEY not reported as a set secret")
+		}
+	})
+
+	t.Run("env file masks secrets", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, makeRequest(t, "GET", "/api/settings/env", "", ""))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		if strings.Contains(rec.Body.String(), "test-token-123") {
+			t.Fatalf("token returned: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("person tokens cannot change settings", func(t *testing.T) {
+		tok := createToken(t, router, "petra")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, personTokenRequest("POST", "/api/settings", `{"values":{"CLAUDE_MODEL":"x"}}`, tok.Token, ""))
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("expected 403, got %d", rec.Code)
+		}
+	})
+}
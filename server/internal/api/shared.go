@@ -0,0 +1,83 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"notes-editor/internal/vault"
+)
+
+// defaultSharedEditsLimit bounds the edit history returned by default.
+const defaultSharedEditsLimit = 100
+
+// applySharedFolders installs the SHARED_FOLDERS access rules on the store. An
+// invalid spec disables sharing rather than failing startup.
+func applySharedFolders(store *vault.Store, spec string) {
+	folders, err := vault.ParseSharedFolders(spec)
+	if err != nil {
+		log.Printf("shared folders disabled: %v", err)
+	}
+	store.SetSharedFolders(folders)
+}
+
+// writeStoreError writes a store error, answering shared folder denials with
+// 403 and locked or not yet set up encrypted folders with 423.
+func writeStoreError(w http.ResponseWriter, err error) {
+	if errors.Is(err, vault.ErrSharedAccess) {
+		writeForbidden(w, err.Error())
+		return
+	}
+	if errors.Is(err, vault.ErrLocked) || errors.Is(err, vault.ErrEncryptionNotSetUp) {
+		writeError(w, http.StatusLocked, err.Error())
+		return
+	}
+	writeBadRequest(w, err.Error())
+}
+
+// handleListSharedFolders lists the shared folders the person can access.
+func (s *Server) handleListSharedFolders(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"folders": s.store.SharedFolders(person),
+	})
+}
+
+// handleSharedEdits returns who changed what in a shared folder, newest first.
+func (s *Server) handleSharedEdits(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+
+	folder := r.URL.Query().Get("folder")
+	if folder == "" {
+		writeBadRequest(w, "Folder is required")
+		return
+	}
+	limit := defaultSharedEditsLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			writeBadRequest(w, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	s.mu.RLock()
+	edits, err := s.store.SharedEdits(person, folder, limit)
+	s.mu.RUnlock()
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"edits": edits,
+	})
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"notes-editor/internal/vault"
+)
+
+func TestSharedFolders(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	applySharedFolders(srv.store, "family=sebastian,petra;budget=petra,sebastian:read")
+	router := NewRouter(srv)
+
+	do := func(method, path, body, person string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, makeRequest(t, method, path, body, person))
+		return rec
+	}
+
+	if rec := do("POST", "/api/files/save", `{"path":"_shared/family/shopping.md","content":"- milk\n"}`, "sebastian"); rec.Code != http.StatusOK {
+		t.Fatalf("save shared: %d %s", rec.Code, rec.Body.String())
+	}
+	rec := do("GET", "/api/files/read?path=_shared/family/shopping.md", "", "petra")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "milk") {
+		t.Fatalf("petra read shared: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do("POST", "/api/files/save", `{"path":"_shared/budget/plan.md","content":"x"}`, "sebastian"); rec.Code != http.StatusForbidden {
+		t.Errorf("write to read-only folder: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = do("GET", "/api/shared", "", "sebastian")
+	var list struct {
+		Folders []vault.SharedFolder `json:"folders"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Folders) != 2 || list.Folders[0].Access != vault.SharedRead || list.Folders[1].Access != vault.SharedWrite {
+		t.Errorf("shared folders = %+v", list.Folders)
+	}
+
+	rec = do("GET", "/api/shared/edits?folder=family", "", "petra")
+	var edits struct {
+		Edits []vault.SharedEdit `json:"edits"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &edits)
+	if rec.Code != http.StatusOK || len(edits.Edits) != 1 || edits.Edits[0].Person != "sebastian" {
+		t.Errorf("edits: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do("GET", "/api/shared/edits?folder=secret", "", "petra"); rec.Code != http.StatusForbidden {
+		t.Errorf("edits of unknown folder: %d", rec.Code)
+	}
+}
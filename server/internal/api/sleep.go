@@ -3,12 +3,15 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"notes-editor/internal/agent"
+	"notes-editor/internal/claude"
 	"notes-editor/internal/sleep"
 )
 
@@ -215,8 +218,7 @@ func (s *Server) handleAppendSleepTime(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_ = s.refreshSleepMarkdownBackup()
-	s.syncMgr.TriggerPush("Sleep entry added")
+	s.sleepLogged("Sleep entry added", "")
 	writeSuccess(w, "Entry added")
 
 }
@@ -353,6 +355,32 @@ func (s *Server) handleExportSleepMarkdown(w http.ResponseWriter, r *http.Reques
 	})
 }
 
+// agentSleepLog gives the agent's sleep tools the sleep log, scoped to the
+// children in SLEEP_CHILDREN. An invalid spec disables the tools. Callers hold
+// s.mu, which guards the config and sleep store.
+func (s *Server) agentSleepLog() claude.SleepLog {
+	children, err := sleep.ParseChildren(s.config.SleepChildren)
+	if err != nil {
+		log.Printf("invalid SLEEP_CHILDREN, agent sleep tools disabled: %v", err)
+	}
+	return claude.SleepLog{
+		Store:    s.sleepStore,
+		Children: children,
+		Logged: func(source string) {
+			s.sleepLogged("Agent sleep entry added", source)
+		},
+	}
+}
+
+// sleepLogged refreshes the markdown backup, syncs and notifies triggered
+// actions after an entry was added by source (empty for the person).
+func (s *Server) sleepLogged(message, source string) {
+	_ = s.refreshSleepMarkdownBackup()
+	s.syncMgr.TriggerPush(message)
+	// The sleep log is shared, so every person's actions may react.
+	s.scheduler.Notify(agent.Event{Kind: agent.EventSleepLogged, Source: source})
+}
+
 func (s *Server) refreshSleepMarkdownBackup() error {
 	s.mu.RLock()
 	store := s.sleepStore
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+
+	"notes-editor/internal/certs"
+	"notes-editor/internal/config"
+)
+
+// newLocalCA returns the local CA for TLS_MODE=local-ca, or nil.
+func newLocalCA(cfg *config.Config) *certs.LocalCA {
+	if cfg.TLS.Mode != config.TLSLocalCA {
+		return nil
+	}
+	hosts := cfg.TLS.Hosts
+	if len(hosts) == 0 {
+		hosts = certs.DefaultHosts()
+	}
+	return certs.NewLocalCA(cfg.TLSDir(), hosts)
+}
+
+// TLSConfig returns the HTTPS configuration, or nil when TLS is off. In
+// local-ca mode the CA and server certificate are created on first use.
+func (s *Server) TLSConfig() (*tls.Config, error) {
+	switch {
+	case s.localCA != nil:
+		if err := s.localCA.Init(); err != nil {
+			return nil, err
+		}
+		return s.localCA.TLSConfig(), nil
+	case s.config.TLS.Mode == config.TLSFiles:
+		cert, err := tls.LoadX509KeyPair(s.config.TLS.CertFile, s.config.TLS.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}, nil
+	}
+	return nil, nil
+}
+
+// handleCACert serves the local CA's root certificate so phones and other
+// devices can install it. It needs no auth: the certificate is public, and
+// devices fetch it before they trust the server.
+func (s *Server) handleCACert(w http.ResponseWriter, r *http.Request) {
+	if s.localCA == nil {
+		writeNotFound(w, "No local CA configured")
+		return
+	}
+	pem, err := s.localCA.CACertPEM()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to read CA certificate")
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+	w.Header().Set("Content-Disposition", `attachment; filename="notes-local-ca.crt"`)
+	w.Write(pem)
+}
+
+// HTTPSRedirect redirects plain HTTP requests to the HTTPS listener at tlsAddr.
+// Requests from loopback addresses (local sidecars such as the pi-gateway) and
+// the CA certificate download are served by next.
+func HTTPSRedirect(tlsAddr string, next http.Handler) http.Handler {
+	_, port, _ := net.SplitHostPort(tlsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := net.ParseIP(remoteHost(r)); (ip != nil && ip.IsLoopback()) || strings.HasPrefix(r.URL.Path, "/tls/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
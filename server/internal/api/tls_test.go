@@ -0,0 +1,86 @@
+package api
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"notes-editor/internal/certs"
+)
+
+func TestHTTPSRedirect(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	tests := []struct {
+		name       string
+		tlsAddr    string
+		url        string
+		remoteAddr string
+		want       string
+	}{
+		{"default port", ":443", "http://notes.local/api/daily?date=today", "192.168.1.5:5000", "https://notes.local/api/daily?date=today"},
+		{"custom port", ":8443", "http://notes.local:8080/", "192.168.1.5:5000", "https://notes.local:8443/"},
+		{"ip address", ":443", "http://192.168.1.20/", "192.168.1.5:5000", "https://192.168.1.20/"},
+		{"loopback", ":443", "http://127.0.0.1:8080/api/agent/tools/execute", "127.0.0.1:5000", ""},
+		{"ca certificate", ":443", "http://notes.local/tls/ca.crt", "192.168.1.5:5000", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.url, nil)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+			HTTPSRedirect(tt.tlsAddr, next).ServeHTTP(rec, req)
+
+			if tt.want == "" {
+				if rec.Code != http.StatusTeapot {
+					t.Fatalf("expected request to be served, got %d", rec.Code)
+				}
+				return
+			}
+			if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != tt.want {
+				t.Fatalf("got %d to %q, want redirect to %q", rec.Code, rec.Header().Get("Location"), tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalCA(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	// Without a local CA there is nothing to download and TLS is off
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/tls/ca.crt", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+	if cfg, err := srv.TLSConfig(); cfg != nil || err != nil {
+		t.Fatalf("expected no TLS config, got %v, %v", cfg, err)
+	}
+
+	srv.localCA = certs.NewLocalCA(filepath.Join(t.TempDir(), "tls"), []string{"notes.local"})
+	tlsConfig, err := srv.TLSConfig()
+	if err != nil {
+		t.Fatalf("tls config: %v", err)
+	}
+	cert, err := tlsConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: "notes.local"})
+	if err != nil || cert.Leaf.DNSNames[0] != "notes.local" {
+		t.Fatalf("certificate: %v", err)
+	}
+
+	// The root certificate needs no auth
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/tls/ca.crt", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if rec.Header().Get("Content-Type") != "application/x-x509-ca-cert" || !strings.HasPrefix(rec.Body.String(), "-----BEGIN CERTIFICATE-----") {
+		t.Fatalf("unexpected response: %v %q", rec.Header(), rec.Body.String())
+	}
+}
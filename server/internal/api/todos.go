@@ -39,13 +39,13 @@ func (s *Server) handleAddTodo(w http.ResponseWriter, r *http.Request) {
 	_, path, _, err := s.daily.GetOrCreateDaily(person, time.Now())
 	if err != nil {
 		s.mu.Unlock()
-		writeBadRequest(w, err.Error())
+		writeStoreError(w, err)
 		return
 	}
 
 	if err := s.daily.AddTask(person, path, req.Category, req.Text); err != nil {
 		s.mu.Unlock()
-		writeBadRequest(w, err.Error())
+		writeStoreError(w, err)
 		return
 	}
 	s.mu.Unlock()
@@ -86,7 +86,7 @@ func (s *Server) handleToggleTodo(w http.ResponseWriter, r *http.Request) {
 	s.mu.Lock()
 	if err := s.daily.ToggleTask(person, req.Path, req.Line); err != nil {
 		s.mu.Unlock()
-		writeBadRequest(w, err.Error())
+		writeStoreError(w, err)
 		return
 	}
 	s.mu.Unlock()
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"notes-editor/internal/auth"
+)
+
+// maxTokenNameLength bounds the label of an API token.
+const maxTokenNameLength = 100
+
+// APIToken describes a per-person API token without its secret.
+type APIToken struct {
+	ID        string       `json:"id"`
+	Person    string       `json:"person"`
+	Name      string       `json:"name"`
+	Scopes    []auth.Scope `json:"scopes"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// CreateTokenRequest issues a token. Person is only honoured for admins; it
+// defaults to the selected person. Without scopes the token can do everything its
+// person can. Callers can only grant scopes they hold themselves.
+type CreateTokenRequest struct {
+	Name   string   `json:"name"`
+	Person string   `json:"person"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreateTokenResponse returns the new token. Token is shown only once.
+type CreateTokenResponse struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+// RevokeTokenRequest revokes a token by ID.
+type RevokeTokenRequest struct {
+	ID string `json:"id"`
+}
+
+// WhoAmIResponse describes the authenticated caller. Scopes is empty for admins,
+// who hold every scope.
+type WhoAmIResponse struct {
+	Role   auth.Role    `json:"role"`
+	Person string       `json:"person"`
+	Scopes []auth.Scope `json:"scopes"`
+}
+
+func newAPIToken(tok auth.Token) APIToken {
+	scopes := tok.Scopes
+	if !tok.IsAdmin() {
+		scopes = tok.EffectiveScopes()
+	}
+	return APIToken{ID: tok.ID, Person: tok.Person, Name: tok.Name, Scopes: scopes, CreatedAt: tok.CreatedAt}
+}
+
+// handleWhoAmI reports the role, person and scopes the request authenticated as.
+func (s *Server) handleWhoAmI(w http.ResponseWriter, r *http.Request) {
+	p, _ := auth.PrincipalFromContext(r.Context())
+	scopes := p.Scopes
+	if scopes == nil {
+		scopes = []auth.Scope{}
+	}
+	writeJSON(w, http.StatusOK, WhoAmIResponse{Role: p.Role, Person: auth.PersonFromContext(r.Context()), Scopes: scopes})
+}
+
+// handleListTokens lists the caller's own tokens. Admins see every person's tokens,
+// optionally filtered with ?person=.
+func (s *Server) handleListTokens(w http.ResponseWriter, r *http.Request) {
+	p, _ := auth.PrincipalFromContext(r.Context())
+	person := p.Person
+	if p.IsAdmin() {
+		person = r.URL.Query().Get("person")
+	}
+
+	tokens, err := s.tokens.List(person)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	out := make([]APIToken, 0, len(tokens))
+	for _, tok := range tokens {
+		out = append(out, newAPIToken(tok))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleCreateToken issues a per-person token. Person tokens can only issue tokens
+// for their own person and with scopes they hold; admins can issue any token.
+func (s *Server) handleCreateToken(w http.ResponseWriter, r *http.Request) {
+	var req CreateTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, "Invalid request body")
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		writeBadRequest(w, "Name is required")
+		return
+	}
+	if utf8.RuneCountInString(name) > maxTokenNameLength {
+		writeBadRequest(w, "Name is too long")
+		return
+	}
+
+	scopes, err := auth.ParseScopes(req.Scopes)
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	granted := scopes
+	if len(granted) == 0 {
+		granted = auth.PersonScopes
+	}
+	p, _ := auth.PrincipalFromContext(r.Context())
+	if !p.HasScopes(granted) {
+		writeForbidden(w, "Cannot grant scopes the caller does not hold")
+		return
+	}
+
+	person := auth.PersonFromContext(r.Context())
+	if req.Person != "" {
+		if !p.IsAdmin() && req.Person != p.Person {
+			writeForbidden(w, "Token is not valid for this person")
+			return
+		}
+		person = req.Person
+	}
+	if person == "" && !slices.Contains(scopes, auth.ScopeAdmin) {
+		writeBadRequest(w, "Person not selected")
+		return
+	}
+	if person != "" && !auth.IsValidPerson(person) {
+		writeBadRequest(w, "Invalid person")
+		return
+	}
+
+	tok, plaintext, err := s.tokens.Create(person, name, scopes)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, CreateTokenResponse{APIToken: newAPIToken(tok), Token: plaintext})
+}
+
+// handleRevokeToken revokes a token. Person tokens can only revoke their own
+// person's tokens.
+func (s *Server) handleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	var req RevokeTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, "Invalid request body")
+		return
+	}
+	if req.ID == "" {
+		writeBadRequest(w, "ID is required")
+		return
+	}
+
+	p, _ := auth.PrincipalFromContext(r.Context())
+	owner := p.Person
+	if p.IsAdmin() {
+		owner = ""
+	}
+
+	_, err := s.tokens.Revoke(owner, req.ID)
+	if errors.Is(err, auth.ErrTokenNotFound) {
+		writeNotFound(w, "Token not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeSuccess(w, "Token revoked")
+}
@@ -0,0 +1,218 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func personTokenRequest(method, path, body, token, person string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if person != "" {
+		req.Header.Set("X-Notes-Person", person)
+	}
+	return req
+}
+
+func createToken(t *testing.T, router http.Handler, person string) CreateTokenResponse {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, makeRequest(t, "POST", "/api/auth/tokens", `{"name":"phone","person":"`+person+`"}`, ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create token: %d %s", rec.Code, rec.Body.String())
+	}
+	var resp CreateTokenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestPersonTokens_BindPerson(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	created := createToken(t, router, "sebastian")
+	if created.Token == "" || created.Person != "sebastian" {
+		t.Fatalf("unexpected response: %+v", created)
+	}
+
+	// The person comes from the token; the header is optional.
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, personTokenRequest("GET", "/api/files/read?path=notes/secret.md", "", created.Token, ""))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Sebastian's Secret") {
+		t.Fatalf("read own note: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Picking another person via the header is rejected.
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, personTokenRequest("GET", "/api/files/read?path=notes/secret.md", "", created.Token, "petra"))
+	if rec.Code != http.StatusForbidden || strings.Contains(rec.Body.String(), "Petra") {
+		t.Fatalf("cross-person read: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, personTokenRequest("GET", "/api/auth/whoami", "", created.Token, ""))
+	var who WhoAmIResponse
+	json.Unmarshal(rec.Body.Bytes(), &who)
+	if who.Role != "person" || who.Person != "sebastian" {
+		t.Fatalf("whoami = %+v", who)
+	}
+}
+
+func TestPersonTokens_AdminOnlyRoutes(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	created := createToken(t, router, "petra")
+	for _, path := range []string{"/api/settings/env", "/api/settings/backups"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, personTokenRequest("GET", path, "", created.Token, ""))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s with person token: %d, want 403", path, rec.Code)
+		}
+	}
+
+	// A person token cannot mint tokens for someone else.
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, personTokenRequest("POST", "/api/auth/tokens", `{"name":"x","person":"sebastian"}`, created.Token, ""))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("cross-person create: %d", rec.Code)
+	}
+}
+
+func TestPersonTokens_ListAndRevoke(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	seb := createToken(t, router, "sebastian")
+	petra := createToken(t, router, "petra")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, personTokenRequest("GET", "/api/auth/tokens", "", seb.Token, ""))
+	var list []APIToken
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list) != 1 || list[0].ID != seb.ID || strings.Contains(rec.Body.String(), "hash") {
+		t.Fatalf("person list: %s", rec.Body.String())
+	}
+
+	// Sebastian cannot revoke Petra's token.
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, personTokenRequest("POST", "/api/auth/tokens/revoke", `{"id":"`+petra.ID+`"}`, seb.Token, ""))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("cross-person revoke: %d", rec.Code)
+	}
+
+	// The admin token can.
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, makeRequest(t, "POST", "/api/auth/tokens/revoke", `{"id":"`+petra.ID+`"}`, ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("admin revoke: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, personTokenRequest("GET", "/api/daily", "", petra.Token, ""))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked token: %d, want 401", rec.Code)
+	}
+}
+
+func createScopedToken(t *testing.T, router http.Handler, person string, scopes string) CreateTokenResponse {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, makeRequest(t, "POST", "/api/auth/tokens", `{"name":"script","person":"`+person+`","scopes":`+scopes+`}`, ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create scoped token: %d %s", rec.Code, rec.Body.String())
+	}
+	var resp CreateTokenResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return resp
+}
+
+func TestScopedTokens_RouteGroups(t *testing.T) {
+	srv, vaultRoot, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	today := time.Now().Format("2006-01-02")
+	os.WriteFile(filepath.Join(vaultRoot, "sebastian", "daily", today+".md"), []byte("# daily "+today+"\n\n## custom notes\n"), 0644)
+
+	sleepOnly := createScopedToken(t, router, "sebastian", `["sleep"]`)
+	readOnly := createScopedToken(t, router, "sebastian", `["vault:read"]`)
+	capture := createScopedToken(t, router, "sebastian", `["vault:append"]`)
+	tools := createScopedToken(t, router, "sebastian", `["tools:execute"]`)
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"sleep token logs sleep", sleepOnly.Token, "POST", "/api/sleep-times/append", `{"child":"Thomas","status":"eingeschlafen","occurred_at":"2026-01-15T19:00:00Z"}`, http.StatusOK},
+		{"sleep token cannot read notes", sleepOnly.Token, "GET", "/api/files/read?path=notes/secret.md", "", http.StatusForbidden},
+		{"sleep token cannot touch .env", sleepOnly.Token, "POST", "/api/settings/env", `{"content":"X=1"}`, http.StatusForbidden},
+		{"sleep token cannot mint tokens", sleepOnly.Token, "POST", "/api/auth/tokens", `{"name":"x"}`, http.StatusForbidden},
+		{"read token reads", readOnly.Token, "GET", "/api/files/read?path=notes/secret.md", "", http.StatusOK},
+		{"read token cannot write", readOnly.Token, "POST", "/api/files/save", `{"path":"notes/secret.md","content":"x"}`, http.StatusForbidden},
+		{"capture token appends", capture.Token, "POST", "/api/append", `{"path":"daily/` + today + `.md","text":"idea"}`, http.StatusOK},
+		{"capture token cannot save", capture.Token, "POST", "/api/save", `{"content":"x"}`, http.StatusForbidden},
+		{"tools token cannot chat", tools.Token, "POST", "/api/agent/chat", `{"message":"hi"}`, http.StatusForbidden},
+		{"tools token cannot read files directly", tools.Token, "GET", "/api/files/list", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, personTokenRequest(tt.method, tt.path, tt.body, tt.token, ""))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestScopedTokens_NoEscalation(t *testing.T) {
+	srv, _, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	account := createScopedToken(t, router, "petra", `["account","sleep"]`)
+	for body, want := range map[string]int{
+		`{"name":"x","scopes":["sleep"]}`:      http.StatusOK,
+		`{"name":"x","scopes":["vault:read"]}`: http.StatusForbidden,
+		`{"name":"x"}`:                         http.StatusForbidden,
+		`{"name":"x","scopes":["admin"]}`:      http.StatusForbidden,
+		`{"name":"x","scopes":["root"]}`:       http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, personTokenRequest("POST", "/api/auth/tokens", body, account.Token, ""))
+		if rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", body, rec.Code, want)
+		}
+	}
+
+	// Admin-scoped tokens act as admin and may pick the person.
+	admin := createScopedToken(t, router, "", `["admin"]`)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, personTokenRequest("GET", "/api/settings/backups", "", admin.Token, ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("admin token on admin route: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, personTokenRequest("GET", "/api/files/read?path=notes/secret.md", "", admin.Token, "petra"))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Petra") {
+		t.Fatalf("admin token selecting person: %d %s", rec.Code, rec.Body.String())
+	}
+}
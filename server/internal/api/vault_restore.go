@@ -0,0 +1,196 @@
+package api
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+
+	"notes-editor/internal/vault"
+)
+
+// maxVaultRestoreUpload bounds the size of an uploaded restore archive.
+const maxVaultRestoreUpload = 1 << 30
+
+// VaultRestoreResponse describes a planned or applied vault restore.
+type VaultRestoreResponse struct {
+	DryRun    bool                  `json:"dry_run"`
+	Person    string                `json:"person"`
+	Changes   []vault.RestoreChange `json:"changes"`
+	Added     int                   `json:"added"`
+	Modified  int                   `json:"modified"`
+	Deleted   int                   `json:"deleted"`
+	Unchanged int                   `json:"unchanged"`
+	Committed bool                  `json:"committed"`
+	Message   string                `json:"message,omitempty"`
+	PushError string                `json:"push_error,omitempty"`
+}
+
+// handleVaultRestore restores the selected person's vault from a ZIP produced by the
+// vault backup download. The archive is sent as the raw request body or as the "file"
+// field of a multipart form. Without dry_run=false only the diff is returned;
+// delete_missing=true also removes files that are not in the archive.
+func (s *Server) handleVaultRestore(w http.ResponseWriter, r *http.Request) {
+	person, ok := requirePerson(w, r)
+	if !ok {
+		return
+	}
+
+	dryRun := true
+	if raw := r.URL.Query().Get("dry_run"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeBadRequest(w, "Invalid dry_run value")
+			return
+		}
+		dryRun = parsed
+	}
+	deleteMissing := false
+	if raw := r.URL.Query().Get("delete_missing"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeBadRequest(w, "Invalid delete_missing value")
+			return
+		}
+		deleteMissing = parsed
+	}
+
+	archivePath, filename, err := s.spoolUpload(w, r, "vault-restore-*.zip")
+	if err != nil {
+		writeBadRequest(w, err.Error())
+		return
+	}
+	defer os.Remove(archivePath)
+
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		writeBadRequest(w, "Upload is not a valid ZIP archive")
+		return
+	}
+	defer zr.Close()
+
+	if dryRun {
+		s.mu.RLock()
+		plan, err := s.store.PlanRestore(person, &zr.Reader, deleteMissing)
+		s.mu.RUnlock()
+		if err != nil {
+			writeBadRequest(w, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, newVaultRestoreResponse(plan, true))
+		return
+	}
+
+	s.mu.Lock()
+	plan, err := s.store.PlanRestore(person, &zr.Reader, deleteMissing)
+	if err != nil {
+		s.mu.Unlock()
+		writeBadRequest(w, err.Error())
+		return
+	}
+	resp := newVaultRestoreResponse(plan, false)
+	if len(plan.Changes) == 0 {
+		s.mu.Unlock()
+		resp.Message = "Vault already matches the archive"
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	if err := s.store.ApplyRestore(plan); err != nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusInternalServerError, "Restore failed: "+err.Error())
+		return
+	}
+	resp.Message = plan.CommitMessage(filename)
+	committed, commitErr := s.git.CommitPaths(resp.Message, plan.RootPaths())
+	var pushErr error
+	if commitErr == nil && committed {
+		pushErr = s.git.Push()
+	}
+	s.mu.Unlock()
+
+	if commitErr != nil {
+		writeError(w, http.StatusInternalServerError, "Files restored but commit failed: "+commitErr.Error())
+		return
+	}
+	resp.Committed = committed
+	if committed {
+		s.syncMgr.RecordManualPush(pushErr)
+		if pushErr != nil {
+			resp.PushError = pushErr.Error()
+		}
+	}
+	s.indexMgr.TriggerReindex("vault restore")
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func newVaultRestoreResponse(plan *vault.RestorePlan, dryRun bool) VaultRestoreResponse {
+	added, modified, deleted := plan.Counts()
+	return VaultRestoreResponse{
+		DryRun:    dryRun,
+		Person:    plan.Person,
+		Changes:   plan.Changes,
+		Added:     added,
+		Modified:  modified,
+		Deleted:   deleted,
+		Unchanged: plan.Unchanged,
+	}
+}
+
+// spoolUpload copies the uploaded archive to a temporary file named after pattern
+// in the server's upload directory, since ZIP reading needs random access. It
+// returns the temp path and the uploaded filename.
+func (s *Server) spoolUpload(w http.ResponseWriter, r *http.Request, pattern string) (string, string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxVaultRestoreUpload)
+
+	var src io.Reader = r.Body
+	filename := ""
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mediaType == "multipart/form-data" {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			return "", "", errors.New("Invalid multipart body")
+		}
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return "", "", errors.New("Multipart field \"file\" is required")
+			}
+			if err != nil {
+				return "", "", errors.New("Invalid multipart body")
+			}
+			if part.FormName() == "file" {
+				src = part
+				filename = part.FileName()
+				break
+			}
+		}
+	}
+
+	s.mu.RLock()
+	dir := s.config.UploadDir()
+	s.mu.RUnlock()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", err
+	}
+	tmp, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return "", "", errors.New("Archive is too large")
+		}
+		return "", "", errors.New("Failed to read upload")
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", "", err
+	}
+	return tmp.Name(), filename, nil
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func zipBytes(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip create: %v", err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("zip write: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func restoreRequest(t *testing.T, query string, archive []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", "sebastian-vault-20260101-120000.zip")
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	part.Write(archive)
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/api/settings/vault-restore"+query, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer test-token-123")
+	req.Header.Set("X-Notes-Person", "sebastian")
+	return req
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestVaultRestore(t *testing.T) {
+	srv, vaultRoot, cleanup := setupTestServer(t)
+	defer cleanup()
+	router := NewRouter(srv)
+
+	runGit(t, vaultRoot, "init")
+	runGit(t, vaultRoot, "config", "user.email", "test@example.com")
+	runGit(t, vaultRoot, "config", "user.name", "Test User")
+	runGit(t, vaultRoot, "add", ".")
+	runGit(t, vaultRoot, "commit", "-m", "initial")
+
+	archive := zipBytes(t, map[string]string{
+		"notes/secret.md":   "# Restored secret",
+		"notes/restored.md": "restored",
+	})
+
+	t.Run("dry run reports changes without writing", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, restoreRequest(t, "", archive))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d; body = %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var resp VaultRestoreResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !resp.DryRun || resp.Added != 1 || resp.Modified != 1 {
+			t.Fatalf("unexpected dry run response: %s", rec.Body.String())
+		}
+		// The upload was spooled next to .env, not in the system temp directory,
+		// and removed afterwards.
+		info, err := os.Stat(srv.config.UploadDir())
+		if err != nil || info.Mode().Perm() != 0700 {
+			t.Fatalf("upload directory = %v, %v", info, err)
+		}
+		if spooled, _ := os.ReadDir(srv.config.UploadDir()); len(spooled) != 0 {
+			t.Fatalf("spooled uploads left behind: %v", spooled)
+		}
+		if _, err := os.Stat(filepath.Join(vaultRoot, "sebastian", "notes", "restored.md")); !os.IsNotExist(err) {
+			t.Fatalf("dry run must not write files")
+		}
+	})
+
+	t.Run("apply writes files in one commit", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, restoreRequest(t, "?dry_run=false", archive))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d; body = %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var resp VaultRestoreResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if resp.DryRun || !resp.Committed {
+			t.Fatalf("unexpected apply response: %s", rec.Body.String())
+		}
+
+		content, _ := os.ReadFile(filepath.Join(vaultRoot, "sebastian", "notes", "secret.md"))
+		if string(content) != "# Restored secret" {
+			t.Fatalf("secret.md = %q", content)
+		}
+		files := runGit(t, vaultRoot, "show", "--name-only", "--format=%s", "HEAD")
+		if !strings.HasPrefix(files, "Restore sebastian vault from sebastian-vault-20260101-120000.zip") ||
+			!strings.Contains(files, "sebastian/notes/restored.md") ||
+			!strings.Contains(files, "sebastian/notes/secret.md") {
+			t.Fatalf("unexpected commit:\n%s", files)
+		}
+		// Petra's vault is untouched.
+		petra, _ := os.ReadFile(filepath.Join(vaultRoot, "petra", "notes", "secret.md"))
+		if !strings.Contains(string(petra), "Petra's Secret") {
+			t.Fatalf("petra's vault was modified")
+		}
+	})
+
+	t.Run("rejects entries escaping the person vault", func(t *testing.T) {
+		evil := zipBytes(t, map[string]string{"../petra/notes/secret.md": "pwned"})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, restoreRequest(t, "?dry_run=false", evil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d; body = %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+		}
+		petra, _ := os.ReadFile(filepath.Join(vaultRoot, "petra", "notes", "secret.md"))
+		if string(petra) == "pwned" {
+			t.Fatalf("path escape was written")
+		}
+	})
+
+	t.Run("rejects non-zip uploads", func(t *testing.T) {
+		req := makeRequest(t, "POST", "/api/settings/vault-restore", "not a zip", "sebastian")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+}
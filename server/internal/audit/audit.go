@@ -0,0 +1,260 @@
+// Package audit records mutating API requests and agent tool calls in an
+// append-only SQLite log.
+package audit
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// DefaultRetention applies when Open is given no retention.
+const DefaultRetention = 90 * 24 * time.Hour
+
+// pruneInterval limits how often Record deletes expired entries.
+const pruneInterval = time.Hour
+
+// maxQueryLimit bounds the entries returned by one query.
+const maxQueryLimit = 1000
+
+// Entry kinds.
+const (
+	KindRequest = "request"
+	KindTool    = "tool"
+)
+
+// Outcomes.
+const (
+	OutcomeOK    = "ok"
+	OutcomeError = "error"
+)
+
+// Entry is one audited operation.
+type Entry struct {
+	ID   int64     `json:"id"`
+	Time time.Time `json:"time"`
+	// Kind is KindRequest for API requests and KindTool for agent tool calls.
+	Kind   string `json:"kind"`
+	Person string `json:"person"`
+	// Client identifies the credential: "admin", "token:<id>" or "session:<id>".
+	Client    string `json:"client"`
+	UserAgent string `json:"user_agent,omitempty"`
+	// Method and Route are the HTTP method and route pattern. Tool calls record
+	// the tool name in Route.
+	Method     string `json:"method,omitempty"`
+	Route      string `json:"route"`
+	Target     string `json:"target,omitempty"`
+	RunID      string `json:"run_id,omitempty"`
+	Status     int    `json:"status,omitempty"`
+	Outcome    string `json:"outcome"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Filter narrows a query. Zero fields match everything.
+type Filter struct {
+	Person string
+	Kind   string
+	Route  string
+	RunID  string
+	Since  time.Time
+	Until  time.Time
+	// Limit defaults to 100 and is capped at 1000.
+	Limit int
+}
+
+// Log is the audit database. Entries can only be added; they are removed only by
+// the retention policy.
+type Log struct {
+	db        *sql.DB
+	retention time.Duration
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// Open opens or creates the audit database at dbPath. Entries older than
+// retention are pruned; a non-positive retention selects DefaultRetention.
+func Open(dbPath string, retention time.Duration) (*Log, error) {
+	if dbPath == "" {
+		return nil, errors.New("audit db path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0700); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+
+	l := &Log{db: db, retention: retention}
+	if err := l.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Close closes the database.
+func (l *Log) Close() error {
+	if l == nil || l.db == nil {
+		return nil
+	}
+	return l.db.Close()
+}
+
+func (l *Log) init() error {
+	stmts := []string{
+		"PRAGMA journal_mode=WAL;",
+		"PRAGMA busy_timeout=5000;",
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			time_utc TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			person TEXT NOT NULL DEFAULT '',
+			client TEXT NOT NULL DEFAULT '',
+			user_agent TEXT NOT NULL DEFAULT '',
+			method TEXT NOT NULL DEFAULT '',
+			route TEXT NOT NULL,
+			target TEXT NOT NULL DEFAULT '',
+			run_id TEXT NOT NULL DEFAULT '',
+			status INTEGER NOT NULL DEFAULT 0,
+			outcome TEXT NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			duration_ms INTEGER NOT NULL DEFAULT 0
+		);`,
+		"CREATE INDEX IF NOT EXISTS idx_audit_time ON audit_log(time_utc);",
+		"CREATE INDEX IF NOT EXISTS idx_audit_person ON audit_log(person, time_utc);",
+		"CREATE INDEX IF NOT EXISTS idx_audit_run ON audit_log(run_id);",
+		// The log is append-only: rows may only be removed by retention pruning.
+		`CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+		BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END;`,
+	}
+	for _, stmt := range stmts {
+		if _, err := l.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Record appends an entry. Time defaults to now and Outcome is derived from
+// Error and Status when empty. Expired entries are pruned at most hourly.
+func (l *Log) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Outcome == "" {
+		e.Outcome = OutcomeOK
+		if e.Error != "" || e.Status >= 400 {
+			e.Outcome = OutcomeError
+		}
+	}
+	_, err := l.db.Exec(`INSERT INTO audit_log
+		(time_utc, kind, person, client, user_agent, method, route, target, run_id, status, outcome, error, duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		formatTime(e.Time), e.Kind, e.Person, e.Client, e.UserAgent, e.Method, e.Route, e.Target, e.RunID,
+		e.Status, e.Outcome, e.Error, e.DurationMS)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	due := time.Since(l.lastPrune) >= pruneInterval
+	if due {
+		l.lastPrune = time.Now()
+	}
+	l.mu.Unlock()
+	if due {
+		_, err = l.Prune()
+	}
+	return err
+}
+
+// Query returns matching entries, newest first.
+func (l *Log) Query(f Filter) ([]Entry, error) {
+	var where []string
+	var args []any
+	add := func(clause string, arg any) {
+		where = append(where, clause)
+		args = append(args, arg)
+	}
+	if f.Person != "" {
+		add("person = ?", f.Person)
+	}
+	if f.Kind != "" {
+		add("kind = ?", f.Kind)
+	}
+	if f.Route != "" {
+		add("route = ?", f.Route)
+	}
+	if f.RunID != "" {
+		add("run_id = ?", f.RunID)
+	}
+	if !f.Since.IsZero() {
+		add("time_utc >= ?", formatTime(f.Since))
+	}
+	if !f.Until.IsZero() {
+		add("time_utc < ?", formatTime(f.Until))
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > maxQueryLimit {
+		limit = maxQueryLimit
+	}
+
+	query := `SELECT id, time_utc, kind, person, client, user_agent, method, route, target, run_id, status, outcome, error, duration_ms
+		FROM audit_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := l.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]Entry, 0)
+	for rows.Next() {
+		var e Entry
+		var ts string
+		if err := rows.Scan(&e.ID, &ts, &e.Kind, &e.Person, &e.Client, &e.UserAgent, &e.Method, &e.Route,
+			&e.Target, &e.RunID, &e.Status, &e.Outcome, &e.Error, &e.DurationMS); err != nil {
+			return nil, err
+		}
+		if e.Time, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// Prune deletes entries older than the retention period and returns how many
+// were removed.
+func (l *Log) Prune() (int64, error) {
+	res, err := l.db.Exec("DELETE FROM audit_log WHERE time_utc < ?", formatTime(time.Now().Add(-l.retention)))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// formatTime uses a fixed-width layout so stored timestamps sort as strings.
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z07:00")
+}
@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestLog(t *testing.T, retention time.Duration) *Log {
+	t.Helper()
+	l, err := Open(filepath.Join(t.TempDir(), "audit.db"), retention)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestLog_RecordAndQuery(t *testing.T) {
+	l := openTestLog(t, 0)
+
+	entries := []Entry{
+		{Kind: KindRequest, Person: "sebastian", Client: "admin", Method: "POST", Route: "/api/files/save", Target: "notes/a.md", Status: 200},
+		{Kind: KindRequest, Person: "petra", Client: "token:t1", Method: "POST", Route: "/api/settings/env", Status: 403},
+		{Kind: KindTool, Person: "sebastian", Route: "write_file", Target: "notes/b.md", RunID: "run-1", Error: "boom"},
+	}
+	for _, e := range entries {
+		if err := l.Record(e); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	all, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(all) != 3 || all[0].Route != "write_file" {
+		t.Fatalf("entries = %+v, want newest first", all)
+	}
+	if all[0].Outcome != OutcomeError || all[1].Outcome != OutcomeError || all[2].Outcome != OutcomeOK {
+		t.Errorf("outcomes = %s, %s, %s", all[0].Outcome, all[1].Outcome, all[2].Outcome)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"person", Filter{Person: "sebastian"}, 2},
+		{"kind", Filter{Kind: KindTool}, 1},
+		{"route", Filter{Route: "/api/settings/env"}, 1},
+		{"run", Filter{RunID: "run-1"}, 1},
+		{"limit", Filter{Limit: 2}, 2},
+		{"since", Filter{Since: time.Now().Add(time.Hour)}, 0},
+		{"until", Filter{Until: time.Now().Add(-time.Hour)}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := l.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("got %d entries, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestLog_AppendOnly(t *testing.T) {
+	l := openTestLog(t, 0)
+	if err := l.Record(Entry{Kind: KindRequest, Route: "/api/save"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.db.Exec("UPDATE audit_log SET person = 'x'"); err == nil {
+		t.Error("expected updates to be rejected")
+	}
+}
+
+func TestLog_Retention(t *testing.T) {
+	l := openTestLog(t, 24*time.Hour)
+	l.Record(Entry{Kind: KindRequest, Route: "/api/old", Time: time.Now().Add(-48 * time.Hour)})
+	l.Record(Entry{Kind: KindRequest, Route: "/api/new"})
+
+	n, err := l.Prune()
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	// Record also prunes on its first call, so n may already be zero.
+	got, _ := l.Query(Filter{})
+	if len(got) != 1 || got[0].Route != "/api/new" {
+		t.Errorf("after prune (%d removed): %+v", n, got)
+	}
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"notes-editor/internal/fileutil"
+	"notes-editor/internal/vault"
+)
+
+// readLocalFile decodes the JSON file at path into v. A missing file leaves v
+// untouched.
+func readLocalFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeLocalFile replaces the file at path with v as JSON, readable only by the
+// owner.
+func writeLocalFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteAtomic(path, append(data, '\n'), 0600)
+}
+
+// readLegacyFile decodes a vault-root file from before the auth stores moved out
+// of the vault. It reports whether the file existed.
+func readLegacyFile(store *vault.Store, name string, v any) (bool, error) {
+	content, err := store.ReadRootFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal([]byte(content), v)
+}
+
+// removeLegacyFile deletes a migrated vault-root file.
+func removeLegacyFile(store *vault.Store, name string) error {
+	fullPath, err := vault.ResolveRootPath(store.RootPath(), name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
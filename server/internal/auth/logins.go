@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+
+	"notes-editor/internal/vault"
+)
+
+// LegacyLoginsFile is the vault-root file that held password hashes and passkeys
+// before they moved to a local file outside the synced vault. ImportLegacy
+// migrates it.
+const LegacyLoginsFile = ".auth/logins.json"
+
+// ErrPasskeyNotFound is returned when removing or using an unknown passkey.
+var ErrPasskeyNotFound = errors.New("passkey not found")
+
+// Login holds a person's interactive login methods.
+type Login struct {
+	Person       string `json:"person"`
+	PasswordHash string `json:"password_hash,omitempty"`
+	// WebAuthnID is the random user handle presented to authenticators.
+	WebAuthnID []byte    `json:"webauthn_id,omitempty"`
+	Passkeys   []Passkey `json:"passkeys,omitempty"`
+}
+
+// Passkey is a registered WebAuthn credential.
+type Passkey struct {
+	ID         string              `json:"id"`
+	Name       string              `json:"name"`
+	Credential webauthn.Credential `json:"credential"`
+	CreatedAt  time.Time           `json:"created_at"`
+	LastUsedAt *time.Time          `json:"last_used_at,omitempty"`
+}
+
+// HasPassword reports whether a password is set.
+func (l Login) HasPassword() bool {
+	return l.PasswordHash != ""
+}
+
+// WebAuthn adapts the login to the webauthn.User interface.
+func (l Login) WebAuthn() webauthn.User {
+	return webAuthnUser{l}
+}
+
+type webAuthnUser struct {
+	login Login
+}
+
+func (u webAuthnUser) WebAuthnID() []byte          { return u.login.WebAuthnID }
+func (u webAuthnUser) WebAuthnName() string        { return u.login.Person }
+func (u webAuthnUser) WebAuthnDisplayName() string { return u.login.Person }
+func (u webAuthnUser) WebAuthnIcon() string        { return "" }
+
+func (u webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	out := make([]webauthn.Credential, 0, len(u.login.Passkeys))
+	for _, pk := range u.login.Passkeys {
+		out = append(out, pk.Credential)
+	}
+	return out
+}
+
+// Logins reads and writes login methods in a local file outside the synced
+// vault, like Tokens.
+type Logins struct {
+	mu   sync.RWMutex
+	path string
+}
+
+// NewLogins returns a login store backed by the file at path.
+func NewLogins(path string) *Logins {
+	return &Logins{path: path}
+}
+
+func (l *Logins) load() ([]Login, error) {
+	var logins []Login
+	if err := readLocalFile(l.path, &logins); err != nil {
+		return nil, err
+	}
+	return logins, nil
+}
+
+func (l *Logins) save(logins []Login) error {
+	if logins == nil {
+		logins = make([]Login, 0)
+	}
+	return writeLocalFile(l.path, logins)
+}
+
+// ImportLegacy adds the logins of persons without a local login from
+// LegacyLoginsFile in the vault and deletes the vault file. It reports whether
+// the vault changed. The caller holds the vault write lock.
+func (l *Logins) ImportLegacy(store *vault.Store) (bool, error) {
+	var legacy []Login
+	found, err := readLegacyFile(store, LegacyLoginsFile, &legacy)
+	if err != nil || !found {
+		return false, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	all, err := l.load()
+	if err != nil {
+		return false, err
+	}
+	known := make(map[string]bool, len(all))
+	for _, login := range all {
+		known[login.Person] = true
+	}
+	for _, login := range legacy {
+		if !known[login.Person] {
+			all = append(all, login)
+		}
+	}
+	if err := l.save(all); err != nil {
+		return false, err
+	}
+	return true, removeLegacyFile(store, LegacyLoginsFile)
+}
+
+// Get returns the person's login methods. A person without any has a zero Login
+// with only Person set.
+func (l *Logins) Get(person string) (Login, error) {
+	l.mu.RLock()
+	all, err := l.load()
+	l.mu.RUnlock()
+	if err != nil {
+		return Login{}, err
+	}
+	for _, login := range all {
+		if login.Person == person {
+			return login, nil
+		}
+	}
+	return Login{Person: person}, nil
+}
+
+// FindByWebAuthnID returns the login owning the WebAuthn user handle.
+func (l *Logins) FindByWebAuthnID(id []byte) (Login, bool, error) {
+	if len(id) == 0 {
+		return Login{}, false, nil
+	}
+	l.mu.RLock()
+	all, err := l.load()
+	l.mu.RUnlock()
+	if err != nil {
+		return Login{}, false, err
+	}
+	for _, login := range all {
+		if bytes.Equal(login.WebAuthnID, id) {
+			return login, true, nil
+		}
+	}
+	return Login{}, false, nil
+}
+
+// update applies fn to the person's login, creating it if needed, and saves.
+func (l *Logins) update(person string, fn func(*Login) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	all, err := l.load()
+	if err != nil {
+		return err
+	}
+	idx := -1
+	for i := range all {
+		if all[i].Person == person {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		all = append(all, Login{Person: person})
+		idx = len(all) - 1
+	}
+	if err := fn(&all[idx]); err != nil {
+		return err
+	}
+	return l.save(all)
+}
+
+// SetPassword stores a new password hash for person.
+func (l *Logins) SetPassword(person, hash string) error {
+	return l.update(person, func(login *Login) error {
+		login.PasswordHash = hash
+		return nil
+	})
+}
+
+// EnsureWebAuthnID returns the person's WebAuthn user handle, generating and
+// storing one on first use.
+func (l *Logins) EnsureWebAuthnID(person string) ([]byte, error) {
+	var id []byte
+	err := l.update(person, func(login *Login) error {
+		if len(login.WebAuthnID) == 0 {
+			login.WebAuthnID = make([]byte, 32)
+			if _, err := rand.Read(login.WebAuthnID); err != nil {
+				return err
+			}
+		}
+		id = login.WebAuthnID
+		return nil
+	})
+	return id, err
+}
+
+// AddPasskey registers a new passkey for person.
+func (l *Logins) AddPasskey(person, name string, cred webauthn.Credential) (Passkey, error) {
+	pk := Passkey{ID: uuid.NewString(), Name: name, Credential: cred, CreatedAt: time.Now().UTC()}
+	err := l.update(person, func(login *Login) error {
+		login.Passkeys = append(login.Passkeys, pk)
+		return nil
+	})
+	return pk, err
+}
+
+// RecordPasskeyUse stores the updated sign counter after a passkey login.
+func (l *Logins) RecordPasskeyUse(person string, cred webauthn.Credential) error {
+	return l.update(person, func(login *Login) error {
+		for i := range login.Passkeys {
+			if bytes.Equal(login.Passkeys[i].Credential.ID, cred.ID) {
+				now := time.Now().UTC()
+				login.Passkeys[i].Credential.Authenticator = cred.Authenticator
+				login.Passkeys[i].LastUsedAt = &now
+				return nil
+			}
+		}
+		return ErrPasskeyNotFound
+	})
+}
+
+// RemovePasskey deletes one of the person's passkeys.
+func (l *Logins) RemovePasskey(person, id string) error {
+	return l.update(person, func(login *Login) error {
+		for i, pk := range login.Passkeys {
+			if pk.ID == id {
+				login.Passkeys = append(login.Passkeys[:i], login.Passkeys[i+1:]...)
+				return nil
+			}
+		}
+		return ErrPasskeyNotFound
+	})
+}
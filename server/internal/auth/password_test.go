@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHashAndVerifyPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse battery")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=2$") {
+		t.Fatalf("unexpected hash format %q", hash)
+	}
+	other, _ := HashPassword("correct horse battery")
+	if other == hash {
+		t.Fatal("hashes must be salted")
+	}
+
+	if ok, err := VerifyPassword("correct horse battery", hash); err != nil || !ok {
+		t.Fatalf("VerifyPassword(correct) = %v, %v", ok, err)
+	}
+	if ok, _ := VerifyPassword("wrong horse battery", hash); ok {
+		t.Fatal("wrong password accepted")
+	}
+	if _, err := VerifyPassword("x", "$2a$10$bcrypt"); !errors.Is(err, ErrInvalidHash) {
+		t.Fatalf("foreign hash err = %v", err)
+	}
+	if _, err := HashPassword("short"); !errors.Is(err, ErrPasswordTooShort) {
+		t.Fatalf("short password err = %v", err)
+	}
+}
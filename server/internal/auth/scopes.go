@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Scope limits what a per-person token may do. Each route group in the API
+// requires one of a small set of scopes.
+type Scope string
+
+const (
+	// ScopeVaultRead reads notes, files and sync status.
+	ScopeVaultRead Scope = "vault:read"
+	// ScopeVaultWrite changes notes and files and runs git operations.
+	ScopeVaultWrite Scope = "vault:write"
+	// ScopeVaultAppend only appends to the daily note and adds todos (quick capture).
+	ScopeVaultAppend Scope = "vault:append"
+	// ScopeSleep reads and logs sleep times.
+	ScopeSleep Scope = "sleep"
+	// ScopeAgent uses Claude and agent chats.
+	ScopeAgent Scope = "agent"
+	// ScopeToolsExecute runs agent tools for the token's person (the pi-gateway).
+	ScopeToolsExecute Scope = "tools:execute"
+	// ScopeAccount manages the person's own tokens, sessions, password and passkeys.
+	ScopeAccount Scope = "account"
+	// ScopeAdmin grants the admin role: server settings, whole-vault operations and
+	// acting as any person.
+	ScopeAdmin Scope = "admin"
+)
+
+// PersonScopes is everything a person can do. Tokens without explicit scopes and
+// login sessions get these.
+var PersonScopes = []Scope{
+	ScopeVaultRead, ScopeVaultWrite, ScopeVaultAppend, ScopeSleep,
+	ScopeAgent, ScopeToolsExecute, ScopeAccount,
+}
+
+// ParseScopes validates scope names. Duplicates are removed.
+func ParseScopes(names []string) ([]Scope, error) {
+	out := make([]Scope, 0, len(names))
+	for _, name := range names {
+		s := Scope(name)
+		if s != ScopeAdmin && !slices.Contains(PersonScopes, s) {
+			return nil, fmt.Errorf("unknown scope %q", name)
+		}
+		if !slices.Contains(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// HasScope reports whether the principal may use routes requiring s. Admins hold
+// every scope.
+func (p Principal) HasScope(s Scope) bool {
+	return p.IsAdmin() || slices.Contains(p.Scopes, s)
+}
+
+// HasScopes reports whether the principal holds all of scopes.
+func (p Principal) HasScopes(scopes []Scope) bool {
+	for _, s := range scopes {
+		if !p.HasScope(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// EffectiveScopes returns the scopes a token grants: its own, or PersonScopes for
+// tokens issued without explicit scopes.
+func (t Token) EffectiveScopes() []Scope {
+	if len(t.Scopes) == 0 {
+		return PersonScopes
+	}
+	return t.Scopes
+}
+
+// IsAdmin reports whether the token grants the admin role.
+func (t Token) IsAdmin() bool {
+	return slices.Contains(t.Scopes, ScopeAdmin)
+}
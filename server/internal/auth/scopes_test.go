@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes([]string{"sleep", "vault:read", "sleep"})
+	if err != nil {
+		t.Fatalf("ParseScopes: %v", err)
+	}
+	if !slices.Equal(scopes, []Scope{ScopeSleep, ScopeVaultRead}) {
+		t.Errorf("scopes = %v", scopes)
+	}
+	if _, err := ParseScopes([]string{"root"}); err == nil {
+		t.Error("expected error for unknown scope")
+	}
+}
+
+func TestPrincipalHasScope(t *testing.T) {
+	p := Principal{Role: RolePerson, Person: "sebastian", Scopes: []Scope{ScopeSleep}}
+	if !p.HasScope(ScopeSleep) || p.HasScope(ScopeVaultWrite) {
+		t.Errorf("unexpected scopes for %+v", p)
+	}
+	if p.HasScopes([]Scope{ScopeSleep, ScopeAccount}) {
+		t.Error("HasScopes should require every scope")
+	}
+	admin := Principal{Role: RoleAdmin}
+	if !admin.HasScope(ScopeAdmin) || !admin.HasScopes(PersonScopes) {
+		t.Error("admin should hold every scope")
+	}
+}
+
+func TestTokenEffectiveScopes(t *testing.T) {
+	if got := (Token{}).EffectiveScopes(); !slices.Equal(got, PersonScopes) {
+		t.Errorf("unscoped token = %v, want PersonScopes", got)
+	}
+	tok := Token{Scopes: []Scope{ScopeAdmin}}
+	if !tok.IsAdmin() {
+		t.Error("admin-scoped token should be admin")
+	}
+}
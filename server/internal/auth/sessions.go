@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ErrSessionNotFound is returned when revoking an unknown session.
+var ErrSessionNotFound = errors.New("session not found")
+
+// DefaultSessionTTL applies when NewSessions is given no TTL.
+const DefaultSessionTTL = 30 * 24 * time.Hour
+
+// lastSeenResolution limits how often a session's last-seen time is written.
+const lastSeenResolution = time.Minute
+
+// Session is a browser login session. The cookie value is only stored hashed;
+// ID is a separate public identifier used for listing and remote logout.
+type Session struct {
+	ID         string    `json:"id"`
+	Person     string    `json:"person"`
+	Method     string    `json:"method"`
+	UserAgent  string    `json:"user_agent"`
+	RemoteAddr string    `json:"remote_addr"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// CSRFToken must accompany unsafe requests authenticated by this session.
+	CSRFToken string `json:"-"`
+}
+
+// Sessions stores login sessions in a local SQLite database. Sessions are
+// per server and deliberately not synced with the vault.
+type Sessions struct {
+	db  *sql.DB
+	ttl time.Duration
+}
+
+// NewSessions opens or creates the session database at dbPath. Sessions expire
+// ttl after login; a non-positive ttl selects DefaultSessionTTL.
+func NewSessions(dbPath string, ttl time.Duration) (*Sessions, error) {
+	if dbPath == "" {
+		return nil, errors.New("session db path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0700); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+
+	s := &Sessions{db: db, ttl: ttl}
+	if err := s.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the database.
+func (s *Sessions) Close() error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func (s *Sessions) init() error {
+	stmts := []string{
+		"PRAGMA journal_mode=WAL;",
+		"PRAGMA busy_timeout=5000;",
+		`CREATE TABLE IF NOT EXISTS sessions (
+			token_hash TEXT PRIMARY KEY,
+			id TEXT NOT NULL UNIQUE,
+			person TEXT NOT NULL,
+			method TEXT NOT NULL,
+			csrf_token TEXT NOT NULL,
+			user_agent TEXT NOT NULL DEFAULT '',
+			remote_addr TEXT NOT NULL DEFAULT '',
+			created_at_utc TEXT NOT NULL,
+			last_seen_at_utc TEXT NOT NULL,
+			expires_at_utc TEXT NOT NULL
+		);`,
+		"CREATE INDEX IF NOT EXISTS idx_sessions_person ON sessions(person, expires_at_utc);",
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Create starts a session for person and returns it with the cookie value.
+func (s *Sessions) Create(person, method, userAgent, remoteAddr string) (Session, string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return Session{}, "", err
+	}
+	csrf, err := randomToken()
+	if err != nil {
+		return Session{}, "", err
+	}
+	now := time.Now().UTC()
+	sess := Session{
+		ID:         uuid.NewString(),
+		Person:     person,
+		Method:     method,
+		UserAgent:  userAgent,
+		RemoteAddr: remoteAddr,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.ttl),
+		CSRFToken:  csrf,
+	}
+	_, err = s.db.Exec(`INSERT INTO sessions
+		(token_hash, id, person, method, csrf_token, user_agent, remote_addr, created_at_utc, last_seen_at_utc, expires_at_utc)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		HashToken(token), sess.ID, sess.Person, sess.Method, sess.CSRFToken, sess.UserAgent, sess.RemoteAddr,
+		formatTime(sess.CreatedAt), formatTime(sess.LastSeenAt), formatTime(sess.ExpiresAt))
+	if err != nil {
+		return Session{}, "", err
+	}
+	return sess, token, nil
+}
+
+// Lookup returns the live session for a cookie value and refreshes its last-seen time.
+func (s *Sessions) Lookup(token string) (Session, bool, error) {
+	if token == "" {
+		return Session{}, false, nil
+	}
+	row := s.db.QueryRow(`SELECT id, person, method, csrf_token, user_agent, remote_addr, created_at_utc, last_seen_at_utc, expires_at_utc
+		FROM sessions WHERE token_hash = ?`, HashToken(token))
+	sess, err := scanSession(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, false, nil
+	}
+	if err != nil {
+		return Session{}, false, err
+	}
+	now := time.Now().UTC()
+	if !now.Before(sess.ExpiresAt) {
+		_, err := s.db.Exec("DELETE FROM sessions WHERE id = ?", sess.ID)
+		return Session{}, false, err
+	}
+	if now.Sub(sess.LastSeenAt) >= lastSeenResolution {
+		sess.LastSeenAt = now
+		if _, err := s.db.Exec("UPDATE sessions SET last_seen_at_utc = ? WHERE id = ?", formatTime(now), sess.ID); err != nil {
+			return Session{}, false, err
+		}
+	}
+	return sess, true, nil
+}
+
+// List returns the person's live sessions, most recently used first.
+func (s *Sessions) List(person string) ([]Session, error) {
+	rows, err := s.db.Query(`SELECT id, person, method, csrf_token, user_agent, remote_addr, created_at_utc, last_seen_at_utc, expires_at_utc
+		FROM sessions WHERE person = ? AND expires_at_utc > ? ORDER BY last_seen_at_utc DESC`,
+		person, formatTime(time.Now().UTC()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]Session, 0)
+	for rows.Next() {
+		sess, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sess)
+	}
+	return out, rows.Err()
+}
+
+// Revoke ends one of the person's sessions by its public ID.
+func (s *Sessions) Revoke(person, id string) error {
+	res, err := s.db.Exec("DELETE FROM sessions WHERE id = ? AND person = ?", id, person)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeAll ends all of the person's sessions except keepID, e.g. after a
+// password change.
+func (s *Sessions) RevokeAll(person, keepID string) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE person = ? AND id != ?", person, keepID)
+	return err
+}
+
+// Prune deletes expired sessions.
+func (s *Sessions) Prune() error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE expires_at_utc <= ?", formatTime(time.Now().UTC()))
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSession(row rowScanner) (Session, error) {
+	var sess Session
+	var created, lastSeen, expires string
+	if err := row.Scan(&sess.ID, &sess.Person, &sess.Method, &sess.CSRFToken, &sess.UserAgent, &sess.RemoteAddr,
+		&created, &lastSeen, &expires); err != nil {
+		return Session{}, err
+	}
+	var err error
+	if sess.CreatedAt, err = time.Parse(time.RFC3339Nano, created); err != nil {
+		return Session{}, err
+	}
+	if sess.LastSeenAt, err = time.Parse(time.RFC3339Nano, lastSeen); err != nil {
+		return Session{}, err
+	}
+	if sess.ExpiresAt, err = time.Parse(time.RFC3339Nano, expires); err != nil {
+		return Session{}, err
+	}
+	return sess, nil
+}
+
+// formatTime uses a fixed-width layout so stored timestamps sort as strings.
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z07:00")
+}
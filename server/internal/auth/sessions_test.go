@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSessions(t *testing.T) {
+	sessions, err := NewSessions(filepath.Join(t.TempDir(), "sessions.db"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sessions.Close()
+
+	sess, token, err := sessions.Create("sebastian", "password", "test-agent", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if token == "" || sess.CSRFToken == "" || token == sess.ID {
+		t.Fatalf("unexpected session %+v token %q", sess, token)
+	}
+
+	found, ok, err := sessions.Lookup(token)
+	if err != nil || !ok || found.ID != sess.ID || found.CSRFToken != sess.CSRFToken {
+		t.Fatalf("Lookup = %+v, %v, %v", found, ok, err)
+	}
+	if _, ok, _ := sessions.Lookup(sess.ID); ok {
+		t.Fatal("the public session ID must not authenticate")
+	}
+
+	if list, _ := sessions.List("sebastian"); len(list) != 1 {
+		t.Fatalf("List = %+v", list)
+	}
+	if err := sessions.Revoke("petra", sess.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("revoking another person's session: %v", err)
+	}
+	if err := sessions.Revoke("sebastian", sess.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, ok, _ := sessions.Lookup(token); ok {
+		t.Fatal("revoked session still valid")
+	}
+}
+
+func TestSessions_Expire(t *testing.T) {
+	sessions, err := NewSessions(filepath.Join(t.TempDir(), "sessions.db"), time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sessions.Close()
+
+	_, token, err := sessions.Create("petra", "passkey", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, ok, _ := sessions.Lookup(token); ok {
+		t.Fatal("expired session still valid")
+	}
+}
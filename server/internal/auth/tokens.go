@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"notes-editor/internal/vault"
+)
+
+// LegacyTokensFile is the vault-root file that held per-person API tokens before
+// they moved to a local file outside the synced vault. ImportLegacy migrates it.
+const LegacyTokensFile = ".auth/tokens.json"
+
+// tokenPrefix marks per-person tokens so they are recognisable in configs and logs.
+const tokenPrefix = "nt_"
+
+// ErrTokenNotFound is returned when revoking an unknown token.
+var ErrTokenNotFound = errors.New("token not found")
+
+// Role is the authorisation level of an authenticated caller.
+type Role string
+
+const (
+	// RoleAdmin is granted to the legacy NOTES_TOKEN and to tokens with ScopeAdmin.
+	// Admins may act as any person.
+	RoleAdmin Role = "admin"
+	// RolePerson is granted to per-person tokens and login sessions. The person is
+	// fixed by the credential.
+	RolePerson Role = "person"
+)
+
+const principalContextKey contextKey = "principal"
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Role Role
+	// Person is set for RolePerson and empty for RoleAdmin.
+	Person string
+	// Scopes limits what a RolePerson principal may do.
+	Scopes []Scope
+	// TokenID identifies the per-person token used, if any.
+	TokenID string
+	// SessionID identifies the login session used, if any.
+	SessionID string
+}
+
+// IsAdmin reports whether the principal has the admin role.
+func (p Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+// WithPrincipal returns a new context carrying the authenticated principal.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey, p)
+}
+
+// PrincipalFromContext returns the authenticated principal, if any.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalContextKey).(Principal)
+	return p, ok
+}
+
+// Token is a stored per-person API token. The plaintext is never stored. Tokens
+// without Scopes grant PersonScopes; admin tokens may have no person.
+type Token struct {
+	ID        string    `json:"id"`
+	Person    string    `json:"person"`
+	Name      string    `json:"name"`
+	Scopes    []Scope   `json:"scopes,omitempty"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Tokens reads and writes per-person API tokens in a local file outside the
+// synced vault. Only SHA-256 hashes are stored.
+type Tokens struct {
+	mu   sync.RWMutex
+	path string
+}
+
+// NewTokens returns a token store backed by the file at path.
+func NewTokens(path string) *Tokens {
+	return &Tokens{path: path}
+}
+
+// HashToken returns the hex-encoded SHA-256 hash under which a token is stored.
+// Tokens are long random strings, so an unsalted fast hash is sufficient.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *Tokens) load() ([]Token, error) {
+	var tokens []Token
+	if err := readLocalFile(t.path, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (t *Tokens) save(tokens []Token) error {
+	if tokens == nil {
+		tokens = make([]Token, 0)
+	}
+	return writeLocalFile(t.path, tokens)
+}
+
+// ImportLegacy merges the tokens from LegacyTokensFile in the vault into the
+// local file and deletes the vault file. It reports whether the vault changed.
+// The caller holds the vault write lock.
+func (t *Tokens) ImportLegacy(store *vault.Store) (bool, error) {
+	var legacy []Token
+	found, err := readLegacyFile(store, LegacyTokensFile, &legacy)
+	if err != nil || !found {
+		return false, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	all, err := t.load()
+	if err != nil {
+		return false, err
+	}
+	known := make(map[string]bool, len(all))
+	for _, tok := range all {
+		known[tok.ID] = true
+	}
+	for _, tok := range legacy {
+		if !known[tok.ID] {
+			all = append(all, tok)
+		}
+	}
+	if err := t.save(all); err != nil {
+		return false, err
+	}
+	return true, removeLegacyFile(store, LegacyTokensFile)
+}
+
+// List returns the tokens of person, or of every person when person is empty,
+// newest first.
+func (t *Tokens) List(person string) ([]Token, error) {
+	t.mu.RLock()
+	all, err := t.load()
+	t.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Token, 0)
+	for _, tok := range all {
+		if person == "" || tok.Person == person {
+			out = append(out, tok)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+// Create issues a new token for person with the given scopes and returns the stored
+// record together with the plaintext token, which is not retrievable afterwards.
+func (t *Tokens) Create(person, name string, scopes []Scope) (Token, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return Token{}, "", err
+	}
+	plaintext := tokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	tok := Token{
+		ID:        uuid.NewString(),
+		Person:    person,
+		Name:      name,
+		Scopes:    scopes,
+		Hash:      HashToken(plaintext),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	all, err := t.load()
+	if err != nil {
+		return Token{}, "", err
+	}
+	if err := t.save(append(all, tok)); err != nil {
+		return Token{}, "", err
+	}
+	return tok, plaintext, nil
+}
+
+// Revoke deletes the token with the given ID. A non-empty person restricts the
+// revocation to that person's tokens.
+func (t *Tokens) Revoke(person, id string) (Token, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	all, err := t.load()
+	if err != nil {
+		return Token{}, err
+	}
+	for i, tok := range all {
+		if tok.ID == id && (person == "" || tok.Person == person) {
+			return tok, t.save(append(all[:i], all[i+1:]...))
+		}
+	}
+	return Token{}, ErrTokenNotFound
+}
+
+// Verify returns the stored token matching plaintext.
+func (t *Tokens) Verify(plaintext string) (Token, bool, error) {
+	if plaintext == "" {
+		return Token{}, false, nil
+	}
+	t.mu.RLock()
+	all, err := t.load()
+	t.mu.RUnlock()
+	if err != nil {
+		return Token{}, false, err
+	}
+	hash := []byte(HashToken(plaintext))
+	for _, tok := range all {
+		if subtle.ConstantTimeCompare(hash, []byte(tok.Hash)) == 1 {
+			return tok, true, nil
+		}
+	}
+	return Token{}, false, nil
+}
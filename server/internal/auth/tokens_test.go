@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"notes-editor/internal/vault"
+)
+
+func TestTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	tokens := NewTokens(path)
+
+	tok, plaintext, err := tokens.Create("sebastian", "phone", nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !strings.HasPrefix(plaintext, tokenPrefix) || len(plaintext) < 40 {
+		t.Fatalf("unexpected token %q", plaintext)
+	}
+	if _, _, err := tokens.Create("petra", "laptop", []Scope{ScopeSleep}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw := string(data); strings.Contains(raw, plaintext) || !strings.Contains(raw, HashToken(plaintext)) {
+		t.Fatalf("tokens must be stored hashed:\n%s", raw)
+	}
+
+	found, ok, err := tokens.Verify(plaintext)
+	if err != nil || !ok || found.ID != tok.ID || found.Person != "sebastian" {
+		t.Fatalf("Verify = %+v, %v, %v", found, ok, err)
+	}
+	for _, bad := range []string{"", "nt_wrong", HashToken(plaintext)} {
+		if _, ok, _ := tokens.Verify(bad); ok {
+			t.Errorf("Verify(%q) accepted", bad)
+		}
+	}
+
+	if list, _ := tokens.List("sebastian"); len(list) != 1 || list[0].Name != "phone" {
+		t.Fatalf("List(sebastian) = %+v", list)
+	}
+	if list, _ := tokens.List(""); len(list) != 2 {
+		t.Fatalf("List(all) = %+v", list)
+	}
+
+	if _, err := tokens.Revoke("petra", tok.ID); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("revoking another person's token: %v", err)
+	}
+	if _, err := tokens.Revoke("sebastian", tok.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, ok, _ := tokens.Verify(plaintext); ok {
+		t.Fatal("revoked token still verifies")
+	}
+}
+
+func TestTokensImportLegacy(t *testing.T) {
+	root := t.TempDir()
+	store := vault.NewStore(root)
+	legacy := `[{"id":"old","person":"petra","name":"tablet","hash":"` + HashToken("nt_old") + `","created_at":"2026-01-02T03:04:05Z"}]`
+	if err := store.WriteRootFile(LegacyTokensFile, legacy); err != nil {
+		t.Fatal(err)
+	}
+	tokens := NewTokens(filepath.Join(t.TempDir(), "tokens.json"))
+	if _, _, err := tokens.Create("sebastian", "phone", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, err := tokens.ImportLegacy(store)
+	if err != nil || !migrated {
+		t.Fatalf("ImportLegacy = %v, %v", migrated, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(LegacyTokensFile))); !os.IsNotExist(err) {
+		t.Fatalf("legacy file should be deleted, stat err = %v", err)
+	}
+	if found, ok, _ := tokens.Verify("nt_old"); !ok || found.Person != "petra" {
+		t.Fatalf("migrated token does not verify: %+v", found)
+	}
+	if list, _ := tokens.List(""); len(list) != 2 {
+		t.Fatalf("List = %+v", list)
+	}
+}
+
+func TestLoginsImportLegacy(t *testing.T) {
+	root := t.TempDir()
+	store := vault.NewStore(root)
+	legacy := `[{"person":"petra","password_hash":"old-petra"},{"person":"sebastian","password_hash":"old-sebastian"}]`
+	if err := store.WriteRootFile(LegacyLoginsFile, legacy); err != nil {
+		t.Fatal(err)
+	}
+	logins := NewLogins(filepath.Join(t.TempDir(), "logins.json"))
+	if err := logins.SetPassword("sebastian", "new-sebastian"); err != nil {
+		t.Fatal(err)
+	}
+
+	if migrated, err := logins.ImportLegacy(store); err != nil || !migrated {
+		t.Fatalf("ImportLegacy = %v, %v", migrated, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(LegacyLoginsFile))); !os.IsNotExist(err) {
+		t.Fatalf("legacy file should be deleted, stat err = %v", err)
+	}
+	if login, _ := logins.Get("petra"); login.PasswordHash != "old-petra" {
+		t.Errorf("petra = %+v", login)
+	}
+	// A login changed locally wins over the vault copy.
+	if login, _ := logins.Get("sebastian"); login.PasswordHash != "new-sebastian" {
+		t.Errorf("sebastian = %+v", login)
+	}
+}
@@ -0,0 +1,231 @@
+package backup
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Archive entry prefixes.
+const (
+	manifestName  = "manifest.json"
+	vaultPrefix   = "vault/"
+	sessionPrefix = "agent-sessions/"
+	sleepDBName   = "sleep.db"
+	agentDBName   = "agent.db"
+)
+
+// Manifest describes the contents of one backup archive. It is stored as the last
+// entry of the archive so it can carry checksums of everything before it.
+type Manifest struct {
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"created_at"`
+	Files     []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry is one file recorded in a backup manifest.
+type ManifestEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Sources describes what goes into a backup archive.
+type Sources struct {
+	// VaultRoot is the notes root containing all person folders.
+	VaultRoot string
+	// SleepDBPath is an SQLite snapshot of the sleep database. Optional.
+	SleepDBPath string
+	// AgentDBPath is an SQLite snapshot of the agent session database. Optional.
+	AgentDBPath string
+	// SessionDir holds agent gateway session files stored outside the vault. Optional.
+	SessionDir string
+	// ExcludeDir is skipped while archiving, e.g. a backup directory inside the vault.
+	ExcludeDir string
+}
+
+// vaultSkip reports whether a vault-relative path is excluded from backups.
+// Git metadata lives on the remote, and the live sleep and agent databases are
+// backed up from consistent snapshots instead of their on-disk files.
+func vaultSkip(rel string, isDir bool) bool {
+	first := strings.SplitN(rel, "/", 2)[0]
+	if first == ".git" {
+		return true
+	}
+	if !isDir && !strings.Contains(rel, "/") && (strings.HasPrefix(rel, sleepDBName) || strings.HasPrefix(rel, agentDBName)) {
+		return true
+	}
+	return false
+}
+
+type archiveWriter struct {
+	zw       *zip.Writer
+	manifest Manifest
+	exclude  string
+}
+
+func writeArchive(w io.Writer, src Sources, now time.Time) (Manifest, error) {
+	aw := &archiveWriter{
+		zw:       zip.NewWriter(w),
+		manifest: Manifest{Version: 1, CreatedAt: now.UTC()},
+	}
+	if src.ExcludeDir != "" {
+		if abs, err := filepath.Abs(src.ExcludeDir); err == nil {
+			aw.exclude = abs
+		}
+	}
+
+	if err := aw.addTree(src.VaultRoot, vaultPrefix, vaultSkip); err != nil {
+		return Manifest{}, fmt.Errorf("archive vault: %w", err)
+	}
+	if src.SleepDBPath != "" {
+		if err := aw.addFile(src.SleepDBPath, sleepDBName); err != nil {
+			return Manifest{}, fmt.Errorf("archive sleep db: %w", err)
+		}
+	}
+	if src.AgentDBPath != "" {
+		if err := aw.addFile(src.AgentDBPath, agentDBName); err != nil {
+			return Manifest{}, fmt.Errorf("archive agent db: %w", err)
+		}
+	}
+	if src.SessionDir != "" {
+		if _, err := os.Stat(src.SessionDir); err == nil {
+			if err := aw.addTree(src.SessionDir, sessionPrefix, nil); err != nil {
+				return Manifest{}, fmt.Errorf("archive agent sessions: %w", err)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(aw.manifest, "", "  ")
+	if err != nil {
+		return Manifest{}, err
+	}
+	mw, err := aw.zw.Create(manifestName)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if _, err := mw.Write(data); err != nil {
+		return Manifest{}, err
+	}
+	if err := aw.zw.Close(); err != nil {
+		return Manifest{}, err
+	}
+	return aw.manifest, nil
+}
+
+func (aw *archiveWriter) addTree(root, prefix string, skip func(rel string, isDir bool) bool) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if p == root {
+			return nil
+		}
+		if d.Type()&os.ModeSymlink != 0 {
+			return nil
+		}
+		if d.IsDir() && aw.exclude != "" {
+			if abs, err := filepath.Abs(p); err == nil && abs == aw.exclude {
+				return filepath.SkipDir
+			}
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if skip != nil && skip(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return aw.addFile(p, prefix+rel)
+	})
+}
+
+func (aw *archiveWriter) addFile(src, name string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+
+	writer, err := aw.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(writer, hash), f)
+	if err != nil {
+		return err
+	}
+
+	aw.manifest.Files = append(aw.manifest.Files, ManifestEntry{
+		Path:   name,
+		Size:   size,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	})
+	return nil
+}
+
+// ValidateEntryName rejects archive entry names that are absolute or escape the
+// extraction root.
+func ValidateEntryName(name string) error {
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, "\\") {
+		return fmt.Errorf("invalid archive entry %q", name)
+	}
+	cleaned := path.Clean(name)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return fmt.Errorf("archive entry %q escapes the destination", name)
+	}
+	return nil
+}
+
+// readManifest loads the manifest of an opened archive.
+func readManifest(zr *zip.Reader) (Manifest, error) {
+	for _, f := range zr.File {
+		if f.Name != manifestName {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return Manifest{}, err
+		}
+		defer rc.Close()
+		var m Manifest
+		if err := json.NewDecoder(rc).Decode(&m); err != nil {
+			return Manifest{}, fmt.Errorf("invalid manifest: %w", err)
+		}
+		return m, nil
+	}
+	return Manifest{}, errors.New("backup archive has no manifest")
+}
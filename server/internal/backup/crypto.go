@@ -0,0 +1,276 @@
+package backup
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Encrypted archive layout:
+//
+//	magic (6) | version (1) | argon2 time (4) | argon2 memory KiB (4) | argon2 threads (1) |
+//	salt (16) | nonce prefix (7) | sealed chunks...
+//
+// Each chunk holds up to chunkSize plaintext bytes sealed with AES-256-GCM. The nonce is
+// the prefix followed by a big-endian chunk counter and a final-chunk flag, so reordered,
+// dropped or truncated chunks fail authentication.
+const (
+	fileMagic   = "NEBKP1"
+	fileVersion = 1
+
+	saltSize        = 16
+	noncePrefixSize = 7
+	chunkSize       = 64 * 1024
+
+	defaultArgonTime    = 2
+	defaultArgonMemory  = 64 * 1024
+	defaultArgonThreads = 4
+
+	maxArgonMemory = 1024 * 1024
+)
+
+// Decryption errors.
+var (
+	ErrNotEncryptedBackup = errors.New("not an encrypted backup archive")
+	ErrDecrypt            = errors.New("wrong passphrase or corrupted backup archive")
+	ErrEmptyPassphrase    = errors.New("backup passphrase is empty")
+)
+
+type header struct {
+	argonTime    uint32
+	argonMemory  uint32
+	argonThreads uint8
+	salt         [saltSize]byte
+	noncePrefix  [noncePrefixSize]byte
+}
+
+func (h header) marshal() []byte {
+	out := make([]byte, 0, len(fileMagic)+1+4+4+1+saltSize+noncePrefixSize)
+	out = append(out, fileMagic...)
+	out = append(out, fileVersion)
+	out = binary.BigEndian.AppendUint32(out, h.argonTime)
+	out = binary.BigEndian.AppendUint32(out, h.argonMemory)
+	out = append(out, h.argonThreads)
+	out = append(out, h.salt[:]...)
+	out = append(out, h.noncePrefix[:]...)
+	return out
+}
+
+func readHeader(r io.Reader) (header, error) {
+	var h header
+	buf := make([]byte, len(fileMagic)+1+4+4+1+saltSize+noncePrefixSize)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return h, ErrNotEncryptedBackup
+		}
+		return h, err
+	}
+	if string(buf[:len(fileMagic)]) != fileMagic {
+		return h, ErrNotEncryptedBackup
+	}
+	offset := len(fileMagic)
+	if buf[offset] != fileVersion {
+		return h, fmt.Errorf("unsupported backup archive version %d", buf[offset])
+	}
+	offset++
+	h.argonTime = binary.BigEndian.Uint32(buf[offset:])
+	offset += 4
+	h.argonMemory = binary.BigEndian.Uint32(buf[offset:])
+	offset += 4
+	h.argonThreads = buf[offset]
+	offset++
+	copy(h.salt[:], buf[offset:offset+saltSize])
+	offset += saltSize
+	copy(h.noncePrefix[:], buf[offset:offset+noncePrefixSize])
+
+	if h.argonTime == 0 || h.argonThreads == 0 || h.argonMemory == 0 || h.argonMemory > maxArgonMemory {
+		return h, ErrNotEncryptedBackup
+	}
+	return h, nil
+}
+
+func (h header) aead(passphrase string) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(passphrase), h.salt[:], h.argonTime, h.argonMemory, h.argonThreads, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (h header) nonce(counter uint32, final bool) []byte {
+	nonce := make([]byte, 0, noncePrefixSize+5)
+	nonce = append(nonce, h.noncePrefix[:]...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if final {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+type encryptWriter struct {
+	dst     io.Writer
+	aead    cipher.AEAD
+	header  header
+	buf     []byte
+	counter uint32
+	closed  bool
+}
+
+// NewEncryptWriter returns a writer that encrypts everything written to it into dst.
+// Close must be called to write the final authenticated chunk; it does not close dst.
+func NewEncryptWriter(dst io.Writer, passphrase string) (io.WriteCloser, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+
+	h := header{
+		argonTime:    defaultArgonTime,
+		argonMemory:  defaultArgonMemory,
+		argonThreads: defaultArgonThreads,
+	}
+	if _, err := rand.Read(h.salt[:]); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(h.noncePrefix[:]); err != nil {
+		return nil, err
+	}
+
+	aead, err := h.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dst.Write(h.marshal()); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{
+		dst:    dst,
+		aead:   aead,
+		header: h,
+		buf:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed backup encrypter")
+	}
+	written := 0
+	for len(p) > 0 {
+		// Only flush a full chunk once more data arrives, so the final chunk is never empty
+		// unless the whole stream is.
+		if len(w.buf) == chunkSize {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *encryptWriter) flush(final bool) error {
+	if w.counter == ^uint32(0) {
+		return errors.New("backup archive too large")
+	}
+	sealed := w.aead.Seal(nil, w.header.nonce(w.counter, final), w.buf, nil)
+	w.counter++
+	w.buf = w.buf[:0]
+	_, err := w.dst.Write(sealed)
+	return err
+}
+
+func (w *encryptWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.flush(true)
+}
+
+type decryptReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	header  header
+	sealed  []byte
+	plain   []byte
+	counter uint32
+	done    bool
+}
+
+// NewDecryptReader returns a reader yielding the plaintext of an archive written by
+// NewEncryptWriter. Reads fail with ErrDecrypt when the passphrase is wrong or the
+// archive was modified or truncated.
+func NewDecryptReader(src io.Reader, passphrase string) (io.Reader, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+
+	h, err := readHeader(src)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := h.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		src:    bufio.NewReaderSize(src, chunkSize+64),
+		aead:   aead,
+		header: h,
+		sealed: make([]byte, chunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+func (r *decryptReader) next() error {
+	n, err := io.ReadFull(r.src, r.sealed)
+	switch {
+	case err == nil:
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		// Short read: this must be the final chunk.
+	default:
+		return err
+	}
+
+	final := n < len(r.sealed)
+	if !final {
+		if _, peekErr := r.src.Peek(1); errors.Is(peekErr, io.EOF) {
+			final = true
+		}
+	}
+
+	plain, openErr := r.aead.Open(r.sealed[:0], r.header.nonce(r.counter, final), r.sealed[:n], nil)
+	if openErr != nil {
+		return ErrDecrypt
+	}
+	r.counter++
+	r.plain = plain
+	r.done = final
+	return nil
+}
@@ -0,0 +1,101 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func encryptBytes(t *testing.T, plain []byte, passphrase string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc, err := NewEncryptWriter(&buf, passphrase)
+	if err != nil {
+		t.Fatalf("NewEncryptWriter: %v", err)
+	}
+	if _, err := enc.Write(plain); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func decryptBytes(sealed []byte, passphrase string) ([]byte, error) {
+	dec, err := NewDecryptReader(bytes.NewReader(sealed), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(dec)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	large := make([]byte, 3*chunkSize+123)
+	if _, err := rand.Read(large); err != nil {
+		t.Fatalf("rand: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		plain []byte
+	}{
+		{"empty", nil},
+		{"small", []byte("hello vault")},
+		{"exact chunk", bytes.Repeat([]byte("a"), chunkSize)},
+		{"multi chunk", large},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed := encryptBytes(t, tt.plain, "secret")
+			got, err := decryptBytes(sealed, "secret")
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(got, tt.plain) {
+				t.Fatalf("round trip mismatch: got %d bytes, want %d", len(got), len(tt.plain))
+			}
+		})
+	}
+}
+
+func TestDecryptRejectsWrongPassphrase(t *testing.T) {
+	sealed := encryptBytes(t, []byte("private"), "secret")
+	if _, err := decryptBytes(sealed, "wrong"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("err = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestDecryptDetectsTruncation(t *testing.T) {
+	plain := bytes.Repeat([]byte("x"), 2*chunkSize+10)
+	sealed := encryptBytes(t, plain, "secret")
+
+	// Drop the final chunk: the remaining last chunk was not sealed as final.
+	headerLen := len(header{}.marshal())
+	truncated := sealed[:headerLen+2*(chunkSize+16)]
+	if _, err := decryptBytes(truncated, "secret"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("err = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestDecryptDetectsTampering(t *testing.T) {
+	sealed := encryptBytes(t, []byte("private note"), "secret")
+	sealed[len(sealed)-1] ^= 0x01
+	if _, err := decryptBytes(sealed, "secret"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("err = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestDecryptRejectsPlainZip(t *testing.T) {
+	if _, err := NewDecryptReader(bytes.NewReader([]byte("PK\x03\x04not encrypted at all..........")), "secret"); !errors.Is(err, ErrNotEncryptedBackup) {
+		t.Fatalf("err = %v, want ErrNotEncryptedBackup", err)
+	}
+}
+
+func TestEmptyPassphraseRejected(t *testing.T) {
+	if _, err := NewEncryptWriter(io.Discard, ""); !errors.Is(err, ErrEmptyPassphrase) {
+		t.Fatalf("err = %v, want ErrEmptyPassphrase", err)
+	}
+}
@@ -0,0 +1,639 @@
+// Package backup writes scheduled, encrypted archives of the notes vault, the sleep
+// database and agent session files, and verifies or restores them.
+package backup
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	archivePrefix     = "notes-backup-"
+	archiveSuffix     = ".zip.enc"
+	archiveTimeLayout = "20060102-150405"
+
+	// workDirPrefix and decryptedPrefix name the plaintext scratch files kept in
+	// the backup directory while an archive is written or read.
+	workDirPrefix   = ".backup-"
+	decryptedPrefix = ".decrypted-"
+
+	// startupDelay keeps the first scheduled backup out of the server boot path.
+	startupDelay = time.Minute
+)
+
+// Manager errors.
+var (
+	ErrNotConfigured = errors.New("backups are not configured (BACKUP_DIR and BACKUP_PASSPHRASE are required)")
+	ErrInvalidName   = errors.New("invalid backup name")
+	ErrNotFound      = errors.New("backup not found")
+)
+
+// Options configures a Manager.
+type Options struct {
+	// Dir receives the encrypted archives.
+	Dir string
+	// Passphrase derives the archive encryption key.
+	Passphrase string
+	// Interval between scheduled backups. Zero disables scheduling.
+	Interval time.Duration
+	// Keep is the number of most recent archives retained. Zero keeps all.
+	Keep int
+	// MaxAge removes archives older than this. Zero disables age-based pruning.
+	// The newest archive is never pruned.
+	MaxAge time.Duration
+
+	// Sources describes what to archive. SleepDBPath and AgentDBPath are ignored;
+	// the snapshot functions are used instead so the databases are captured
+	// consistently.
+	Sources Sources
+	// SnapshotSleepDB writes a consistent copy of the sleep database to dst. Optional.
+	SnapshotSleepDB func(dst string) error
+	// SnapshotAgentDB writes a consistent copy of the agent session database to dst. Optional.
+	SnapshotAgentDB func(dst string) error
+	// VaultLock is held for reading while the vault is archived. Optional.
+	VaultLock *sync.RWMutex
+}
+
+// Enabled reports whether the options are sufficient to write backups.
+func (o Options) Enabled() bool {
+	return strings.TrimSpace(o.Dir) != "" && o.Passphrase != ""
+}
+
+// Info describes one archive in the backup directory.
+type Info struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Status reports scheduler state.
+type Status struct {
+	Enabled       bool       `json:"enabled"`
+	InProgress    bool       `json:"in_progress"`
+	Interval      string     `json:"interval,omitempty"`
+	NextRunAt     *time.Time `json:"next_run_at,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	LastBackup    string     `json:"last_backup,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+}
+
+// VerifyResult reports the outcome of verifying one archive.
+type VerifyResult struct {
+	Name      string    `json:"name"`
+	OK        bool      `json:"ok"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	Files     int       `json:"files"`
+	Bytes     int64     `json:"bytes"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Manager runs backups on a schedule and applies the retention rules.
+type Manager struct {
+	runMu sync.Mutex
+
+	mu       sync.Mutex
+	opts     Options
+	started  bool
+	stopping chan struct{}
+	wake     chan struct{}
+
+	inProgress    bool
+	nextRunAt     time.Time
+	lastSuccessAt time.Time
+	lastBackup    string
+	lastError     string
+	lastErrorAt   time.Time
+
+	now func() time.Time
+}
+
+// NewManager creates a backup manager. Call Start to enable scheduling.
+func NewManager(opts Options) *Manager {
+	return &Manager{
+		opts:     opts,
+		stopping: make(chan struct{}),
+		wake:     make(chan struct{}, 1),
+		now:      time.Now,
+	}
+}
+
+// SetOptions replaces the manager configuration, e.g. after settings were reloaded.
+// Source and lock settings are preserved when the new options leave them empty.
+func (m *Manager) SetOptions(opts Options) {
+	m.mu.Lock()
+	if opts.Sources.VaultRoot == "" {
+		opts.Sources = m.opts.Sources
+	}
+	if opts.SnapshotSleepDB == nil {
+		opts.SnapshotSleepDB = m.opts.SnapshotSleepDB
+	}
+	if opts.SnapshotAgentDB == nil {
+		opts.SnapshotAgentDB = m.opts.SnapshotAgentDB
+	}
+	if opts.VaultLock == nil {
+		opts.VaultLock = m.opts.VaultLock
+	}
+	m.opts = opts
+	m.nextRunAt = time.Time{}
+	m.mu.Unlock()
+	m.signal()
+}
+
+func (m *Manager) options() Options {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.opts
+}
+
+// Start removes scratch files left by an unclean exit and launches the
+// scheduling loop.
+func (m *Manager) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.started {
+		return
+	}
+	m.started = true
+	if m.opts.Dir != "" {
+		removeScratch(m.opts.Dir)
+	}
+	go m.loop()
+}
+
+// Stop terminates the scheduling loop.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.started {
+		return
+	}
+	select {
+	case <-m.stopping:
+	default:
+		close(m.stopping)
+	}
+}
+
+func (m *Manager) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Status returns the current scheduler state.
+func (m *Manager) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := Status{
+		Enabled:    m.opts.Enabled(),
+		InProgress: m.inProgress,
+		LastBackup: m.lastBackup,
+		LastError:  m.lastError,
+	}
+	if m.opts.Interval > 0 {
+		status.Interval = m.opts.Interval.String()
+	}
+	if !m.nextRunAt.IsZero() {
+		t := m.nextRunAt
+		status.NextRunAt = &t
+	}
+	if !m.lastSuccessAt.IsZero() {
+		t := m.lastSuccessAt
+		status.LastSuccessAt = &t
+	}
+	if !m.lastErrorAt.IsZero() {
+		t := m.lastErrorAt
+		status.LastErrorAt = &t
+	}
+	return status
+}
+
+func (m *Manager) loop() {
+	for {
+		delay, ok := m.scheduleNext()
+		var timer <-chan time.Time
+		if ok {
+			t := time.NewTimer(delay)
+			timer = t.C
+			select {
+			case <-m.stopping:
+				t.Stop()
+				return
+			case <-m.wake:
+				t.Stop()
+				continue
+			case <-timer:
+			}
+		} else {
+			select {
+			case <-m.stopping:
+				return
+			case <-m.wake:
+				continue
+			}
+		}
+
+		if _, err := m.Run(); err != nil {
+			log.Printf("backup: scheduled run failed: %v", err)
+		}
+	}
+}
+
+// scheduleNext computes the delay until the next scheduled backup.
+func (m *Manager) scheduleNext() (time.Duration, bool) {
+	opts := m.options()
+	if !opts.Enabled() || opts.Interval <= 0 {
+		m.mu.Lock()
+		m.nextRunAt = time.Time{}
+		m.mu.Unlock()
+		return 0, false
+	}
+
+	now := m.now()
+	next := now.Add(startupDelay)
+	if backups, err := m.List(); err == nil && len(backups) > 0 {
+		if due := backups[0].CreatedAt.Add(opts.Interval); due.After(next) {
+			next = due
+		}
+	}
+
+	m.mu.Lock()
+	m.nextRunAt = next
+	m.mu.Unlock()
+	return next.Sub(now), true
+}
+
+// Run writes one backup archive now and applies the retention rules.
+func (m *Manager) Run() (Info, error) {
+	opts := m.options()
+	if !opts.Enabled() {
+		return Info{}, ErrNotConfigured
+	}
+
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	m.mu.Lock()
+	m.inProgress = true
+	m.mu.Unlock()
+
+	info, err := m.run(opts)
+
+	m.mu.Lock()
+	m.inProgress = false
+	if err != nil {
+		m.lastError = err.Error()
+		m.lastErrorAt = m.now()
+	} else {
+		m.lastSuccessAt = info.CreatedAt
+		m.lastBackup = info.Name
+		m.lastError = ""
+		m.lastErrorAt = time.Time{}
+	}
+	m.mu.Unlock()
+
+	if err != nil {
+		return Info{}, err
+	}
+	if pruneErr := m.prune(opts); pruneErr != nil {
+		log.Printf("backup: retention failed: %v", pruneErr)
+	}
+	log.Printf("backup: wrote %s (%d bytes)", info.Name, info.Size)
+	return info, nil
+}
+
+func (m *Manager) run(opts Options) (Info, error) {
+	if err := os.MkdirAll(opts.Dir, 0700); err != nil {
+		return Info{}, err
+	}
+
+	now := m.now().UTC()
+	name := archivePrefix + now.Format(archiveTimeLayout) + archiveSuffix
+	finalPath := filepath.Join(opts.Dir, name)
+	if _, err := os.Stat(finalPath); err == nil {
+		return Info{}, fmt.Errorf("backup %s already exists", name)
+	}
+
+	workDir, err := os.MkdirTemp(opts.Dir, workDirPrefix)
+	if err != nil {
+		return Info{}, err
+	}
+	defer os.RemoveAll(workDir)
+
+	src := opts.Sources
+	src.SleepDBPath = ""
+	src.AgentDBPath = ""
+	src.ExcludeDir = opts.Dir
+	if opts.SnapshotSleepDB != nil {
+		snapshot := filepath.Join(workDir, sleepDBName)
+		if err := opts.SnapshotSleepDB(snapshot); err != nil {
+			return Info{}, fmt.Errorf("snapshot sleep db: %w", err)
+		}
+		src.SleepDBPath = snapshot
+	}
+	if opts.SnapshotAgentDB != nil {
+		snapshot := filepath.Join(workDir, agentDBName)
+		if err := opts.SnapshotAgentDB(snapshot); err != nil {
+			return Info{}, fmt.Errorf("snapshot agent db: %w", err)
+		}
+		src.AgentDBPath = snapshot
+	}
+
+	tmpPath := filepath.Join(workDir, name)
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return Info{}, err
+	}
+
+	writeErr := func() error {
+		enc, err := NewEncryptWriter(f, opts.Passphrase)
+		if err != nil {
+			return err
+		}
+		if opts.VaultLock != nil {
+			opts.VaultLock.RLock()
+			defer opts.VaultLock.RUnlock()
+		}
+		if _, err := writeArchive(enc, src, now); err != nil {
+			return err
+		}
+		return enc.Close()
+	}()
+	if writeErr == nil {
+		writeErr = f.Sync()
+	}
+	if closeErr := f.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		return Info{}, writeErr
+	}
+
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		return Info{}, err
+	}
+	stat, err := os.Stat(finalPath)
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{Name: name, Size: stat.Size(), CreatedAt: now}, nil
+}
+
+// List returns the archives in the backup directory, newest first.
+func (m *Manager) List() ([]Info, error) {
+	opts := m.options()
+	if strings.TrimSpace(opts.Dir) == "" {
+		return nil, ErrNotConfigured
+	}
+
+	entries, err := os.ReadDir(opts.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Info{}, nil
+		}
+		return nil, err
+	}
+
+	out := make([]Info, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		createdAt, ok := parseArchiveName(entry.Name())
+		if !ok {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, Info{Name: entry.Name(), Size: stat.Size(), CreatedAt: createdAt})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+func parseArchiveName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, archivePrefix) || !strings.HasSuffix(name, archiveSuffix) {
+		return time.Time{}, false
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, archivePrefix), archiveSuffix)
+	t, err := time.ParseInLocation(archiveTimeLayout, stamp, time.UTC)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// prune deletes archives exceeding Keep or older than MaxAge. The newest archive is kept.
+func (m *Manager) prune(opts Options) error {
+	backups, err := m.List()
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Time{}
+	if opts.MaxAge > 0 {
+		cutoff = m.now().Add(-opts.MaxAge)
+	}
+
+	var errs []error
+	for i, b := range backups {
+		if i == 0 {
+			continue
+		}
+		expired := opts.Keep > 0 && i >= opts.Keep
+		if !cutoff.IsZero() && b.CreatedAt.Before(cutoff) {
+			expired = true
+		}
+		if !expired {
+			continue
+		}
+		if err := os.Remove(filepath.Join(opts.Dir, b.Name)); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+			continue
+		}
+		log.Printf("backup: pruned %s", b.Name)
+	}
+	return errors.Join(errs...)
+}
+
+// Path resolves a backup name to its file in the backup directory.
+func (m *Manager) Path(name string) (string, error) {
+	opts := m.options()
+	if strings.TrimSpace(opts.Dir) == "" {
+		return "", ErrNotConfigured
+	}
+	if name != filepath.Base(name) {
+		return "", ErrInvalidName
+	}
+	if _, ok := parseArchiveName(name); !ok {
+		return "", ErrInvalidName
+	}
+	full := filepath.Join(opts.Dir, name)
+	if _, err := os.Stat(full); err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return full, nil
+}
+
+// Verify decrypts an archive and checks every file against the manifest checksums.
+func (m *Manager) Verify(name string) (VerifyResult, error) {
+	full, err := m.Path(name)
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	opts := m.options()
+	if opts.Passphrase == "" {
+		return VerifyResult{}, ErrNotConfigured
+	}
+
+	result := VerifyResult{Name: name}
+	manifest, err := VerifyFile(full, opts.Passphrase)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.OK = true
+	result.CreatedAt = manifest.CreatedAt
+	result.Files = len(manifest.Files)
+	for _, f := range manifest.Files {
+		result.Bytes += f.Size
+	}
+	return result, nil
+}
+
+// VerifyFile decrypts the archive at path and checks its contents against the manifest.
+func VerifyFile(path, passphrase string) (Manifest, error) {
+	zr, cleanup, err := openArchive(path, passphrase)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer cleanup()
+
+	manifest, err := readManifest(&zr.Reader)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if err := checkManifest(&zr.Reader, manifest); err != nil {
+		return Manifest{}, err
+	}
+	return manifest, nil
+}
+
+// removeScratch deletes plaintext scratch files left in dir by an unclean exit.
+func removeScratch(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, workDirPrefix) && !strings.HasPrefix(name, decryptedPrefix) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+			log.Printf("backup: remove stale %s: %v", name, err)
+		}
+	}
+}
+
+// openArchive decrypts an archive into a temporary file next to it and opens it
+// as a zip. The plaintext copy stays in the backup directory, readable only by
+// the owner, rather than in a possibly shared system temp directory.
+func openArchive(path, passphrase string) (*zip.ReadCloser, func(), error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer src.Close()
+
+	dec, err := NewDecryptReader(src, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), decryptedPrefix+"*.zip")
+	if err != nil {
+		return nil, nil, err
+	}
+	removeTmp := func() { os.Remove(tmp.Name()) }
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		removeTmp()
+		return nil, nil, err
+	}
+	if _, err := io.Copy(tmp, dec); err != nil {
+		tmp.Close()
+		removeTmp()
+		return nil, nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		removeTmp()
+		return nil, nil, err
+	}
+
+	zr, err := zip.OpenReader(tmp.Name())
+	if err != nil {
+		removeTmp()
+		return nil, nil, fmt.Errorf("decrypted archive is not a valid zip: %w", err)
+	}
+	return zr, func() {
+		zr.Close()
+		removeTmp()
+	}, nil
+}
+
+func checkManifest(zr *zip.Reader, manifest Manifest) error {
+	byName := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		byName[f.Name] = f
+	}
+	for _, entry := range manifest.Files {
+		f, ok := byName[entry.Path]
+		if !ok {
+			return fmt.Errorf("missing file %s", entry.Path)
+		}
+		sum, size, err := hashZipFile(f)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", entry.Path, err)
+		}
+		if size != entry.Size || sum != entry.SHA256 {
+			return fmt.Errorf("checksum mismatch for %s", entry.Path)
+		}
+	}
+	return nil
+}
+
+func hashZipFile(f *zip.File) (string, int64, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", 0, err
+	}
+	defer rc.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, rc)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
@@ -0,0 +1,199 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func setupSources(t *testing.T) (Sources, string) {
+	t.Helper()
+	root := t.TempDir()
+	vault := filepath.Join(root, "notes")
+	sessions := filepath.Join(root, "sessions")
+
+	files := map[string]string{
+		filepath.Join(vault, "sebastian", "daily", "2026-01-01.md"): "# daily",
+		filepath.Join(vault, "petra", "notes", "list.md"):           "- milk",
+		filepath.Join(vault, ".git", "HEAD"):                        "ref: refs/heads/main",
+		filepath.Join(vault, "sleep.db"):                            "live db",
+		filepath.Join(vault, "sleep.db-wal"):                        "wal",
+		filepath.Join(vault, "agent.db"):                            "live agent db",
+		filepath.Join(sessions, "sebastian--abc.jsonl"):             `{"type":"message"}`,
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	return Sources{VaultRoot: vault, SessionDir: sessions}, root
+}
+
+func newTestManager(t *testing.T, src Sources, dir string) *Manager {
+	t.Helper()
+	m := NewManager(Options{
+		Dir:        dir,
+		Passphrase: "secret",
+		Keep:       2,
+		Sources:    src,
+		SnapshotSleepDB: func(dst string) error {
+			return os.WriteFile(dst, []byte("snapshot db"), 0600)
+		},
+		SnapshotAgentDB: func(dst string) error {
+			return os.WriteFile(dst, []byte("agent snapshot"), 0600)
+		},
+	})
+	return m
+}
+
+func TestRunVerifyAndRestore(t *testing.T) {
+	src, root := setupSources(t)
+	backupDir := filepath.Join(root, "backups")
+	m := newTestManager(t, src, backupDir)
+
+	info, err := m.Run()
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	list, err := m.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 1 || list[0].Name != info.Name {
+		t.Fatalf("unexpected list: %#v", list)
+	}
+
+	result, err := m.Verify(info.Name)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !result.OK || result.Files != 5 {
+		t.Fatalf("unexpected verify result: %#v", result)
+	}
+	entries, err := os.ReadDir(backupDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("backup dir should only hold the archive after verify: %v (err=%v)", entries, err)
+	}
+
+	dest := filepath.Join(root, "restore")
+	if _, err := Restore(filepath.Join(backupDir, info.Name), "secret", dest); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	want := map[string]string{
+		"vault/sebastian/daily/2026-01-01.md": "# daily",
+		"vault/petra/notes/list.md":           "- milk",
+		"sleep.db":                            "snapshot db",
+		"agent.db":                            "agent snapshot",
+		"agent-sessions/sebastian--abc.jsonl": `{"type":"message"}`,
+	}
+	for rel, content := range want {
+		got, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatalf("read %s: %v", rel, err)
+		}
+		if string(got) != content {
+			t.Fatalf("%s = %q, want %q", rel, got, content)
+		}
+	}
+	for _, excluded := range []string{"vault/.git/HEAD", "vault/sleep.db", "vault/sleep.db-wal", "vault/agent.db"} {
+		if _, err := os.Stat(filepath.Join(dest, filepath.FromSlash(excluded))); !os.IsNotExist(err) {
+			t.Fatalf("%s should not be restored (err=%v)", excluded, err)
+		}
+	}
+
+	if _, err := Restore(filepath.Join(backupDir, info.Name), "secret", dest); !errors.Is(err, ErrDestinationNotEmpty) {
+		t.Fatalf("restore into non-empty dir: err = %v", err)
+	}
+}
+
+func TestVerifyReportsWrongPassphrase(t *testing.T) {
+	src, root := setupSources(t)
+	backupDir := filepath.Join(root, "backups")
+	m := newTestManager(t, src, backupDir)
+
+	info, err := m.Run()
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	opts := m.options()
+	opts.Passphrase = "other"
+	m.SetOptions(opts)
+
+	result, err := m.Verify(info.Name)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if result.OK || result.Error == "" {
+		t.Fatalf("expected failed verification, got %#v", result)
+	}
+}
+
+func TestRetentionKeepsNewest(t *testing.T) {
+	src, root := setupSources(t)
+	backupDir := filepath.Join(root, "backups")
+	m := newTestManager(t, src, backupDir)
+
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		current := base.Add(time.Duration(i) * time.Hour)
+		m.now = func() time.Time { return current }
+		if _, err := m.Run(); err != nil {
+			t.Fatalf("Run %d: %v", i, err)
+		}
+	}
+
+	list, err := m.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 retained backups, got %d", len(list))
+	}
+	if !list[0].CreatedAt.Equal(base.Add(3*time.Hour)) || !list[1].CreatedAt.Equal(base.Add(2*time.Hour)) {
+		t.Fatalf("unexpected retained backups: %#v", list)
+	}
+
+	// Age-based pruning never removes the newest archive.
+	opts := m.options()
+	opts.Keep = 0
+	opts.MaxAge = time.Minute
+	m.SetOptions(opts)
+	later := base.Add(30 * 24 * time.Hour)
+	m.now = func() time.Time { return later }
+	if err := m.prune(m.options()); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	list, _ = m.List()
+	if len(list) != 1 || !list[0].CreatedAt.Equal(base.Add(3*time.Hour)) {
+		t.Fatalf("unexpected backups after age pruning: %#v", list)
+	}
+}
+
+func TestPathRejectsInvalidNames(t *testing.T) {
+	src, root := setupSources(t)
+	m := newTestManager(t, src, filepath.Join(root, "backups"))
+
+	for _, name := range []string{"../secret", "notes-backup-x.zip.enc", "other.zip"} {
+		if _, err := m.Path(name); !errors.Is(err, ErrInvalidName) {
+			t.Fatalf("Path(%q) err = %v, want ErrInvalidName", name, err)
+		}
+	}
+	if _, err := m.Path("notes-backup-20260101-000000.zip.enc"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for missing backup, got %v", err)
+	}
+}
+
+func TestRunRequiresConfiguration(t *testing.T) {
+	m := NewManager(Options{Dir: t.TempDir()})
+	if _, err := m.Run(); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("err = %v, want ErrNotConfigured", err)
+	}
+}
@@ -0,0 +1,85 @@
+package backup
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrDestinationNotEmpty is returned when restoring into a directory that has content.
+var ErrDestinationNotEmpty = errors.New("restore destination is not empty")
+
+// Restore decrypts the archive at path, verifies it against its manifest and extracts
+// it into destDir, which must be empty or not yet exist. The result mirrors the archive
+// layout: vault/ holds the notes root, sleep.db the sleep database, agent.db the
+// agent session database and agent-sessions/ the gateway session files.
+func Restore(path, passphrase, destDir string) (Manifest, error) {
+	if err := ensureEmptyDir(destDir); err != nil {
+		return Manifest{}, err
+	}
+
+	zr, cleanup, err := openArchive(path, passphrase)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer cleanup()
+
+	manifest, err := readManifest(&zr.Reader)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if err := checkManifest(&zr.Reader, manifest); err != nil {
+		return Manifest{}, err
+	}
+
+	for _, f := range zr.File {
+		if f.Name == manifestName || f.FileInfo().IsDir() {
+			continue
+		}
+		if err := ValidateEntryName(f.Name); err != nil {
+			return Manifest{}, err
+		}
+		if err := extractFile(f, filepath.Join(destDir, filepath.FromSlash(f.Name))); err != nil {
+			return Manifest{}, fmt.Errorf("extract %s: %w", f.Name, err)
+		}
+	}
+	return manifest, nil
+}
+
+func ensureEmptyDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return os.MkdirAll(dir, 0755)
+		}
+		return err
+	}
+	if len(entries) > 0 {
+		return ErrDestinationNotEmpty
+	}
+	return nil
+}
+
+func extractFile(f *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
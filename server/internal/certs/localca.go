@@ -0,0 +1,276 @@
+// Package certs issues TLS server certificates from a self-signed local CA, for
+// serving HTTPS on a home network without a public domain.
+package certs
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	caCertFile     = "ca.crt"
+	caKeyFile      = "ca.key"
+	serverCertFile = "server.crt"
+	serverKeyFile  = "server.key"
+
+	caValidity = 10 * 365 * 24 * time.Hour
+	// serverValidity stays below the 398 days browsers and phones accept.
+	serverValidity = 397 * 24 * time.Hour
+	// renewBefore renews the server certificate this long before it expires.
+	renewBefore = 30 * 24 * time.Hour
+)
+
+// LocalCA keeps a CA and a server certificate for hosts in dir. The CA is
+// created once; the server certificate is reissued when it nears expiry or no
+// longer covers hosts.
+type LocalCA struct {
+	dir   string
+	hosts []string
+
+	mu   sync.Mutex
+	cert *tls.Certificate
+	now  func() time.Time
+}
+
+// NewLocalCA returns a local CA stored in dir issuing certificates for hosts
+// (DNS names or IP addresses).
+func NewLocalCA(dir string, hosts []string) *LocalCA {
+	return &LocalCA{dir: dir, hosts: hosts, now: time.Now}
+}
+
+// Init creates the CA and server certificate if needed.
+func (ca *LocalCA) Init() error {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	_, err := ca.serverCert()
+	return err
+}
+
+// CACertPath returns the root certificate that clients install to trust the
+// server.
+func (ca *LocalCA) CACertPath() string {
+	return filepath.Join(ca.dir, caCertFile)
+}
+
+// CACertPEM returns the PEM-encoded root certificate.
+func (ca *LocalCA) CACertPEM() ([]byte, error) {
+	return os.ReadFile(ca.CACertPath())
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (ca *LocalCA) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	return ca.serverCert()
+}
+
+// TLSConfig returns a server configuration using the local CA's certificate.
+func (ca *LocalCA) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: ca.GetCertificate,
+	}
+}
+
+// serverCert returns the current server certificate, issuing a new one when
+// needed. The caller holds ca.mu.
+func (ca *LocalCA) serverCert() (*tls.Certificate, error) {
+	if ca.cert != nil && ca.valid(ca.cert.Leaf) {
+		return ca.cert, nil
+	}
+	certPath := filepath.Join(ca.dir, serverCertFile)
+	keyPath := filepath.Join(ca.dir, serverKeyFile)
+	if cert, err := loadKeyPair(certPath, keyPath); err == nil && ca.valid(cert.Leaf) {
+		ca.cert = cert
+		return cert, nil
+	}
+
+	caCert, caKey, err := ca.loadOrCreateCA()
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	now := ca.now()
+	tmpl := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{CommonName: ca.hosts[0]},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(serverValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range ca.hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := writePEM(certPath, "CERTIFICATE", der, 0644); err != nil {
+		return nil, err
+	}
+	if err := writeKey(keyPath, key); err != nil {
+		return nil, err
+	}
+	cert, err := loadKeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, err
+	}
+	ca.cert = cert
+	return cert, nil
+}
+
+// valid reports whether leaf covers all hosts and is not due for renewal.
+func (ca *LocalCA) valid(leaf *x509.Certificate) bool {
+	if leaf == nil || ca.now().Add(renewBefore).After(leaf.NotAfter) {
+		return false
+	}
+	for _, host := range ca.hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			if !slices.ContainsFunc(leaf.IPAddresses, ip.Equal) {
+				return false
+			}
+		} else if !slices.Contains(leaf.DNSNames, host) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ca *LocalCA) loadOrCreateCA() (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	if len(ca.hosts) == 0 {
+		return nil, nil, errors.New("local CA needs at least one host")
+	}
+	certPath := filepath.Join(ca.dir, caCertFile)
+	keyPath := filepath.Join(ca.dir, caKeyFile)
+	if pair, err := loadKeyPair(certPath, keyPath); err == nil {
+		key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s: unsupported key type", keyPath)
+		}
+		return pair.Leaf, key, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	if err := os.MkdirAll(ca.dir, 0700); err != nil {
+		return nil, nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	name := "Notes Local CA"
+	if host, err := os.Hostname(); err == nil && host != "" {
+		name += " (" + host + ")"
+	}
+	now := ca.now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          randomSerial(),
+		Subject:               pkix.Name{CommonName: name, Organization: []string{"Notes Editor"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writeKey(keyPath, key); err != nil {
+		return nil, nil, err
+	}
+	if err := writePEM(certPath, "CERTIFICATE", der, 0644); err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// DefaultHosts returns the names and addresses the server is likely reached at
+// on the local network: the host name, its mDNS name, localhost and the
+// machine's non-loopback IP addresses.
+func DefaultHosts() []string {
+	var hosts []string
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, name)
+		if !strings.Contains(name, ".") {
+			hosts = append(hosts, name+".local")
+		}
+	}
+	hosts = append(hosts, "localhost", "127.0.0.1", "::1")
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+				hosts = append(hosts, ipNet.IP.String())
+			}
+		}
+	}
+	return hosts
+}
+
+func loadKeyPair(certPath, keyPath string) (*tls.Certificate, error) {
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if pair.Leaf == nil {
+		if pair.Leaf, err = x509.ParseCertificate(pair.Certificate[0]); err != nil {
+			return nil, err
+		}
+	}
+	return &pair, nil
+}
+
+func writeKey(path string, key *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	return writePEM(path, "EC PRIVATE KEY", der, 0600)
+}
+
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	var buf bytes.Buffer
+	if err := pem.Encode(&buf, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), perm)
+}
+
+func randomSerial() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return big.NewInt(time.Now().UnixNano())
+	}
+	return serial
+}
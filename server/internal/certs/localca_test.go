@@ -0,0 +1,84 @@
+package certs
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLocalCAIssuesTrustedServerCert(t *testing.T) {
+	dir := t.TempDir()
+	ca := NewLocalCA(dir, []string{"notes.local", "192.168.1.20"})
+	if err := ca.Init(); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	raw, err := ca.CACertPEM()
+	if err != nil {
+		t.Fatalf("ca cert: %v", err)
+	}
+	block, _ := pem.Decode(raw)
+	root, err := x509.ParseCertificate(block.Bytes)
+	if err != nil || !root.IsCA {
+		t.Fatalf("invalid root certificate: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, caKeyFile)); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("ca key must be private: %v %v", info.Mode(), err)
+	}
+
+	cert, err := ca.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("get certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(root)
+	for _, host := range []string{"notes.local", "192.168.1.20"} {
+		if _, err := cert.Leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: pool}); err != nil {
+			t.Errorf("verify %s: %v", host, err)
+		}
+	}
+}
+
+func TestLocalCAReusesAndRenews(t *testing.T) {
+	dir := t.TempDir()
+	ca := NewLocalCA(dir, []string{"notes.local"})
+	first, err := ca.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootBefore, _ := ca.CACertPEM()
+
+	// A restart reuses the stored certificate
+	restarted := NewLocalCA(dir, []string{"notes.local"})
+	second, err := restarted.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Leaf.SerialNumber.Cmp(first.Leaf.SerialNumber) != 0 {
+		t.Fatal("expected the stored certificate to be reused")
+	}
+
+	// New hosts and upcoming expiry reissue it, with the same CA
+	moved := NewLocalCA(dir, []string{"notes.local", "10.0.0.5"})
+	third, err := moved.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.Leaf.SerialNumber.Cmp(second.Leaf.SerialNumber) == 0 {
+		t.Fatal("expected a new certificate for new hosts")
+	}
+	moved.now = func() time.Time { return time.Now().Add(serverValidity - renewBefore/2) }
+	fourth, err := moved.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fourth.Leaf.SerialNumber.Cmp(third.Leaf.SerialNumber) == 0 {
+		t.Fatal("expected renewal before expiry")
+	}
+	if rootAfter, _ := moved.CACertPEM(); string(rootAfter) != string(rootBefore) {
+		t.Fatal("the CA must not change")
+	}
+}
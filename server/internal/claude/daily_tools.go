@@ -0,0 +1,171 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"notes-editor/internal/vault"
+)
+
+// dailyNote is a daily note opened by one of the daily tools.
+type dailyNote struct {
+	Date    string `json:"date"`
+	Path    string `json:"path"`
+	Content string `json:"content,omitempty"`
+}
+
+// dailyDate parses the optional date argument; empty means today.
+func dailyDate(input map[string]any) (time.Time, bool, error) {
+	now := time.Now()
+	raw, _ := input["date"].(string)
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == now.Format("2006-01-02") {
+		return now, true, nil
+	}
+	date, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("date must be YYYY-MM-DD")
+	}
+	return date, false, nil
+}
+
+// withDaily runs fn on a daily note under the vault lock. Today's note is
+// created like the web UI does; other days must exist. message is the sync
+// commit message for changes made by fn, empty when fn only reads.
+func (te *ToolExecutor) withDaily(input map[string]any, message string, fn func(note dailyNote) (string, error)) (string, error) {
+	date, today, err := dailyDate(input)
+	if err != nil {
+		return "", err
+	}
+	note := dailyNote{Date: date.Format("2006-01-02"), Path: vault.DailyPath(date)}
+	if err := te.store.AgentCanAccess(te.person, note.Path); err != nil {
+		return "", err
+	}
+
+	te.lockVault()
+	created := false
+	if today {
+		note.Content, note.Path, created, err = te.daily.GetOrCreateDaily(te.person, date)
+	} else {
+		note.Content, err = te.store.ReadFile(te.person, note.Path)
+		if os.IsNotExist(err) {
+			err = fmt.Errorf("no daily note for %s", note.Date)
+		}
+	}
+	result := ""
+	if err == nil {
+		result, err = fn(note)
+	}
+	te.unlockVault()
+
+	if err == nil && message != "" {
+		te.syncVault(message)
+	} else if created {
+		te.syncVault("Daily note created")
+	}
+	return result, err
+}
+
+func (te *ToolExecutor) getDailyNote(input map[string]any) (string, error) {
+	return te.withDaily(input, "", func(note dailyNote) (string, error) {
+		result, err := json.Marshal(note)
+		if err != nil {
+			return "", err
+		}
+		return string(result), nil
+	})
+}
+
+func (te *ToolExecutor) listOpenTasks(input map[string]any) (string, error) {
+	return te.withDaily(input, "", func(note dailyNote) (string, error) {
+		tasks, err := te.daily.OpenTasks(te.person, note.Path)
+		if err != nil {
+			return "", err
+		}
+		result, err := json.Marshal(map[string]any{
+			"date":  note.Date,
+			"path":  note.Path,
+			"tasks": tasks,
+		})
+		if err != nil {
+			return "", err
+		}
+		return string(result), nil
+	})
+}
+
+func (te *ToolExecutor) addTask(input map[string]any) (string, error) {
+	category, _ := input["category"].(string)
+	if category != "work" && category != "priv" {
+		return "", fmt.Errorf("category must be work or priv")
+	}
+	text, ok := input["text"].(string)
+	if !ok || strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("text is required")
+	}
+	return te.withDaily(nil, "Agent add task", func(note dailyNote) (string, error) {
+		if err := te.daily.AddTask(te.person, note.Path, category, strings.TrimSpace(text)); err != nil {
+			return "", err
+		}
+		return "Task added to " + note.Path, nil
+	})
+}
+
+func (te *ToolExecutor) completeTask(input map[string]any) (string, error) {
+	line, err := lineArg(input, "line")
+	if err != nil {
+		return "", err
+	}
+	text, _ := input["text"].(string)
+	text = strings.TrimSpace(text)
+	if line == 0 && text == "" {
+		return "", fmt.Errorf("line or text is required")
+	}
+	return te.withDaily(input, "Agent complete task", func(note dailyNote) (string, error) {
+		if line == 0 {
+			tasks, err := te.daily.OpenTasks(te.person, note.Path)
+			if err != nil {
+				return "", err
+			}
+			var matches []vault.Task
+			for _, task := range tasks {
+				if strings.Contains(strings.ToLower(task.Text), strings.ToLower(text)) {
+					matches = append(matches, task)
+				}
+			}
+			switch len(matches) {
+			case 0:
+				return "", fmt.Errorf("no open task matching %q in %s", text, note.Path)
+			case 1:
+				line = matches[0].Line
+			default:
+				lines := make([]string, len(matches))
+				for i, task := range matches {
+					lines[i] = fmt.Sprint(task.Line)
+				}
+				return "", fmt.Errorf("%d open tasks match %q (lines %s); pass line instead", len(matches), text, strings.Join(lines, ", "))
+			}
+		}
+		if err := te.daily.CompleteTask(te.person, note.Path, line); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Task on line %d of %s completed", line, note.Path), nil
+	})
+}
+
+func (te *ToolExecutor) appendDailyEntry(input map[string]any) (string, error) {
+	text, ok := input["text"].(string)
+	if !ok || strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("text is required")
+	}
+	pinned, _ := input["pinned"].(bool)
+	return te.withDaily(nil, "Agent append entry", func(note dailyNote) (string, error) {
+		if err := te.daily.AppendEntry(te.person, note.Path, text, pinned); err != nil {
+			return "", err
+		}
+		return "Entry appended to " + note.Path, nil
+	})
+}
@@ -0,0 +1,93 @@
+package claude
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"notes-editor/internal/vault"
+)
+
+func TestToolExecutor_DailyTools(t *testing.T) {
+	yesterday := time.Now().AddDate(0, 0, -1)
+	te, store, pushes := newFileToolExecutor(t, map[string]string{
+		vault.DailyPath(yesterday): "# y\n\n## todos\n\n### work\n- [ ] carried over\n- [x] finished\n\n## custom notes\n\n",
+	})
+	today := vault.DailyPath(time.Now())
+
+	out, err := te.ExecuteTool("get_daily_note", map[string]any{})
+	if err != nil {
+		t.Fatalf("get_daily_note() error = %v", err)
+	}
+	var note dailyNote
+	if err := json.Unmarshal([]byte(out), &note); err != nil {
+		t.Fatalf("unmarshal note: %v", err)
+	}
+	if note.Path != today || !strings.Contains(note.Content, "- [ ] carried over") || strings.Contains(note.Content, "finished") {
+		t.Fatalf("get_daily_note() = %+v", note)
+	}
+	if _, err := te.ExecuteTool("get_daily_note", map[string]any{"date": "2001-01-01"}); err == nil {
+		t.Fatal("get_daily_note(missing day) should fail")
+	}
+	if _, err := te.ExecuteTool("get_daily_note", map[string]any{"date": "yesterday"}); err == nil {
+		t.Fatal("get_daily_note(bad date) should fail")
+	}
+
+	for _, input := range []map[string]any{
+		{"category": "work", "text": "write report"},
+		{"category": "priv", "text": "call the plumber"},
+	} {
+		if _, err := te.ExecuteTool("add_task", input); err != nil {
+			t.Fatalf("add_task(%v) error = %v", input, err)
+		}
+	}
+	if _, err := te.ExecuteTool("add_task", map[string]any{"category": "errands", "text": "x"}); err == nil {
+		t.Fatal("add_task(unknown category) should fail")
+	}
+
+	out, err = te.ExecuteTool("list_open_tasks", map[string]any{})
+	if err != nil {
+		t.Fatalf("list_open_tasks() error = %v", err)
+	}
+	var listed struct {
+		Tasks []vault.Task `json:"tasks"`
+	}
+	if err := json.Unmarshal([]byte(out), &listed); err != nil {
+		t.Fatalf("unmarshal tasks: %v", err)
+	}
+	if len(listed.Tasks) != 3 {
+		t.Fatalf("open tasks = %+v", listed.Tasks)
+	}
+
+	if _, err := te.ExecuteTool("complete_task", map[string]any{"text": "r"}); err == nil || !strings.Contains(err.Error(), "pass line") {
+		t.Fatalf("complete_task(ambiguous) error = %v", err)
+	}
+	if _, err := te.ExecuteTool("complete_task", map[string]any{"text": "PLUMBER"}); err != nil {
+		t.Fatalf("complete_task(text) error = %v", err)
+	}
+	var report vault.Task
+	for _, task := range listed.Tasks {
+		if task.Text == "write report" {
+			report = task
+		}
+	}
+	if _, err := te.ExecuteTool("complete_task", map[string]any{"line": float64(report.Line)}); err != nil {
+		t.Fatalf("complete_task(line) error = %v", err)
+	}
+
+	if _, err := te.ExecuteTool("append_daily_entry", map[string]any{"text": "Remember the keys", "pinned": true}); err != nil {
+		t.Fatalf("append_daily_entry() error = %v", err)
+	}
+
+	content, _ := store.ReadFile("sebastian", today)
+	for _, want := range []string{"- [x] write report", "- [x] call the plumber", "- [ ] carried over", "<pinned>\nRemember the keys"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("daily note missing %q:\n%s", want, content)
+		}
+	}
+	want := "Daily note created,Agent add task,Agent add task,Agent complete task,Agent complete task,Agent append entry"
+	if got := strings.Join(*pushes, ","); got != want {
+		t.Errorf("pushes = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,317 @@
+package claude
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// changeVault runs a file-changing tool under the vault lock and reports the
+// change for syncing once it succeeded.
+func (te *ToolExecutor) changeVault(message string, change func() (string, error)) (string, error) {
+	te.lockVault()
+	result, err := change()
+	te.unlockVault()
+	if err == nil {
+		te.syncVault(message)
+	}
+	return result, err
+}
+
+func (te *ToolExecutor) syncVault(message string) {
+	if te.afterWrite != nil {
+		te.afterWrite(message)
+	}
+}
+
+// lockVault takes the vault lock for writing and attributes the store's
+// changes to this executor's run until unlockVault.
+func (te *ToolExecutor) lockVault() {
+	if te.vaultMu != nil {
+		te.vaultMu.Lock()
+	}
+	te.store.SetWriteSource(te.writeSource())
+}
+
+func (te *ToolExecutor) unlockVault() {
+	te.store.SetWriteSource("")
+	if te.vaultMu != nil {
+		te.vaultMu.Unlock()
+	}
+}
+
+// writeSource is the change source of the executor's writes: its run ID, or
+// AgentWriteSource when the run is unknown.
+func (te *ToolExecutor) writeSource() string {
+	if te.runID != "" {
+		return te.runID
+	}
+	return AgentWriteSource
+}
+
+func (te *ToolExecutor) rlockVault() {
+	if te.vaultMu != nil {
+		te.vaultMu.RLock()
+	}
+}
+
+func (te *ToolExecutor) runlockVault() {
+	if te.vaultMu != nil {
+		te.vaultMu.RUnlock()
+	}
+}
+
+// lineArg reads an optional positive line number or count; zero means unset.
+func lineArg(input map[string]any, key string) (int, error) {
+	var n float64
+	switch v := input[key].(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		n = v
+	case int:
+		n = float64(v)
+	default:
+		return 0, fmt.Errorf("%s must be a number", key)
+	}
+	if n < 1 || n != math.Trunc(n) {
+		return 0, fmt.Errorf("%s must be a positive whole number", key)
+	}
+	return int(n), nil
+}
+
+// lineRange returns limit lines of content starting at the 1-indexed line
+// offset. A zero offset starts at the first line, a zero limit reads to the end.
+func lineRange(content string, offset, limit int) (string, error) {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	start := 0
+	if offset > 0 {
+		start = offset - 1
+	}
+	if start > 0 && start >= len(lines) {
+		return "", fmt.Errorf("offset %d is past the end of the file (%d lines)", offset, len(lines))
+	}
+	end := len(lines)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	return strings.Join(lines[start:end], ""), nil
+}
+
+func (te *ToolExecutor) editFile(input map[string]any) (string, error) {
+	path, ok := input["path"].(string)
+	if !ok {
+		return "", fmt.Errorf("path is required")
+	}
+	oldString, ok := input["old_string"].(string)
+	if !ok || oldString == "" {
+		return "", fmt.Errorf("old_string is required")
+	}
+	newString, ok := input["new_string"].(string)
+	if !ok {
+		return "", fmt.Errorf("new_string is required")
+	}
+	if err := te.store.AgentCanAccess(te.person, path); err != nil {
+		return "", err
+	}
+	return te.changeVault("Agent edit file", func() (string, error) {
+		content, err := te.store.ReadFile(te.person, path)
+		if err != nil {
+			return "", err
+		}
+		switch n := strings.Count(content, oldString); n {
+		case 0:
+			return "", fmt.Errorf("old_string not found in %s", path)
+		case 1:
+		default:
+			return "", fmt.Errorf("old_string appears %d times in %s; include more surrounding text to make it unique", n, path)
+		}
+		if err := te.store.WriteFile(te.person, path, strings.Replace(content, oldString, newString, 1)); err != nil {
+			return "", err
+		}
+		return "File edited successfully", nil
+	})
+}
+
+func (te *ToolExecutor) appendToFile(input map[string]any) (string, error) {
+	path, ok := input["path"].(string)
+	if !ok {
+		return "", fmt.Errorf("path is required")
+	}
+	content, ok := input["content"].(string)
+	if !ok {
+		return "", fmt.Errorf("content is required")
+	}
+	if err := te.store.AgentCanAccess(te.person, path); err != nil {
+		return "", err
+	}
+	return te.changeVault("Agent append to file", func() (string, error) {
+		existing, err := te.store.ReadFile(te.person, path)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		// Start on a new line rather than gluing onto the last one.
+		if existing != "" && !strings.HasSuffix(existing, "\n") && !strings.HasPrefix(content, "\n") {
+			content = "\n" + content
+		}
+		if err := te.store.AppendFile(te.person, path, content); err != nil {
+			return "", err
+		}
+		return "Content appended successfully", nil
+	})
+}
+
+func (te *ToolExecutor) insertAtHeading(input map[string]any) (string, error) {
+	path, ok := input["path"].(string)
+	if !ok {
+		return "", fmt.Errorf("path is required")
+	}
+	heading, ok := input["heading"].(string)
+	if !ok || strings.TrimSpace(heading) == "" {
+		return "", fmt.Errorf("heading is required")
+	}
+	content, ok := input["content"].(string)
+	if !ok {
+		return "", fmt.Errorf("content is required")
+	}
+	position, _ := input["position"].(string)
+	if position != "" && position != "end" && position != "start" {
+		return "", fmt.Errorf("position must be end or start")
+	}
+	if err := te.store.AgentCanAccess(te.person, path); err != nil {
+		return "", err
+	}
+	return te.changeVault("Agent insert at heading", func() (string, error) {
+		existing, err := te.store.ReadFile(te.person, path)
+		if err != nil {
+			return "", err
+		}
+		updated, err := insertUnderHeading(existing, heading, content, position == "start")
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		if err := te.store.WriteFile(te.person, path, updated); err != nil {
+			return "", err
+		}
+		return "Content inserted successfully", nil
+	})
+}
+
+// insertUnderHeading inserts text into the section below heading, either right
+// below the heading line or after the section's last non-blank line. heading
+// may carry its level ("## Tasks"); otherwise any level matches. Headings
+// inside fenced code blocks are ignored.
+func insertUnderHeading(content, heading, text string, atStart bool) (string, error) {
+	wantLevel, wantTitle := parseHeading(strings.TrimSpace(heading))
+	if wantLevel == 0 {
+		wantTitle = strings.TrimSpace(heading)
+	}
+
+	lines := strings.Split(content, "\n")
+	levels := make([]int, len(lines))
+	match, matches := -1, 0
+	inFence := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		level, title := parseHeading(line)
+		levels[i] = level
+		if level > 0 && (wantLevel == 0 || level == wantLevel) && strings.EqualFold(title, wantTitle) {
+			match = i
+			matches++
+		}
+	}
+	switch matches {
+	case 0:
+		return "", fmt.Errorf("heading %q not found", heading)
+	case 1:
+	default:
+		return "", fmt.Errorf("heading %q appears %d times", heading, matches)
+	}
+
+	at := match + 1
+	if !atStart {
+		at = len(lines)
+		for i := match + 1; i < len(lines); i++ {
+			if levels[i] > 0 && levels[i] <= levels[match] {
+				at = i
+				break
+			}
+		}
+		for at > match+1 && strings.TrimSpace(lines[at-1]) == "" {
+			at--
+		}
+	}
+
+	inserted := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	result := make([]string, 0, len(lines)+len(inserted))
+	result = append(result, lines[:at]...)
+	result = append(result, inserted...)
+	result = append(result, lines[at:]...)
+	return strings.Join(result, "\n"), nil
+}
+
+// parseHeading returns the level and title of an ATX heading line, or level 0.
+func parseHeading(line string) (int, string) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return 0, ""
+	}
+	rest := line[level:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return 0, ""
+	}
+	return level, strings.TrimSpace(rest)
+}
+
+func (te *ToolExecutor) moveFile(input map[string]any) (string, error) {
+	from, ok := input["from"].(string)
+	if !ok {
+		return "", fmt.Errorf("from is required")
+	}
+	to, ok := input["to"].(string)
+	if !ok {
+		return "", fmt.Errorf("to is required")
+	}
+	for _, path := range []string{from, to} {
+		if err := te.store.AgentCanAccess(te.person, path); err != nil {
+			return "", err
+		}
+	}
+	return te.changeVault("Agent move file", func() (string, error) {
+		if err := te.store.MoveFile(te.person, from, to); err != nil {
+			return "", err
+		}
+		return "File moved successfully", nil
+	})
+}
+
+func (te *ToolExecutor) deleteFile(input map[string]any) (string, error) {
+	path, ok := input["path"].(string)
+	if !ok {
+		return "", fmt.Errorf("path is required")
+	}
+	if err := te.store.AgentCanAccess(te.person, path); err != nil {
+		return "", err
+	}
+	return te.changeVault("Agent delete file", func() (string, error) {
+		trashed, err := te.store.TrashFile(te.person, path)
+		if err != nil {
+			return "", err
+		}
+		return "File moved to trash at " + trashed, nil
+	})
+}
@@ -0,0 +1,194 @@
+package claude
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"notes-editor/internal/vault"
+)
+
+func newFileToolExecutor(t *testing.T, files map[string]string) (*ToolExecutor, *vault.Store, *[]string) {
+	t.Helper()
+	store := vault.NewStore(t.TempDir())
+	for path, content := range files {
+		if err := store.WriteFile("sebastian", path, content); err != nil {
+			t.Fatalf("WriteFile(%s) error = %v", path, err)
+		}
+	}
+	var mu sync.RWMutex
+	var pushes []string
+	te := NewToolExecutor(store, nil, "sebastian")
+	te.Synchronize(&mu, func(message string) {
+		// The lock is released before syncing.
+		if !mu.TryLock() {
+			t.Errorf("vault lock held while syncing %q", message)
+		} else {
+			mu.Unlock()
+		}
+		pushes = append(pushes, message)
+	})
+	return te, store, &pushes
+}
+
+func TestToolExecutor_ReadFileLineRange(t *testing.T) {
+	te, _, _ := newFileToolExecutor(t, map[string]string{"notes/a.md": "one\ntwo\nthree\nfour\n"})
+
+	tests := []struct {
+		input map[string]any
+		want  string
+	}{
+		{map[string]any{}, "one\ntwo\nthree\nfour\n"},
+		{map[string]any{"offset": float64(2), "limit": float64(2)}, "two\nthree\n"},
+		{map[string]any{"offset": float64(3)}, "three\nfour\n"},
+		{map[string]any{"limit": float64(1)}, "one\n"},
+		{map[string]any{"offset": float64(4), "limit": float64(10)}, "four\n"},
+	}
+	for _, tt := range tests {
+		tt.input["path"] = "notes/a.md"
+		got, err := te.ExecuteTool("read_file", tt.input)
+		if err != nil || got != tt.want {
+			t.Errorf("read_file(%v) = %q, %v; want %q", tt.input, got, err, tt.want)
+		}
+	}
+
+	for _, input := range []map[string]any{
+		{"path": "notes/a.md", "offset": float64(5)},
+		{"path": "notes/a.md", "limit": float64(0)},
+		{"path": "notes/a.md", "offset": 1.5},
+		{"path": "notes/a.md", "offset": "2"},
+	} {
+		if _, err := te.ExecuteTool("read_file", input); err == nil {
+			t.Errorf("read_file(%v) should fail", input)
+		}
+	}
+}
+
+func TestToolExecutor_EditFile(t *testing.T) {
+	te, store, pushes := newFileToolExecutor(t, map[string]string{"notes/a.md": "- [ ] milk\n- [ ] eggs\n- [ ] milk chocolate\n"})
+
+	if _, err := te.ExecuteTool("edit_file", map[string]any{"path": "notes/a.md", "old_string": "bread", "new_string": "x"}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("edit_file(missing) error = %v", err)
+	}
+	if _, err := te.ExecuteTool("edit_file", map[string]any{"path": "notes/a.md", "old_string": "- [ ] milk", "new_string": "- [x] milk"}); err == nil || !strings.Contains(err.Error(), "2 times") {
+		t.Fatalf("edit_file(ambiguous) error = %v", err)
+	}
+	if _, err := te.ExecuteTool("edit_file", map[string]any{"path": "notes/a.md", "old_string": "- [ ] milk\n", "new_string": "- [x] milk\n"}); err != nil {
+		t.Fatalf("edit_file() error = %v", err)
+	}
+
+	got, _ := store.ReadFile("sebastian", "notes/a.md")
+	if got != "- [x] milk\n- [ ] eggs\n- [ ] milk chocolate\n" {
+		t.Fatalf("content = %q", got)
+	}
+	if len(*pushes) != 1 || (*pushes)[0] != "Agent edit file" {
+		t.Fatalf("pushes = %v, want one for the successful edit", *pushes)
+	}
+}
+
+func TestToolExecutor_AppendToFile(t *testing.T) {
+	te, store, pushes := newFileToolExecutor(t, map[string]string{"notes/log.md": "first"})
+
+	for _, input := range []map[string]any{
+		{"path": "notes/log.md", "content": "second\n"},
+		{"path": "notes/new.md", "content": "created\n"},
+	} {
+		if _, err := te.ExecuteTool("append_to_file", input); err != nil {
+			t.Fatalf("append_to_file(%v) error = %v", input, err)
+		}
+	}
+	if got, _ := store.ReadFile("sebastian", "notes/log.md"); got != "first\nsecond\n" {
+		t.Errorf("log.md = %q", got)
+	}
+	if got, _ := store.ReadFile("sebastian", "notes/new.md"); got != "created\n" {
+		t.Errorf("new.md = %q", got)
+	}
+	if len(*pushes) != 2 {
+		t.Errorf("pushes = %v", *pushes)
+	}
+}
+
+func TestToolExecutor_WriteSource(t *testing.T) {
+	te, store, _ := newFileToolExecutor(t, nil)
+	var sources []string
+	store.SetChangeHook(func(c vault.Change) { sources = append(sources, c.Kind+" "+c.Source) })
+
+	if _, err := te.ExecuteTool("write_file", map[string]any{"path": "notes/a.md", "content": "a"}); err != nil {
+		t.Fatalf("write_file() error = %v", err)
+	}
+	te.Observe("run-1", nil)
+	if _, err := te.ExecuteTool("add_task", map[string]any{"category": "work", "text": "ship it"}); err != nil {
+		t.Fatalf("add_task() error = %v", err)
+	}
+	if err := store.WriteFile("sebastian", "notes/b.md", "b"); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	want := []string{"file_created " + AgentWriteSource, "file_created run-1", "daily_created run-1", "capture run-1", "file_created "}
+	if strings.Join(sources, ",") != strings.Join(want, ",") {
+		t.Fatalf("sources = %q, want %q", sources, want)
+	}
+}
+
+func TestInsertUnderHeading(t *testing.T) {
+	const doc = "# Day\n\n## Tasks\n\n- [ ] a\n\n### Later\n\n- [ ] b\n\n## Notes\n\n```\n## Tasks\n```\n"
+	tests := []struct {
+		name    string
+		heading string
+		atStart bool
+		want    string
+	}{
+		{"end of section", "Tasks", false, "# Day\n\n## Tasks\n\n- [ ] a\n\n### Later\n\n- [ ] b\n- [ ] new\n\n## Notes\n\n```\n## Tasks\n```\n"},
+		{"start of section", "## tasks", true, "# Day\n\n## Tasks\n- [ ] new\n\n- [ ] a\n\n### Later\n\n- [ ] b\n\n## Notes\n\n```\n## Tasks\n```\n"},
+		{"last section", "Notes", false, "# Day\n\n## Tasks\n\n- [ ] a\n\n### Later\n\n- [ ] b\n\n## Notes\n\n```\n## Tasks\n```\n- [ ] new\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := insertUnderHeading(doc, tt.heading, "- [ ] new\n", tt.atStart)
+			if err != nil || got != tt.want {
+				t.Fatalf("insertUnderHeading() = %q, %v\nwant %q", got, err, tt.want)
+			}
+		})
+	}
+
+	for _, heading := range []string{"Missing", "### Tasks"} {
+		if _, err := insertUnderHeading(doc, heading, "x", false); err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Errorf("insertUnderHeading(%q) error = %v", heading, err)
+		}
+	}
+	if _, err := insertUnderHeading("## A\n## A\n", "A", "x", false); err == nil || !strings.Contains(err.Error(), "2 times") {
+		t.Errorf("insertUnderHeading(duplicate) error = %v", err)
+	}
+}
+
+func TestToolExecutor_MoveAndDeleteFile(t *testing.T) {
+	te, store, pushes := newFileToolExecutor(t, map[string]string{"inbox/idea.md": "idea", "projects/plan.md": "plan"})
+
+	if _, err := te.ExecuteTool("move_file", map[string]any{"from": "inbox/idea.md", "to": "projects/plan.md"}); err == nil {
+		t.Fatal("move_file onto an existing file should fail")
+	}
+	if _, err := te.ExecuteTool("move_file", map[string]any{"from": "inbox/idea.md", "to": "projects/idea.md"}); err != nil {
+		t.Fatalf("move_file() error = %v", err)
+	}
+	if got, _ := store.ReadFile("sebastian", "projects/idea.md"); got != "idea" {
+		t.Errorf("moved file = %q", got)
+	}
+
+	out, err := te.ExecuteTool("delete_file", map[string]any{"path": "projects/plan.md"})
+	if err != nil {
+		t.Fatalf("delete_file() error = %v", err)
+	}
+	trashed := strings.TrimPrefix(out, "File moved to trash at ")
+	if !strings.HasPrefix(trashed, vault.TrashDir+"/") {
+		t.Fatalf("delete_file() = %q", out)
+	}
+	if got, _ := store.ReadFile("sebastian", trashed); got != "plan" {
+		t.Errorf("trashed file = %q", got)
+	}
+	if exists, _ := store.FileExists("sebastian", "projects/plan.md"); exists {
+		t.Error("deleted file still exists")
+	}
+	if strings.Join(*pushes, ",") != "Agent move file,Agent delete file" {
+		t.Errorf("pushes = %v", *pushes)
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 
 	"notes-editor/internal/linkedin"
+	"notes-editor/internal/sandbox"
 	"notes-editor/internal/textnorm"
 	"notes-editor/internal/vault"
 )
@@ -45,6 +47,13 @@ type Service struct {
 	store    *vault.Store
 	linkedin *linkedin.Service
 	sessions *SessionStore
+
+	toolObserver ToolObserver
+	toolApprover ToolApprover
+	bashSandbox  sandbox.Policy
+	vaultMu      *sync.RWMutex
+	afterWrite   func(message string)
+	sleepLog     SleepLog
 }
 
 // NewService creates a new Claude service.
@@ -58,6 +67,33 @@ func NewService(apiKey string, model string, store *vault.Store, linkedin *linke
 	}
 }
 
+// SetToolObserver registers a callback notified after every tool call.
+func (s *Service) SetToolObserver(fn ToolObserver) {
+	s.toolObserver = fn
+}
+
+// SetToolApprover registers a callback that must allow every tool call before it runs.
+func (s *Service) SetToolApprover(fn ToolApprover) {
+	s.toolApprover = fn
+}
+
+// SetBashSandbox sets how run_bash is isolated for each person.
+func (s *Service) SetBashSandbox(policy sandbox.Policy) {
+	s.bashSandbox = policy
+}
+
+// SetVaultSync makes file tools hold mu while they touch the vault and call
+// afterWrite with a commit message after each change.
+func (s *Service) SetVaultSync(mu *sync.RWMutex, afterWrite func(message string)) {
+	s.vaultMu = mu
+	s.afterWrite = afterWrite
+}
+
+// SetSleepLog enables the sleep tools, scoped to each person's children.
+func (s *Service) SetSleepLog(log SleepLog) {
+	s.sleepLog = log
+}
+
 // Sessions returns the session store for external access.
 func (s *Service) Sessions() *SessionStore {
 	return s.sessions
@@ -67,6 +103,8 @@ func (s *Service) Sessions() *SessionStore {
 type ChatRequest struct {
 	SessionID string `json:"session_id"`
 	Message   string `json:"message"`
+	// RunID is set by the agent service and attached to audited tool calls.
+	RunID string `json:"-"`
 }
 
 // ChatResponse represents a non-streaming chat response.
@@ -123,6 +161,11 @@ func (s *Service) Chat(person string, req ChatRequest) (*ChatResponse, error) {
 
 	// Create tool executor
 	toolExec := NewToolExecutor(s.store, s.linkedin, person)
+	toolExec.Observe(req.RunID, s.toolObserver)
+	toolExec.RequireApproval(s.toolApprover)
+	toolExec.Sandbox(s.bashSandbox)
+	toolExec.Synchronize(s.vaultMu, s.afterWrite)
+	toolExec.AccessSleepLog(s.sleepLog)
 
 	// Call API with tool loop
 	response, err := s.callWithToolLoop(messages, toolExec, systemPrompt)
@@ -0,0 +1,208 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"notes-editor/internal/sleep"
+)
+
+// defaultSleepRangeDays is the range the sleep tools cover without from/to.
+const defaultSleepRangeDays = 7
+
+// SleepLog gives the sleep tools access to the family's shared sleep log.
+type SleepLog struct {
+	Store *sleep.Store
+	// Children scopes each person to the children they may see and log.
+	Children sleep.Children
+	// Logged is called after the agent added an entry, e.g. to sync it, with
+	// the change source of the executor's writes.
+	Logged func(source string)
+}
+
+// sleepEntry is a sleep log entry as returned to the agent.
+type sleepEntry struct {
+	ID     string `json:"id"`
+	Child  string `json:"child"`
+	Status string `json:"status"`
+	Date   string `json:"date"`
+	Time   string `json:"time"`
+	Notes  string `json:"notes,omitempty"`
+}
+
+// sleepChildren returns the children the person may see, narrowed to the
+// optional child argument.
+func (te *ToolExecutor) sleepChildren(input map[string]any) ([]string, error) {
+	if te.sleepLog.Store == nil {
+		return nil, fmt.Errorf("sleep log is not available")
+	}
+	allowed := te.sleepLog.Children[te.person]
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("no children are configured for %s in SLEEP_CHILDREN", te.person)
+	}
+	raw, _ := input["child"].(string)
+	if strings.TrimSpace(raw) == "" {
+		return allowed, nil
+	}
+	child, ok := sleep.NormalizeChild(raw)
+	if !ok || !te.sleepLog.Children.Allows(te.person, child) {
+		return nil, fmt.Errorf("child must be one of %s", strings.Join(allowed, ", "))
+	}
+	return []string{child}, nil
+}
+
+// sleepRange parses the optional from and to dates (YYYY-MM-DD, inclusive) in
+// the sleep log's time zone. It defaults to the last seven days.
+func sleepRange(input map[string]any, loc *time.Location) (time.Time, time.Time, error) {
+	parse := func(key string, fallback time.Time) (time.Time, error) {
+		raw, _ := input[key].(string)
+		if strings.TrimSpace(raw) == "" {
+			return fallback, nil
+		}
+		date, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(raw), loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s must be YYYY-MM-DD", key)
+		}
+		return date, nil
+	}
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	to, err := parse("to", today)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	from, err := parse("from", to.AddDate(0, 0, -(defaultSleepRangeDays-1)))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("to must not be before from")
+	}
+	return from, to, nil
+}
+
+func (te *ToolExecutor) sleepEntries(input map[string]any) (string, error) {
+	children, err := te.sleepChildren(input)
+	if err != nil {
+		return "", err
+	}
+	loc := te.sleepLog.Store.Location()
+	from, to, err := sleepRange(input, loc)
+	if err != nil {
+		return "", err
+	}
+	entries, err := te.sleepLog.Store.EntriesBetween(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return "", err
+	}
+
+	out := make([]sleepEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.OccurredAt == nil || !slices.Contains(children, e.Child) {
+			continue
+		}
+		local := e.OccurredAt.In(loc)
+		out = append(out, sleepEntry{
+			ID:     e.ID,
+			Child:  e.Child,
+			Status: e.Status,
+			Date:   local.Format("2006-01-02"),
+			Time:   local.Format("15:04"),
+			Notes:  e.Notes,
+		})
+	}
+	result, err := json.Marshal(map[string]any{
+		"from":    from.Format("2006-01-02"),
+		"to":      to.Format("2006-01-02"),
+		"entries": out,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+func (te *ToolExecutor) sleepSummary(input map[string]any) (string, error) {
+	children, err := te.sleepChildren(input)
+	if err != nil {
+		return "", err
+	}
+	from, to, err := sleepRange(input, te.sleepLog.Store.Location())
+	if err != nil {
+		return "", err
+	}
+	summary, err := te.sleepLog.Store.SummarizeRange(from, to)
+	if err != nil {
+		return "", err
+	}
+
+	nights := make([]sleep.NightSummary, 0, len(summary.Nights))
+	for _, n := range summary.Nights {
+		if slices.Contains(children, n.Child) {
+			nights = append(nights, n)
+		}
+	}
+	averages := make([]sleep.AverageSummary, 0, len(summary.Averages))
+	for _, a := range summary.Averages {
+		if slices.Contains(children, a.Child) {
+			averages = append(averages, a)
+		}
+	}
+	result, err := json.Marshal(map[string]any{
+		"from":     from.Format("2006-01-02"),
+		"to":       to.Format("2006-01-02"),
+		"nights":   nights,
+		"averages": averages,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+func (te *ToolExecutor) logSleep(input map[string]any) (string, error) {
+	raw, _ := input["child"].(string)
+	if strings.TrimSpace(raw) == "" {
+		return "", fmt.Errorf("child is required")
+	}
+	children, err := te.sleepChildren(input)
+	if err != nil {
+		return "", err
+	}
+	child := children[0]
+	rawStatus, _ := input["status"].(string)
+	status, err := sleep.NormalizeStatus(rawStatus)
+	if err != nil {
+		return "", fmt.Errorf("status must be asleep or awake")
+	}
+
+	loc := te.sleepLog.Store.Location()
+	occurredAt := time.Now().UTC()
+	if rawTime, _ := input["time"].(string); strings.TrimSpace(rawTime) != "" {
+		if parsed, err := sleep.ParseOccurredAtISO(rawTime); err == nil {
+			occurredAt = *parsed
+		} else if parsed, ok := sleep.ParseOccurredAt(time.Now().In(loc).Format("2006-01-02"), rawTime, loc); ok {
+			occurredAt = *parsed
+			// A time later than now means last night, e.g. 23:30 logged after midnight.
+			if occurredAt.After(time.Now()) {
+				occurredAt = occurredAt.AddDate(0, 0, -1)
+			}
+		} else {
+			return "", fmt.Errorf("time must be HH:MM or RFC3339")
+		}
+	}
+	notes, _ := input["notes"].(string)
+
+	entry, err := te.sleepLog.Store.CreateEntry(child, status, &occurredAt, notes)
+	if err != nil {
+		return "", err
+	}
+	if te.sleepLog.Logged != nil {
+		te.sleepLog.Logged(te.writeSource())
+	}
+	local := occurredAt.In(loc)
+	return fmt.Sprintf("Logged %s %s at %s %s (id %s)", child, status, local.Format("2006-01-02"), local.Format("15:04"), entry.ID), nil
+}
@@ -0,0 +1,124 @@
+package claude
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"notes-editor/internal/sleep"
+	"notes-editor/internal/vault"
+)
+
+func newSleepToolExecutor(t *testing.T, person string) (*ToolExecutor, *sleep.Store, *[]string) {
+	t.Helper()
+	store, err := sleep.NewStore(filepath.Join(t.TempDir(), "sleep.db"))
+	if err != nil {
+		t.Fatalf("sleep.NewStore() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	children, err := sleep.ParseChildren("sebastian=Thomas,Fabian;petra=Fabian")
+	if err != nil {
+		t.Fatalf("ParseChildren() error = %v", err)
+	}
+	var logged []string
+	te := NewToolExecutor(vault.NewStore(t.TempDir()), nil, person)
+	te.Observe("run-1", nil)
+	te.AccessSleepLog(SleepLog{Store: store, Children: children, Logged: func(source string) { logged = append(logged, source) }})
+	return te, store, &logged
+}
+
+func TestToolExecutor_SleepTools(t *testing.T) {
+	te, store, logged := newSleepToolExecutor(t, "petra")
+	loc := store.Location()
+	yesterday := time.Now().In(loc).AddDate(0, 0, -1).Format("2006-01-02")
+	today := time.Now().In(loc).Format("2006-01-02")
+	at := func(date, clock string) *time.Time {
+		parsed, _ := sleep.ParseOccurredAt(date, clock, loc)
+		return parsed
+	}
+	for _, e := range []struct {
+		child, status string
+		at            *time.Time
+	}{
+		{sleep.ChildFabian, sleep.StatusAsleep, at(yesterday, "19:30")},
+		{sleep.ChildThomas, sleep.StatusAsleep, at(yesterday, "20:00")},
+	} {
+		if _, err := store.CreateEntry(e.child, e.status, e.at, ""); err != nil {
+			t.Fatalf("CreateEntry() error = %v", err)
+		}
+	}
+
+	if _, err := te.ExecuteTool("log_sleep", map[string]any{"child": "Thomas", "status": "awake"}); err == nil {
+		t.Fatal("log_sleep for another person's child should fail")
+	}
+	if _, err := te.ExecuteTool("log_sleep", map[string]any{"child": "fabian", "status": "woke up"}); err == nil {
+		t.Fatal("log_sleep with an unknown status should fail")
+	}
+	wake := time.Now().In(loc).Add(-time.Minute).Format("15:04")
+	if wake > "19:30" {
+		// Keep the night shorter than a day when the test runs late.
+		wake = "06:30"
+	}
+	wakeAt := at(today, wake)
+	if _, err := te.ExecuteTool("log_sleep", map[string]any{"child": "fabian", "status": "awake", "time": wakeAt.Format(time.RFC3339), "notes": "woke once"}); err != nil {
+		t.Fatalf("log_sleep() error = %v", err)
+	}
+	// Logged reports the run so its own sleep_logged triggers can be ignored.
+	if len(*logged) != 1 || (*logged)[0] != "run-1" {
+		t.Fatalf("Logged calls = %q", *logged)
+	}
+
+	out, err := te.ExecuteTool("sleep_entries", map[string]any{})
+	if err != nil {
+		t.Fatalf("sleep_entries() error = %v", err)
+	}
+	var listed struct {
+		From    string       `json:"from"`
+		Entries []sleepEntry `json:"entries"`
+	}
+	if err := json.Unmarshal([]byte(out), &listed); err != nil {
+		t.Fatalf("unmarshal entries: %v", err)
+	}
+	if len(listed.Entries) != 2 || listed.Entries[0].Notes != "woke once" {
+		t.Fatalf("sleep_entries() = %s", out)
+	}
+	if listed.From != time.Now().In(loc).AddDate(0, 0, -6).Format("2006-01-02") {
+		t.Errorf("default from = %s", listed.From)
+	}
+
+	out, err = te.ExecuteTool("sleep_summary", map[string]any{"from": yesterday, "to": yesterday})
+	if err != nil {
+		t.Fatalf("sleep_summary() error = %v", err)
+	}
+	var summary sleep.Summary
+	if err := json.Unmarshal([]byte(out), &summary); err != nil {
+		t.Fatalf("unmarshal summary: %v", err)
+	}
+	if len(summary.Nights) != 1 || summary.Nights[0].Child != sleep.ChildFabian || summary.Nights[0].Bedtime != "19:30" {
+		t.Fatalf("sleep_summary() nights = %+v", summary.Nights)
+	}
+	if len(summary.Averages) != 1 || summary.Averages[0].Days != 1 || summary.Averages[0].Nights != 1 {
+		t.Fatalf("sleep_summary() averages = %+v", summary.Averages)
+	}
+
+	if _, err := te.ExecuteTool("sleep_summary", map[string]any{"child": "Thomas"}); err == nil {
+		t.Fatal("sleep_summary for another person's child should fail")
+	}
+	if _, err := te.ExecuteTool("sleep_entries", map[string]any{"from": today, "to": yesterday}); err == nil {
+		t.Fatal("sleep_entries with a reversed range should fail")
+	}
+}
+
+func TestToolExecutor_SleepToolsNeedChildren(t *testing.T) {
+	te, _, _ := newSleepToolExecutor(t, "tom")
+	if _, err := te.ExecuteTool("sleep_summary", map[string]any{}); err == nil || !strings.Contains(err.Error(), "SLEEP_CHILDREN") {
+		t.Fatalf("sleep_summary() error = %v", err)
+	}
+
+	te = NewToolExecutor(vault.NewStore(t.TempDir()), nil, "petra")
+	if _, err := te.ExecuteTool("sleep_entries", map[string]any{}); err == nil {
+		t.Fatal("sleep_entries without a sleep log should fail")
+	}
+}
@@ -53,6 +53,11 @@ func (s *Service) ChatStream(person string, req ChatRequest) (<-chan StreamEvent
 
 	// Create tool executor
 	toolExec := NewToolExecutor(s.store, s.linkedin, person)
+	toolExec.Observe(req.RunID, s.toolObserver)
+	toolExec.RequireApproval(s.toolApprover)
+	toolExec.Sandbox(s.bashSandbox)
+	toolExec.Synchronize(s.vaultMu, s.afterWrite)
+	toolExec.AccessSleepLog(s.sleepLog)
 
 	events := make(chan StreamEvent, 100)
 
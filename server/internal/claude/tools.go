@@ -23,6 +23,7 @@ import (
 	"unicode/utf8"
 
 	"notes-editor/internal/linkedin"
+	"notes-editor/internal/sandbox"
 	"notes-editor/internal/vault"
 
 	"golang.org/x/net/html"
@@ -68,6 +69,14 @@ var ToolDefinitions = []map[string]any{
 					"type":        "string",
 					"description": "Path to the file relative to the person's vault root",
 				},
+				"offset": map[string]any{
+					"type":        "number",
+					"description": "Optional line number to start reading from (1-indexed)",
+				},
+				"limit": map[string]any{
+					"type":        "number",
+					"description": "Optional maximum number of lines to read",
+				},
 			},
 			"required": []string{"path"},
 		},
@@ -90,6 +99,258 @@ var ToolDefinitions = []map[string]any{
 			"required": []string{"path", "content"},
 		},
 	},
+	{
+		"name":        "edit_file",
+		"description": "Replace one exact occurrence of a string in a file. Fails unless old_string appears exactly once, so include enough surrounding text to make it unique.",
+		"input_schema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Path to the file relative to the person's vault root",
+				},
+				"old_string": map[string]any{
+					"type":        "string",
+					"description": "Exact text to replace",
+				},
+				"new_string": map[string]any{
+					"type":        "string",
+					"description": "Replacement text",
+				},
+			},
+			"required": []string{"path", "old_string", "new_string"},
+		},
+	},
+	{
+		"name":        "append_to_file",
+		"description": "Append content to the end of a file in the notes vault. Creates the file if it doesn't exist.",
+		"input_schema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Path to the file relative to the person's vault root",
+				},
+				"content": map[string]any{
+					"type":        "string",
+					"description": "Content to append, starting on a new line",
+				},
+			},
+			"required": []string{"path", "content"},
+		},
+	},
+	{
+		"name":        "insert_at_heading",
+		"description": "Insert content into the section under a markdown heading of a file in the notes vault",
+		"input_schema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Path to the file relative to the person's vault root",
+				},
+				"heading": map[string]any{
+					"type":        "string",
+					"description": "Heading text, e.g. 'Tasks' or '## Tasks' to also match the level. Must identify exactly one heading.",
+				},
+				"content": map[string]any{
+					"type":        "string",
+					"description": "Content to insert",
+				},
+				"position": map[string]any{
+					"type":        "string",
+					"enum":        []string{"end", "start"},
+					"description": "Insert at the end of the section (default) or right below the heading",
+				},
+			},
+			"required": []string{"path", "heading", "content"},
+		},
+	},
+	{
+		"name":        "move_file",
+		"description": "Move or rename a file in the notes vault. Fails if the destination exists.",
+		"input_schema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"from": map[string]any{
+					"type":        "string",
+					"description": "Current path relative to the person's vault root",
+				},
+				"to": map[string]any{
+					"type":        "string",
+					"description": "New path relative to the person's vault root",
+				},
+			},
+			"required": []string{"from", "to"},
+		},
+	},
+	{
+		"name":        "delete_file",
+		"description": "Delete a file from the notes vault by moving it to the trash",
+		"input_schema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Path to the file relative to the person's vault root",
+				},
+			},
+			"required": []string{"path"},
+		},
+	},
+	{
+		"name":        "get_daily_note",
+		"description": "Get a daily note. Today's note is created from the previous one if it doesn't exist yet, carrying over open tasks and pinned entries.",
+		"input_schema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"date": map[string]any{
+					"type":        "string",
+					"description": "Date as YYYY-MM-DD. Defaults to today.",
+				},
+			},
+		},
+	},
+	{
+		"name":        "add_task",
+		"description": "Add an open task to a category in the ## todos section of today's daily note",
+		"input_schema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"category": map[string]any{
+					"type":        "string",
+					"enum":        []string{"work", "priv"},
+					"description": "Task category: work or priv",
+				},
+				"text": map[string]any{
+					"type":        "string",
+					"description": "Task text",
+				},
+			},
+			"required": []string{"category", "text"},
+		},
+	},
+	{
+		"name":        "complete_task",
+		"description": "Check off an open task in a daily note, identified by its line from list_open_tasks or by text",
+		"input_schema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"line": map[string]any{
+					"type":        "number",
+					"description": "Line number of the task as returned by list_open_tasks",
+				},
+				"text": map[string]any{
+					"type":        "string",
+					"description": "Text identifying exactly one open task (case-insensitive substring), used when line is not given",
+				},
+				"date": map[string]any{
+					"type":        "string",
+					"description": "Date of the daily note as YYYY-MM-DD. Defaults to today.",
+				},
+			},
+		},
+	},
+	{
+		"name":        "list_open_tasks",
+		"description": "List the open tasks of a daily note with their line numbers and categories",
+		"input_schema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"date": map[string]any{
+					"type":        "string",
+					"description": "Date as YYYY-MM-DD. Defaults to today.",
+				},
+			},
+		},
+	},
+	{
+		"name":        "append_daily_entry",
+		"description": "Append a timestamped entry to the custom notes of today's daily note. Pinned entries carry over to the next days.",
+		"input_schema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"text": map[string]any{
+					"type":        "string",
+					"description": "Entry text",
+				},
+				"pinned": map[string]any{
+					"type":        "boolean",
+					"description": "Pin the entry so it is carried over to the next daily notes",
+				},
+			},
+			"required": []string{"text"},
+		},
+	},
+	{
+		"name":        "sleep_entries",
+		"description": "List the children's sleep log entries (fell asleep / woke up) in a date range",
+		"input_schema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"child": map[string]any{
+					"type":        "string",
+					"description": "Only this child. Defaults to all children you may see.",
+				},
+				"from": map[string]any{
+					"type":        "string",
+					"description": "First date as YYYY-MM-DD. Defaults to six days before to.",
+				},
+				"to": map[string]any{
+					"type":        "string",
+					"description": "Last date as YYYY-MM-DD. Defaults to today.",
+				},
+			},
+		},
+	},
+	{
+		"name":        "sleep_summary",
+		"description": "Summarize the children's nights in a date range: bedtime, wake time and duration per night, and averages per child. Nights are dated by the evening they started.",
+		"input_schema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"child": map[string]any{
+					"type":        "string",
+					"description": "Only this child. Defaults to all children you may see.",
+				},
+				"from": map[string]any{
+					"type":        "string",
+					"description": "First night as YYYY-MM-DD. Defaults to six days before to.",
+				},
+				"to": map[string]any{
+					"type":        "string",
+					"description": "Last night as YYYY-MM-DD. Defaults to today.",
+				},
+			},
+		},
+	},
+	{
+		"name":        "log_sleep",
+		"description": "Log that a child fell asleep or woke up",
+		"input_schema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"child": map[string]any{
+					"type":        "string",
+					"description": "Child's name",
+				},
+				"status": map[string]any{
+					"type":        "string",
+					"enum":        []string{"asleep", "awake"},
+					"description": "asleep when the child fell asleep, awake when it woke up",
+				},
+				"time": map[string]any{
+					"type":        "string",
+					"description": "When it happened: HH:MM in the family's time zone (the most recent such time), or RFC3339. Defaults to now.",
+				},
+				"notes": map[string]any{
+					"type":        "string",
+					"description": "Optional notes",
+				},
+			},
+			"required": []string{"child", "status"},
+		},
+	},
 	{
 		"name":        "list_directory",
 		"description": "List files and directories in the notes vault",
@@ -260,29 +521,142 @@ var ToolDefinitions = []map[string]any{
 	},
 }
 
+// ToolCall describes one executed tool call.
+type ToolCall struct {
+	Person   string
+	RunID    string
+	Tool     string
+	Path     string
+	Err      error
+	Duration time.Duration
+}
+
+// AgentWriteSource is the vault change source of agent writes that are not
+// tied to a known run, see vault.Store.SetWriteSource.
+const AgentWriteSource = "agent"
+
+// ToolObserver is notified after every tool call, e.g. to audit it.
+type ToolObserver func(ToolCall)
+
+// ToolApprover decides whether a tool call may run. A non-nil error rejects the
+// call and is returned as the tool's error. It may block, e.g. to ask the person.
+type ToolApprover func(person, runID, tool string, input map[string]any) error
+
 // ToolExecutor handles tool execution with access to vault and services.
 type ToolExecutor struct {
 	store    *vault.Store
+	daily    *vault.Daily
 	linkedin *linkedin.Service
 	person   string
+
+	runID    string
+	observer ToolObserver
+	approver ToolApprover
+	sandbox  sandbox.Policy
+
+	vaultMu    *sync.RWMutex
+	afterWrite func(message string)
+	sleepLog   SleepLog
 }
 
 // NewToolExecutor creates a new tool executor.
 func NewToolExecutor(store *vault.Store, linkedin *linkedin.Service, person string) *ToolExecutor {
 	return &ToolExecutor{
 		store:    store,
+		daily:    vault.NewDaily(store),
 		linkedin: linkedin,
 		person:   person,
 	}
 }
 
+// Observe reports every subsequent tool call, tagged with runID, to fn.
+func (te *ToolExecutor) Observe(runID string, fn ToolObserver) {
+	te.runID = runID
+	te.observer = fn
+}
+
+// Sandbox sets how run_bash is isolated for each person.
+func (te *ToolExecutor) Sandbox(policy sandbox.Policy) {
+	te.sandbox = policy
+}
+
+// Synchronize makes the file tools hold mu, the lock shared with the vault's
+// other writers, and call afterWrite with a commit message after each change.
+func (te *ToolExecutor) Synchronize(mu *sync.RWMutex, afterWrite func(message string)) {
+	te.vaultMu = mu
+	te.afterWrite = afterWrite
+}
+
+// AccessSleepLog enables the sleep tools, scoped to the person's children.
+func (te *ToolExecutor) AccessSleepLog(log SleepLog) {
+	te.sleepLog = log
+}
+
+// RequireApproval makes every subsequent tool call ask fn before running.
+func (te *ToolExecutor) RequireApproval(fn ToolApprover) {
+	te.approver = fn
+}
+
 // ExecuteTool executes a tool call and returns the result.
 func (te *ToolExecutor) ExecuteTool(name string, input map[string]any) (string, error) {
+	if te.observer == nil {
+		return te.approveAndExecute(name, input)
+	}
+	start := time.Now()
+	result, err := te.approveAndExecute(name, input)
+	path, _ := input["path"].(string)
+	te.observer(ToolCall{
+		Person:   te.person,
+		RunID:    te.runID,
+		Tool:     name,
+		Path:     path,
+		Err:      err,
+		Duration: time.Since(start),
+	})
+	return result, err
+}
+
+func (te *ToolExecutor) approveAndExecute(name string, input map[string]any) (string, error) {
+	if te.approver != nil {
+		if err := te.approver(te.person, te.runID, name, input); err != nil {
+			return "", err
+		}
+	}
+	return te.execute(name, input)
+}
+
+func (te *ToolExecutor) execute(name string, input map[string]any) (string, error) {
 	switch name {
 	case "read_file":
 		return te.readFile(input)
 	case "write_file":
 		return te.writeFile(input)
+	case "edit_file":
+		return te.editFile(input)
+	case "append_to_file":
+		return te.appendToFile(input)
+	case "insert_at_heading":
+		return te.insertAtHeading(input)
+	case "move_file":
+		return te.moveFile(input)
+	case "delete_file":
+		return te.deleteFile(input)
+	case "get_daily_note":
+		return te.getDailyNote(input)
+	case "add_task":
+		return te.addTask(input)
+	case "complete_task":
+		return te.completeTask(input)
+	case "list_open_tasks":
+		return te.listOpenTasks(input)
+	case "append_daily_entry":
+		return te.appendDailyEntry(input)
+	case "sleep_entries":
+		return te.sleepEntries(input)
+	case "sleep_summary":
+		return te.sleepSummary(input)
+	case "log_sleep":
+		return te.logSleep(input)
 	case "list_directory":
 		return te.listDirectory(input)
 	case "search_files":
@@ -313,7 +687,24 @@ func (te *ToolExecutor) readFile(input map[string]any) (string, error) {
 	if !ok {
 		return "", fmt.Errorf("path is required")
 	}
-	return te.store.ReadFile(te.person, path)
+	offset, err := lineArg(input, "offset")
+	if err != nil {
+		return "", err
+	}
+	limit, err := lineArg(input, "limit")
+	if err != nil {
+		return "", err
+	}
+	if err := te.store.AgentCanAccess(te.person, path); err != nil {
+		return "", err
+	}
+	te.rlockVault()
+	content, err := te.store.ReadFile(te.person, path)
+	te.runlockVault()
+	if err != nil || (offset == 0 && limit == 0) {
+		return content, err
+	}
+	return lineRange(content, offset, limit)
 }
 
 func (te *ToolExecutor) writeFile(input map[string]any) (string, error) {
@@ -325,11 +716,15 @@ func (te *ToolExecutor) writeFile(input map[string]any) (string, error) {
 	if !ok {
 		return "", fmt.Errorf("content is required")
 	}
-	err := te.store.WriteFile(te.person, path, content)
-	if err != nil {
+	if err := te.store.AgentCanAccess(te.person, path); err != nil {
 		return "", err
 	}
-	return "File written successfully", nil
+	return te.changeVault("Agent write file", func() (string, error) {
+		if err := te.store.WriteFile(te.person, path, content); err != nil {
+			return "", err
+		}
+		return "File written successfully", nil
+	})
 }
 
 func (te *ToolExecutor) listDirectory(input map[string]any) (string, error) {
@@ -337,6 +732,9 @@ func (te *ToolExecutor) listDirectory(input map[string]any) (string, error) {
 	if !ok {
 		path = "."
 	}
+	if err := te.store.AgentCanAccess(te.person, path); err != nil {
+		return "", err
+	}
 	entries, err := te.store.ListDir(te.person, path)
 	if err != nil {
 		return "", err
@@ -373,7 +771,7 @@ func (te *ToolExecutor) globFiles(input map[string]any) (string, error) {
 		}
 	}
 
-	fullPath, err := vault.ResolvePath(te.store.RootPath(), te.person, searchPath)
+	fullPath, err := te.store.Resolve(te.person, searchPath)
 	if err != nil {
 		return "", err
 	}
@@ -405,12 +803,18 @@ func (te *ToolExecutor) globFiles(input map[string]any) (string, error) {
 		}
 		relForMatch = filepath.ToSlash(relForMatch)
 
-		if re.MatchString(relForMatch) {
-			relVault, relVaultErr := filepath.Rel(filepath.Join(te.store.RootPath(), te.person), p)
-			if relVaultErr != nil {
+		// Encrypted files appear under their plaintext names, and only where
+		// the agent may use them.
+		vaultRel := filepath.Join(searchPath, relForMatch)
+		if te.store.IsEncrypted(te.person, vaultRel) {
+			if te.store.AgentCanAccess(te.person, vaultRel) != nil {
 				return nil
 			}
-			matches = append(matches, filepath.ToSlash(relVault))
+			relForMatch = strings.TrimSuffix(relForMatch, vault.EncryptedSuffix)
+		}
+
+		if re.MatchString(relForMatch) {
+			matches = append(matches, filepath.ToSlash(filepath.Join(searchPath, relForMatch)))
 			if len(matches) >= limit {
 				return filepath.SkipAll
 			}
@@ -589,15 +993,26 @@ func (te *ToolExecutor) runBash(input map[string]any) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "bash", "-lc", command)
-	cmd.Dir = vaultRoot
-
 	stdout := &cappedBuffer{limit: maxBashOutputBytes}
 	stderr := &cappedBuffer{limit: maxBashOutputBytes}
-	cmd.Stdout = stdout
-	cmd.Stderr = stderr
 
-	err = cmd.Run()
+	mode := te.sandbox.ModeFor(te.person)
+	if mode == sandbox.ModeOff {
+		cmd := exec.CommandContext(ctx, "bash", "-lc", command)
+		cmd.Dir = vaultRoot
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		err = cmd.Run()
+	} else {
+		err = sandbox.Run(ctx, sandbox.Spec{
+			Dir:     vaultRoot,
+			Network: mode == sandbox.ModeNetwork,
+			Limits:  te.sandbox.Limits,
+		}, command, stdout, stderr)
+		if errors.Is(err, sandbox.ErrUnavailable) {
+			return "", fmt.Errorf("bash commands must run sandboxed, but the sandbox cannot start: %w", err)
+		}
+	}
 	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 		return "", fmt.Errorf("bash command timed out after %s", timeout)
 	}
@@ -620,6 +1035,9 @@ func (te *ToolExecutor) runBash(input map[string]any) (string, error) {
 		"stdout_truncated": stdout.truncated,
 		"stderr_truncated": stderr.truncated,
 	}
+	if mode != sandbox.ModeOff {
+		response["sandbox"] = mode
+	}
 
 	encoded, marshalErr := json.Marshal(response)
 	if marshalErr != nil {
@@ -2,6 +2,7 @@ package claude
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"sync/atomic"
 	"testing"
 
+	"notes-editor/internal/sandbox"
 	"notes-editor/internal/vault"
 )
 
@@ -268,6 +270,82 @@ func TestToolExecutor_GlobFiles_PathAndLimit(t *testing.T) {
 	}
 }
 
+func TestToolExecutor_EncryptedFolders(t *testing.T) {
+	root := t.TempDir()
+	person := "sebastian"
+
+	store := vault.NewStore(root)
+	folders, err := vault.ParseEncryptedFolders("sebastian=health,finance:agent")
+	if err != nil {
+		t.Fatalf("ParseEncryptedFolders: %v", err)
+	}
+	store.SetEncryptedFolders(folders)
+	if _, err := store.SetupEncryption(person, "correct horse"); err != nil {
+		t.Fatalf("SetupEncryption: %v", err)
+	}
+	for _, path := range []string{"health/blood.md", "finance/2026.md"} {
+		if err := store.WriteFile(person, path, "secret"); err != nil {
+			t.Fatalf("WriteFile(%s): %v", path, err)
+		}
+	}
+	te := NewToolExecutor(store, nil, person)
+
+	if _, err := te.ExecuteTool("read_file", map[string]any{"path": "health/blood.md"}); err == nil {
+		t.Fatal("read_file in a folder closed to the agent should fail")
+	}
+	if _, err := te.ExecuteTool("write_file", map[string]any{"path": "health/new.md", "content": "x"}); err == nil {
+		t.Fatal("write_file in a folder closed to the agent should fail")
+	}
+	if out, err := te.ExecuteTool("read_file", map[string]any{"path": "finance/2026.md"}); err != nil || out != "secret" {
+		t.Fatalf("read_file(finance) = %q, %v", out, err)
+	}
+
+	out, err := te.ExecuteTool("glob_files", map[string]any{"pattern": "**/*.md"})
+	if err != nil {
+		t.Fatalf("glob_files: %v", err)
+	}
+	var got []string
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("unmarshal: %v. out=%q", err, out)
+	}
+	assertContains(t, got, "finance/2026.md")
+	assertNotContains(t, got, "health/blood.md")
+}
+
+func TestToolExecutor_RequireApproval(t *testing.T) {
+	store := vault.NewStore(t.TempDir())
+	if err := store.WriteFile("sebastian", "notes.md", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	te := NewToolExecutor(store, nil, "sebastian")
+	var observed []ToolCall
+	te.Observe("run-1", func(call ToolCall) { observed = append(observed, call) })
+	var asked []string
+	te.RequireApproval(func(person, runID, tool string, input map[string]any) error {
+		asked = append(asked, person+"/"+runID+"/"+tool)
+		if tool == "write_file" {
+			return errors.New("denied")
+		}
+		return nil
+	})
+
+	if _, err := te.ExecuteTool("write_file", map[string]any{"path": "a.md", "content": "x"}); err == nil {
+		t.Fatal("rejected write_file should fail")
+	}
+	if exists, _ := store.FileExists("sebastian", "a.md"); exists {
+		t.Fatal("rejected write_file must not write")
+	}
+	if _, err := te.ExecuteTool("list_directory", map[string]any{"path": "."}); err != nil {
+		t.Fatalf("list_directory: %v", err)
+	}
+	if strings.Join(asked, ",") != "sebastian/run-1/write_file,sebastian/run-1/list_directory" {
+		t.Fatalf("asked = %v", asked)
+	}
+	if len(observed) != 2 || observed[0].Err == nil {
+		t.Fatalf("observed = %+v", observed)
+	}
+}
+
 func assertContains(t *testing.T, haystack []string, needle string) {
 	t.Helper()
 	for _, s := range haystack {
@@ -439,6 +517,51 @@ func TestToolExecutor_RunBash_NonZeroExit(t *testing.T) {
 	}
 }
 
+func TestToolExecutor_RunBash_Sandboxed(t *testing.T) {
+	root := t.TempDir()
+	person := "sebastian"
+	if err := os.MkdirAll(filepath.Join(root, person), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "other.txt"), []byte("other person"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	store := vault.NewStore(root)
+	te := NewToolExecutor(store, nil, person)
+	policy, err := sandbox.ParsePolicy("sebastian=on")
+	if err != nil {
+		t.Fatalf("ParsePolicy: %v", err)
+	}
+	te.Sandbox(policy)
+
+	out, err := te.ExecuteTool("run_bash", map[string]any{
+		"command": "pwd; cat ../other.txt 2>/dev/null || echo hidden",
+	})
+	if unavailable := sandbox.Available(); unavailable != nil {
+		if err == nil || !strings.Contains(err.Error(), "sandbox") {
+			t.Fatalf("ExecuteTool without a sandbox = %q, %v; want a sandbox error", out, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("ExecuteTool(run_bash): %v", err)
+	}
+
+	raw := strings.TrimPrefix(out, "<bash_result_json>\n")
+	raw = strings.TrimSuffix(raw, "\n</bash_result_json>")
+	var payload struct {
+		Stdout  string `json:"stdout"`
+		Sandbox string `json:"sandbox"`
+	}
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+		t.Fatalf("unmarshal payload: %v; out=%q", err, out)
+	}
+	if payload.Sandbox != sandbox.ModeOn || payload.Stdout != sandbox.WorkDir+"\nhidden\n" {
+		t.Fatalf("payload = %+v", payload)
+	}
+}
+
 func TestToolExecutor_RunBash_Timeout(t *testing.T) {
 	root := t.TempDir()
 	person := "sebastian"
@@ -3,6 +3,7 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -28,6 +29,14 @@ type Config struct {
 	ServerAddr string
 	// ValidPersons defines accepted X-Notes-Person values.
 	ValidPersons []string
+	// SharedFolders grants persons access to folders under _shared/, e.g.
+	// "family=sebastian,petra;budget=petra,sebastian:read".
+	SharedFolders string
+	// EncryptedFolders lists each person's encrypted folders, e.g.
+	// "sebastian=health,finance:agent;petra=medical".
+	EncryptedFolders string
+	// UnlockTimeout locks encrypted folders after this long without use.
+	UnlockTimeout time.Duration
 	// LinkedIn configuration for OAuth and API access.
 	LinkedIn LinkedInConfig
 	// PiGatewayURL is the local gateway sidecar endpoint base URL.
@@ -38,6 +47,125 @@ type Config struct {
 	AgentMaxRunDuration time.Duration
 	// AgentMaxToolCallsPerRun bounds tool calls emitted in one run.
 	AgentMaxToolCallsPerRun int
+	// AgentToolApprovalTimeout bounds how long a tool call waits for approval.
+	AgentToolApprovalTimeout time.Duration
+	// BashSandbox selects the run_bash sandbox mode per person, e.g.
+	// "on,petra=network,sebastian=off".
+	BashSandbox string
+	// BashSandboxMemoryMB and BashSandboxCPUSeconds limit sandboxed commands.
+	BashSandboxMemoryMB   int
+	BashSandboxCPUSeconds int
+	// SleepChildren lists the children whose sleep each person's agent may see
+	// and log, e.g. "sebastian=Thomas,Fabian;petra=Fabian".
+	SleepChildren string
+	// Backup configures scheduled encrypted backups.
+	Backup BackupConfig
+	// Login configures browser login sessions and passkeys.
+	Login LoginConfig
+	// AuditRetention is how long audit log entries are kept.
+	AuditRetention time.Duration
+	// RateLimit configures request limits and the auth failure lockout.
+	RateLimit RateLimitConfig
+	// Security configures CORS and browser security headers.
+	Security SecurityConfig
+	// TLS configures HTTPS.
+	TLS TLSConfig
+}
+
+// TLS modes.
+const (
+	TLSOff = "off"
+	// TLSFiles serves the certificate and key in CertFile and KeyFile.
+	TLSFiles = "files"
+	// TLSLocalCA issues a certificate from a self-signed CA generated next to .env.
+	TLSLocalCA = "local-ca"
+)
+
+// TLSConfig holds HTTPS settings. With TLS enabled, ServerAddr keeps serving
+// plain HTTP and redirects to HTTPS.
+type TLSConfig struct {
+	// Mode is TLSOff, TLSFiles or TLSLocalCA.
+	Mode string
+	// Addr is the HTTPS listen address.
+	Addr     string
+	CertFile string
+	KeyFile  string
+	// Hosts are the names and IP addresses the local CA certificate covers.
+	// Empty selects the host name, localhost and the machine's IP addresses.
+	Hosts []string
+	// RedirectHTTP redirects plain HTTP requests to HTTPS, except requests from
+	// loopback addresses such as the pi-gateway.
+	RedirectHTTP bool
+}
+
+// Enabled reports whether HTTPS is served.
+func (t TLSConfig) Enabled() bool {
+	return t.Mode == TLSFiles || t.Mode == TLSLocalCA
+}
+
+// DefaultContentSecurityPolicy allows the web UI's own scripts and the Google
+// Fonts stylesheet it loads.
+const DefaultContentSecurityPolicy = "default-src 'self'; script-src 'self'; " +
+	"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; font-src 'self' data: https://fonts.gstatic.com; " +
+	"img-src 'self' data: blob:; connect-src 'self'; object-src 'none'; base-uri 'self'; form-action 'self'"
+
+// SecurityConfig holds CORS and browser security header settings.
+type SecurityConfig struct {
+	// AllowedOrigins may call the API from other origins with cookies. Empty
+	// allows any origin, but only with bearer tokens.
+	AllowedOrigins []string
+	// StrictOrigin rejects requests without a bearer token (cookie sessions and
+	// logins) unless their Origin is the server itself or in AllowedOrigins.
+	StrictOrigin bool
+	// ContentSecurityPolicy is sent with the web UI. Empty disables it.
+	ContentSecurityPolicy string
+	// FrameAncestors may embed the web UI, as CSP sources. Empty forbids framing.
+	FrameAncestors []string
+	// HSTSMaxAge is sent in Strict-Transport-Security over HTTPS. Zero disables it.
+	HSTSMaxAge time.Duration
+}
+
+// RateLimitConfig holds per-minute request budgets. A zero budget disables that
+// limit; zero LockoutFailures disables the lockout.
+type RateLimitConfig struct {
+	// PerIP bounds all requests from one IP address.
+	PerIP int
+	// PerClient bounds authenticated requests from one token or login session.
+	PerClient int
+	// Expensive bounds chat, sync and backup download requests from one client.
+	Expensive int
+	// LockoutFailures is the number of failed authentications from one IP
+	// address within LockoutDuration that locks it out for LockoutDuration.
+	LockoutFailures int
+	LockoutDuration time.Duration
+}
+
+// LoginConfig holds browser login settings. Passkeys are disabled unless
+// WebAuthnRPID and WebAuthnOrigins are set.
+type LoginConfig struct {
+	// SessionTTL is how long a login session stays valid.
+	SessionTTL time.Duration
+	// WebAuthnRPID is the relying party ID, normally the host name of the web client.
+	WebAuthnRPID string
+	// WebAuthnRPName is shown by authenticators when registering a passkey.
+	WebAuthnRPName string
+	// WebAuthnOrigins are the fully qualified origins allowed to use passkeys.
+	WebAuthnOrigins []string
+}
+
+// BackupConfig holds scheduled backup settings. Backups are disabled unless both
+// Dir and Passphrase are set.
+type BackupConfig struct {
+	// Dir receives the encrypted archives.
+	Dir string
+	// Passphrase derives the archive encryption key.
+	Passphrase string
+	// Interval between scheduled backups. Zero disables scheduling.
+	Interval time.Duration
+	// Keep is the number of most recent archives retained.
+	Keep int
+	// MaxAge removes archives older than this. Zero disables age-based pruning.
+	MaxAge time.Duration
 }
 
 // LinkedInConfig holds LinkedIn OAuth and API configuration.
@@ -52,36 +180,28 @@ type LinkedInConfig struct {
 
 // Load reads configuration from environment variables.
 // It loads .env file if present, but environment variables take precedence.
+// Secrets from the encrypted secrets store take precedence over .env.
 func Load() (*Config, error) {
-	// Load .env file if present (ignore error if not found)
-	_ = godotenv.Load()
+	if err := LoadEnvironment(); err != nil {
+		return nil, err
+	}
 
 	cfg := &Config{
-		NotesToken:   os.Getenv("NOTES_TOKEN"),
-		NotesRoot:    os.Getenv("NOTES_ROOT"),
-		AnthropicKey: os.Getenv("ANTHROPIC_API_KEY"),
-		ClaudeModel:  strings.TrimSpace(os.Getenv("CLAUDE_MODEL")),
-		StaticDir:    os.Getenv("STATIC_DIR"),
-		ServerAddr:   os.Getenv("SERVER_ADDR"),
-		ValidPersons: parseCSV(os.Getenv("VALID_PERSONS")),
-		PiGatewayURL: strings.TrimSpace(os.Getenv("PI_GATEWAY_URL")),
-		LinkedIn: LinkedInConfig{
-			ClientID:     os.Getenv("LINKEDIN_CLIENT_ID"),
-			ClientSecret: os.Getenv("LINKEDIN_CLIENT_SECRET"),
-			RedirectURI:  os.Getenv("LINKEDIN_REDIRECT_URI"),
-			AccessToken:  os.Getenv("LINKEDIN_ACCESS_TOKEN"),
-			TokenURL:     os.Getenv("LINKEDIN_TOKEN_URL"),
-		},
-	}
-	if cfg.ClaudeModel == "" {
-		cfg.ClaudeModel = "claude-sonnet-4-6"
-	}
-	cfg.AgentEnablePiFallback = parseBoolEnv("AGENT_ENABLE_PI_FALLBACK", true)
-	cfg.AgentMaxRunDuration = parseDurationEnv("AGENT_MAX_RUN_DURATION", 45*time.Minute)
-	cfg.AgentMaxToolCallsPerRun = parseIntEnv("AGENT_MAX_TOOL_CALLS_PER_RUN", 40)
-	if cfg.PiGatewayURL == "" {
-		cfg.PiGatewayURL = "http://127.0.0.1:4317"
+		NotesToken:    os.Getenv("NOTES_TOKEN"),
+		NotesRoot:     os.Getenv("NOTES_ROOT"),
+		StaticDir:     os.Getenv("STATIC_DIR"),
+		ServerAddr:    os.Getenv("SERVER_ADDR"),
+		SharedFolders: strings.TrimSpace(os.Getenv("SHARED_FOLDERS")),
 	}
+	cfg.loadRuntime()
+	cfg.loadPersons()
+	cfg.loadEncryption()
+	cfg.Backup = loadBackupConfig()
+	cfg.Login = loadLoginConfig()
+	cfg.AuditRetention = parseDurationEnv("AUDIT_RETENTION", 90*24*time.Hour)
+	cfg.RateLimit = loadRateLimitConfig()
+	cfg.Security = loadSecurityConfig()
+	cfg.TLS = loadTLSConfig()
 
 	if err := cfg.Validate(); err != nil {
 		return nil, err
@@ -90,6 +210,24 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// LoadEnvironment loads .env and the encrypted secrets store into the process
+// environment. Variables already set in the environment win over both.
+func LoadEnvironment() error {
+	preset := presetSecrets()
+	// Load .env file if present (ignore error if not found)
+	_ = godotenv.Load()
+
+	root := os.Getenv("NOTES_ROOT")
+	if root == "" {
+		return nil
+	}
+	c := &Config{NotesRoot: root}
+	if err := applySecrets(c.SecretStore(), preset); err != nil {
+		return fmt.Errorf("loading secrets: %w", err)
+	}
+	return nil
+}
+
 // Validate checks that all required configuration fields are set.
 func (c *Config) Validate() error {
 	if c.NotesToken == "" {
@@ -104,6 +242,17 @@ func (c *Config) Validate() error {
 	if len(c.ValidPersons) == 0 {
 		c.ValidPersons = []string{"sebastian", "petra"}
 	}
+	switch c.TLS.Mode {
+	case "", TLSOff:
+		c.TLS.Mode = TLSOff
+	case TLSFiles:
+		if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+			return errors.New("TLS_CERT_FILE and TLS_KEY_FILE are required with TLS_MODE=files")
+		}
+	case TLSLocalCA:
+	default:
+		return fmt.Errorf("TLS_MODE must be off, files or local-ca, not %q", c.TLS.Mode)
+	}
 	// AnthropicKey is optional - Claude features will be disabled without it
 	// LinkedIn config is optional - LinkedIn features will be disabled without it
 	return nil
@@ -125,7 +274,22 @@ func (c *Config) ReloadRuntimeSettings() error {
 	if err := godotenv.Overload(c.envPath()); err != nil && !os.IsNotExist(err) {
 		return err
 	}
+	if err := applySecrets(c.SecretStore(), nil); err != nil {
+		return err
+	}
+
+	c.applyReload(ReloadRuntime | ReloadPersons | ReloadBackup | ReloadSharedFolders | ReloadEncryption)
+	return nil
+}
+
+// loadEncryption reads the encrypted folders and their unlock timeout.
+func (c *Config) loadEncryption() {
+	c.EncryptedFolders = strings.TrimSpace(os.Getenv("ENCRYPTED_FOLDERS"))
+	c.UnlockTimeout = parseDurationEnv("ENCRYPTION_IDLE_TIMEOUT", 30*time.Minute)
+}
 
+// loadRuntime reads the settings of the Claude, agent and LinkedIn services.
+func (c *Config) loadRuntime() {
 	c.AnthropicKey = os.Getenv("ANTHROPIC_API_KEY")
 	c.ClaudeModel = strings.TrimSpace(os.Getenv("CLAUDE_MODEL"))
 	if c.ClaudeModel == "" {
@@ -138,21 +302,167 @@ func (c *Config) ReloadRuntimeSettings() error {
 	c.AgentEnablePiFallback = parseBoolEnv("AGENT_ENABLE_PI_FALLBACK", true)
 	c.AgentMaxRunDuration = parseDurationEnv("AGENT_MAX_RUN_DURATION", 45*time.Minute)
 	c.AgentMaxToolCallsPerRun = parseIntEnv("AGENT_MAX_TOOL_CALLS_PER_RUN", 40)
-	c.ValidPersons = parseCSV(os.Getenv("VALID_PERSONS"))
-	if len(c.ValidPersons) == 0 {
-		c.ValidPersons = []string{"sebastian", "petra"}
-	}
+	c.AgentToolApprovalTimeout = parseDurationEnv("AGENT_TOOL_APPROVAL_TIMEOUT", 5*time.Minute)
+	c.BashSandbox = strings.TrimSpace(os.Getenv("BASH_SANDBOX"))
+	c.BashSandboxMemoryMB = parseIntEnv("BASH_SANDBOX_MEMORY_MB", 1024)
+	c.BashSandboxCPUSeconds = parseIntEnv("BASH_SANDBOX_CPU_SECONDS", 600)
+	c.SleepChildren = strings.TrimSpace(os.Getenv("SLEEP_CHILDREN"))
 	c.LinkedIn.ClientID = os.Getenv("LINKEDIN_CLIENT_ID")
 	c.LinkedIn.ClientSecret = os.Getenv("LINKEDIN_CLIENT_SECRET")
 	c.LinkedIn.RedirectURI = os.Getenv("LINKEDIN_REDIRECT_URI")
 	c.LinkedIn.AccessToken = os.Getenv("LINKEDIN_ACCESS_TOKEN")
 	c.LinkedIn.TokenURL = os.Getenv("LINKEDIN_TOKEN_URL")
+}
 
-	return nil
+// loadPersons reads VALID_PERSONS.
+func (c *Config) loadPersons() {
+	c.ValidPersons = parseCSV(os.Getenv("VALID_PERSONS"))
+	if len(c.ValidPersons) == 0 {
+		c.ValidPersons = []string{"sebastian", "petra"}
+	}
+}
+
+func loadBackupConfig() BackupConfig {
+	return BackupConfig{
+		Dir:        strings.TrimSpace(os.Getenv("BACKUP_DIR")),
+		Passphrase: os.Getenv("BACKUP_PASSPHRASE"),
+		Interval:   parseOptionalDurationEnv("BACKUP_INTERVAL", 24*time.Hour),
+		Keep:       parseIntEnv("BACKUP_KEEP", 14),
+		MaxAge:     parseOptionalDurationEnv("BACKUP_MAX_AGE", 0),
+	}
+}
+
+func loadLoginConfig() LoginConfig {
+	cfg := LoginConfig{
+		SessionTTL:      parseDurationEnv("SESSION_TTL", 30*24*time.Hour),
+		WebAuthnRPID:    strings.TrimSpace(os.Getenv("WEBAUTHN_RP_ID")),
+		WebAuthnRPName:  strings.TrimSpace(os.Getenv("WEBAUTHN_RP_NAME")),
+		WebAuthnOrigins: parseCSV(os.Getenv("WEBAUTHN_ORIGINS")),
+	}
+	if cfg.WebAuthnRPName == "" {
+		cfg.WebAuthnRPName = "Notes"
+	}
+	return cfg
+}
+
+func loadRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		PerIP:           parseOptionalIntEnv("RATE_LIMIT_IP", 600),
+		PerClient:       parseOptionalIntEnv("RATE_LIMIT_CLIENT", 300),
+		Expensive:       parseOptionalIntEnv("RATE_LIMIT_EXPENSIVE", 20),
+		LockoutFailures: parseOptionalIntEnv("AUTH_LOCKOUT_FAILURES", 10),
+		LockoutDuration: parseDurationEnv("AUTH_LOCKOUT_DURATION", 15*time.Minute),
+	}
+}
+
+func loadSecurityConfig() SecurityConfig {
+	csp := strings.TrimSpace(os.Getenv("CONTENT_SECURITY_POLICY"))
+	switch {
+	case strings.EqualFold(csp, "off"):
+		csp = ""
+	case csp == "":
+		csp = DefaultContentSecurityPolicy
+	}
+	return SecurityConfig{
+		AllowedOrigins:        parseCSV(os.Getenv("CORS_ALLOWED_ORIGINS")),
+		StrictOrigin:          parseBoolEnv("CORS_STRICT", false),
+		ContentSecurityPolicy: csp,
+		FrameAncestors:        parseCSV(os.Getenv("FRAME_ANCESTORS")),
+		HSTSMaxAge:            parseOptionalDurationEnv("HSTS_MAX_AGE", 180*24*time.Hour),
+	}
+}
+
+func loadTLSConfig() TLSConfig {
+	cfg := TLSConfig{
+		Mode:         strings.ToLower(strings.TrimSpace(os.Getenv("TLS_MODE"))),
+		Addr:         strings.TrimSpace(os.Getenv("TLS_ADDR")),
+		CertFile:     strings.TrimSpace(os.Getenv("TLS_CERT_FILE")),
+		KeyFile:      strings.TrimSpace(os.Getenv("TLS_KEY_FILE")),
+		Hosts:        parseCSV(os.Getenv("TLS_HOSTS")),
+		RedirectHTTP: parseBoolEnv("TLS_REDIRECT_HTTP", true),
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = ":443"
+	}
+	return cfg
+}
+
+// TLSDir returns the directory of the local CA and its server certificate, next
+// to .env.
+func (c *Config) TLSDir() string {
+	return c.sidecarPath("tls")
+}
+
+// SessionDBPath returns the local login session database. It lives next to .env,
+// outside the synced vault.
+func (c *Config) SessionDBPath() string {
+	return c.sidecarPath("auth-sessions.db")
+}
+
+// AuditDBPath returns the local audit log database. Like the session database it
+// lives next to .env, outside the synced vault.
+func (c *Config) AuditDBPath() string {
+	return c.sidecarPath("audit.db")
+}
+
+// AgentDBPath returns the agent session database. Like the other local
+// databases it lives next to .env, outside the synced vault.
+func (c *Config) AgentDBPath() string {
+	return c.sidecarPath("agent.db")
+}
+
+// AgentToolPoliciesPath returns the local store of the persons' agent tool
+// policies. It lives next to .env, where the agent's file tools cannot change it.
+func (c *Config) AgentToolPoliciesPath() string {
+	return c.sidecarPath("agent-tool-policies.json")
+}
+
+// TokensPath returns the local API token store, next to .env and outside the
+// synced vault.
+func (c *Config) TokensPath() string {
+	return c.sidecarPath("auth-tokens.json")
+}
+
+// LoginsPath returns the local store of password hashes and passkeys, next to
+// .env and outside the synced vault.
+func (c *Config) LoginsPath() string {
+	return c.sidecarPath("auth-logins.json")
+}
+
+// SharesPath returns the local share link store. Like the other local stores it
+// lives next to .env, outside the synced vault.
+func (c *Config) SharesPath() string {
+	return c.sidecarPath("publish-shares.json")
+}
+
+// UploadDir returns the directory where uploaded archives are spooled while
+// they are read. It lives next to .env, so uploads never pass through a shared
+// system temp directory.
+func (c *Config) UploadDir() string {
+	return c.sidecarPath("uploads")
+}
+
+// EnvPath returns the .env file next to the vault.
+func (c *Config) EnvPath() string {
+	return c.envPath()
 }
 
 func (c *Config) envPath() string {
-	return filepath.Join(c.NotesRoot, "..", ".env")
+	return c.sidecarPath(".env")
+}
+
+// sidecarPath returns a file next to .env, outside the synced vault.
+func (c *Config) sidecarPath(name string) string {
+	return filepath.Join(c.NotesRoot, "..", name)
+}
+
+// readEnvFile parses the .env file. A missing file is empty.
+func readEnvFile(path string) (map[string]string, error) {
+	values, err := godotenv.Read(path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	return values, err
 }
 
 func parseCSV(value string) []string {
@@ -194,6 +504,26 @@ func parseIntEnv(key string, defaultValue int) int {
 	return parsed
 }
 
+// parseOptionalIntEnv is like parseIntEnv but accepts "0" or "off" to disable
+// the setting.
+func parseOptionalIntEnv(key string, defaultValue int) int {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "0" || strings.EqualFold(value, "off") {
+		return 0
+	}
+	return parseIntEnv(key, defaultValue)
+}
+
+// parseOptionalDurationEnv is like parseDurationEnv but accepts "0" or "off" to
+// disable the setting.
+func parseOptionalDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "0" || strings.EqualFold(value, "off") {
+		return 0
+	}
+	return parseDurationEnv(key, defaultValue)
+}
+
 func parseDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	value := strings.TrimSpace(os.Getenv(key))
 	if value == "" {
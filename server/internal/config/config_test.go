@@ -103,3 +103,75 @@ func TestLoadDefaultsClaudeModelWhenEmpty(t *testing.T) {
 		t.Fatalf("unexpected ClaudeModel default: %q", cfg.ClaudeModel)
 	}
 }
+
+func TestLoadParsesBackupSettings(t *testing.T) {
+	t.Setenv("NOTES_TOKEN", "token")
+	t.Setenv("NOTES_ROOT", "/tmp/notes")
+	t.Setenv("BACKUP_DIR", "/var/backups/notes")
+	t.Setenv("BACKUP_PASSPHRASE", "correct horse")
+	t.Setenv("BACKUP_INTERVAL", "off")
+	t.Setenv("BACKUP_KEEP", "3")
+	t.Setenv("BACKUP_MAX_AGE", "720h")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	if cfg.Backup.Dir != "/var/backups/notes" || cfg.Backup.Passphrase != "correct horse" {
+		t.Fatalf("unexpected backup config: %#v", cfg.Backup)
+	}
+	if cfg.Backup.Interval != 0 {
+		t.Fatalf("expected scheduling disabled, got %v", cfg.Backup.Interval)
+	}
+	if cfg.Backup.Keep != 3 {
+		t.Fatalf("unexpected Keep: %d", cfg.Backup.Keep)
+	}
+	if cfg.Backup.MaxAge != 720*time.Hour {
+		t.Fatalf("unexpected MaxAge: %v", cfg.Backup.MaxAge)
+	}
+}
+
+func TestLoadParsesRateLimitSettings(t *testing.T) {
+	t.Setenv("NOTES_TOKEN", "token")
+	t.Setenv("NOTES_ROOT", "/tmp/notes")
+	t.Setenv("RATE_LIMIT_IP", "off")
+	t.Setenv("RATE_LIMIT_CLIENT", "")
+	t.Setenv("RATE_LIMIT_EXPENSIVE", "5")
+	t.Setenv("AUTH_LOCKOUT_FAILURES", "")
+	t.Setenv("AUTH_LOCKOUT_DURATION", "1h")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	want := RateLimitConfig{PerIP: 0, PerClient: 300, Expensive: 5, LockoutFailures: 10, LockoutDuration: time.Hour}
+	if cfg.RateLimit != want {
+		t.Fatalf("unexpected RateLimit: %+v", cfg.RateLimit)
+	}
+}
+
+func TestValidateTLSMode(t *testing.T) {
+	tests := []struct {
+		tls  TLSConfig
+		ok   bool
+		mode string
+	}{
+		{TLSConfig{}, true, TLSOff},
+		{TLSConfig{Mode: TLSLocalCA}, true, TLSLocalCA},
+		{TLSConfig{Mode: TLSFiles}, false, ""},
+		{TLSConfig{Mode: TLSFiles, CertFile: "cert.pem", KeyFile: "key.pem"}, true, TLSFiles},
+		{TLSConfig{Mode: "acme"}, false, ""},
+	}
+	for _, tt := range tests {
+		cfg := &Config{NotesToken: "token", NotesRoot: "/tmp/notes", TLS: tt.tls}
+		err := cfg.Validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("Validate(%+v) = %v, want ok=%v", tt.tls, err, tt.ok)
+		}
+		if err == nil && cfg.TLS.Mode != tt.mode {
+			t.Errorf("mode = %q, want %q", cfg.TLS.Mode, tt.mode)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+
+	"notes-editor/internal/fileutil"
+)
+
+// envLineKey returns the variable assigned on a .env line, or "" for comments,
+// blank lines and anything else.
+func envLineKey(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return ""
+	}
+	trimmed = strings.TrimPrefix(trimmed, "export ")
+	key, _, ok := strings.Cut(trimmed, "=")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(key)
+}
+
+// formatEnvValue quotes values that godotenv would otherwise misread.
+func formatEnvValue(value string) string {
+	if value == "" || !strings.ContainsAny(value, " \t#\"'\\\n$") {
+		return value
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(value) + `"`
+}
+
+// updateEnvFile sets or removes variables in the .env file at path, keeping
+// comments, order and unrelated lines. A nil value removes the variable; new
+// variables are appended.
+func updateEnvFile(path string, changes map[string]*string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	content := string(raw)
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	written := map[string]bool{}
+	out := make([]string, 0, len(lines)+len(changes))
+	for _, line := range lines {
+		key := envLineKey(line)
+		value, changed := changes[key]
+		if key == "" || !changed {
+			out = append(out, line)
+			continue
+		}
+		// Drop removed variables and duplicate assignments.
+		if value == nil || written[key] {
+			continue
+		}
+		out = append(out, key+"="+formatEnvValue(*value))
+		written[key] = true
+	}
+	var added []string
+	for key, value := range changes {
+		if value != nil && !written[key] {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+	for _, key := range added {
+		out = append(out, key+"="+formatEnvValue(*changes[key]))
+	}
+
+	return fileutil.WriteAtomic(path, []byte(strings.Join(out, "\n")+"\n"), 0600)
+}
+
+// SecretMask replaces secret values in the .env contents returned to clients.
+// Saving a line with the mask keeps the stored secret.
+const SecretMask = "********"
+
+// MaskedEnvFile returns the .env contents with secret values masked, followed by
+// a masked line for each secret in the encrypted store.
+func (c *Config) MaskedEnvFile() (string, error) {
+	raw, err := os.ReadFile(c.envPath())
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	secrets, err := c.SecretStore().Load()
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(raw), "\n")
+	for i, line := range lines {
+		key := envLineKey(line)
+		if key != "" && IsSecret(key) && envLineValue(line) != "" {
+			lines[i] = key + "=" + SecretMask
+		}
+	}
+	masked := strings.Join(lines, "\n")
+
+	stored := make([]string, 0, len(secrets))
+	for key := range secrets {
+		stored = append(stored, key)
+	}
+	sort.Strings(stored)
+	if len(stored) > 0 && masked != "" && !strings.HasSuffix(masked, "\n") {
+		masked += "\n"
+	}
+	for _, key := range stored {
+		masked += key + "=" + SecretMask + "\n"
+	}
+	return masked, nil
+}
+
+// SaveEnvFile replaces the .env contents as edited by a client. Masked secrets
+// keep their stored value. Secrets that belong in the encrypted store are moved
+// there instead of being written to .env, and stored secrets missing from content
+// are deleted.
+func (c *Config) SaveEnvFile(content string) error {
+	current, err := readEnvFile(c.envPath())
+	if err != nil {
+		return err
+	}
+
+	secretChanges := map[string]string{}
+	seen := map[string]bool{}
+	lines := strings.Split(content, "\n")
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		key := envLineKey(line)
+		s, ok := LookupSetting(key)
+		if key == "" || !ok || !s.Secret {
+			out = append(out, line)
+			continue
+		}
+		value := envLineValue(line)
+		masked := value == SecretMask
+		seen[key] = true
+		switch {
+		case s.encrypted() && !masked:
+			secretChanges[key] = value
+		case s.encrypted():
+			// Keep the stored secret.
+		case masked:
+			out = append(out, key+"="+formatEnvValue(current[key]))
+		default:
+			out = append(out, line)
+		}
+	}
+
+	store := c.SecretStore()
+	secrets, err := store.Load()
+	if err != nil {
+		return err
+	}
+	var removed []string
+	for key := range secrets {
+		if !seen[key] {
+			removed = append(removed, key)
+		}
+	}
+	if len(secretChanges) > 0 || len(removed) > 0 {
+		for key, value := range secretChanges {
+			if value == "" {
+				removed = append(removed, key)
+			} else {
+				secrets[key] = value
+			}
+		}
+		for _, key := range removed {
+			delete(secrets, key)
+		}
+		if err := store.Save(secrets); err != nil {
+			return err
+		}
+	}
+	if err := fileutil.WriteAtomic(c.envPath(), []byte(strings.Join(out, "\n")), 0600); err != nil {
+		return err
+	}
+	// Reloading re-exports the stored secrets but cannot tell which were removed.
+	for _, key := range removed {
+		os.Unsetenv(key)
+	}
+	return nil
+}
+
+// envLineValue returns the unquoted value assigned on a .env line.
+func envLineValue(line string) string {
+	key := envLineKey(line)
+	if key == "" {
+		return ""
+	}
+	values, err := godotenv.Unmarshal(strings.TrimSpace(line))
+	if err != nil {
+		_, value, _ := strings.Cut(line, "=")
+		return strings.TrimSpace(value)
+	}
+	return values[key]
+}
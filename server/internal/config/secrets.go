@@ -0,0 +1,205 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"notes-editor/internal/fileutil"
+)
+
+// secretsKeyEnv holds a base64-encoded 32-byte key for the secrets store.
+// secretsKeyFileEnv names a file holding the same, which must not live next to the
+// store or in the vault: a backup or copy of the data directory would otherwise
+// carry the key along with the secrets.
+const (
+	secretsKeyEnv     = "SECRETS_KEY"
+	secretsKeyFileEnv = "SECRETS_KEY_FILE"
+)
+
+// secretsFileVersion is the current on-disk format of the secrets store.
+const secretsFileVersion = 1
+
+// ErrSecretsKey is returned when the secrets store cannot be decrypted with the
+// configured key.
+var ErrSecretsKey = errors.New("secrets store cannot be decrypted with the configured key")
+
+// SecretStore keeps secret settings encrypted with AES-256-GCM outside the
+// editable .env file.
+type SecretStore struct {
+	path    string
+	keyPath string
+}
+
+type secretsFile struct {
+	Version int    `json:"version"`
+	Nonce   string `json:"nonce"`
+	Data    string `json:"data"`
+}
+
+// NewSecretStore returns the store at path. The key comes from SECRETS_KEY or,
+// if unset, from keyPath, which must be outside the store's directory.
+func NewSecretStore(path, keyPath string) *SecretStore {
+	return &SecretStore{path: path, keyPath: keyPath}
+}
+
+// Load decrypts all secrets. A missing store is empty.
+func (s *SecretStore) Load() (map[string]string, error) {
+	raw, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file secretsFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("secrets store: %w", err)
+	}
+	if file.Version != secretsFileVersion {
+		return nil, fmt.Errorf("secrets store: unsupported version %d", file.Version)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(file.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("secrets store: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(file.Data)
+	if err != nil {
+		return nil, fmt.Errorf("secrets store: %w", err)
+	}
+
+	gcm, err := s.cipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("secrets store: invalid nonce")
+	}
+	plain, err := gcm.Open(nil, nonce, data, nil)
+	if err != nil {
+		return nil, ErrSecretsKey
+	}
+
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("secrets store: %w", err)
+	}
+	return secrets, nil
+}
+
+// Save encrypts and replaces all secrets.
+func (s *SecretStore) Save(secrets map[string]string) error {
+	gcm, err := s.cipher()
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(secretsFile{
+		Version: secretsFileVersion,
+		Nonce:   base64.StdEncoding.EncodeToString(nonce),
+		Data:    base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plain, nil)),
+	})
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteAtomic(s.path, raw, 0600)
+}
+
+// Set stores one secret.
+func (s *SecretStore) Set(key, value string) error {
+	secrets, err := s.Load()
+	if err != nil {
+		return err
+	}
+	secrets[key] = value
+	return s.Save(secrets)
+}
+
+// Delete removes one secret. Deleting a missing secret is not an error.
+func (s *SecretStore) Delete(key string) error {
+	secrets, err := s.Load()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[key]; !ok {
+		return nil
+	}
+	delete(secrets, key)
+	return s.Save(secrets)
+}
+
+// cipher builds the AEAD from SECRETS_KEY or the key file.
+func (s *SecretStore) cipher() (cipher.AEAD, error) {
+	key, err := s.key()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *SecretStore) key() ([]byte, error) {
+	encoded := strings.TrimSpace(os.Getenv(secretsKeyEnv))
+	source := secretsKeyEnv
+	if encoded == "" {
+		if err := s.checkKeyPath(); err != nil {
+			return nil, err
+		}
+		raw, err := os.ReadFile(s.keyPath)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("secrets key file %s not found", s.keyPath)
+		}
+		if err != nil {
+			return nil, err
+		}
+		encoded = strings.TrimSpace(string(raw))
+		source = s.keyPath
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s must hold a base64-encoded 32-byte key", source)
+	}
+	return key, nil
+}
+
+// checkKeyPath rejects a missing key location and key files in or below the
+// directory of the store, which also holds .env and the vault.
+func (s *SecretStore) checkKeyPath() error {
+	dir, err := filepath.Abs(filepath.Dir(s.path))
+	if err != nil {
+		return err
+	}
+	if s.keyPath == "" {
+		legacy := filepath.Join(dir, "secrets.key")
+		if _, err := os.Stat(legacy); err == nil {
+			return fmt.Errorf("move %s out of %s and set %s to its new path", legacy, dir, secretsKeyFileEnv)
+		}
+		return fmt.Errorf("no secrets key: set %s or %s", secretsKeyEnv, secretsKeyFileEnv)
+	}
+	keyPath, err := filepath.Abs(s.keyPath)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(dir, keyPath); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s must be outside %s, which holds the secrets store", secretsKeyFileEnv, dir)
+	}
+	return nil
+}
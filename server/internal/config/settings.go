@@ -0,0 +1,388 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SettingType is the value type of a setting, used for validation.
+type SettingType string
+
+const (
+	SettingString SettingType = "string"
+	SettingBool   SettingType = "bool"
+	SettingInt    SettingType = "int"
+	// SettingDuration is a Go duration such as "45m".
+	SettingDuration SettingType = "duration"
+	SettingURL      SettingType = "url"
+	// SettingList is a comma-separated list.
+	SettingList SettingType = "list"
+)
+
+// Reload is a set of components that must be rebuilt after settings change.
+type Reload uint
+
+const (
+	// ReloadRuntime rebuilds the Claude, agent and LinkedIn services.
+	ReloadRuntime Reload = 1 << iota
+	// ReloadBackup reschedules backups.
+	ReloadBackup
+	// ReloadPersons replaces the valid persons.
+	ReloadPersons
+	// ReloadSharedFolders replaces the shared folder grants.
+	ReloadSharedFolders
+	// ReloadEncryption replaces the encrypted folders and unlock timeout.
+	ReloadEncryption
+	// ReloadRestart marks settings that take effect after a restart.
+	ReloadRestart
+)
+
+// Has reports whether r includes all of other.
+func (r Reload) Has(other Reload) bool {
+	return r&other == other
+}
+
+// Setting describes one configurable environment variable.
+type Setting struct {
+	Key         string      `json:"key"`
+	Type        SettingType `json:"type"`
+	Description string      `json:"description"`
+	// Secret settings are write-only: reads only report whether they are set.
+	// They live in the encrypted secrets store, not in .env, unless EnvFile is set.
+	Secret bool `json:"secret"`
+	// EnvFile keeps a secret in .env because other processes read it from there.
+	EnvFile bool `json:"-"`
+	// AllowOff accepts "0" and "off" for int and duration settings.
+	AllowOff bool   `json:"-"`
+	Reload   Reload `json:"-"`
+}
+
+// encrypted reports whether the setting is kept in the secrets store.
+func (s Setting) encrypted() bool {
+	return s.Secret && !s.EnvFile
+}
+
+// Settings lists every setting the settings API can read and change.
+var Settings = []Setting{
+	// The pi-gateway reads NOTES_TOKEN from .env, so it stays there.
+	{Key: "NOTES_TOKEN", Type: SettingString, Secret: true, EnvFile: true, Reload: ReloadRestart, Description: "Admin API token"},
+	{Key: "VALID_PERSONS", Type: SettingList, Reload: ReloadPersons, Description: "Persons with a vault"},
+	{Key: "SHARED_FOLDERS", Type: SettingString, Reload: ReloadSharedFolders, Description: "Folders under _shared/ and who may use them"},
+	{Key: "ENCRYPTED_FOLDERS", Type: SettingString, Reload: ReloadEncryption, Description: "Each person's encrypted folders (:agent allows agent tools)"},
+	{Key: "ENCRYPTION_IDLE_TIMEOUT", Type: SettingDuration, Reload: ReloadEncryption, Description: "Lock encrypted folders after this long without use"},
+	{Key: "ANTHROPIC_API_KEY", Type: SettingString, Secret: true, Reload: ReloadRuntime, Description: "Anthropic API key for Claude"},
+	{Key: "CLAUDE_MODEL", Type: SettingString, Reload: ReloadRuntime, Description: "Claude model name"},
+	{Key: "PI_GATEWAY_URL", Type: SettingURL, Reload: ReloadRuntime, Description: "Pi gateway sidecar URL"},
+	{Key: "AGENT_ENABLE_PI_FALLBACK", Type: SettingBool, Reload: ReloadRuntime, Description: "Fall back to the API key runtime when the gateway is down"},
+	{Key: "AGENT_MAX_RUN_DURATION", Type: SettingDuration, Reload: ReloadRuntime, Description: "Maximum duration of one agent run"},
+	{Key: "AGENT_MAX_TOOL_CALLS_PER_RUN", Type: SettingInt, Reload: ReloadRuntime, Description: "Maximum tool calls in one agent run"},
+	{Key: "BASH_SANDBOX", Type: SettingString, Reload: ReloadRuntime, Description: "run_bash sandbox mode per person: off, on or network"},
+	{Key: "BASH_SANDBOX_MEMORY_MB", Type: SettingInt, Reload: ReloadRuntime, Description: "Memory limit of sandboxed bash commands"},
+	{Key: "BASH_SANDBOX_CPU_SECONDS", Type: SettingInt, Reload: ReloadRuntime, Description: "CPU time limit of sandboxed bash commands"},
+	{Key: "AGENT_TOOL_APPROVAL_TIMEOUT", Type: SettingDuration, Reload: ReloadRuntime, Description: "How long a tool call waits for approval before the run is aborted"},
+	{Key: "SLEEP_CHILDREN", Type: SettingString, Reload: ReloadRuntime, Description: "Children whose sleep each person's agent may see and log"},
+	{Key: "LINKEDIN_CLIENT_ID", Type: SettingString, Reload: ReloadRuntime, Description: "LinkedIn OAuth client ID"},
+	{Key: "LINKEDIN_CLIENT_SECRET", Type: SettingString, Secret: true, Reload: ReloadRuntime, Description: "LinkedIn OAuth client secret"},
+	{Key: "LINKEDIN_REDIRECT_URI", Type: SettingURL, Reload: ReloadRuntime, Description: "LinkedIn OAuth redirect URI"},
+	{Key: "LINKEDIN_ACCESS_TOKEN", Type: SettingString, Secret: true, Reload: ReloadRuntime, Description: "LinkedIn access token"},
+	{Key: "BACKUP_DIR", Type: SettingString, Reload: ReloadBackup, Description: "Directory for encrypted backups"},
+	{Key: "BACKUP_PASSPHRASE", Type: SettingString, Secret: true, Reload: ReloadBackup, Description: "Backup encryption passphrase"},
+	{Key: "BACKUP_INTERVAL", Type: SettingDuration, AllowOff: true, Reload: ReloadBackup, Description: "Interval between scheduled backups"},
+	{Key: "BACKUP_KEEP", Type: SettingInt, Reload: ReloadBackup, Description: "Number of backups to keep"},
+	{Key: "BACKUP_MAX_AGE", Type: SettingDuration, AllowOff: true, Reload: ReloadBackup, Description: "Remove backups older than this"},
+	{Key: "SESSION_TTL", Type: SettingDuration, Reload: ReloadRestart, Description: "Lifetime of browser login sessions"},
+	{Key: "WEBAUTHN_RP_ID", Type: SettingString, Reload: ReloadRestart, Description: "Passkey relying party ID"},
+	{Key: "WEBAUTHN_RP_NAME", Type: SettingString, Reload: ReloadRestart, Description: "Passkey relying party name"},
+	{Key: "WEBAUTHN_ORIGINS", Type: SettingList, Reload: ReloadRestart, Description: "Origins allowed to use passkeys"},
+	{Key: "RATE_LIMIT_IP", Type: SettingInt, AllowOff: true, Reload: ReloadRestart, Description: "Requests per minute from one IP address"},
+	{Key: "RATE_LIMIT_CLIENT", Type: SettingInt, AllowOff: true, Reload: ReloadRestart, Description: "Requests per minute from one token or login session"},
+	{Key: "RATE_LIMIT_EXPENSIVE", Type: SettingInt, AllowOff: true, Reload: ReloadRestart, Description: "Requests per minute to chat, sync and backup downloads from one client"},
+	{Key: "AUTH_LOCKOUT_FAILURES", Type: SettingInt, AllowOff: true, Reload: ReloadRestart, Description: "Failed logins from one IP address before it is locked out"},
+	{Key: "AUTH_LOCKOUT_DURATION", Type: SettingDuration, Reload: ReloadRestart, Description: "How long an IP address stays locked out"},
+	{Key: "CORS_ALLOWED_ORIGINS", Type: SettingList, Reload: ReloadRestart, Description: "Other origins allowed to call the API with cookies"},
+	{Key: "CORS_STRICT", Type: SettingBool, Reload: ReloadRestart, Description: "Reject cookie requests and logins from unexpected origins"},
+	{Key: "CONTENT_SECURITY_POLICY", Type: SettingString, Reload: ReloadRestart, Description: "Content-Security-Policy of the web UI (off disables it)"},
+	{Key: "FRAME_ANCESTORS", Type: SettingList, Reload: ReloadRestart, Description: "Sites allowed to embed the web UI"},
+	{Key: "HSTS_MAX_AGE", Type: SettingDuration, AllowOff: true, Reload: ReloadRestart, Description: "Strict-Transport-Security max age over HTTPS"},
+	{Key: "TLS_MODE", Type: SettingString, Reload: ReloadRestart, Description: "HTTPS: off, files or local-ca"},
+	{Key: "TLS_ADDR", Type: SettingString, Reload: ReloadRestart, Description: "HTTPS listen address"},
+	{Key: "TLS_CERT_FILE", Type: SettingString, Reload: ReloadRestart, Description: "Certificate file for TLS_MODE=files"},
+	{Key: "TLS_KEY_FILE", Type: SettingString, Reload: ReloadRestart, Description: "Private key file for TLS_MODE=files"},
+	{Key: "TLS_HOSTS", Type: SettingList, Reload: ReloadRestart, Description: "Host names and IP addresses of the local CA certificate"},
+	{Key: "TLS_REDIRECT_HTTP", Type: SettingBool, Reload: ReloadRestart, Description: "Redirect plain HTTP to HTTPS"},
+	{Key: "AUDIT_RETENTION", Type: SettingDuration, Reload: ReloadRestart, Description: "How long audit log entries are kept"},
+	{Key: "STATIC_DIR", Type: SettingString, Reload: ReloadRestart, Description: "Web UI directory"},
+	{Key: "SERVER_ADDR", Type: SettingString, Reload: ReloadRestart, Description: "HTTP listen address"},
+}
+
+// LookupSetting returns the setting for key.
+func LookupSetting(key string) (Setting, bool) {
+	for _, s := range Settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// IsSecret reports whether key names a secret setting, whose value must never be
+// returned.
+func IsSecret(key string) bool {
+	s, ok := LookupSetting(key)
+	return ok && s.Secret
+}
+
+// Validate checks value against the setting's type. Empty values are always
+// valid and restore the default.
+func (s Setting) Validate(value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	if s.AllowOff && (value == "0" || strings.EqualFold(value, "off")) {
+		return nil
+	}
+	switch s.Type {
+	case SettingBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s must be true or false", s.Key)
+		}
+	case SettingInt:
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			return fmt.Errorf("%s must be a positive integer", s.Key)
+		}
+	case SettingDuration:
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("%s must be a positive duration such as 24h", s.Key)
+		}
+	case SettingURL:
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%s must be an absolute URL", s.Key)
+		}
+	}
+	if strings.ContainsAny(value, "\n\r") {
+		return fmt.Errorf("%s must be a single line", s.Key)
+	}
+	return nil
+}
+
+// SettingValue is a setting with its current value. Secrets are never returned;
+// IsSet tells whether one is configured.
+type SettingValue struct {
+	Setting
+	Value string `json:"value"`
+	IsSet bool   `json:"is_set"`
+	// Source is "environment", "secrets", "env_file" or "" when unset.
+	Source string `json:"source"`
+	// RestartRequired is true for settings that only apply after a restart.
+	RestartRequired bool `json:"restart_required"`
+}
+
+// SecretsPath returns the encrypted secrets store, next to .env.
+func (c *Config) SecretsPath() string {
+	return c.sidecarPath("secrets.enc")
+}
+
+// SecretsKeyPath returns the secrets key file from SECRETS_KEY_FILE, used when
+// SECRETS_KEY is not set. It is empty when neither is configured.
+func (c *Config) SecretsKeyPath() string {
+	return strings.TrimSpace(os.Getenv(secretsKeyFileEnv))
+}
+
+// SecretStore returns the encrypted secrets store.
+func (c *Config) SecretStore() *SecretStore {
+	return NewSecretStore(c.SecretsPath(), c.SecretsKeyPath())
+}
+
+// SettingValues returns every setting with its current value, secrets masked.
+func (c *Config) SettingValues() ([]SettingValue, error) {
+	fileValues, err := readEnvFile(c.envPath())
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := c.SecretStore().Load()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]SettingValue, 0, len(Settings))
+	for _, s := range Settings {
+		v := SettingValue{Setting: s, RestartRequired: s.Reload.Has(ReloadRestart)}
+		value, inEnv := os.LookupEnv(s.Key)
+		v.IsSet = inEnv && value != ""
+		switch {
+		case !v.IsSet:
+		case s.encrypted() && secrets[s.Key] != "":
+			v.Source = "secrets"
+		case fileValues[s.Key] == value:
+			v.Source = "env_file"
+		default:
+			v.Source = "environment"
+		}
+		if !s.Secret {
+			v.Value = value
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// UpdateSettings validates and applies changes: a nil value clears a setting.
+// Secrets go to the encrypted store and are removed from .env; other settings
+// are written to .env. The process environment and the affected Config fields
+// are updated in place, and the returned Reload tells the caller which
+// components to rebuild.
+func (c *Config) UpdateSettings(changes map[string]*string) (Reload, error) {
+	var reload Reload
+	values := map[string]*string{}
+	envChanges := map[string]*string{}
+	secretChanges := map[string]*string{}
+	for key, value := range changes {
+		s, ok := LookupSetting(key)
+		if !ok {
+			return 0, fmt.Errorf("unknown setting %s", key)
+		}
+		if value != nil {
+			if err := s.Validate(*value); err != nil {
+				return 0, err
+			}
+			trimmed := strings.TrimSpace(*value)
+			value = &trimmed
+			if trimmed == "" {
+				value = nil
+			}
+		}
+		if key == "NOTES_TOKEN" && value == nil {
+			return 0, fmt.Errorf("NOTES_TOKEN cannot be cleared")
+		}
+		values[key] = value
+		if s.encrypted() {
+			secretChanges[key] = value
+			// Secrets never stay in .env.
+			envChanges[key] = nil
+		} else {
+			envChanges[key] = value
+		}
+		reload |= s.Reload
+	}
+
+	if len(secretChanges) > 0 {
+		store := c.SecretStore()
+		secrets, err := store.Load()
+		if err != nil {
+			return 0, err
+		}
+		for key, value := range secretChanges {
+			if value == nil {
+				delete(secrets, key)
+			} else {
+				secrets[key] = *value
+			}
+		}
+		if err := store.Save(secrets); err != nil {
+			return 0, err
+		}
+	}
+	if err := updateEnvFile(c.envPath(), envChanges); err != nil {
+		return 0, err
+	}
+
+	for key, value := range values {
+		if value == nil {
+			os.Unsetenv(key)
+		} else {
+			os.Setenv(key, *value)
+		}
+	}
+	c.applyReload(reload)
+	return reload, nil
+}
+
+// applyReload re-reads the Config fields belonging to the reloaded components
+// from the environment.
+func (c *Config) applyReload(reload Reload) {
+	if reload.Has(ReloadRuntime) {
+		c.loadRuntime()
+	}
+	if reload.Has(ReloadBackup) {
+		c.Backup = loadBackupConfig()
+	}
+	if reload.Has(ReloadPersons) {
+		c.loadPersons()
+	}
+	if reload.Has(ReloadSharedFolders) {
+		c.SharedFolders = strings.TrimSpace(os.Getenv("SHARED_FOLDERS"))
+	}
+	if reload.Has(ReloadEncryption) {
+		c.loadEncryption()
+	}
+}
+
+// MigrateSecrets moves secrets that are still in plaintext in .env into the
+// encrypted store and returns the keys it moved.
+func (c *Config) MigrateSecrets() ([]string, error) {
+	fileValues, err := readEnvFile(c.envPath())
+	if err != nil {
+		return nil, err
+	}
+	store := c.SecretStore()
+	secrets, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var moved []string
+	envChanges := map[string]*string{}
+	for _, s := range Settings {
+		value, ok := fileValues[s.Key]
+		if !s.encrypted() || !ok {
+			continue
+		}
+		if value != "" {
+			secrets[s.Key] = value
+		}
+		envChanges[s.Key] = nil
+		moved = append(moved, s.Key)
+	}
+	if len(moved) == 0 {
+		return nil, nil
+	}
+	if err := store.Save(secrets); err != nil {
+		return nil, err
+	}
+	if err := updateEnvFile(c.envPath(), envChanges); err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+// applySecrets exports decrypted secrets to the process environment, except
+// for variables in preset, which were set by the real environment and win.
+func applySecrets(store *SecretStore, preset map[string]bool) error {
+	secrets, err := store.Load()
+	if err != nil {
+		return err
+	}
+	for key, value := range secrets {
+		if !preset[key] {
+			os.Setenv(key, value)
+		}
+	}
+	return nil
+}
+
+// presetSecrets records which secret variables the process environment already
+// sets before .env is loaded.
+func presetSecrets() map[string]bool {
+	preset := map[string]bool{}
+	for _, s := range Settings {
+		if _, ok := os.LookupEnv(s.Key); ok && s.encrypted() {
+			preset[s.Key] = true
+		}
+	}
+	return preset
+}
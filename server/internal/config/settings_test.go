@@ -0,0 +1,309 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newSettingsConfig(t *testing.T, envFile string) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	key := make([]byte, 32)
+	rand.Read(key)
+	keyPath := filepath.Join(t.TempDir(), "secrets.key")
+	if err := os.WriteFile(keyPath, []byte(base64.StdEncoding.EncodeToString(key)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECRETS_KEY", "")
+	t.Setenv("SECRETS_KEY_FILE", keyPath)
+	if envFile != "" {
+		if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(envFile), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Config{NotesRoot: filepath.Join(dir, "vault")}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestSecretStoreRoundTrip(t *testing.T) {
+	cfg := newSettingsConfig(t, "")
+	store := cfg.SecretStore()
+
+	if err := store.Set("ANTHROPIC_API_KEY", "sk-test"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if strings.Contains(readFile(t, cfg.SecretsPath()), "sk-test") {
+		t.Fatal("secret stored in plaintext")
+	}
+
+	secrets, err := store.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if secrets["ANTHROPIC_API_KEY"] != "sk-test" {
+		t.Fatalf("secrets = %v", secrets)
+	}
+
+	if err := store.Delete("ANTHROPIC_API_KEY"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if secrets, _ := store.Load(); len(secrets) != 0 {
+		t.Fatalf("secrets after delete = %v", secrets)
+	}
+}
+
+func TestSecretStoreWrongKey(t *testing.T) {
+	cfg := newSettingsConfig(t, "")
+	if err := cfg.SecretStore().Set("BACKUP_PASSPHRASE", "hunter2"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	t.Setenv("SECRETS_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if _, err := cfg.SecretStore().Load(); !errors.Is(err, ErrSecretsKey) {
+		t.Fatalf("load with wrong key: %v", err)
+	}
+
+	t.Setenv("SECRETS_KEY", "short")
+	if _, err := cfg.SecretStore().Load(); err == nil {
+		t.Fatal("expected invalid key error")
+	}
+}
+
+func TestSecretStoreKeyLocation(t *testing.T) {
+	cfg := newSettingsConfig(t, "")
+
+	t.Setenv("SECRETS_KEY_FILE", "")
+	if err := cfg.SecretStore().Set("BACKUP_PASSPHRASE", "hunter2"); err == nil {
+		t.Fatal("expected missing key error")
+	}
+
+	nextToStore := filepath.Join(filepath.Dir(cfg.SecretsPath()), "secrets.key")
+	if err := os.WriteFile(nextToStore, []byte(base64.StdEncoding.EncodeToString(make([]byte, 32))), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SecretStore().Set("BACKUP_PASSPHRASE", "hunter2"); err == nil || !strings.Contains(err.Error(), "move") {
+		t.Fatalf("legacy key file err = %v", err)
+	}
+	for _, keyPath := range []string{nextToStore, filepath.Join(cfg.NotesRoot, "secrets.key")} {
+		t.Setenv("SECRETS_KEY_FILE", keyPath)
+		if err := cfg.SecretStore().Set("BACKUP_PASSPHRASE", "hunter2"); err == nil {
+			t.Errorf("key file %s accepted", keyPath)
+		}
+	}
+	if _, err := os.Stat(cfg.SecretsPath()); !os.IsNotExist(err) {
+		t.Fatalf("secrets store written without a usable key: %v", err)
+	}
+}
+
+func TestUpdateEnvFileKeepsLayout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	original := "# Notes\nNOTES_TOKEN=abc\n\nCLAUDE_MODEL=old\nCLAUDE_MODEL=dup\nBACKUP_DIR=/backups\n"
+	if err := os.WriteFile(path, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	model := "claude-new"
+	name := "Notes Editor"
+	err := updateEnvFile(path, map[string]*string{
+		"CLAUDE_MODEL":     &model,
+		"BACKUP_DIR":       nil,
+		"WEBAUTHN_RP_NAME": &name,
+	})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	want := "# Notes\nNOTES_TOKEN=abc\n\nCLAUDE_MODEL=claude-new\nWEBAUTHN_RP_NAME=\"Notes Editor\"\n"
+	if got := readFile(t, path); got != want {
+		t.Fatalf("env file =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSettingValidate(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		ok    bool
+	}{
+		{"AGENT_ENABLE_PI_FALLBACK", "true", true},
+		{"AGENT_ENABLE_PI_FALLBACK", "maybe", false},
+		{"AGENT_MAX_TOOL_CALLS_PER_RUN", "12", true},
+		{"AGENT_MAX_TOOL_CALLS_PER_RUN", "twelve", false},
+		{"AGENT_MAX_RUN_DURATION", "90s", true},
+		{"AGENT_MAX_RUN_DURATION", "off", false},
+		{"BACKUP_INTERVAL", "off", true},
+		{"PI_GATEWAY_URL", "http://127.0.0.1:4301", true},
+		{"PI_GATEWAY_URL", "localhost", false},
+		{"CLAUDE_MODEL", "", true},
+	}
+	for _, tt := range tests {
+		s, _ := LookupSetting(tt.key)
+		if err := s.Validate(tt.value); (err == nil) != tt.ok {
+			t.Errorf("Validate(%s=%q) = %v, want ok=%v", tt.key, tt.value, err, tt.ok)
+		}
+	}
+}
+
+func TestUpdateSettingsStoresSecretsEncrypted(t *testing.T) {
+	cfg := newSettingsConfig(t, "NOTES_TOKEN=abc\nANTHROPIC_API_KEY=old-key\n")
+	t.Setenv("ANTHROPIC_API_KEY", "old-key")
+	t.Setenv("CLAUDE_MODEL", "")
+	t.Setenv("BACKUP_KEEP", "")
+
+	key := "sk-new"
+	model := "claude-custom"
+	keep := "3"
+	reload, err := cfg.UpdateSettings(map[string]*string{
+		"ANTHROPIC_API_KEY": &key,
+		"CLAUDE_MODEL":      &model,
+		"BACKUP_KEEP":       &keep,
+	})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if !reload.Has(ReloadRuntime|ReloadBackup) || reload.Has(ReloadRestart) {
+		t.Fatalf("reload = %b", reload)
+	}
+	if cfg.AnthropicKey != "sk-new" || cfg.ClaudeModel != "claude-custom" || cfg.Backup.Keep != 3 {
+		t.Fatalf("config not reloaded: key=%q model=%q keep=%d", cfg.AnthropicKey, cfg.ClaudeModel, cfg.Backup.Keep)
+	}
+
+	envFile := readFile(t, cfg.EnvPath())
+	if strings.Contains(envFile, "ANTHROPIC_API_KEY") {
+		t.Fatalf("secret left in .env:\n%s", envFile)
+	}
+	if !strings.Contains(envFile, "CLAUDE_MODEL=claude-custom") {
+		t.Fatalf("setting not written to .env:\n%s", envFile)
+	}
+	secrets, err := cfg.SecretStore().Load()
+	if err != nil || secrets["ANTHROPIC_API_KEY"] != "sk-new" {
+		t.Fatalf("secrets = %v, %v", secrets, err)
+	}
+
+	values, err := cfg.SettingValues()
+	if err != nil {
+		t.Fatalf("values: %v", err)
+	}
+	for _, v := range values {
+		if v.Key == "ANTHROPIC_API_KEY" && (v.Value != "" || !v.IsSet || v.Source != "secrets") {
+			t.Fatalf("secret value exposed or misreported: %+v", v)
+		}
+	}
+}
+
+func TestUpdateSettingsRejectsInvalidValues(t *testing.T) {
+	cfg := newSettingsConfig(t, "NOTES_TOKEN=abc\n")
+	bad := "soon"
+	tests := map[string]*string{
+		"AGENT_MAX_RUN_DURATION": &bad,
+		"NOT_A_SETTING":          &bad,
+		"NOTES_TOKEN":            nil,
+	}
+	for key, value := range tests {
+		if _, err := cfg.UpdateSettings(map[string]*string{key: value}); err == nil {
+			t.Errorf("expected error for %s", key)
+		}
+	}
+	if got := readFile(t, cfg.EnvPath()); got != "NOTES_TOKEN=abc\n" {
+		t.Fatalf("env file changed: %q", got)
+	}
+}
+
+func TestMaskedEnvFileRoundTrip(t *testing.T) {
+	cfg := newSettingsConfig(t, "NOTES_TOKEN=abc\nCLAUDE_MODEL=claude\n")
+
+	masked, err := cfg.MaskedEnvFile()
+	if err != nil {
+		t.Fatalf("masked: %v", err)
+	}
+	if strings.Contains(masked, "abc") || !strings.Contains(masked, "NOTES_TOKEN="+SecretMask) {
+		t.Fatalf("token not masked:\n%s", masked)
+	}
+
+	edited := strings.Replace(masked, "CLAUDE_MODEL=claude", "CLAUDE_MODEL=other", 1) + "LINKEDIN_CLIENT_SECRET=li-secret\n"
+	if err := cfg.SaveEnvFile(edited); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	envFile := readFile(t, cfg.EnvPath())
+	if !strings.Contains(envFile, "NOTES_TOKEN=abc") || !strings.Contains(envFile, "CLAUDE_MODEL=other") {
+		t.Fatalf("env file not saved:\n%s", envFile)
+	}
+	if strings.Contains(envFile, "li-secret") {
+		t.Fatalf("secret written to .env:\n%s", envFile)
+	}
+	secrets, err := cfg.SecretStore().Load()
+	if err != nil || secrets["LINKEDIN_CLIENT_SECRET"] != "li-secret" {
+		t.Fatalf("secrets = %v, %v", secrets, err)
+	}
+}
+
+func TestSaveEnvFileRemovesDeletedSecrets(t *testing.T) {
+	cfg := newSettingsConfig(t, "NOTES_TOKEN=abc\n")
+	store := cfg.SecretStore()
+	if err := store.Save(map[string]string{"ANTHROPIC_API_KEY": "sk-test", "BACKUP_PASSPHRASE": "hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ANTHROPIC_API_KEY", "sk-test")
+
+	masked, err := cfg.MaskedEnvFile()
+	if err != nil {
+		t.Fatalf("masked: %v", err)
+	}
+	if want := "NOTES_TOKEN=" + SecretMask + "\nANTHROPIC_API_KEY=" + SecretMask + "\nBACKUP_PASSPHRASE=" + SecretMask + "\n"; masked != want {
+		t.Fatalf("masked = %q, want %q", masked, want)
+	}
+
+	if err := cfg.SaveEnvFile(strings.Replace(masked, "ANTHROPIC_API_KEY="+SecretMask+"\n", "", 1)); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	secrets, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := secrets["ANTHROPIC_API_KEY"]; ok || secrets["BACKUP_PASSPHRASE"] != "hunter2" {
+		t.Fatalf("secrets = %v", secrets)
+	}
+	if _, ok := os.LookupEnv("ANTHROPIC_API_KEY"); ok {
+		t.Fatal("deleted secret still in the environment")
+	}
+	if got := readFile(t, cfg.EnvPath()); got != "NOTES_TOKEN=abc\n" {
+		t.Fatalf("env file = %q", got)
+	}
+}
+
+func TestMigrateSecrets(t *testing.T) {
+	cfg := newSettingsConfig(t, "NOTES_TOKEN=abc\nANTHROPIC_API_KEY=sk-old\nBACKUP_PASSPHRASE=\"pass phrase\"\nCLAUDE_MODEL=claude\n")
+
+	moved, err := cfg.MigrateSecrets()
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if strings.Join(moved, ",") != "ANTHROPIC_API_KEY,BACKUP_PASSPHRASE" {
+		t.Fatalf("moved = %v", moved)
+	}
+	if got := readFile(t, cfg.EnvPath()); got != "NOTES_TOKEN=abc\nCLAUDE_MODEL=claude\n" {
+		t.Fatalf("env file = %q", got)
+	}
+	secrets, err := cfg.SecretStore().Load()
+	if err != nil || secrets["BACKUP_PASSPHRASE"] != "pass phrase" || secrets["ANTHROPIC_API_KEY"] != "sk-old" {
+		t.Fatalf("secrets = %v, %v", secrets, err)
+	}
+
+	if moved, err := cfg.MigrateSecrets(); err != nil || len(moved) != 0 {
+		t.Fatalf("second migrate = %v, %v", moved, err)
+	}
+}
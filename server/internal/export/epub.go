@@ -0,0 +1,173 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+
+	"github.com/google/uuid"
+)
+
+type epubChapter struct {
+	ID    string
+	Href  string
+	Title string
+	Body  template.HTML
+}
+
+type epubItem struct {
+	ID        string
+	Href      string
+	MediaType string
+}
+
+type epubData struct {
+	ID       string
+	Title    string
+	Author   string
+	Modified string
+	Chapters []epubChapter
+	Items    []epubItem
+}
+
+// WriteEPUB writes an EPUB 3 book with one chapter per note. Links between notes
+// point at chapters and referenced attachments are packaged with the book.
+func (c *Collection) WriteEPUB(w io.Writer) error {
+	data := epubData{
+		ID:       "urn:uuid:" + uuid.NewString(),
+		Title:    c.Title,
+		Author:   c.Person,
+		Modified: c.created.UTC().Format("2006-01-02T15:04:05Z"),
+	}
+	for _, pg := range c.pages {
+		body, err := c.renderPage(pg, func(dest string, image bool) string {
+			l, ok := c.resolve(pg, dest)
+			switch {
+			case !ok:
+				return dest
+			case l.page != nil:
+				return l.page.id + ".xhtml" + l.fragment
+			default:
+				return l.asset.name
+			}
+		})
+		if err != nil {
+			return err
+		}
+		data.Chapters = append(data.Chapters, epubChapter{ID: pg.id, Href: pg.id + ".xhtml", Title: pg.title, Body: body})
+	}
+	for i, a := range c.orderedAssets() {
+		data.Items = append(data.Items, epubItem{ID: fmt.Sprintf("asset%d", i+1), Href: a.name, MediaType: a.mediaType})
+	}
+
+	zw := zip.NewWriter(w)
+	// The mimetype entry must come first and be stored uncompressed.
+	if err := writeZipFile(zw, "mimetype", []byte("application/epub+zip"), zip.Store); err != nil {
+		return err
+	}
+	if err := writeZipFile(zw, "META-INF/container.xml", []byte(epubContainer), zip.Deflate); err != nil {
+		return err
+	}
+	files := []struct {
+		name string
+		tmpl *template.Template
+		data any
+	}{
+		{"OEBPS/content.opf", epubPackageTemplate, data},
+		{"OEBPS/nav.xhtml", epubNavTemplate, data},
+	}
+	for _, f := range files {
+		var buf bytes.Buffer
+		buf.WriteString(xmlHeader)
+		if err := f.tmpl.Execute(&buf, f.data); err != nil {
+			return err
+		}
+		if err := writeZipFile(zw, f.name, buf.Bytes(), zip.Deflate); err != nil {
+			return err
+		}
+	}
+	if err := writeZipFile(zw, "OEBPS/style.css", []byte(pageStyle), zip.Deflate); err != nil {
+		return err
+	}
+	for _, ch := range data.Chapters {
+		var buf bytes.Buffer
+		buf.WriteString(xmlHeader)
+		if err := epubChapterTemplate.Execute(&buf, ch); err != nil {
+			return err
+		}
+		if err := writeZipFile(zw, "OEBPS/"+ch.Href, buf.Bytes(), zip.Deflate); err != nil {
+			return err
+		}
+	}
+	for _, a := range c.orderedAssets() {
+		if err := writeZipFile(zw, "OEBPS/"+a.name, a.data, zip.Deflate); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+const xmlHeader = "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"
+
+const epubContainer = xmlHeader + `<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+var epubPackageTemplate = template.Must(template.New("opf").Parse(`<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">{{.ID}}</dc:identifier>
+    <dc:title>{{.Title}}</dc:title>
+    <dc:creator>{{.Author}}</dc:creator>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+{{- range .Chapters}}
+    <item id="{{.ID}}" href="{{.Href}}" media-type="application/xhtml+xml"/>
+{{- end}}
+{{- range .Items}}
+    <item id="{{.ID}}" href="{{.Href}}" media-type="{{.MediaType}}"/>
+{{- end}}
+  </manifest>
+  <spine>
+{{- range .Chapters}}
+    <itemref idref="{{.ID}}"/>
+{{- end}}
+  </spine>
+</package>
+`))
+
+var epubNavTemplate = template.Must(template.New("nav").Parse(`<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><title>{{.Title}}</title></head>
+<body>
+<nav epub:type="toc" id="toc">
+<h1>{{.Title}}</h1>
+<ol>
+{{- range .Chapters}}
+<li><a href="{{.Href}}">{{.Title}}</a></li>
+{{- end}}
+</ol>
+</nav>
+</body>
+</html>
+`))
+
+var epubChapterTemplate = template.Must(template.New("chapter").Parse(`<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+<title>{{.Title}}</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+{{.Body}}
+</body>
+</html>
+`))
@@ -0,0 +1,467 @@
+// Package export renders notes from a person's vault into shareable documents: a
+// self-contained HTML site, a single print-ready HTML page and an EPUB book.
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"notes-editor/internal/vault"
+)
+
+// Supported export formats.
+const (
+	FormatHTML  = "html"
+	FormatPrint = "print"
+	FormatEPUB  = "epub"
+)
+
+// Export limits.
+const (
+	MaxPages       = 5000
+	MaxAssetBytes  = 256 << 20
+	maxDailyRange  = 3700 // days, a little over ten years
+	dailyDirectory = "daily"
+)
+
+// Export errors.
+var (
+	ErrUnknownFormat  = errors.New("unknown export format (expected html, print or epub)")
+	ErrEmptySelection = errors.New("select a path or a from/to date range")
+	ErrBothSelections = errors.New("select either a path or a date range, not both")
+	ErrInvalidRange   = errors.New("invalid date range")
+	ErrNoNotes        = errors.New("no notes found for the selection")
+	ErrTooLarge       = errors.New("selection exceeds the export size limit")
+)
+
+var (
+	wikiLinkPattern = regexp.MustCompile(`(!?)\[\[([^\]|#]+)(#[^\]|]*)?(?:\|([^\]]*))?\]\]`)
+	schemePattern   = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+)
+
+// Selection picks the notes to export: a single file, a folder (recursively) or a
+// range of daily notes. Path and the date range are mutually exclusive.
+type Selection struct {
+	Path string
+	From string // YYYY-MM-DD, inclusive
+	To   string // YYYY-MM-DD, inclusive
+}
+
+// page is one exported note.
+type page struct {
+	path   string // vault-relative source path
+	title  string
+	id     string
+	source string
+}
+
+// asset is a vault file referenced from an exported note.
+type asset struct {
+	name      string // file name inside the export, e.g. assets/a3.png
+	mediaType string
+	data      []byte
+}
+
+// Collection is the set of notes and attachments that make up one export.
+type Collection struct {
+	Person string
+	Title  string
+
+	store      *vault.Store
+	pages      []*page
+	byPath     map[string]*page
+	byName     map[string]*page
+	assets     map[string]*asset
+	assetOrder []string
+	assetBytes int
+	created    time.Time
+}
+
+// Collect reads the selected notes from the person's vault.
+func Collect(store *vault.Store, person string, sel Selection) (*Collection, error) {
+	c := newCollection(store, person)
+
+	var paths []string
+	switch {
+	case sel.Path != "" && (sel.From != "" || sel.To != ""):
+		return nil, ErrBothSelections
+	case sel.Path != "":
+		p := strings.Trim(filepath.ToSlash(sel.Path), "/")
+		if err := vault.ValidatePath(p); err != nil {
+			return nil, err
+		}
+		found, err := c.collectPath(p)
+		if err != nil {
+			return nil, err
+		}
+		paths = found
+		c.Title = strings.TrimSuffix(path.Base(p), path.Ext(p))
+	case sel.From != "" || sel.To != "":
+		found, err := c.collectDailyRange(sel.From, sel.To)
+		if err != nil {
+			return nil, err
+		}
+		paths = found
+		c.Title = fmt.Sprintf("Daily notes %s – %s", sel.From, sel.To)
+		if sel.From == sel.To {
+			c.Title = "Daily note " + sel.From
+		}
+	default:
+		return nil, ErrEmptySelection
+	}
+
+	if err := c.load(paths); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// CollectPaths reads exactly the given notes. Links from these notes only resolve to
+// other notes in the list, which lets callers restrict what a document can reach.
+func CollectPaths(store *vault.Store, person string, paths []string) (*Collection, error) {
+	c := newCollection(store, person)
+	if err := c.load(paths); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func newCollection(store *vault.Store, person string) *Collection {
+	return &Collection{
+		Person:  person,
+		store:   store,
+		byPath:  make(map[string]*page),
+		byName:  make(map[string]*page),
+		assets:  make(map[string]*asset),
+		created: time.Now(),
+	}
+}
+
+// load reads the notes at paths into the collection.
+func (c *Collection) load(paths []string) error {
+	if len(paths) == 0 {
+		return ErrNoNotes
+	}
+	if len(paths) > MaxPages {
+		return ErrTooLarge
+	}
+
+	for i, p := range paths {
+		content, err := c.store.ReadFile(c.Person, p)
+		if err != nil {
+			return err
+		}
+		content = StripFrontMatter(content)
+		pg := &page{
+			path:   p,
+			id:     fmt.Sprintf("p%d", i+1),
+			title:  noteTitle(p, content),
+			source: content,
+		}
+		c.pages = append(c.pages, pg)
+		c.byPath[p] = pg
+		name := strings.ToLower(strings.TrimSuffix(path.Base(p), path.Ext(p)))
+		if _, dup := c.byName[name]; !dup {
+			c.byName[name] = pg
+		}
+	}
+	if len(c.pages) == 1 {
+		c.Title = c.pages[0].title
+	}
+	return nil
+}
+
+// StripFrontMatter removes a leading YAML front matter block.
+func StripFrontMatter(content string) string {
+	if !strings.HasPrefix(content, "---\n") {
+		return content
+	}
+	end := strings.Index(content[4:], "\n---\n")
+	if end < 0 {
+		if strings.HasSuffix(content, "\n---") {
+			return ""
+		}
+		return content
+	}
+	return strings.TrimLeft(content[4+end+5:], "\n")
+}
+
+// PageTitle returns the title of the note at p, or "" if it is not in the collection.
+func (c *Collection) PageTitle(p string) string {
+	if pg, ok := c.byPath[p]; ok {
+		return pg.title
+	}
+	return ""
+}
+
+// Paths returns the vault-relative paths of the notes in the collection, in order.
+func (c *Collection) Paths() []string {
+	paths := make([]string, len(c.pages))
+	for i, pg := range c.pages {
+		paths[i] = pg.path
+	}
+	return paths
+}
+
+// collectPath returns the markdown files at p: the file itself, or every note below
+// the folder in path order. Paths resolve like in the editor, so _shared/ means the
+// shared folders, and exporting the whole vault includes the ones the person can
+// access.
+func (c *Collection) collectPath(p string) ([]string, error) {
+	fullPath, err := c.store.Resolve(c.Person, p)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		if !isMarkdown(p) {
+			return nil, fmt.Errorf("%s is not a markdown note", p)
+		}
+		return []string{p}, nil
+	}
+
+	var out []string
+	// _shared itself is virtual: it holds only the folders the person can access.
+	if p != vault.SharedDir {
+		if out, err = c.walkNotes(fullPath, p); err != nil {
+			return nil, err
+		}
+	}
+	if p == "." || p == vault.SharedDir {
+		for _, folder := range c.store.SharedFolders(c.Person) {
+			found, err := c.collectPath(folder.Path)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, found...)
+		}
+		if len(out) > MaxPages {
+			return nil, ErrTooLarge
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// walkNotes returns the markdown files below the folder at fullPath, which the
+// person sees at p. Hidden files and folders are skipped.
+func (c *Collection) walkNotes(fullPath, p string) ([]string, error) {
+	var out []string
+	err := filepath.WalkDir(fullPath, func(walkPath string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if walkPath != fullPath && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(fullPath, walkPath)
+		if err != nil {
+			return err
+		}
+		rel = path.Join(p, filepath.ToSlash(rel))
+		if d.IsDir() {
+			// A person's own _shared directory is shadowed by the shared folders.
+			if rel == vault.SharedDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !isMarkdown(d.Name()) {
+			return nil
+		}
+		out = append(out, rel)
+		if len(out) > MaxPages {
+			return ErrTooLarge
+		}
+		return nil
+	})
+	return out, err
+}
+
+// collectDailyRange returns the existing daily notes between from and to.
+func (c *Collection) collectDailyRange(from, to string) ([]string, error) {
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return nil, ErrInvalidRange
+	}
+	end, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return nil, ErrInvalidRange
+	}
+	if end.Before(start) || end.Sub(start) > maxDailyRange*24*time.Hour {
+		return nil, ErrInvalidRange
+	}
+
+	var out []string
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		p := path.Join(dailyDirectory, d.Format("2006-01-02")+".md")
+		exists, err := c.store.FileExists(c.Person, p)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// noteTitle returns the first level-one heading, or the file name.
+func noteTitle(p, content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			if t := strings.TrimSpace(line[2:]); t != "" {
+				return t
+			}
+		}
+	}
+	return strings.TrimSuffix(path.Base(p), path.Ext(p))
+}
+
+func isMarkdown(name string) bool {
+	return strings.EqualFold(path.Ext(name), ".md")
+}
+
+// expandWikiLinks turns [[Note]] and [[Note|label]] into markdown links to exported
+// notes, and plain text when the note is not part of the export. Embeds of files
+// (![[photo.png]]) become image links resolved like any other relative link.
+func (c *Collection) expandWikiLinks(pg *page) string {
+	return wikiLinkPattern.ReplaceAllStringFunc(pg.source, func(m string) string {
+		parts := wikiLinkPattern.FindStringSubmatch(m)
+		embed, name, fragment, label := parts[1] == "!", strings.TrimSpace(parts[2]), parts[3], strings.TrimSpace(parts[4])
+		if label == "" {
+			label = name
+		}
+		if embed && !isMarkdown(name) && path.Ext(name) != "" {
+			return fmt.Sprintf("![%s](<%s>)", label, name)
+		}
+		key := strings.ToLower(strings.TrimSuffix(path.Base(name), ".md"))
+		target, ok := c.byName[key]
+		if !ok {
+			return label
+		}
+		return fmt.Sprintf("[%s](<%s%s>)", label, vault.RelativeLink(path.Dir(pg.path), target.path), fragment)
+	})
+}
+
+// link is the resolution of a link destination found in a note.
+type link struct {
+	page     *page
+	asset    *asset
+	fragment string
+}
+
+// resolve maps a link destination in pg to an exported note or a vault attachment.
+// External URLs, pure fragments and unknown targets return ok=false.
+func (c *Collection) resolve(pg *page, dest string) (link, bool) {
+	if dest == "" || strings.HasPrefix(dest, "#") || strings.HasPrefix(dest, "//") || schemePattern.MatchString(dest) {
+		return link{}, false
+	}
+	raw, fragment, _ := strings.Cut(dest, "#")
+	if fragment != "" {
+		fragment = "#" + fragment
+	}
+	if raw, _, _ = strings.Cut(raw, "?"); raw == "" {
+		return link{}, false
+	}
+	if unescaped, err := url.PathUnescape(raw); err == nil {
+		raw = unescaped
+	}
+
+	var target string
+	if strings.HasPrefix(raw, "/") {
+		target = path.Clean(strings.TrimPrefix(raw, "/"))
+	} else {
+		target = path.Join(path.Dir(pg.path), raw)
+	}
+	if vault.ValidatePath(target) != nil {
+		return link{}, false
+	}
+
+	if p, ok := c.byPath[target]; ok {
+		return link{page: p, fragment: fragment}, true
+	}
+	if isMarkdown(target) {
+		return link{}, false
+	}
+	a, err := c.loadAsset(target)
+	if err != nil || a == nil {
+		return link{}, false
+	}
+	return link{asset: a}, true
+}
+
+// loadAsset reads an attachment once and assigns it a stable name in the export.
+func (c *Collection) loadAsset(p string) (*asset, error) {
+	if a, ok := c.assets[p]; ok {
+		return a, nil
+	}
+	data, err := c.store.ReadFile(c.Person, p)
+	if err != nil {
+		return nil, err
+	}
+	if c.assetBytes+len(data) > MaxAssetBytes {
+		return nil, ErrTooLarge
+	}
+	c.assetBytes += len(data)
+
+	ext := strings.ToLower(path.Ext(p))
+	a := &asset{
+		name:      fmt.Sprintf("assets/a%d%s", len(c.assetOrder)+1, ext),
+		mediaType: mediaType(ext),
+		data:      []byte(data),
+	}
+	c.assets[p] = a
+	c.assetOrder = append(c.assetOrder, p)
+	return a, nil
+}
+
+// orderedAssets returns the loaded assets in the order they were first referenced.
+func (c *Collection) orderedAssets() []*asset {
+	out := make([]*asset, 0, len(c.assetOrder))
+	for _, p := range c.assetOrder {
+		out = append(out, c.assets[p])
+	}
+	return out
+}
+
+func mediaType(ext string) string {
+	switch ext {
+	case ".png":
+		return "image/png"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	case ".svg":
+		return "image/svg+xml"
+	case ".pdf":
+		return "application/pdf"
+	case ".mp3":
+		return "audio/mpeg"
+	case ".mp4":
+		return "video/mp4"
+	default:
+		return "application/octet-stream"
+	}
+}
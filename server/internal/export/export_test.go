@@ -0,0 +1,236 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"notes-editor/internal/vault"
+)
+
+func setupExportVault(t *testing.T) *vault.Store {
+	t.Helper()
+	store := vault.NewStore(t.TempDir())
+	files := map[string]string{
+		"trips/italy/index.md":      "# Italy 2026\n\nSee [day one](day%201.md#morning) and [[Packing|the list]].\n",
+		"trips/italy/day 1.md":      "# Day one\n\n## Morning\n\n![Beach](img/beach.png)\n\n- [x] swim\n- [ ] eat gelato\n\n<script>alert(1)</script>\n",
+		"trips/italy/Packing.md":    "Sunscreen, [[Unknown note]], [site](https://example.com)\n",
+		"trips/italy/img/beach.png": "\x89PNG fake",
+		"trips/italy/.draft.md":     "hidden",
+		"daily/2026-01-01.md":       "# 2026-01-01\n\nNew year\n",
+		"daily/2026-01-03.md":       "# 2026-01-03\n\nSnow\n",
+		"daily/2026-02-01.md":       "# 2026-02-01\n\nOutside range\n",
+	}
+	for p, content := range files {
+		if err := store.WriteFile("sebastian", p, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func readZip(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	out := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		out[f.Name] = string(b)
+	}
+	return out
+}
+
+func TestCollect_Selections(t *testing.T) {
+	store := setupExportVault(t)
+
+	c, err := Collect(store, "sebastian", Selection{Path: "trips/italy"})
+	if err != nil {
+		t.Fatalf("Collect folder: %v", err)
+	}
+	if len(c.pages) != 3 || c.Title != "italy" {
+		t.Fatalf("folder: %d pages, title %q", len(c.pages), c.Title)
+	}
+
+	c, err = Collect(store, "sebastian", Selection{From: "2026-01-01", To: "2026-01-31"})
+	if err != nil {
+		t.Fatalf("Collect range: %v", err)
+	}
+	if len(c.pages) != 2 || c.pages[0].path != "daily/2026-01-01.md" || c.pages[1].path != "daily/2026-01-03.md" {
+		t.Fatalf("range pages: %+v", c.pages)
+	}
+
+	c, err = Collect(store, "sebastian", Selection{Path: "trips/italy/Packing.md"})
+	if err != nil || c.Title != "Packing" {
+		t.Fatalf("single file: %v %+v", err, c)
+	}
+
+	for name, sel := range map[string]Selection{
+		"empty":   {},
+		"both":    {Path: "daily", From: "2026-01-01", To: "2026-01-02"},
+		"reverse": {From: "2026-02-01", To: "2026-01-01"},
+		"escape":  {Path: "../petra"},
+	} {
+		if _, err := Collect(store, "sebastian", sel); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := Collect(store, "sebastian", Selection{From: "2030-01-01", To: "2030-01-02"}); !errors.Is(err, ErrNoNotes) {
+		t.Errorf("empty range err = %v", err)
+	}
+}
+
+func TestCollect_SharedFolders(t *testing.T) {
+	store := setupExportVault(t)
+	folders, err := vault.ParseSharedFolders("family=sebastian,petra; budget=petra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetSharedFolders(folders)
+	if err := store.WriteFile("petra", "_shared/family/groceries.md", "# Groceries\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.WriteFile("petra", "_shared/budget/2026.md", "# Budget\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Collect(store, "sebastian", Selection{Path: "_shared/family"})
+	if err != nil {
+		t.Fatalf("Collect shared folder: %v", err)
+	}
+	if len(c.pages) != 1 || c.pages[0].path != "_shared/family/groceries.md" {
+		t.Fatalf("shared folder pages: %+v", c.pages)
+	}
+
+	for _, p := range []string{".", "_shared"} {
+		c, err = Collect(store, "sebastian", Selection{Path: p})
+		if err != nil {
+			t.Fatalf("Collect %q: %v", p, err)
+		}
+		if c.PageTitle("_shared/family/groceries.md") == "" {
+			t.Errorf("Collect %q misses the shared note", p)
+		}
+		if c.PageTitle("_shared/budget/2026.md") != "" {
+			t.Errorf("Collect %q includes an inaccessible shared folder", p)
+		}
+	}
+	if _, err := Collect(store, "sebastian", Selection{Path: "_shared/budget"}); !errors.Is(err, vault.ErrSharedAccess) {
+		t.Errorf("inaccessible folder err = %v", err)
+	}
+}
+
+func TestWriteHTMLSite(t *testing.T) {
+	store := setupExportVault(t)
+	c, err := Collect(store, "sebastian", Selection{Path: "trips/italy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.WriteHTMLSite(&buf); err != nil {
+		t.Fatalf("WriteHTMLSite: %v", err)
+	}
+	files := readZip(t, buf.Bytes())
+
+	index := files["index.html"]
+	if !strings.Contains(index, `href="trips/italy/day%201.html"`) || !strings.Contains(index, "Italy 2026") {
+		t.Fatalf("index.html:\n%s", index)
+	}
+	overview := files["trips/italy/index.html"]
+	for _, want := range []string{`href="day%201.html#morning"`, `href="Packing.html">the list</a>`, `href="../../index.html"`} {
+		if !strings.Contains(overview, want) {
+			t.Fatalf("overview missing %q:\n%s", want, overview)
+		}
+	}
+	day := files["trips/italy/day 1.html"]
+	if !strings.Contains(day, `src="../../assets/a1.png"`) || !strings.Contains(day, `type="checkbox"`) {
+		t.Fatalf("day page:\n%s", day)
+	}
+	if strings.Contains(day, "<script>") {
+		t.Fatal("raw HTML must not be passed through")
+	}
+	packing := files["trips/italy/Packing.html"]
+	if !strings.Contains(packing, "Unknown note") || strings.Contains(packing, "[[") || !strings.Contains(packing, `href="https://example.com"`) {
+		t.Fatalf("packing page:\n%s", packing)
+	}
+	if files["assets/a1.png"] != "\x89PNG fake" {
+		t.Fatalf("attachment not packaged: %v", files)
+	}
+	if _, ok := files["trips/italy/.draft.html"]; ok {
+		t.Fatal("hidden notes must not be exported")
+	}
+}
+
+func TestWritePrintHTML(t *testing.T) {
+	store := setupExportVault(t)
+	c, err := Collect(store, "sebastian", Selection{Path: "trips/italy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.WritePrintHTML(&buf); err != nil {
+		t.Fatalf("WritePrintHTML: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<section class="note" id="p1">`,
+		`href="#p1"`,
+		`src="data:image/png;base64,`,
+		"break-before: page",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("print output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteEPUB(t *testing.T) {
+	store := setupExportVault(t)
+	c, err := Collect(store, "sebastian", Selection{Path: "trips/italy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Title = "Italy & friends"
+	var buf bytes.Buffer
+	if err := c.WriteEPUB(&buf); err != nil {
+		t.Fatalf("WriteEPUB: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zr.File[0].Name != "mimetype" || zr.File[0].Method != zip.Store {
+		t.Fatalf("first entry must be stored mimetype, got %s", zr.File[0].Name)
+	}
+	files := readZip(t, buf.Bytes())
+	if files["mimetype"] != "application/epub+zip" {
+		t.Fatalf("mimetype = %q", files["mimetype"])
+	}
+	opf := files["OEBPS/content.opf"]
+	for _, want := range []string{
+		"<dc:title>Italy &amp; friends</dc:title>",
+		`<item id="asset1" href="assets/a1.png" media-type="image/png"/>`,
+		`<itemref idref="p3"/>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Fatalf("content.opf missing %q:\n%s", want, opf)
+		}
+	}
+	chapter := files["OEBPS/p3.xhtml"]
+	if !strings.HasPrefix(chapter, "<?xml") || !strings.Contains(chapter, `href="p2.xhtml#morning"`) {
+		t.Fatalf("chapter:\n%s", chapter)
+	}
+	if files["OEBPS/assets/a1.png"] == "" {
+		t.Fatal("attachment missing from EPUB")
+	}
+}
@@ -0,0 +1,338 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+
+	"notes-editor/internal/vault"
+)
+
+// rewriteFunc maps a link or image destination to its location in the output. An
+// empty result drops the link (keeping its text) or the image.
+type rewriteFunc func(dest string, image bool) string
+
+// linkRewriter is a goldmark AST transformer that rewrites link and image targets.
+type linkRewriter struct {
+	rewrite rewriteFunc
+}
+
+func (t *linkRewriter) Transform(doc *ast.Document, _ text.Reader, _ parser.Context) {
+	var dropped []ast.Node
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch v := n.(type) {
+		case *ast.Link:
+			v.Destination = []byte(t.rewrite(string(v.Destination), false))
+			if len(v.Destination) == 0 {
+				dropped = append(dropped, v)
+			}
+		case *ast.Image:
+			v.Destination = []byte(t.rewrite(string(v.Destination), true))
+			if len(v.Destination) == 0 {
+				dropped = append(dropped, v)
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, n := range dropped {
+		parent := n.Parent()
+		if parent == nil {
+			continue
+		}
+		if _, isLink := n.(*ast.Link); isLink {
+			for child := n.FirstChild(); child != nil; {
+				next := child.NextSibling()
+				parent.InsertBefore(parent, n, child)
+				child = next
+			}
+		}
+		parent.RemoveChild(parent, n)
+	}
+}
+
+// renderPage converts a note to an HTML fragment. Raw HTML in notes is not passed
+// through, so the output is safe to publish and valid XHTML for EPUB.
+func (c *Collection) renderPage(pg *page, rewrite rewriteFunc) (template.HTML, error) {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+			parser.WithASTTransformers(util.Prioritized(&linkRewriter{rewrite: rewrite}, 100)),
+		),
+		goldmark.WithRendererOptions(html.WithXHTML()),
+	)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(c.expandWikiLinks(pg)), &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// pageFile is the output path of a note in the HTML site.
+func pageFile(pg *page) string {
+	return strings.TrimSuffix(pg.path, path.Ext(pg.path)) + ".html"
+}
+
+type sitePage struct {
+	Title string
+	Href  string
+	ID    string
+	Body  template.HTML
+}
+
+type siteData struct {
+	Title   string
+	Style   template.CSS
+	Home    string
+	Pages   []sitePage
+	Current *sitePage
+}
+
+// WriteHTMLSite writes a ZIP with one HTML page per note, an index page and all
+// referenced attachments, with links between notes pointing at the exported pages.
+func (c *Collection) WriteHTMLSite(w io.Writer) error {
+	data := siteData{Title: c.Title, Style: template.CSS(pageStyle)}
+	bodies := make([]sitePage, len(c.pages))
+	for i, pg := range c.pages {
+		dir := path.Dir(pg.path)
+		body, err := c.renderPage(pg, func(dest string, image bool) string {
+			l, ok := c.resolve(pg, dest)
+			switch {
+			case !ok:
+				return dest
+			case l.page != nil:
+				return escapeLink(vault.RelativeLink(dir, pageFile(l.page))) + l.fragment
+			default:
+				return escapeLink(vault.RelativeLink(dir, l.asset.name))
+			}
+		})
+		if err != nil {
+			return err
+		}
+		bodies[i] = sitePage{Title: pg.title, Href: escapeLink(pageFile(pg)), Body: body}
+	}
+	data.Pages = bodies
+
+	zw := zip.NewWriter(w)
+	var index bytes.Buffer
+	if err := siteIndexTemplate.Execute(&index, data); err != nil {
+		return err
+	}
+	if err := writeZipFile(zw, "index.html", index.Bytes(), zip.Deflate); err != nil {
+		return err
+	}
+	for i, pg := range c.pages {
+		current := bodies[i]
+		pageData := data
+		pageData.Current = &current
+		pageData.Home = escapeLink(vault.RelativeLink(path.Dir(pg.path), "index.html"))
+		var buf bytes.Buffer
+		if err := sitePageTemplate.Execute(&buf, pageData); err != nil {
+			return err
+		}
+		if err := writeZipFile(zw, pageFile(pg), buf.Bytes(), zip.Deflate); err != nil {
+			return err
+		}
+	}
+	for _, a := range c.orderedAssets() {
+		if err := writeZipFile(zw, a.name, a.data, zip.Deflate); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// WritePrintHTML writes a single HTML document containing every note with a table
+// of contents and page breaks, ready for the browser's print-to-PDF. Images are
+// embedded as data URIs so the file works on its own.
+func (c *Collection) WritePrintHTML(w io.Writer) error {
+	data := siteData{Title: c.Title, Style: template.CSS(pageStyle + printStyle)}
+	for _, pg := range c.pages {
+		body, err := c.renderPage(pg, func(dest string, image bool) string {
+			l, ok := c.resolve(pg, dest)
+			switch {
+			case !ok:
+				return dest
+			case l.page != nil:
+				return "#" + l.page.id
+			case image:
+				return "data:" + l.asset.mediaType + ";base64," + base64.StdEncoding.EncodeToString(l.asset.data)
+			default:
+				// Non-image attachments cannot be embedded in a printed page.
+				return dest
+			}
+		})
+		if err != nil {
+			return err
+		}
+		data.Pages = append(data.Pages, sitePage{Title: pg.title, ID: pg.id, Body: body})
+	}
+	return printTemplate.Execute(w, data)
+}
+
+// WritePublicPage writes the note at p as a standalone HTML page for the public web.
+// Links to notes in the collection point at pageURL(path); links to anything else in
+// the vault are dropped so that unpublished notes are never revealed, not even by
+// name. Images are inlined and other attachments are dropped.
+func (c *Collection) WritePublicPage(w io.Writer, p string, pageURL func(path string) string, homeURL string) error {
+	pg, ok := c.byPath[p]
+	if !ok {
+		return ErrNoNotes
+	}
+	body, err := c.renderPage(pg, func(dest string, image bool) string {
+		l, ok := c.resolve(pg, dest)
+		switch {
+		case !ok:
+			if strings.HasPrefix(dest, "#") || isWebURL(dest) {
+				return dest
+			}
+			return ""
+		case l.page != nil:
+			if u := pageURL(l.page.path); u != "" {
+				return u + l.fragment
+			}
+			return ""
+		case image && strings.HasPrefix(l.asset.mediaType, "image/"):
+			return "data:" + l.asset.mediaType + ";base64," + base64.StdEncoding.EncodeToString(l.asset.data)
+		default:
+			return ""
+		}
+	})
+	if err != nil {
+		return err
+	}
+	current := sitePage{Title: pg.title, Body: body}
+	return sitePageTemplate.Execute(w, siteData{
+		Title:   c.Title,
+		Style:   template.CSS(pageStyle),
+		Home:    homeURL,
+		Current: &current,
+	})
+}
+
+// WritePublicIndex writes a page listing the notes in the collection.
+func (c *Collection) WritePublicIndex(w io.Writer, pageURL func(path string) string) error {
+	data := siteData{Title: c.Title, Style: template.CSS(pageStyle)}
+	for _, pg := range c.pages {
+		data.Pages = append(data.Pages, sitePage{Title: pg.title, Href: pageURL(pg.path)})
+	}
+	return siteIndexTemplate.Execute(w, data)
+}
+
+func isWebURL(dest string) bool {
+	lower := strings.ToLower(dest)
+	return strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "mailto:")
+}
+
+// escapeLink percent-encodes characters that would break a URL in an href.
+func escapeLink(p string) string {
+	r := strings.NewReplacer("%", "%25", " ", "%20", "#", "%23", "?", "%3F", "(", "%28", ")", "%29")
+	return r.Replace(p)
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte, method uint16) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(data)
+	return err
+}
+
+const pageStyle = `
+body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; line-height: 1.55; max-width: 46rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+nav.crumbs { font-size: .9rem; margin-bottom: 1.5rem; }
+img { max-width: 100%; height: auto; }
+pre { background: #f5f5f5; padding: .75rem; overflow-x: auto; }
+code { font-size: .9em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: .25rem .5rem; }
+li input[type=checkbox] { margin-right: .4rem; }
+blockquote { border-left: 3px solid #ccc; margin-left: 0; padding-left: 1rem; color: #555; }
+`
+
+const printStyle = `
+section.note { break-before: page; }
+nav.toc { break-after: page; }
+@media print { body { max-width: none; margin: 0; } a { color: inherit; text-decoration: none; } }
+`
+
+var siteIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>{{.Style}}</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<ul>
+{{- range .Pages}}
+<li><a href="{{.Href}}">{{.Title}}</a></li>
+{{- end}}
+</ul>
+</body>
+</html>
+`))
+
+var sitePageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Current.Title}} · {{.Title}}</title>
+<style>{{.Style}}</style>
+</head>
+<body>
+{{- if .Home}}
+<nav class="crumbs"><a href="{{.Home}}">{{.Title}}</a></nav>
+{{- end}}
+<article>
+{{.Current.Body}}
+</article>
+</body>
+</html>
+`))
+
+var printTemplate = template.Must(template.New("print").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>{{.Style}}</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<nav class="toc">
+<ol>
+{{- range .Pages}}
+<li><a href="#{{.ID}}">{{.Title}}</a></li>
+{{- end}}
+</ol>
+</nav>
+{{- range .Pages}}
+<section class="note" id="{{.ID}}">
+{{.Body}}
+</section>
+{{- end}}
+</body>
+</html>
+`))
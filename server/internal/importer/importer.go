@@ -0,0 +1,392 @@
+// Package importer converts notes exported from other tools (Obsidian vaults, plain
+// markdown folders and Google Keep Takeout archives) into files in a person's vault.
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"notes-editor/internal/vault"
+)
+
+// Supported import formats.
+const (
+	FormatObsidian = "obsidian"
+	FormatMarkdown = "markdown"
+	FormatKeep     = "keep"
+)
+
+// File kinds in an import plan.
+const (
+	KindNote       = "note"
+	KindAttachment = "attachment"
+	KindDaily      = "daily"
+	KindReport     = "report"
+)
+
+// MaxImportBytes bounds the total size of files read from an import source.
+const MaxImportBytes = 2 << 30
+
+const reportFilename = "IMPORT-REPORT.md"
+
+// Import errors.
+var (
+	ErrUnknownFormat = errors.New("unknown import format (expected obsidian, markdown or keep)")
+	ErrNothingFound  = errors.New("no importable notes found")
+	ErrTooLarge      = errors.New("import source exceeds the maximum import size")
+)
+
+var dailyFilePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})\.md$`)
+
+// Options configures an import.
+type Options struct {
+	// Format is one of FormatObsidian, FormatMarkdown or FormatKeep.
+	Format string
+	// Person owns the target vault.
+	Person string
+	// TargetDir is the vault-relative folder receiving imported notes.
+	// Defaults to imports/<format>-<date>.
+	TargetDir string
+	// SkipDaily disables mapping date-named notes into daily/YYYY-MM-DD.md.
+	SkipDaily bool
+	// Now is used for the default target folder and report timestamp. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// File is one file the import will write.
+type File struct {
+	Path    string `json:"path"`
+	Kind    string `json:"kind"`
+	Source  string `json:"source,omitempty"`
+	Size    int    `json:"size"`
+	Merged  bool   `json:"merged,omitempty"`
+	content []byte
+}
+
+// Rename records an imported note or attachment written under a different name to
+// avoid a clash.
+type Rename struct {
+	Source string `json:"source"`
+	Path   string `json:"path"`
+}
+
+// Skip records a source file that was not imported.
+type Skip struct {
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// Report summarises an import.
+type Report struct {
+	Format       string   `json:"format"`
+	Person       string   `json:"person"`
+	TargetDir    string   `json:"target_dir"`
+	Notes        int      `json:"notes"`
+	Attachments  int      `json:"attachments"`
+	DailyCreated int      `json:"daily_created"`
+	DailyMerged  int      `json:"daily_merged"`
+	Renamed      []Rename `json:"renamed"`
+	Skipped      []Skip   `json:"skipped"`
+	Files        []File   `json:"files"`
+}
+
+// Plan is a fully computed import that can be reviewed and then applied.
+type Plan struct {
+	Report
+	createdAt time.Time
+}
+
+// note is an intermediate converted note before it is placed in the vault.
+type note struct {
+	source  string
+	relPath string // path below the target dir
+	date    string // YYYY-MM-DD when the note maps to a daily note
+	content string
+	// embeds maps attachment file names to their source paths for Obsidian embeds.
+	embeds map[string]string
+}
+
+// attachment is a binary file referenced by imported notes.
+type attachment struct {
+	source  string
+	relPath string
+	content []byte
+}
+
+// converted is the output of a format-specific converter.
+type converted struct {
+	notes       []note
+	attachments []attachment
+	skipped     []Skip
+}
+
+// Build reads src, converts it according to opts and plans where every file goes in
+// the person's vault. Nothing is written; existing files are only read to resolve
+// name clashes and to merge daily notes.
+func Build(store *vault.Store, src fs.FS, opts Options) (*Plan, error) {
+	now := time.Now
+	if opts.Now != nil {
+		now = opts.Now
+	}
+	createdAt := now()
+
+	target := strings.Trim(path.Clean(strings.ReplaceAll(opts.TargetDir, "\\", "/")), "/")
+	if opts.TargetDir == "" || target == "." {
+		target = fmt.Sprintf("imports/%s-%s", opts.Format, createdAt.Format("2006-01-02"))
+	}
+	if err := vault.ValidatePath(target); err != nil {
+		return nil, err
+	}
+
+	var (
+		conv converted
+		err  error
+	)
+	switch opts.Format {
+	case FormatObsidian:
+		conv, err = convertMarkdownTree(src, true)
+	case FormatMarkdown:
+		conv, err = convertMarkdownTree(src, false)
+	case FormatKeep:
+		conv, err = convertKeep(src)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(conv.notes) == 0 {
+		return nil, ErrNothingFound
+	}
+
+	plan := &Plan{
+		Report: Report{
+			Format:    opts.Format,
+			Person:    opts.Person,
+			TargetDir: target,
+			Renamed:   make([]Rename, 0),
+			Skipped:   conv.skipped,
+			Files:     make([]File, 0, len(conv.notes)+len(conv.attachments)+1),
+		},
+		createdAt: createdAt,
+	}
+	if plan.Skipped == nil {
+		plan.Skipped = make([]Skip, 0)
+	}
+
+	used := make(map[string]bool)
+	// placed maps attachments written under a different name to their new path so
+	// embeds still point at them.
+	placed := make(map[string]string)
+	for _, a := range conv.attachments {
+		want := path.Join(target, a.relPath)
+		dest, err := uniquePath(store, opts.Person, want, used)
+		if err != nil {
+			plan.Skipped = append(plan.Skipped, Skip{Source: a.source, Reason: err.Error()})
+			continue
+		}
+		if dest != want {
+			plan.Renamed = append(plan.Renamed, Rename{Source: a.source, Path: dest})
+			placed[want] = dest
+		}
+		used[dest] = true
+		plan.Files = append(plan.Files, File{Path: dest, Kind: KindAttachment, Source: a.source, Size: len(a.content), content: a.content})
+		plan.Attachments++
+	}
+
+	dailies := make(map[string]int)
+	for _, n := range conv.notes {
+		daily := n.date != "" && !opts.SkipDaily
+		if n.embeds != nil {
+			dir := "daily"
+			if !daily {
+				dir = path.Dir(path.Join(target, n.relPath))
+			}
+			n.content = rewriteEmbeds(n.content, dir, target, n.embeds, placed)
+		}
+		if daily {
+			if err := plan.addDaily(store, n, dailies); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		dest, err := uniquePath(store, opts.Person, path.Join(target, n.relPath), used)
+		if err != nil {
+			plan.Skipped = append(plan.Skipped, Skip{Source: n.source, Reason: err.Error()})
+			continue
+		}
+		if dest != path.Join(target, n.relPath) {
+			plan.Renamed = append(plan.Renamed, Rename{Source: n.source, Path: dest})
+		}
+		used[dest] = true
+		plan.Files = append(plan.Files, File{Path: dest, Kind: KindNote, Source: n.source, Size: len(n.content), content: []byte(n.content)})
+		plan.Notes++
+	}
+
+	reportPath, err := uniquePath(store, opts.Person, path.Join(target, reportFilename), used)
+	if err != nil {
+		return nil, err
+	}
+	report := plan.renderReport()
+	plan.Files = append(plan.Files, File{
+		Path:    reportPath,
+		Kind:    KindReport,
+		Size:    len(report),
+		content: []byte(report),
+	})
+
+	sort.SliceStable(plan.Files, func(i, j int) bool {
+		return plan.Files[i].Path < plan.Files[j].Path
+	})
+	return plan, nil
+}
+
+// addDaily maps a dated note into daily/YYYY-MM-DD.md, creating the daily note with
+// the usual sections or appending to an existing one.
+func (p *Plan) addDaily(store *vault.Store, n note, dailies map[string]int) error {
+	dest := path.Join("daily", n.date+".md")
+	section := fmt.Sprintf("### imported from %s\n%s\n", p.Format, strings.TrimSpace(n.content))
+
+	// Several sources can map to the same day; merge them into one planned file.
+	if idx, ok := dailies[dest]; ok {
+		f := &p.Files[idx]
+		f.content = append(f.content, []byte("\n"+section)...)
+		f.Size = len(f.content)
+		f.Source += ", " + n.source
+		return nil
+	}
+
+	existing, err := store.ReadFile(p.Person, dest)
+	var content string
+	merged := false
+	switch {
+	case err == nil:
+		content = strings.TrimRight(existing, "\n") + "\n\n" + section
+		merged = true
+		p.DailyMerged++
+	case isNotExist(err):
+		content = fmt.Sprintf("# %s\n\n## todos\n\n## custom notes\n\n%s", n.date, section)
+		p.DailyCreated++
+	default:
+		return err
+	}
+
+	dailies[dest] = len(p.Files)
+	p.Files = append(p.Files, File{Path: dest, Kind: KindDaily, Source: n.source, Size: len(content), Merged: merged, content: []byte(content)})
+	return nil
+}
+
+// uniquePath returns dest, or a numbered variant when a file already exists there.
+func uniquePath(store *vault.Store, person, dest string, used map[string]bool) (string, error) {
+	if err := vault.ValidatePath(dest); err != nil {
+		return "", err
+	}
+	ext := path.Ext(dest)
+	base := strings.TrimSuffix(dest, ext)
+	candidate := dest
+	for i := 2; ; i++ {
+		if !used[candidate] {
+			exists, err := store.FileExists(person, candidate)
+			if err != nil {
+				return "", err
+			}
+			if !exists {
+				return candidate, nil
+			}
+		}
+		candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+}
+
+// RootPaths returns the planned paths relative to the vault root, suitable for git.
+func (p *Plan) RootPaths() []string {
+	out := make([]string, 0, len(p.Files))
+	for _, f := range p.Files {
+		out = append(out, path.Join(p.Person, f.Path))
+	}
+	return out
+}
+
+// CommitMessage describes the import for the git history.
+func (p *Plan) CommitMessage() string {
+	return fmt.Sprintf("Import %d notes from %s into %s/%s", p.Notes+p.DailyCreated+p.DailyMerged, p.Format, p.Person, p.TargetDir)
+}
+
+// Apply writes all planned files through the store. Only daily notes may already
+// exist; if any other planned file appeared since the plan was built, nothing is
+// written.
+func Apply(store *vault.Store, plan *Plan) error {
+	for _, f := range plan.Files {
+		if f.Kind == KindDaily {
+			continue
+		}
+		exists, err := store.FileExists(plan.Person, f.Path)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("%s: %w", f.Path, vault.ErrFileExists)
+		}
+	}
+	for _, f := range plan.Files {
+		if err := store.WriteFile(plan.Person, f.Path, string(f.content)); err != nil {
+			return fmt.Errorf("write %s: %w", f.Path, err)
+		}
+	}
+	return nil
+}
+
+func (p *Plan) renderReport() string {
+	var b strings.Builder
+	b.WriteString("# Import report\n\n")
+	b.WriteString(fmt.Sprintf("- Format: %s\n", p.Format))
+	b.WriteString(fmt.Sprintf("- Imported at: %s\n", p.createdAt.Format("2006-01-02 15:04")))
+	b.WriteString(fmt.Sprintf("- Notes: %d\n", p.Notes))
+	b.WriteString(fmt.Sprintf("- Attachments: %d\n", p.Attachments))
+	b.WriteString(fmt.Sprintf("- Daily notes created: %d\n", p.DailyCreated))
+	b.WriteString(fmt.Sprintf("- Daily notes merged: %d\n", p.DailyMerged))
+
+	if len(p.Renamed) > 0 {
+		b.WriteString("\n## Renamed\n\n")
+		for _, r := range p.Renamed {
+			b.WriteString(fmt.Sprintf("- %s → %s\n", r.Source, r.Path))
+		}
+	}
+	if len(p.Skipped) > 0 {
+		b.WriteString("\n## Skipped\n\n")
+		for _, s := range p.Skipped {
+			b.WriteString(fmt.Sprintf("- %s: %s\n", s.Source, s.Reason))
+		}
+	}
+	return b.String()
+}
+
+// readLimited reads a file from src, charging its size against the shared budget.
+func readLimited(src fs.FS, name string, budget *int64) ([]byte, error) {
+	f, err := src.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, *budget+1))
+	if err != nil {
+		return nil, err
+	}
+	*budget -= int64(len(data))
+	if *budget < 0 {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+func isNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
@@ -0,0 +1,243 @@
+package importer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"notes-editor/internal/vault"
+)
+
+func fixedNow() time.Time {
+	return time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+}
+
+func planFile(t *testing.T, plan *Plan, p string) File {
+	t.Helper()
+	for _, f := range plan.Files {
+		if f.Path == p {
+			return f
+		}
+	}
+	t.Fatalf("plan has no file %s; files: %+v", p, plan.Files)
+	return File{}
+}
+
+func TestBuild_Obsidian(t *testing.T) {
+	store := vault.NewStore(t.TempDir())
+	if err := store.WriteFile("sebastian", "daily/2024-01-02.md", "# 2024-01-02\n\n## todos\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	src := fstest.MapFS{
+		"MyVault/.obsidian/app.json":          {Data: []byte("{}")},
+		"MyVault/Projects/Garden.md":          {Data: []byte("Plan ![[bed plan.png]] and [[Other]]\n")},
+		"MyVault/Journal/2024-01-02.md":       {Data: []byte("Walked ![[bed plan.png|Beds]]\n")},
+		"MyVault/Journal/2024-01-03.md":       {Data: []byte("Rain\n")},
+		"MyVault/assets/bed plan.png":         {Data: []byte("PNG")},
+		"MyVault/.trash/old.md":               {Data: []byte("gone")},
+		"MyVault/Projects/.hidden-draft.md":   {Data: []byte("hidden")},
+		"MyVault/Projects/Sub/Nested note.md": {Data: []byte("deep ![[bed plan.png]]")},
+	}
+
+	plan, err := Build(store, src, Options{Format: FormatObsidian, Person: "sebastian", Now: fixedNow})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if plan.TargetDir != "imports/obsidian-2026-03-01" {
+		t.Fatalf("TargetDir = %q", plan.TargetDir)
+	}
+	if plan.Notes != 2 || plan.Attachments != 1 || plan.DailyCreated != 1 || plan.DailyMerged != 1 {
+		t.Fatalf("unexpected counts: %+v", plan.Report)
+	}
+
+	garden := planFile(t, plan, "imports/obsidian-2026-03-01/Projects/Garden.md")
+	if got := string(garden.content); got != "Plan ![bed plan.png](<../assets/bed plan.png>) and [[Other]]\n" {
+		t.Fatalf("Garden.md = %q", got)
+	}
+	nested := planFile(t, plan, "imports/obsidian-2026-03-01/Projects/Sub/Nested note.md")
+	if !strings.Contains(string(nested.content), "(<../../assets/bed plan.png>)") {
+		t.Fatalf("nested embed = %q", nested.content)
+	}
+
+	merged := planFile(t, plan, "daily/2024-01-02.md")
+	if !merged.Merged {
+		t.Fatal("existing daily note should be merged")
+	}
+	want := "# 2024-01-02\n\n## todos\n\n### imported from obsidian\nWalked ![Beds](<../imports/obsidian-2026-03-01/assets/bed plan.png>)\n"
+	if got := string(merged.content); got != want {
+		t.Fatalf("merged daily = %q, want %q", got, want)
+	}
+	created := planFile(t, plan, "daily/2024-01-03.md")
+	if !strings.HasPrefix(string(created.content), "# 2024-01-03\n\n## todos\n\n## custom notes\n\n### imported from obsidian\nRain") {
+		t.Fatalf("created daily = %q", created.content)
+	}
+
+	for _, f := range plan.Files {
+		if strings.Contains(f.Path, ".obsidian") || strings.Contains(f.Path, ".trash") || strings.Contains(f.Path, "hidden") {
+			t.Fatalf("unexpected file imported: %s", f.Path)
+		}
+	}
+	planFile(t, plan, "imports/obsidian-2026-03-01/IMPORT-REPORT.md")
+}
+
+func TestBuild_MarkdownRenamesClashesAndSkipsDaily(t *testing.T) {
+	store := vault.NewStore(t.TempDir())
+	if err := store.WriteFile("sebastian", "notes/todo.md", "existing"); err != nil {
+		t.Fatal(err)
+	}
+	src := fstest.MapFS{
+		"todo.md":       {Data: []byte("new todo")},
+		"2024-05-05.md": {Data: []byte("dated")},
+		"img/a.jpg":     {Data: []byte("JPG")},
+	}
+
+	plan, err := Build(store, src, Options{Format: FormatMarkdown, Person: "sebastian", TargetDir: "notes", SkipDaily: true})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	planFile(t, plan, "notes/todo-2.md")
+	planFile(t, plan, "notes/2024-05-05.md")
+	planFile(t, plan, "notes/img/a.jpg")
+	if len(plan.Renamed) != 1 || plan.Renamed[0].Path != "notes/todo-2.md" {
+		t.Fatalf("Renamed = %+v", plan.Renamed)
+	}
+	if plan.DailyCreated != 0 {
+		t.Fatalf("daily mapping should be disabled")
+	}
+
+	if err := Apply(store, plan); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	existing, _ := store.ReadFile("sebastian", "notes/todo.md")
+	if existing != "existing" {
+		t.Fatalf("existing note overwritten: %q", existing)
+	}
+	imported, _ := store.ReadFile("sebastian", "notes/todo-2.md")
+	if imported != "new todo" {
+		t.Fatalf("imported note = %q", imported)
+	}
+	paths := plan.RootPaths()
+	if len(paths) != len(plan.Files) || !strings.HasPrefix(paths[0], "sebastian/notes/") {
+		t.Fatalf("RootPaths = %v", paths)
+	}
+}
+
+func TestBuild_ObsidianRenamesClashingAttachments(t *testing.T) {
+	store := vault.NewStore(t.TempDir())
+	if err := store.WriteFile("sebastian", "notes/img.png", "existing"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.WriteFile("sebastian", "notes/"+reportFilename, "earlier report"); err != nil {
+		t.Fatal(err)
+	}
+	src := fstest.MapFS{
+		"Note.md": {Data: []byte("![[img.png]]")},
+		"img.png": {Data: []byte("PNG")},
+	}
+
+	plan, err := Build(store, src, Options{Format: FormatObsidian, Person: "sebastian", TargetDir: "notes"})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	planFile(t, plan, "notes/img-2.png")
+	planFile(t, plan, "notes/IMPORT-REPORT-2.md")
+	if got := string(planFile(t, plan, "notes/Note.md").content); got != "![img.png](img-2.png)" {
+		t.Fatalf("Note.md = %q", got)
+	}
+	if len(plan.Renamed) != 1 || plan.Renamed[0].Source != "img.png" {
+		t.Fatalf("Renamed = %+v", plan.Renamed)
+	}
+
+	// A file created after planning is never overwritten.
+	if err := store.WriteFile("sebastian", "notes/img-2.png", "racing"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(store, plan); !errors.Is(err, vault.ErrFileExists) {
+		t.Fatalf("Apply error = %v, want ErrFileExists", err)
+	}
+	if got, _ := store.ReadFile("sebastian", "notes/img.png"); got != "existing" {
+		t.Fatalf("img.png = %q", got)
+	}
+	if exists, _ := store.FileExists("sebastian", "notes/Note.md"); exists {
+		t.Fatal("nothing should be written when Apply fails")
+	}
+}
+
+func TestBuild_Keep(t *testing.T) {
+	store := vault.NewStore(t.TempDir())
+	src := fstest.MapFS{
+		"Takeout/Keep/Groceries.json": {Data: []byte(`{
+			"title": "Groceries",
+			"listContent": [{"text": "Milk", "isChecked": false}, {"text": "Bread", "isChecked": true}],
+			"labels": [{"name": "home stuff"}],
+			"isPinned": true,
+			"createdTimestampUsec": 1700000000000000,
+			"attachments": [{"filePath": "photo.jpeg", "mimetype": "image/jpeg"}]
+		}`)},
+		"Takeout/Keep/photo.jpg": {Data: []byte("JPG")},
+		"Takeout/Keep/2024-02-10.json": {Data: []byte(`{
+			"title": "2024-02-10",
+			"textContent": "Dentist at 10",
+			"userEditedTimestampUsec": 1707550000000000,
+			"attachments": [{"filePath": "photo.jpeg"}]
+		}`)},
+		"Takeout/Keep/Untitled.json":  {Data: []byte(`{"title": "", "textContent": "loose thought", "userEditedTimestampUsec": 1}`)},
+		"Takeout/Keep/Old.json":       {Data: []byte(`{"title": "Old", "textContent": "x", "isTrashed": true, "userEditedTimestampUsec": 1}`)},
+		"Takeout/Keep/Labels.json":    {Data: []byte(`[]`)},
+		"Takeout/Keep/Groceries.html": {Data: []byte("<html></html>")},
+	}
+
+	plan, err := Build(store, src, Options{Format: FormatKeep, Person: "sebastian", TargetDir: "keep"})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if plan.Notes != 2 || plan.Attachments != 1 || plan.DailyCreated != 1 {
+		t.Fatalf("unexpected counts: %+v", plan.Report)
+	}
+
+	groceries := string(planFile(t, plan, "keep/Groceries.md").content)
+	for _, want := range []string{
+		"# Groceries\n",
+		"_created: 2023-11-14 22:13 · pinned · #home-stuff_",
+		"- [ ] Milk\n- [x] Bread\n",
+		"![photo.jpg](attachments/photo.jpg)",
+	} {
+		if !strings.Contains(groceries, want) {
+			t.Fatalf("Groceries.md missing %q:\n%s", want, groceries)
+		}
+	}
+	planFile(t, plan, "keep/attachments/photo.jpg")
+	planFile(t, plan, "keep/Untitled.md")
+
+	daily := string(planFile(t, plan, "daily/2024-02-10.md").content)
+	if !strings.Contains(daily, "Dentist at 10") || !strings.Contains(daily, "![photo.jpg](../keep/attachments/photo.jpg)") {
+		t.Fatalf("daily note = %q", daily)
+	}
+
+	if len(plan.Skipped) != 1 || plan.Skipped[0].Source != "Takeout/Keep/Old.json" {
+		t.Fatalf("Skipped = %+v", plan.Skipped)
+	}
+	report := string(planFile(t, plan, "keep/IMPORT-REPORT.md").content)
+	if !strings.Contains(report, "Takeout/Keep/Old.json: note is in the Keep trash") {
+		t.Fatalf("report = %s", report)
+	}
+}
+
+func TestBuild_Errors(t *testing.T) {
+	store := vault.NewStore(t.TempDir())
+	src := fstest.MapFS{"a.txt": {Data: []byte("x")}}
+
+	if _, err := Build(store, src, Options{Format: "evernote", Person: "sebastian"}); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("unknown format err = %v", err)
+	}
+	if _, err := Build(store, src, Options{Format: FormatMarkdown, Person: "sebastian"}); !errors.Is(err, ErrNothingFound) {
+		t.Fatalf("empty source err = %v", err)
+	}
+	md := fstest.MapFS{"a.md": {Data: []byte("x")}}
+	if _, err := Build(store, md, Options{Format: FormatMarkdown, Person: "sebastian", TargetDir: "../petra"}); err == nil {
+		t.Fatal("expected escaping target dir to be rejected")
+	}
+}
@@ -0,0 +1,236 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const keepAttachmentDir = "attachments"
+
+// keepNote is one note in a Google Keep Takeout export (Takeout/Keep/*.json).
+type keepNote struct {
+	Title                   string           `json:"title"`
+	TextContent             string           `json:"textContent"`
+	ListContent             []keepListItem   `json:"listContent"`
+	Attachments             []keepAttachment `json:"attachments"`
+	Labels                  []keepLabel      `json:"labels"`
+	IsTrashed               bool             `json:"isTrashed"`
+	IsArchived              bool             `json:"isArchived"`
+	IsPinned                bool             `json:"isPinned"`
+	CreatedTimestampUsec    int64            `json:"createdTimestampUsec"`
+	UserEditedTimestampUsec int64            `json:"userEditedTimestampUsec"`
+}
+
+type keepListItem struct {
+	Text      string `json:"text"`
+	IsChecked bool   `json:"isChecked"`
+}
+
+type keepAttachment struct {
+	FilePath string `json:"filePath"`
+	Mimetype string `json:"mimetype"`
+}
+
+type keepLabel struct {
+	Name string `json:"name"`
+}
+
+var (
+	isoDatePattern  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	unsafeNameChars = regexp.MustCompile(`[\\/:*?"<>|#^\[\]\x00-\x1f]+`)
+)
+
+// convertKeep turns Keep notes into markdown. Checklists become task lists, labels
+// become tags and attachments are copied next to the notes. Trashed notes are skipped.
+// Notes titled with a date (YYYY-MM-DD) map to the matching daily note.
+func convertKeep(src fs.FS) (converted, error) {
+	var conv converted
+	budget := int64(MaxImportBytes)
+
+	var jsonPaths []string
+	files := make(map[string]string) // lower-cased path -> actual path, for attachment lookup
+	err := fs.WalkDir(src, ".", func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			if p != "." && (strings.HasPrefix(d.Name(), ".") || d.Name() == "__MACOSX") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		files[strings.ToLower(p)] = p
+		if strings.EqualFold(path.Ext(p), ".json") {
+			jsonPaths = append(jsonPaths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return conv, err
+	}
+	sort.Strings(jsonPaths)
+
+	// Attachments are embedded Obsidian-style and resolved to relative links once the
+	// note's final location is known, which differs for daily notes.
+	byName := make(map[string]string)
+	for _, p := range jsonPaths {
+		data, err := readLimited(src, p, &budget)
+		if err != nil {
+			return conv, err
+		}
+		var kn keepNote
+		if err := json.Unmarshal(data, &kn); err != nil || !kn.isNote() {
+			// Takeout also ships Labels.json and other metadata; ignore anything
+			// that does not look like a note.
+			continue
+		}
+		if kn.IsTrashed {
+			conv.skipped = append(conv.skipped, Skip{Source: p, Reason: "note is in the Keep trash"})
+			continue
+		}
+
+		var embeds []string
+		for _, a := range kn.Attachments {
+			actual, ok := findKeepAttachment(files, path.Dir(p), a.FilePath)
+			if !ok {
+				conv.skipped = append(conv.skipped, Skip{Source: a.FilePath, Reason: "attachment missing from export"})
+				continue
+			}
+			name := path.Base(actual)
+			rel := path.Join(keepAttachmentDir, name)
+			embeds = append(embeds, fmt.Sprintf("![[%s]]", name))
+			if _, ok := byName[name]; ok {
+				continue
+			}
+			content, err := readLimited(src, actual, &budget)
+			if err != nil {
+				return conv, err
+			}
+			byName[name] = rel
+			conv.attachments = append(conv.attachments, attachment{source: actual, relPath: rel, content: content})
+		}
+
+		n := note{source: p, content: kn.markdown(embeds), embeds: byName}
+		title := strings.TrimSpace(kn.Title)
+		if isoDatePattern.MatchString(title) {
+			n.date = title
+		}
+		n.relPath = keepFilename(title, strings.TrimSuffix(path.Base(p), path.Ext(p)))
+		conv.notes = append(conv.notes, n)
+	}
+	return conv, nil
+}
+
+func (kn keepNote) isNote() bool {
+	return kn.UserEditedTimestampUsec != 0 || kn.CreatedTimestampUsec != 0 ||
+		kn.TextContent != "" || len(kn.ListContent) > 0
+}
+
+// markdown renders the note body. The title heading is omitted for date-titled
+// notes since those are merged into a daily note that already has one.
+func (kn keepNote) markdown(embeds []string) string {
+	var b strings.Builder
+	title := strings.TrimSpace(kn.Title)
+	if title != "" && !isoDatePattern.MatchString(title) {
+		b.WriteString("# " + title + "\n\n")
+	}
+
+	var meta []string
+	if ts := kn.timestamp(); !ts.IsZero() {
+		meta = append(meta, "created: "+ts.Format("2006-01-02 15:04"))
+	}
+	if kn.IsPinned {
+		meta = append(meta, "pinned")
+	}
+	if kn.IsArchived {
+		meta = append(meta, "archived")
+	}
+	var tags []string
+	for _, l := range kn.Labels {
+		if name := strings.TrimSpace(l.Name); name != "" {
+			tags = append(tags, "#"+strings.ReplaceAll(name, " ", "-"))
+		}
+	}
+	if len(tags) > 0 {
+		meta = append(meta, strings.Join(tags, " "))
+	}
+	if len(meta) > 0 {
+		b.WriteString("_" + strings.Join(meta, " · ") + "_\n\n")
+	}
+
+	if text := strings.TrimSpace(kn.TextContent); text != "" {
+		b.WriteString(text + "\n\n")
+	}
+	for _, item := range kn.ListContent {
+		box := "[ ]"
+		if item.IsChecked {
+			box = "[x]"
+		}
+		b.WriteString("- " + box + " " + strings.TrimSpace(item.Text) + "\n")
+	}
+	if len(kn.ListContent) > 0 {
+		b.WriteString("\n")
+	}
+	for _, e := range embeds {
+		b.WriteString(e + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+// timestamp returns the creation time, falling back to the last edit.
+func (kn keepNote) timestamp() time.Time {
+	usec := kn.CreatedTimestampUsec
+	if usec == 0 {
+		usec = kn.UserEditedTimestampUsec
+	}
+	if usec == 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(usec).UTC()
+}
+
+// findKeepAttachment locates an attachment next to its note. Takeout sometimes
+// records .jpeg where the exported file is .jpg (and vice versa).
+func findKeepAttachment(files map[string]string, dir, name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+	candidates := []string{path.Join(dir, name)}
+	ext := strings.ToLower(path.Ext(name))
+	base := strings.TrimSuffix(name, path.Ext(name))
+	switch ext {
+	case ".jpeg":
+		candidates = append(candidates, path.Join(dir, base+".jpg"))
+	case ".jpg":
+		candidates = append(candidates, path.Join(dir, base+".jpeg"))
+	}
+	for _, c := range candidates {
+		if actual, ok := files[strings.ToLower(c)]; ok {
+			return actual, true
+		}
+	}
+	return "", false
+}
+
+// keepFilename derives a markdown file name from the note title, falling back to
+// the export's own file name for untitled notes.
+func keepFilename(title, fallback string) string {
+	name := strings.TrimSpace(unsafeNameChars.ReplaceAllString(title, " "))
+	name = strings.Join(strings.Fields(name), " ")
+	if r := []rune(name); len(r) > 80 {
+		name = strings.TrimSpace(string(r[:80]))
+	}
+	if name == "" || strings.HasPrefix(name, ".") {
+		name = fallback
+	}
+	return name + ".md"
+}
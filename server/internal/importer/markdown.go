@@ -0,0 +1,151 @@
+package importer
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+
+	"notes-editor/internal/vault"
+)
+
+// wikiEmbedPattern matches Obsidian embeds such as ![[photo.png]] or ![[scan.pdf|Scan]].
+var wikiEmbedPattern = regexp.MustCompile(`!\[\[([^\]|#]+)(?:#[^\]|]*)?(?:\|([^\]]*))?\]\]`)
+
+// obsidianSkipDirs are Obsidian's own config and trash folders, never imported.
+var obsidianSkipDirs = map[string]bool{
+	".obsidian": true,
+	".trash":    true,
+}
+
+// convertMarkdownTree imports a folder of markdown files, keeping its layout. Other
+// files are carried along as attachments so relative links keep working. With
+// obsidian set, wiki-style embeds of attachments are rewritten to standard markdown
+// links because the editor does not resolve Obsidian's vault-wide names.
+func convertMarkdownTree(src fs.FS, obsidian bool) (converted, error) {
+	var conv converted
+	budget := int64(MaxImportBytes)
+
+	root := singleTopDir(src)
+	var mdPaths, attPaths []string
+	err := fs.WalkDir(src, root, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if p == root {
+			return nil
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if strings.HasPrefix(name, ".") || name == "__MACOSX" || (obsidian && obsidianSkipDirs[name]) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(name, ".") || !d.Type().IsRegular() {
+			return nil
+		}
+		if strings.EqualFold(path.Ext(name), ".md") {
+			mdPaths = append(mdPaths, p)
+		} else {
+			attPaths = append(attPaths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return conv, err
+	}
+
+	// Obsidian resolves embeds by file name anywhere in the vault.
+	byName := make(map[string]string)
+	for _, p := range attPaths {
+		rel := relTo(root, p)
+		if _, dup := byName[path.Base(rel)]; !dup {
+			byName[path.Base(rel)] = rel
+		}
+		data, err := readLimited(src, p, &budget)
+		if err != nil {
+			return conv, err
+		}
+		conv.attachments = append(conv.attachments, attachment{source: rel, relPath: rel, content: data})
+	}
+
+	for _, p := range mdPaths {
+		rel := relTo(root, p)
+		data, err := readLimited(src, p, &budget)
+		if err != nil {
+			return conv, err
+		}
+		n := note{source: rel, relPath: rel, content: string(data)}
+		if m := dailyFilePattern.FindStringSubmatch(path.Base(rel)); m != nil {
+			n.date = m[1]
+		}
+		if obsidian {
+			n.embeds = byName
+		}
+		conv.notes = append(conv.notes, n)
+	}
+	return conv, nil
+}
+
+// rewriteEmbeds replaces attachment embeds with markdown links relative to dir.
+// All paths are vault-relative; embeds of other notes are left untouched. placed
+// maps attachments renamed to avoid a clash to their new path.
+func rewriteEmbeds(content, dir, target string, byName, placed map[string]string) string {
+	return wikiEmbedPattern.ReplaceAllStringFunc(content, func(m string) string {
+		parts := wikiEmbedPattern.FindStringSubmatch(m)
+		name := strings.TrimSpace(parts[1])
+		rel, ok := byName[path.Base(name)]
+		if !ok {
+			return m
+		}
+		alt := strings.TrimSpace(parts[2])
+		if alt == "" {
+			alt = path.Base(name)
+		}
+		dest := path.Join(target, rel)
+		if renamed, ok := placed[dest]; ok {
+			dest = renamed
+		}
+		return fmt.Sprintf("![%s](%s)", alt, linkTarget(vault.RelativeLink(dir, dest)))
+	})
+}
+
+// linkTarget wraps paths containing spaces in angle brackets as CommonMark requires.
+func linkTarget(p string) string {
+	if strings.ContainsAny(p, " ()") {
+		return "<" + p + ">"
+	}
+	return p
+}
+
+// singleTopDir returns the only top-level folder of src when an export was zipped
+// with a wrapping folder, so the wrapper does not end up in the vault.
+func singleTopDir(src fs.FS) string {
+	entries, err := fs.ReadDir(src, ".")
+	if err != nil {
+		return "."
+	}
+	var dirs []fs.DirEntry
+	for _, e := range entries {
+		if e.Name() == "__MACOSX" || strings.HasPrefix(e.Name(), ".") && e.IsDir() {
+			continue
+		}
+		if !e.IsDir() {
+			return "."
+		}
+		dirs = append(dirs, e)
+	}
+	if len(dirs) == 1 {
+		return dirs[0].Name()
+	}
+	return "."
+}
+
+func relTo(root, p string) string {
+	if root == "." {
+		return p
+	}
+	return strings.TrimPrefix(p, root+"/")
+}
@@ -0,0 +1,54 @@
+package publish
+
+import (
+	"sync"
+	"time"
+
+	"notes-editor/internal/vault"
+)
+
+// indexMaxAge bounds how long a cached list is served when the vault changed
+// without going through the store, e.g. by the gateway sidecar.
+const indexMaxAge = time.Minute
+
+// Index caches each person's published notes so the public site does not walk
+// the vault on every request. A list is rebuilt after the store's generation
+// changed or after indexMaxAge.
+type Index struct {
+	store *vault.Store
+
+	mu      sync.Mutex
+	entries map[string]indexEntry
+}
+
+type indexEntry struct {
+	generation uint64
+	builtAt    time.Time
+	paths      []string
+}
+
+// NewIndex returns an empty published-notes cache for store.
+func NewIndex(store *vault.Store) *Index {
+	return &Index{store: store, entries: make(map[string]indexEntry)}
+}
+
+// Published returns the vault-relative paths of the person's published notes,
+// like ListPublished. The returned slice must not be modified.
+func (x *Index) Published(person string) ([]string, error) {
+	generation := x.store.Generation()
+	x.mu.Lock()
+	entry, ok := x.entries[person]
+	x.mu.Unlock()
+	if ok && entry.generation == generation && time.Since(entry.builtAt) < indexMaxAge {
+		return entry.paths, nil
+	}
+
+	paths, err := ListPublished(x.store, person)
+	if err != nil {
+		return nil, err
+	}
+	x.mu.Lock()
+	x.entries[person] = indexEntry{generation: generation, builtAt: time.Now(), paths: paths}
+	x.mu.Unlock()
+	return paths, nil
+}
@@ -0,0 +1,201 @@
+// Package publish decides which notes are public and manages revocable share links.
+//
+// A note is published only when it says so itself: it lives under the top-level
+// public/ folder or its front matter contains "publish: true". Everything else is
+// private and must never be served by the public site.
+package publish
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"notes-editor/internal/vault"
+)
+
+// PublicDir is the top-level folder whose notes are always published.
+const PublicDir = "public"
+
+// ErrNotPublished is returned for notes that are missing, private or otherwise not
+// servable. Callers must not distinguish these cases to anonymous clients.
+var ErrNotPublished = errors.New("note is not published")
+
+// IsPublished reports whether the note at rel with the given content is published.
+func IsPublished(rel, content string) bool {
+	rel = filepath.ToSlash(rel)
+	if !strings.EqualFold(path.Ext(rel), ".md") {
+		return false
+	}
+	if strings.HasPrefix(rel, PublicDir+"/") {
+		return true
+	}
+	value, ok := frontMatterValue(content, "publish")
+	if !ok {
+		return false
+	}
+	published, err := strconv.ParseBool(strings.Trim(value, `"'`))
+	return err == nil && published
+}
+
+// frontMatterValue returns a top-level scalar from a leading front matter block.
+func frontMatterValue(content, key string) (string, bool) {
+	if !strings.HasPrefix(content, "---\n") {
+		return "", false
+	}
+	end := strings.Index(content[4:], "\n---")
+	if end < 0 {
+		return "", false
+	}
+	for _, line := range strings.Split(content[4:4+end], "\n") {
+		k, v, found := strings.Cut(line, ":")
+		if !found || strings.TrimSpace(k) != key || strings.HasPrefix(k, " ") {
+			continue
+		}
+		return strings.TrimSpace(v), true
+	}
+	return "", false
+}
+
+// ReadNote returns a note's content for serving. Paths resolve like in the editor,
+// so _shared/<folder>/ notes come from the shared folder. It fails with
+// ErrNotPublished for invalid paths, symlinks leaving the person's vault or the
+// shared folder and non-markdown files; the caller decides whether the note must
+// also be published.
+func ReadNote(store *vault.Store, person, rel string) (string, error) {
+	rel = strings.Trim(rel, "/")
+	if rel == "" || !strings.EqualFold(path.Ext(rel), ".md") {
+		return "", ErrNotPublished
+	}
+	for _, part := range strings.Split(rel, "/") {
+		if strings.HasPrefix(part, ".") {
+			return "", ErrNotPublished
+		}
+	}
+	fullPath, err := store.Resolve(person, filepath.FromSlash(rel))
+	if err != nil {
+		return "", ErrNotPublished
+	}
+	root, err := servingRoot(store, person, rel)
+	if err != nil || !insideRoot(root, fullPath) {
+		return "", ErrNotPublished
+	}
+	info, err := os.Lstat(fullPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", ErrNotPublished
+	}
+	content, err := store.ReadFile(person, filepath.FromSlash(rel))
+	if err != nil {
+		return "", ErrNotPublished
+	}
+	return content, nil
+}
+
+// ReadPublished returns the content of a published note, or ErrNotPublished.
+func ReadPublished(store *vault.Store, person, rel string) (string, error) {
+	content, err := ReadNote(store, person, rel)
+	if err != nil {
+		return "", err
+	}
+	if !IsPublished(rel, content) {
+		return "", ErrNotPublished
+	}
+	return content, nil
+}
+
+// ListPublished returns the vault-relative paths of all published notes of a person,
+// including those in shared folders the person can access, sorted by path. Hidden
+// folders and symlinks are skipped.
+func ListPublished(store *vault.Store, person string) ([]string, error) {
+	personRoot, err := store.Resolve(person, ".")
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	if err := walkPublished(store, person, personRoot, "", &out); err != nil {
+		return nil, err
+	}
+	for _, folder := range store.SharedFolders(person) {
+		root, err := store.Resolve(person, filepath.FromSlash(folder.Path))
+		if err != nil {
+			continue
+		}
+		if err := walkPublished(store, person, root, folder.Path, &out); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// walkPublished appends the published notes below root, which the person sees at
+// prefix, to out.
+func walkPublished(store *vault.Store, person, root, prefix string, out *[]string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if os.IsNotExist(walkErr) && p == root {
+				return filepath.SkipDir
+			}
+			return walkErr
+		}
+		if p == root {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = path.Join(prefix, filepath.ToSlash(rel))
+		if d.IsDir() {
+			// A person's own _shared directory is shadowed by the shared folders.
+			if rel == vault.SharedDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !strings.EqualFold(filepath.Ext(p), ".md") {
+			return nil
+		}
+		content, err := store.ReadFile(person, filepath.FromSlash(rel))
+		if err != nil {
+			return err
+		}
+		if IsPublished(rel, content) {
+			*out = append(*out, rel)
+		}
+		return nil
+	})
+}
+
+// servingRoot returns the directory a note at rel must stay within: the shared
+// folder for _shared/<folder>/ paths and the person's vault otherwise.
+func servingRoot(store *vault.Store, person, rel string) (string, error) {
+	parts := strings.SplitN(rel, "/", 3)
+	if len(parts) == 3 && parts[0] == vault.SharedDir {
+		return store.Resolve(person, path.Join(parts[0], parts[1]))
+	}
+	return store.Resolve(person, ".")
+}
+
+// insideRoot reports whether fullPath, with symlinks resolved, stays within root.
+func insideRoot(root, fullPath string) bool {
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return false
+	}
+	resolved, err := filepath.EvalSymlinks(fullPath)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(resolved, root+string(filepath.Separator))
+}
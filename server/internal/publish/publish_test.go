@@ -0,0 +1,206 @@
+package publish
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"notes-editor/internal/vault"
+)
+
+func TestIsPublished(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		content string
+		want    bool
+	}{
+		{"public folder", "public/about.md", "hi", true},
+		{"nested public folder is not special", "notes/public/x.md", "hi", false},
+		{"front matter true", "notes/trip.md", "---\npublish: true\ntags: x\n---\nbody", true},
+		{"front matter quoted", "notes/trip.md", "---\npublish: \"yes\"\n---\n", false},
+		{"front matter false", "notes/trip.md", "---\npublish: false\n---\nbody", false},
+		{"nested key ignored", "notes/trip.md", "---\nmeta:\n  publish: true\n---\n", false},
+		{"key in body ignored", "notes/trip.md", "body\npublish: true\n", false},
+		{"unterminated front matter", "notes/trip.md", "---\npublish: true\n", false},
+		{"non-markdown in public", "public/photo.png", "", false},
+		{"private by default", "notes/secret.md", "# Secret", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPublished(tt.path, tt.content); got != tt.want {
+				t.Errorf("IsPublished(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListAndReadPublished(t *testing.T) {
+	root := t.TempDir()
+	store := vault.NewStore(root)
+	files := map[string]string{
+		"public/about.md":       "# About",
+		"notes/trip.md":         "---\npublish: true\n---\n# Trip",
+		"notes/secret.md":       "# Secret",
+		".hidden/public.md":     "---\npublish: true\n---\n",
+		"public/.draft.md":      "draft",
+		"public/attachment.txt": "txt",
+	}
+	for p, content := range files {
+		if err := store.WriteFile("sebastian", p, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.WriteFile("petra", "notes/petra.md", "# Petra"); err != nil {
+		t.Fatal(err)
+	}
+	// A published-looking symlink pointing at another person's vault must not be served.
+	os.Symlink(filepath.Join(root, "petra", "notes", "petra.md"), filepath.Join(root, "sebastian", "public", "link.md"))
+
+	got, err := ListPublished(store, "sebastian")
+	if err != nil {
+		t.Fatalf("ListPublished: %v", err)
+	}
+	if want := []string{"notes/trip.md", "public/about.md"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ListPublished = %v, want %v", got, want)
+	}
+
+	if _, err := ReadPublished(store, "sebastian", "notes/trip.md"); err != nil {
+		t.Fatalf("ReadPublished(trip): %v", err)
+	}
+	for _, p := range []string{"notes/secret.md", "public/link.md", "../petra/notes/petra.md", "public/.draft.md", "public/attachment.txt", "missing.md"} {
+		if _, err := ReadPublished(store, "sebastian", p); !errors.Is(err, ErrNotPublished) {
+			t.Errorf("ReadPublished(%q) err = %v, want ErrNotPublished", p, err)
+		}
+	}
+}
+
+func TestListAndReadPublishedSharedFolders(t *testing.T) {
+	root := t.TempDir()
+	store := vault.NewStore(root)
+	folders, err := vault.ParseSharedFolders("family=sebastian,petra; budget=petra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetSharedFolders(folders)
+	for p, content := range map[string]string{
+		"_shared/family/trip.md":  "---\npublish: true\n---\n# Trip",
+		"_shared/family/notes.md": "# Private",
+	} {
+		if err := store.WriteFile("petra", p, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.WriteFile("petra", "_shared/budget/plan.md", "---\npublish: true\n---\n"); err != nil {
+		t.Fatal(err)
+	}
+	// A _shared folder inside the person's own directory is shadowed.
+	shadowed := filepath.Join(root, "sebastian", "_shared", "family", "old.md")
+	os.MkdirAll(filepath.Dir(shadowed), 0755)
+	os.WriteFile(shadowed, []byte("---\npublish: true\n---\n"), 0644)
+
+	got, err := ListPublished(store, "sebastian")
+	if err != nil {
+		t.Fatalf("ListPublished: %v", err)
+	}
+	if want := []string{"_shared/family/trip.md"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ListPublished = %v, want %v", got, want)
+	}
+	if content, err := ReadPublished(store, "sebastian", "_shared/family/trip.md"); err != nil || !strings.Contains(content, "# Trip") {
+		t.Fatalf("ReadPublished(shared) = %q, %v", content, err)
+	}
+	for _, p := range []string{"_shared/family/old.md", "_shared/family/notes.md", "_shared/budget/plan.md"} {
+		if _, err := ReadPublished(store, "sebastian", p); !errors.Is(err, ErrNotPublished) {
+			t.Errorf("ReadPublished(%q) err = %v, want ErrNotPublished", p, err)
+		}
+	}
+}
+
+func TestShares(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shares.json")
+	shares := NewShares(path)
+
+	first, token, err := shares.Create("sebastian", "notes/secret.md")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if len(token) < 32 {
+		t.Fatalf("token too short: %q", token)
+	}
+	if raw, _ := os.ReadFile(path); strings.Contains(string(raw), token) {
+		t.Fatal("plaintext token stored")
+	}
+	if _, _, err := shares.Create("petra", "notes/petra.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := shares.List("sebastian")
+	if err != nil || len(list) != 1 || list[0].ID != first.ID {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+	found, ok, err := shares.Lookup(token)
+	if err != nil || !ok || found.Path != "notes/secret.md" {
+		t.Fatalf("Lookup = %+v, %v, %v", found, ok, err)
+	}
+
+	if err := shares.Revoke("petra", first.ID); !errors.Is(err, ErrShareNotFound) {
+		t.Fatalf("revoking another person's share: %v", err)
+	}
+	if err := shares.Revoke("sebastian", first.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, ok, _ := shares.Lookup(token); ok {
+		t.Fatal("revoked share still resolves")
+	}
+	if list, _ := shares.List("petra"); len(list) != 1 {
+		t.Fatalf("petra's share was affected: %+v", list)
+	}
+}
+
+func TestSharesImportLegacy(t *testing.T) {
+	root := t.TempDir()
+	store := vault.NewStore(root)
+	legacy := `[{"token":"legacy-token","person":"sebastian","path":"notes/trip.md","created_at":"2026-01-02T03:04:05Z"}]`
+	if err := store.WriteRootFile(LegacySharesFile, legacy); err != nil {
+		t.Fatal(err)
+	}
+	shares := NewShares(filepath.Join(t.TempDir(), "shares.json"))
+
+	migrated, err := shares.ImportLegacy(store)
+	if err != nil || !migrated {
+		t.Fatalf("ImportLegacy = %v, %v", migrated, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(LegacySharesFile))); !os.IsNotExist(err) {
+		t.Fatalf("legacy file should be deleted, stat err = %v", err)
+	}
+	found, ok, err := shares.Lookup("legacy-token")
+	if err != nil || !ok || found.Path != "notes/trip.md" {
+		t.Fatalf("Lookup = %+v, %v, %v", found, ok, err)
+	}
+	if migrated, err := shares.ImportLegacy(store); err != nil || migrated {
+		t.Fatalf("second ImportLegacy = %v, %v", migrated, err)
+	}
+}
+
+func TestIndexFollowsStoreChanges(t *testing.T) {
+	store := vault.NewStore(t.TempDir())
+	if err := store.WriteFile("sebastian", "public/a.md", "a"); err != nil {
+		t.Fatal(err)
+	}
+	index := NewIndex(store)
+	if paths, err := index.Published("sebastian"); err != nil || !reflect.DeepEqual(paths, []string{"public/a.md"}) {
+		t.Fatalf("Published = %v, %v", paths, err)
+	}
+	if err := store.WriteFile("sebastian", "notes/b.md", "---\npublish: true\n---\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteFile("sebastian", "public/a.md"); err != nil {
+		t.Fatal(err)
+	}
+	if paths, err := index.Published("sebastian"); err != nil || !reflect.DeepEqual(paths, []string{"notes/b.md"}) {
+		t.Fatalf("Published after change = %v, %v", paths, err)
+	}
+}
@@ -0,0 +1,212 @@
+package publish
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"notes-editor/internal/fileutil"
+	"notes-editor/internal/vault"
+)
+
+// LegacySharesFile is the vault-root file that held share links with plaintext
+// tokens before they moved out of the synced vault. ImportLegacy migrates it.
+const LegacySharesFile = ".publish/shares.json"
+
+// ErrShareNotFound is returned when revoking an unknown share link.
+var ErrShareNotFound = errors.New("share link not found")
+
+// Share is a revocable link to a single note, served at /p/s/<token>. Only the
+// SHA-256 hash of the token is stored; the plaintext is returned once by Create.
+type Share struct {
+	ID        string    `json:"id"`
+	Person    string    `json:"person"`
+	Path      string    `json:"path"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Shares reads and writes share links in a local file outside the synced vault.
+type Shares struct {
+	mu   sync.RWMutex
+	path string
+}
+
+// NewShares returns a share link store backed by the file at path.
+func NewShares(path string) *Shares {
+	return &Shares{path: path}
+}
+
+// hashToken returns the hex-encoded SHA-256 hash under which a token is stored.
+// Tokens are long random strings, so an unsalted fast hash is sufficient.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Shares) load() ([]Share, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var shares []Share
+	if err := json.Unmarshal(data, &shares); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+func (s *Shares) save(shares []Share) error {
+	if shares == nil {
+		shares = make([]Share, 0)
+	}
+	data, err := json.MarshalIndent(shares, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteAtomic(s.path, append(data, '\n'), 0600)
+}
+
+// List returns the person's share links, newest first.
+func (s *Shares) List(person string) ([]Share, error) {
+	s.mu.RLock()
+	all, err := s.load()
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Share, 0)
+	for _, sh := range all {
+		if sh.Person == person {
+			out = append(out, sh)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+// Create adds a share link for the note at path and returns it together with
+// the plaintext token, which is not retrievable afterwards.
+func (s *Shares) Create(person, path string) (Share, string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return Share{}, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	sh := Share{ID: uuid.NewString(), Person: person, Path: path, Hash: hashToken(token), CreatedAt: time.Now().UTC()}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return Share{}, "", err
+	}
+	if err := s.save(append(all, sh)); err != nil {
+		return Share{}, "", err
+	}
+	return sh, token, nil
+}
+
+// Revoke deletes the person's share link with the given ID.
+func (s *Shares) Revoke(person, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i, sh := range all {
+		if sh.ID == id && sh.Person == person {
+			return s.save(append(all[:i], all[i+1:]...))
+		}
+	}
+	return ErrShareNotFound
+}
+
+// Lookup returns the share link with the given plaintext token.
+func (s *Shares) Lookup(token string) (Share, bool, error) {
+	if token == "" {
+		return Share{}, false, nil
+	}
+	s.mu.RLock()
+	all, err := s.load()
+	s.mu.RUnlock()
+	if err != nil {
+		return Share{}, false, err
+	}
+	hash := []byte(hashToken(token))
+	for _, sh := range all {
+		if subtle.ConstantTimeCompare(hash, []byte(sh.Hash)) == 1 {
+			return sh, true, nil
+		}
+	}
+	return Share{}, false, nil
+}
+
+// ImportLegacy moves share links from LegacySharesFile in the vault into the
+// store, keeping their tokens valid, and deletes the vault file. It reports
+// whether the vault changed. The caller holds the vault write lock.
+func (s *Shares) ImportLegacy(store *vault.Store) (bool, error) {
+	content, err := store.ReadRootFile(LegacySharesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var legacy []struct {
+		Token     string    `json:"token"`
+		Person    string    `json:"person"`
+		Path      string    `json:"path"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	if err := json.Unmarshal([]byte(content), &legacy); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.load()
+	if err != nil {
+		return false, err
+	}
+	known := make(map[string]bool, len(all))
+	for _, sh := range all {
+		known[sh.Hash] = true
+	}
+	for _, l := range legacy {
+		hash := hashToken(l.Token)
+		if l.Token == "" || known[hash] {
+			continue
+		}
+		known[hash] = true
+		all = append(all, Share{ID: uuid.NewString(), Person: l.Person, Path: l.Path, Hash: hash, CreatedAt: l.CreatedAt})
+	}
+	if err := s.save(all); err != nil {
+		return false, err
+	}
+
+	fullPath, err := vault.ResolveRootPath(store.RootPath(), LegacySharesFile)
+	if err != nil {
+		return false, err
+	}
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,177 @@
+// Package ratelimit provides in-memory request limiters and a lockout for
+// repeated authentication failures.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval limits how often idle entries are removed.
+const sweepInterval = time.Minute
+
+// Limiter is a token bucket per key: each key may make up to perMinute requests
+// in a burst, refilled evenly over a minute. A nil Limiter allows everything.
+type Limiter struct {
+	mu        sync.Mutex
+	rate      float64 // tokens per second
+	burst     float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewLimiter returns a limiter allowing perMinute requests per key, or nil when
+// perMinute is not positive.
+func NewLimiter(perMinute int) *Limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &Limiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(perMinute),
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Allow takes one request from key's budget. When the budget is spent it
+// returns false and how long until the next request is allowed.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets that have refilled completely. The caller holds l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Lockout blocks a key after maxFailures failures within duration, for
+// duration. A nil Lockout never blocks.
+type Lockout struct {
+	mu          sync.Mutex
+	maxFailures int
+	duration    time.Duration
+	entries     map[string]*lockoutEntry
+	lastSweep   time.Time
+	now         func() time.Time
+}
+
+type lockoutEntry struct {
+	failures    int
+	first       time.Time
+	lockedUntil time.Time
+}
+
+// NewLockout returns a lockout, or nil when maxFailures or duration is not
+// positive.
+func NewLockout(maxFailures int, duration time.Duration) *Lockout {
+	if maxFailures <= 0 || duration <= 0 {
+		return nil
+	}
+	return &Lockout{
+		maxFailures: maxFailures,
+		duration:    duration,
+		entries:     map[string]*lockoutEntry{},
+		now:         time.Now,
+	}
+}
+
+// Locked returns how long key stays locked out, or zero.
+func (l *Lockout) Locked(key string) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+	if remaining := e.lockedUntil.Sub(l.now()); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Fail records a failure for key and reports whether key is now locked out.
+func (l *Lockout) Fail(key string) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.first) >= l.duration && !now.Before(e.lockedUntil) {
+		e = &lockoutEntry{first: now}
+		l.entries[key] = e
+	}
+	e.failures++
+	if e.failures >= l.maxFailures {
+		e.lockedUntil = now.Add(l.duration)
+		return true
+	}
+	return false
+}
+
+// Reset forgets key's failures after a successful authentication.
+func (l *Lockout) Reset(key string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.entries[key]; ok && !l.now().Before(e.lockedUntil) {
+		delete(l.entries, key)
+	}
+}
+
+// sweep drops expired entries. The caller holds l.mu.
+func (l *Lockout) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, e := range l.entries {
+		if now.Sub(e.first) >= l.duration && !now.Before(e.lockedUntil) {
+			delete(l.entries, key)
+		}
+	}
+}
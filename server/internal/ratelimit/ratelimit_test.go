@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestLimiterAllowsBurstThenRefills(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLimiter(3)
+	l.now = clock.now
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d rejected", i)
+		}
+	}
+	ok, wait := l.Allow("a")
+	if ok {
+		t.Fatal("expected fourth request to be rejected")
+	}
+	if wait <= 0 || wait > 20*time.Second {
+		t.Fatalf("wait = %v, want about 20s", wait)
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Fatal("other keys have their own budget")
+	}
+
+	clock.advance(wait)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatal("expected request after waiting to be allowed")
+	}
+}
+
+func TestNilLimiterAllows(t *testing.T) {
+	l := NewLimiter(0)
+	if l != nil {
+		t.Fatal("expected nil limiter for zero budget")
+	}
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatal("nil limiter rejected a request")
+	}
+}
+
+func TestLockout(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLockout(3, 10*time.Minute)
+	l.now = clock.now
+
+	if l.Fail("ip") || l.Fail("ip") {
+		t.Fatal("locked out too early")
+	}
+	if !l.Fail("ip") {
+		t.Fatal("expected lockout after third failure")
+	}
+	if got := l.Locked("ip"); got != 10*time.Minute {
+		t.Fatalf("Locked = %v", got)
+	}
+	l.Reset("ip")
+	if l.Locked("ip") == 0 {
+		t.Fatal("reset must not lift an active lockout")
+	}
+
+	clock.advance(10 * time.Minute)
+	if l.Locked("ip") != 0 {
+		t.Fatal("lockout did not expire")
+	}
+	if l.Fail("ip") {
+		t.Fatal("failures must start over after a lockout")
+	}
+}
+
+func TestLockoutFailuresExpire(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLockout(2, time.Minute)
+	l.now = clock.now
+
+	l.Fail("ip")
+	clock.advance(2 * time.Minute)
+	if l.Fail("ip") {
+		t.Fatal("old failure counted")
+	}
+	l.Reset("ip")
+	if l.Fail("ip") {
+		t.Fatal("reset did not clear failures")
+	}
+}
@@ -0,0 +1,129 @@
+// Package sandbox runs agent shell commands isolated from the host: only one
+// directory is visible read-write, the network is off unless requested, a
+// seccomp filter blocks kernel administration syscalls and CPU and memory are
+// limited. It uses bubblewrap (bwrap) on Linux.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Modes for BASH_SANDBOX.
+const (
+	// ModeOff runs commands directly on the host.
+	ModeOff = "off"
+	// ModeOn runs commands in the sandbox without network access.
+	ModeOn = "on"
+	// ModeNetwork runs commands in the sandbox with network access.
+	ModeNetwork = "network"
+)
+
+// Default resource limits.
+const (
+	DefaultMemoryMB   = 1024
+	DefaultCPUSeconds = 600
+)
+
+// WorkDir is where the exposed directory is mounted inside the sandbox.
+const WorkDir = "/vault"
+
+// ErrUnavailable is returned when the sandbox cannot be set up on this host.
+var ErrUnavailable = errors.New("sandbox unavailable")
+
+// Limits bounds the resources of one sandboxed command. Zero means unlimited.
+type Limits struct {
+	MemoryMB   int
+	CPUSeconds int
+}
+
+// Policy selects the sandbox mode per person.
+type Policy struct {
+	Default string
+	Persons map[string]string
+	Limits  Limits
+}
+
+// ParsePolicy parses BASH_SANDBOX, e.g. "on,petra=network,sebastian=off". An
+// entry without a person sets the default; an empty spec means off.
+func ParsePolicy(spec string) (Policy, error) {
+	policy := Policy{Default: ModeOff, Persons: map[string]string{}}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		person, mode, hasPerson := strings.Cut(entry, "=")
+		if !hasPerson {
+			mode, person = person, ""
+		}
+		person = strings.TrimSpace(person)
+		mode = strings.ToLower(strings.TrimSpace(mode))
+		if !validMode(mode) {
+			return Policy{}, fmt.Errorf("bash sandbox %q: mode must be off, on or network", entry)
+		}
+		if !hasPerson {
+			policy.Default = mode
+			continue
+		}
+		if person == "" {
+			return Policy{}, fmt.Errorf("bash sandbox %q: expected person=mode", entry)
+		}
+		policy.Persons[person] = mode
+	}
+	return policy, nil
+}
+
+// ModeFor returns the person's sandbox mode.
+func (p Policy) ModeFor(person string) string {
+	if mode, ok := p.Persons[person]; ok {
+		return mode
+	}
+	if p.Default == "" {
+		return ModeOff
+	}
+	return p.Default
+}
+
+func validMode(mode string) bool {
+	switch mode {
+	case ModeOff, ModeOn, ModeNetwork:
+		return true
+	default:
+		return false
+	}
+}
+
+// Spec describes one sandboxed command.
+type Spec struct {
+	// Dir is mounted read-write at WorkDir, the working directory.
+	Dir     string
+	Network bool
+	Limits  Limits
+}
+
+// Run executes script with bash inside the sandbox. Errors from the command
+// itself are returned as *exec.ExitError; errors wrapping ErrUnavailable mean
+// the sandbox could not be started and the command did not run.
+func Run(ctx context.Context, spec Spec, script string, stdout, stderr io.Writer) error {
+	if err := Available(); err != nil {
+		return err
+	}
+	return run(ctx, spec, script, stdout, stderr)
+}
+
+// limitScript wraps script so that bash applies the limits before running it.
+func limitScript(limits Limits) string {
+	var b strings.Builder
+	if limits.MemoryMB > 0 {
+		fmt.Fprintf(&b, "ulimit -v %d || exit 126; ", limits.MemoryMB*1024)
+	}
+	if limits.CPUSeconds > 0 {
+		fmt.Fprintf(&b, "ulimit -t %d || exit 126; ", limits.CPUSeconds)
+	}
+	b.WriteString(`exec /bin/bash -c "$1"`)
+	return b.String()
+}
@@ -0,0 +1,203 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// probeTimeout bounds the trial run that checks whether bwrap works here.
+const probeTimeout = 10 * time.Second
+
+var (
+	probeMu sync.Mutex
+	probed  bool
+)
+
+// Available reports whether sandboxed commands can run on this host. A
+// successful check is cached; failures are retried on the next call.
+func Available() error {
+	probeMu.Lock()
+	defer probeMu.Unlock()
+	if probed {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	if err := run(ctx, Spec{Dir: os.TempDir()}, "true", io.Discard, &stderr); err != nil {
+		if errors.Is(err, ErrUnavailable) {
+			return err
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", ErrUnavailable, msg)
+		}
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	probed = true
+	return nil
+}
+
+func run(ctx context.Context, spec Spec, script string, stdout, stderr io.Writer) error {
+	bwrap, err := exec.LookPath("bwrap")
+	if err != nil {
+		return fmt.Errorf("%w: bubblewrap (bwrap) is not installed", ErrUnavailable)
+	}
+	program, err := seccompProgram()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	// bwrap reads the seccomp filter from fd 3. It is small enough to fit in
+	// the pipe buffer, so it can be written before the command starts.
+	filter, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer filter.Close()
+	_, err = w.Write(program)
+	w.Close()
+	if err != nil {
+		return err
+	}
+
+	args := append(bwrapArgs(spec, 3), "--", "/bin/bash", "-c", limitScript(spec.Limits), "sandbox", script)
+	cmd := exec.CommandContext(ctx, bwrap, args...)
+	cmd.ExtraFiles = []*os.File{filter}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+func bwrapArgs(spec Spec, seccompFD int) []string {
+	args := []string{
+		"--unshare-all",
+		"--die-with-parent",
+		"--new-session",
+		"--cap-drop", "ALL",
+		"--hostname", "sandbox",
+		"--clearenv",
+		"--setenv", "PATH", "/usr/local/bin:/usr/bin:/bin",
+		"--setenv", "HOME", WorkDir,
+		"--setenv", "LANG", "C.UTF-8",
+		"--ro-bind", "/usr", "/usr",
+	}
+	for _, dir := range []string{"/bin", "/sbin", "/lib", "/lib64", "/lib32", "/etc/alternatives", "/etc/ssl", "/etc/ld.so.cache"} {
+		args = append(args, "--ro-bind-try", dir, dir)
+	}
+	if spec.Network {
+		args = append(args, "--share-net")
+		for _, file := range []string{"/etc/resolv.conf", "/etc/hosts", "/etc/nsswitch.conf"} {
+			args = append(args, "--ro-bind-try", file, file)
+		}
+	}
+	return append(args,
+		"--proc", "/proc",
+		"--dev", "/dev",
+		"--tmpfs", "/tmp",
+		"--bind", spec.Dir, WorkDir,
+		"--chdir", WorkDir,
+		"--seccomp", fmt.Sprint(seccompFD),
+	)
+}
+
+// blockedSyscalls fail with EPERM inside the sandbox. They administer the
+// kernel, inspect other processes or create new namespaces.
+var blockedSyscalls = []uint32{
+	unix.SYS_ACCT,
+	unix.SYS_ADD_KEY,
+	unix.SYS_BPF,
+	unix.SYS_CLOCK_SETTIME,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_KEXEC_FILE_LOAD,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_KEYCTL,
+	unix.SYS_MOUNT,
+	unix.SYS_NAME_TO_HANDLE_AT,
+	unix.SYS_OPEN_BY_HANDLE_AT,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_PROCESS_VM_READV,
+	unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_PTRACE,
+	unix.SYS_QUOTACTL,
+	unix.SYS_REBOOT,
+	unix.SYS_REQUEST_KEY,
+	unix.SYS_SETNS,
+	unix.SYS_SETTIMEOFDAY,
+	unix.SYS_SWAPOFF,
+	unix.SYS_SWAPON,
+	unix.SYS_SYSLOG,
+	unix.SYS_UMOUNT2,
+	unix.SYS_UNSHARE,
+	unix.SYS_USERFAULTFD,
+}
+
+// Classic BPF opcodes and seccomp return values.
+const (
+	bpfLoadAbs = unix.BPF_LD | unix.BPF_W | unix.BPF_ABS
+	bpfJumpEq  = unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K
+	bpfJumpGE  = unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K
+	bpfReturn  = unix.BPF_RET | unix.BPF_K
+
+	// Offsets into struct seccomp_data.
+	seccompNR   = 0
+	seccompArch = 4
+
+	x32SyscallBit = 0x40000000
+)
+
+// seccompProgram returns the filter as the raw sock_filter array bwrap expects.
+// Calls from a foreign architecture kill the process; blocked syscalls and
+// x32 calls on amd64 fail with EPERM.
+func seccompProgram() ([]byte, error) {
+	var arch uint32
+	switch runtime.GOARCH {
+	case "amd64":
+		arch = unix.AUDIT_ARCH_X86_64
+	case "arm64":
+		arch = unix.AUDIT_ARCH_AARCH64
+	default:
+		return nil, fmt.Errorf("no seccomp filter for %s", runtime.GOARCH)
+	}
+
+	n := len(blockedSyscalls)
+	filter := []unix.SockFilter{
+		{Code: bpfLoadAbs, K: seccompArch},
+		{Code: bpfJumpEq, Jt: 1, K: arch},
+		{Code: bpfReturn, K: unix.SECCOMP_RET_KILL_PROCESS},
+		{Code: bpfLoadAbs, K: seccompNR},
+	}
+	if runtime.GOARCH == "amd64" {
+		// Jump past the checks and the allow below to the EPERM return.
+		filter = append(filter, unix.SockFilter{Code: bpfJumpGE, Jt: uint8(n + 1), K: x32SyscallBit})
+	}
+	for i, nr := range blockedSyscalls {
+		filter = append(filter, unix.SockFilter{Code: bpfJumpEq, Jt: uint8(n - i), K: nr})
+	}
+	filter = append(filter,
+		unix.SockFilter{Code: bpfReturn, K: unix.SECCOMP_RET_ALLOW},
+		unix.SockFilter{Code: bpfReturn, K: unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM)},
+	)
+
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.NativeEndian, filter); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
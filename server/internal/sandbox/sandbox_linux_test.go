@@ -0,0 +1,36 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBwrapArgs(t *testing.T) {
+	args := strings.Join(bwrapArgs(Spec{Dir: "/notes/petra"}, 3), " ")
+	for _, want := range []string{"--unshare-all", "--bind /notes/petra /vault", "--chdir /vault", "--seccomp 3", "--clearenv"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("args missing %q: %s", want, args)
+		}
+	}
+	if strings.Contains(args, "--share-net") {
+		t.Errorf("network shared by default: %s", args)
+	}
+	if args := strings.Join(bwrapArgs(Spec{Dir: "/notes/petra", Network: true}, 3), " "); !strings.Contains(args, "--share-net") {
+		t.Errorf("network mode without --share-net: %s", args)
+	}
+}
+
+func TestSeccompProgram(t *testing.T) {
+	program, err := seccompProgram()
+	if err != nil {
+		t.Skipf("no seccomp filter here: %v", err)
+	}
+	// Each sock_filter is 8 bytes: arch check, syscall load, optional x32
+	// check, one jump per blocked syscall and two returns.
+	n := len(program) / 8
+	if len(program)%8 != 0 || n < len(blockedSyscalls)+5 || n > len(blockedSyscalls)+7 {
+		t.Fatalf("program has %d bytes", len(program))
+	}
+}
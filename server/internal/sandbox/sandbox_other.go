@@ -0,0 +1,19 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// Available reports whether sandboxed commands can run on this host.
+func Available() error {
+	return fmt.Errorf("%w: not supported on %s", ErrUnavailable, runtime.GOOS)
+}
+
+func run(context.Context, Spec, string, io.Writer, io.Writer) error {
+	return Available()
+}
@@ -0,0 +1,86 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy(" on , petra=network,sebastian = OFF ")
+	if err != nil {
+		t.Fatalf("ParsePolicy() error = %v", err)
+	}
+	for person, want := range map[string]string{"petra": ModeNetwork, "sebastian": ModeOff, "tom": ModeOn} {
+		if got := policy.ModeFor(person); got != want {
+			t.Errorf("ModeFor(%s) = %q, want %q", person, got, want)
+		}
+	}
+
+	empty, err := ParsePolicy("")
+	if err != nil || empty.ModeFor("petra") != ModeOff {
+		t.Fatalf("empty policy = %+v, %v", empty, err)
+	}
+	if (Policy{}).ModeFor("petra") != ModeOff {
+		t.Fatal("zero policy should be off")
+	}
+
+	for _, spec := range []string{"maybe", "petra=strict", "=on"} {
+		if _, err := ParsePolicy(spec); err == nil {
+			t.Errorf("ParsePolicy(%q) should fail", spec)
+		}
+	}
+}
+
+func TestLimitScript(t *testing.T) {
+	got := limitScript(Limits{MemoryMB: 256, CPUSeconds: 30})
+	want := `ulimit -v 262144 || exit 126; ulimit -t 30 || exit 126; exec /bin/bash -c "$1"`
+	if got != want {
+		t.Fatalf("limitScript() = %q, want %q", got, want)
+	}
+	if got := limitScript(Limits{}); got != `exec /bin/bash -c "$1"` {
+		t.Fatalf("limitScript(no limits) = %q", got)
+	}
+}
+
+func TestRunIsolatesCommands(t *testing.T) {
+	if err := Available(); err != nil {
+		if !errors.Is(err, ErrUnavailable) {
+			t.Fatalf("Available() error = %v, want ErrUnavailable", err)
+		}
+		t.Skipf("sandbox unavailable: %v", err)
+	}
+
+	dir := t.TempDir()
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(secret, []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	script := "pwd; echo hi > out.txt; cat " + secret + " || echo hidden"
+	if err := Run(ctx, Spec{Dir: dir, Limits: Limits{MemoryMB: 512, CPUSeconds: 10}}, script, &stdout, &stderr); err != nil {
+		t.Fatalf("Run() error = %v, stderr = %s", err, stderr.String())
+	}
+	if got := stdout.String(); !strings.HasPrefix(got, WorkDir+"\n") || !strings.Contains(got, "hidden") {
+		t.Fatalf("stdout = %q", got)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "out.txt")); err != nil || string(data) != "hi\n" {
+		t.Fatalf("out.txt = %q, %v", data, err)
+	}
+
+	stdout.Reset()
+	if err := Run(ctx, Spec{Dir: dir}, "unshare -U true && echo escaped || echo blocked", &stdout, &stderr); err != nil {
+		t.Fatalf("Run(unshare) error = %v", err)
+	}
+	if strings.Contains(stdout.String(), "escaped") {
+		t.Fatalf("unshare succeeded inside the sandbox: %q", stdout.String())
+	}
+}
@@ -36,23 +36,25 @@ type Entry struct {
 }
 
 type NightSummary struct {
-	NightDate      string
-	Child          string
-	DurationMinute int
-	Bedtime        string
-	WakeTime       string
+	NightDate      string `json:"night_date"`
+	Child          string `json:"child"`
+	DurationMinute int    `json:"duration_minutes"`
+	Bedtime        string `json:"bedtime"`
+	WakeTime       string `json:"wake_time"`
 }
 
 type AverageSummary struct {
-	Days            int
-	Child           string
-	AverageBedtime  string
-	AverageWakeTime string
+	Days                   int    `json:"days"`
+	Child                  string `json:"child"`
+	Nights                 int    `json:"nights"`
+	AverageBedtime         string `json:"average_bedtime"`
+	AverageWakeTime        string `json:"average_wake_time"`
+	AverageDurationMinutes int    `json:"average_duration_minutes"`
 }
 
 type Summary struct {
-	Nights   []NightSummary
-	Averages []AverageSummary
+	Nights   []NightSummary   `json:"nights"`
+	Averages []AverageSummary `json:"averages"`
 }
 
 type Store struct {
@@ -103,6 +105,11 @@ func (s *Store) Close() error {
 	return s.db.Close()
 }
 
+// Location returns the time zone sleep times are recorded in.
+func (s *Store) Location() *time.Location {
+	return s.location
+}
+
 func (s *Store) init() error {
 	stmts := []string{
 		"PRAGMA journal_mode=WAL;",
@@ -134,6 +141,58 @@ func IsValidChild(child string) bool {
 	return child == ChildThomas || child == ChildFabian
 }
 
+// NormalizeChild returns the canonical spelling of a child's name.
+func NormalizeChild(child string) (string, bool) {
+	for _, name := range []string{ChildThomas, ChildFabian} {
+		if strings.EqualFold(strings.TrimSpace(child), name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Children maps persons to the children whose sleep they may see and log.
+type Children map[string][]string
+
+// ParseChildren parses SLEEP_CHILDREN, e.g. "sebastian=Thomas,Fabian;petra=Fabian".
+func ParseChildren(spec string) (Children, error) {
+	children := Children{}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		person, names, ok := strings.Cut(entry, "=")
+		person = strings.TrimSpace(person)
+		if !ok || person == "" {
+			return nil, fmt.Errorf("sleep children %q: expected person=child,...", entry)
+		}
+		for _, name := range strings.Split(names, ",") {
+			if strings.TrimSpace(name) == "" {
+				continue
+			}
+			child, ok := NormalizeChild(name)
+			if !ok {
+				return nil, fmt.Errorf("sleep children %q: unknown child %q", entry, strings.TrimSpace(name))
+			}
+			if !children.Allows(person, child) {
+				children[person] = append(children[person], child)
+			}
+		}
+	}
+	return children, nil
+}
+
+// Allows reports whether person may see and log child's sleep.
+func (c Children) Allows(person, child string) bool {
+	for _, name := range c[person] {
+		if name == child {
+			return true
+		}
+	}
+	return false
+}
+
 func NormalizeStatus(status string) (string, error) {
 	switch strings.TrimSpace(strings.ToLower(status)) {
 	case StatusAsleep, "eingeschlafen":
@@ -279,6 +338,23 @@ func (s *Store) ListEntries(limit int) ([]Entry, error) {
 	if err != nil {
 		return nil, err
 	}
+	return scanEntries(rows)
+}
+
+// EntriesBetween returns the entries that occurred in [from, to), newest first.
+func (s *Store) EntriesBetween(from, to time.Time) ([]Entry, error) {
+	rows, err := s.db.Query(`SELECT id, child, status, occurred_at_utc, time_text, notes, created_at_utc, updated_at_utc
+		FROM sleep_events
+		WHERE deleted_at_utc IS NULL AND occurred_at_utc >= ? AND occurred_at_utc < ?
+		ORDER BY occurred_at_utc DESC, created_at_utc DESC`,
+		from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	return scanEntries(rows)
+}
+
+func scanEntries(rows *sql.Rows) ([]Entry, error) {
 	defer rows.Close()
 
 	entries := make([]Entry, 0)
@@ -386,6 +462,88 @@ func (s *Store) BuildSummary() (Summary, error) {
 		return Summary{}, err
 	}
 
+	byChild := s.pairNights(entries)
+	nowLocal := time.Now().In(s.location)
+	sevenCutoff := nowLocal.AddDate(0, 0, -7)
+	thirtyCutoff := nowLocal.AddDate(0, 0, -30)
+
+	nights := make([]NightSummary, 0)
+	averages := make([]AverageSummary, 0)
+	for _, child := range []string{ChildThomas, ChildFabian} {
+		childNights := byChild[child]
+		for _, n := range childNights {
+			nights = append(nights, n.NightSummary)
+		}
+
+		var last7, last30 []night
+		for _, n := range childNights {
+			if !n.start.Before(sevenCutoff) {
+				last7 = append(last7, n)
+			}
+			if !n.start.Before(thirtyCutoff) {
+				last30 = append(last30, n)
+			}
+		}
+		if avg, ok := averageNights(child, 7, last7); ok {
+			averages = append(averages, avg)
+		}
+		if avg, ok := averageNights(child, 30, last30); ok {
+			averages = append(averages, avg)
+		}
+	}
+
+	return Summary{Nights: nights, Averages: averages}, nil
+}
+
+// SummarizeRange returns the nights dated from through to (local dates, both
+// inclusive) with each child's averages over them. A night is dated by the
+// evening it started. Averages carry the number of days in the range.
+func (s *Store) SummarizeRange(from, to time.Time) (Summary, error) {
+	fromDate := from.In(s.location).Format("2006-01-02")
+	toDate := to.In(s.location).Format("2006-01-02")
+	if toDate < fromDate {
+		return Summary{}, errors.New("range ends before it starts")
+	}
+	first, _ := time.ParseInLocation("2006-01-02", fromDate, s.location)
+	last, _ := time.ParseInLocation("2006-01-02", toDate, s.location)
+	days := int(last.Sub(first).Hours()/24+0.5) + 1
+
+	// Nights dated in the range start from noon on the first day and end
+	// within 24 hours of starting before noon the day after the last.
+	entries, err := s.EntriesBetween(first.Add(12*time.Hour), last.AddDate(0, 0, 2).Add(12*time.Hour))
+	if err != nil {
+		return Summary{}, err
+	}
+
+	byChild := s.pairNights(entries)
+	nights := make([]NightSummary, 0)
+	averages := make([]AverageSummary, 0)
+	for _, child := range []string{ChildThomas, ChildFabian} {
+		var inRange []night
+		for _, n := range byChild[child] {
+			if n.NightDate >= fromDate && n.NightDate <= toDate {
+				inRange = append(inRange, n)
+				nights = append(nights, n.NightSummary)
+			}
+		}
+		if avg, ok := averageNights(child, days, inRange); ok {
+			averages = append(averages, avg)
+		}
+	}
+	return Summary{Nights: nights, Averages: averages}, nil
+}
+
+// night is one asleep/awake pair.
+type night struct {
+	NightSummary
+	start   time.Time
+	bedMin  int
+	wakeMin int
+}
+
+// pairNights pairs each child's asleep entry with the next awake entry within
+// 24 hours. Each child's nights are sorted newest first.
+func (s *Store) pairNights(entries []Entry) map[string][]night {
 	type event struct {
 		child      string
 		status     string
@@ -402,23 +560,8 @@ func (s *Store) BuildSummary() (Summary, error) {
 		return allEvents[i].occurredAt.Before(allEvents[j].occurredAt)
 	})
 
-	type acc struct {
-		nights []NightSummary
-		bed7   []int
-		wake7  []int
-		bed30  []int
-		wake30 []int
-	}
-	byChild := map[string]*acc{
-		ChildThomas: {nights: make([]NightSummary, 0)},
-		ChildFabian: {nights: make([]NightSummary, 0)},
-	}
-
+	byChild := map[string][]night{}
 	lastAsleep := map[string]*time.Time{}
-	nowLocal := time.Now().In(s.location)
-	sevenCutoff := nowLocal.AddDate(0, 0, -7)
-	thirtyCutoff := nowLocal.AddDate(0, 0, -30)
-
 	for _, ev := range allEvents {
 		if ev.status == StatusAsleep {
 			t := ev.occurredAt
@@ -443,61 +586,53 @@ func (s *Store) BuildSummary() (Summary, error) {
 			nightDate = startLocal.AddDate(0, 0, -1).Format("2006-01-02")
 		}
 
-		minutes := int(duration / time.Minute)
-		bedMin := startLocal.Hour()*60 + startLocal.Minute()
-		wakeMin := endLocal.Hour()*60 + endLocal.Minute()
-		row := NightSummary{
-			NightDate:      nightDate,
-			Child:          ev.child,
-			DurationMinute: minutes,
-			Bedtime:        startLocal.Format("15:04"),
-			WakeTime:       endLocal.Format("15:04"),
-		}
-		bucket := byChild[ev.child]
-		bucket.nights = append(bucket.nights, row)
-
-		if !startLocal.Before(sevenCutoff) {
-			bucket.bed7 = append(bucket.bed7, bedMin)
-			bucket.wake7 = append(bucket.wake7, wakeMin)
-		}
-		if !startLocal.Before(thirtyCutoff) {
-			bucket.bed30 = append(bucket.bed30, bedMin)
-			bucket.wake30 = append(bucket.wake30, wakeMin)
-		}
+		byChild[ev.child] = append(byChild[ev.child], night{
+			NightSummary: NightSummary{
+				NightDate:      nightDate,
+				Child:          ev.child,
+				DurationMinute: int(duration / time.Minute),
+				Bedtime:        startLocal.Format("15:04"),
+				WakeTime:       endLocal.Format("15:04"),
+			},
+			start:   startLocal,
+			bedMin:  startLocal.Hour()*60 + startLocal.Minute(),
+			wakeMin: endLocal.Hour()*60 + endLocal.Minute(),
+		})
 		lastAsleep[ev.child] = nil
 	}
 
-	nights := make([]NightSummary, 0)
-	averages := make([]AverageSummary, 0)
-	for _, child := range []string{ChildThomas, ChildFabian} {
-		bucket := byChild[child]
-		sort.Slice(bucket.nights, func(i, j int) bool {
-			if bucket.nights[i].NightDate == bucket.nights[j].NightDate {
-				return bucket.nights[i].Bedtime > bucket.nights[j].Bedtime
+	for _, nights := range byChild {
+		sort.Slice(nights, func(i, j int) bool {
+			if nights[i].NightDate == nights[j].NightDate {
+				return nights[i].Bedtime > nights[j].Bedtime
 			}
-			return bucket.nights[i].NightDate > bucket.nights[j].NightDate
+			return nights[i].NightDate > nights[j].NightDate
 		})
-		nights = append(nights, bucket.nights...)
-
-		if len(bucket.bed7) > 0 && len(bucket.wake7) > 0 {
-			averages = append(averages, AverageSummary{
-				Days:            7,
-				Child:           child,
-				AverageBedtime:  formatMeanMinutes(bucket.bed7),
-				AverageWakeTime: formatMeanMinutes(bucket.wake7),
-			})
-		}
-		if len(bucket.bed30) > 0 && len(bucket.wake30) > 0 {
-			averages = append(averages, AverageSummary{
-				Days:            30,
-				Child:           child,
-				AverageBedtime:  formatMeanMinutes(bucket.bed30),
-				AverageWakeTime: formatMeanMinutes(bucket.wake30),
-			})
-		}
 	}
+	return byChild
+}
 
-	return Summary{Nights: nights, Averages: averages}, nil
+// averageNights averages bedtime, wake time and duration; ok is false without nights.
+func averageNights(child string, days int, nights []night) (AverageSummary, bool) {
+	if len(nights) == 0 {
+		return AverageSummary{}, false
+	}
+	bed := make([]int, len(nights))
+	wake := make([]int, len(nights))
+	total := 0
+	for i, n := range nights {
+		bed[i] = n.bedMin
+		wake[i] = n.wakeMin
+		total += n.DurationMinute
+	}
+	return AverageSummary{
+		Days:                   days,
+		Child:                  child,
+		Nights:                 len(nights),
+		AverageBedtime:         formatMeanMinutes(bed),
+		AverageWakeTime:        formatMeanMinutes(wake),
+		AverageDurationMinutes: int(float64(total)/float64(len(nights)) + 0.5),
+	}, true
 }
 
 func formatMeanMinutes(values []int) string {
@@ -548,3 +683,9 @@ func (s *Store) ExportMarkdown() (string, error) {
 
 	return b.String(), nil
 }
+
+// Snapshot writes a consistent copy of the database to dst, which must not exist.
+func (s *Store) Snapshot(dst string) error {
+	_, err := s.db.Exec("VACUUM INTO ?", dst)
+	return err
+}
@@ -0,0 +1,94 @@
+package sleep
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseChildren(t *testing.T) {
+	children, err := ParseChildren(" sebastian = thomas, Fabian ; petra=FABIAN,Fabian ")
+	if err != nil {
+		t.Fatalf("ParseChildren() error = %v", err)
+	}
+	if got := children["sebastian"]; len(got) != 2 || got[0] != ChildThomas || got[1] != ChildFabian {
+		t.Errorf("sebastian = %v", got)
+	}
+	if got := children["petra"]; len(got) != 1 || got[0] != ChildFabian {
+		t.Errorf("petra = %v", got)
+	}
+	if children.Allows("petra", ChildThomas) || !children.Allows("petra", ChildFabian) || children.Allows("tom", ChildFabian) {
+		t.Errorf("Allows() = wrong scoping for %v", children)
+	}
+
+	for _, spec := range []string{"sebastian", "=Thomas", "petra=Lena"} {
+		if _, err := ParseChildren(spec); err == nil {
+			t.Errorf("ParseChildren(%q) should fail", spec)
+		}
+	}
+}
+
+func TestSummarizeRange(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "sleep.db"))
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	defer store.Close()
+
+	loc := store.Location()
+	at := func(date, clock string) *time.Time {
+		t.Helper()
+		parsed, ok := ParseOccurredAt(date, clock, loc)
+		if !ok {
+			t.Fatalf("ParseOccurredAt(%s %s) failed", date, clock)
+		}
+		return parsed
+	}
+	for _, e := range []struct {
+		child, status, date, clock string
+	}{
+		// Outside the range: the night of March 2.
+		{ChildFabian, StatusAsleep, "2026-03-02", "19:00"},
+		{ChildFabian, StatusAwake, "2026-03-03", "06:00"},
+		// Nights of March 3 and 4.
+		{ChildFabian, StatusAsleep, "2026-03-03", "19:30"},
+		{ChildFabian, StatusAwake, "2026-03-04", "06:30"},
+		{ChildFabian, StatusAsleep, "2026-03-04", "20:30"},
+		{ChildFabian, StatusAwake, "2026-03-05", "07:30"},
+		{ChildThomas, StatusAsleep, "2026-03-04", "21:00"},
+		{ChildThomas, StatusAwake, "2026-03-05", "07:00"},
+	} {
+		if _, err := store.CreateEntry(e.child, e.status, at(e.date, e.clock), ""); err != nil {
+			t.Fatalf("CreateEntry() error = %v", err)
+		}
+	}
+
+	from := *at("2026-03-03", "00:00")
+	to := *at("2026-03-04", "00:00")
+	summary, err := store.SummarizeRange(from, to)
+	if err != nil {
+		t.Fatalf("SummarizeRange() error = %v", err)
+	}
+	if len(summary.Nights) != 3 {
+		t.Fatalf("nights = %+v", summary.Nights)
+	}
+	if n := summary.Nights[0]; n.Child != ChildThomas || n.NightDate != "2026-03-04" || n.DurationMinute != 600 {
+		t.Errorf("first night = %+v", n)
+	}
+	want := []AverageSummary{
+		{Days: 2, Child: ChildThomas, Nights: 1, AverageBedtime: "21:00", AverageWakeTime: "07:00", AverageDurationMinutes: 600},
+		{Days: 2, Child: ChildFabian, Nights: 2, AverageBedtime: "20:00", AverageWakeTime: "07:00", AverageDurationMinutes: 660},
+	}
+	if len(summary.Averages) != len(want) {
+		t.Fatalf("averages = %+v", summary.Averages)
+	}
+	for i := range want {
+		if summary.Averages[i] != want[i] {
+			t.Errorf("average %d = %+v, want %+v", i, summary.Averages[i], want[i])
+		}
+	}
+
+	if _, err := store.SummarizeRange(to, from); err == nil {
+		t.Error("SummarizeRange() with reversed range should fail")
+	}
+}
@@ -20,12 +20,17 @@ func NewDaily(store *Store) *Daily {
 	return &Daily{store: store}
 }
 
+// DailyPath returns the vault-relative path of the daily note for date.
+func DailyPath(date time.Time) string {
+	return filepath.Join("daily", date.Format("2006-01-02")+".md")
+}
+
 // GetOrCreateDaily returns today's daily note, creating it if it doesn't exist.
 // The note inherits incomplete todos and pinned entries from the previous note.
-// created is true only when the note did not previously exist and was written.
+// created is true only when the note did not previously exist and was written;
+// the creation is then reported to the store's change hook.
 func (d *Daily) GetOrCreateDaily(person string, date time.Time) (content string, path string, created bool, err error) {
-	filename := date.Format("2006-01-02") + ".md"
-	path = filepath.Join("daily", filename)
+	path = DailyPath(date)
 
 	// Check if today's note exists
 	content, err = d.store.ReadFile(person, path)
@@ -46,6 +51,7 @@ func (d *Daily) GetOrCreateDaily(person string, date time.Time) (content string,
 	if err := d.store.WriteFile(person, path, content); err != nil {
 		return "", "", false, err
 	}
+	d.store.notifyChange(ChangeDailyCreated, person, path)
 
 	return content, path, true, nil
 }
@@ -221,7 +227,8 @@ func (d *Daily) extractPinnedNotes(content string) string {
 	return strings.Join(result, "\n")
 }
 
-// AddTask adds a task to a specific category in the daily note.
+// AddTask adds a task to a specific category in the daily note and reports a
+// capture to the store's change hook.
 func (d *Daily) AddTask(person, path, category, task string) error {
 	content, err := d.store.ReadFile(person, path)
 	if err != nil {
@@ -254,7 +261,11 @@ func (d *Daily) AddTask(person, path, category, task string) error {
 		newContent = content[:insertIdx] + "\n- [ ] " + task + content[insertIdx:]
 	}
 
-	return d.store.WriteFile(person, path, newContent)
+	if err := d.store.WriteFile(person, path, newContent); err != nil {
+		return err
+	}
+	d.store.notifyChange(ChangeCapture, person, path)
+	return nil
 }
 
 // ToggleTask toggles a task's completion status at the given line number (1-indexed).
@@ -284,6 +295,78 @@ func (d *Daily) ToggleTask(person, path string, lineNum int) error {
 	return d.store.WriteFile(person, path, strings.Join(lines, "\n"))
 }
 
+// Task is an open task in a daily note's ## todos section.
+type Task struct {
+	Line     int    `json:"line"` // 1-indexed line number
+	Category string `json:"category,omitempty"`
+	Text     string `json:"text"`
+}
+
+// openTaskPattern matches an unchecked "- [ ]" task line.
+var openTaskPattern = regexp.MustCompile(`^(\s*- )\[ \](\s.*)?$`)
+
+// OpenTasks returns the unchecked tasks in a daily note's ## todos section,
+// with the ### category they are listed under.
+func (d *Daily) OpenTasks(person, path string) ([]Task, error) {
+	content, err := d.store.ReadFile(person, path)
+	if err != nil {
+		return nil, err
+	}
+	return openTasks(strings.Split(content, "\n")), nil
+}
+
+func openTasks(lines []string) []Task {
+	tasks := make([]Task, 0)
+	inTodos := false
+	category := ""
+	for i, line := range lines {
+		if strings.HasPrefix(line, "## ") {
+			inTodos = strings.TrimSpace(line) == "## todos"
+			category = ""
+			continue
+		}
+		if !inTodos {
+			continue
+		}
+		if strings.HasPrefix(line, "### ") {
+			category = strings.TrimSpace(strings.TrimPrefix(line, "### "))
+			continue
+		}
+		if m := openTaskPattern.FindStringSubmatch(line); m != nil {
+			tasks = append(tasks, Task{Line: i + 1, Category: category, Text: strings.TrimSpace(m[2])})
+		}
+	}
+	return tasks
+}
+
+// CompleteTask checks off the open task at the given line number (1-indexed),
+// which must be one of OpenTasks. Unlike ToggleTask it never reopens a
+// completed task.
+func (d *Daily) CompleteTask(person, path string, lineNum int) error {
+	content, err := d.store.ReadFile(person, path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(content, "\n")
+	if lineNum < 1 || lineNum > len(lines) {
+		return fmt.Errorf("line number %d out of range", lineNum)
+	}
+	open := false
+	for _, task := range openTasks(lines) {
+		if task.Line == lineNum {
+			open = true
+			break
+		}
+	}
+	if !open {
+		return fmt.Errorf("line %d is not an open task in ## todos", lineNum)
+	}
+	lines[lineNum-1] = openTaskPattern.ReplaceAllString(lines[lineNum-1], "${1}[x]${2}")
+
+	return d.store.WriteFile(person, path, strings.Join(lines, "\n"))
+}
+
 // ClearAllPinned removes all <pinned> markers from a note.
 func (d *Daily) ClearAllPinned(person, path string) error {
 	content, err := d.store.ReadFile(person, path)
@@ -321,7 +404,8 @@ func (d *Daily) UnpinEntry(person, path string, lineNum int) error {
 	return d.store.WriteFile(person, path, strings.Join(lines, "\n"))
 }
 
-// AppendEntry appends a timestamped entry to the custom notes section.
+// AppendEntry appends a timestamped entry to the custom notes section and
+// reports a capture to the store's change hook.
 func (d *Daily) AppendEntry(person, path, text string, pinned bool) error {
 	content, err := d.store.ReadFile(person, path)
 	if err != nil {
@@ -339,5 +423,9 @@ func (d *Daily) AppendEntry(person, path, text string, pinned bool) error {
 	// Append to end of file
 	newContent := strings.TrimRight(content, "\n") + entry
 
-	return d.store.WriteFile(person, path, newContent)
+	if err := d.store.WriteFile(person, path, newContent); err != nil {
+		return err
+	}
+	d.store.notifyChange(ChangeCapture, person, path)
+	return nil
 }
@@ -249,6 +249,74 @@ func TestDaily_ToggleTask(t *testing.T) {
 	}
 }
 
+func TestDaily_OpenTasks(t *testing.T) {
+	daily, store, _ := setupDailyTest(t)
+
+	content := `# 2024-01-15
+
+## todos
+
+- [ ] loose task
+
+### work
+- [ ] write report
+  - [ ] outline
+- [x] done already
+
+### priv
+- [ ] call mom
+
+## custom notes
+
+- [ ] not a todo
+`
+	if err := store.WriteFile("sebastian", "daily/test.md", content); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := daily.OpenTasks("sebastian", "daily/test.md")
+	if err != nil {
+		t.Fatalf("OpenTasks() error = %v", err)
+	}
+	want := []Task{
+		{Line: 5, Text: "loose task"},
+		{Line: 8, Category: "work", Text: "write report"},
+		{Line: 9, Category: "work", Text: "outline"},
+		{Line: 13, Category: "priv", Text: "call mom"},
+	}
+	if len(tasks) != len(want) {
+		t.Fatalf("OpenTasks() = %+v, want %+v", tasks, want)
+	}
+	for i := range want {
+		if tasks[i] != want[i] {
+			t.Errorf("task %d = %+v, want %+v", i, tasks[i], want[i])
+		}
+	}
+}
+
+func TestDaily_CompleteTask(t *testing.T) {
+	daily, store, _ := setupDailyTest(t)
+
+	content := "## todos\n\n- [ ] open\n- [x] done\n-[ ] no space\n\n## custom notes\n- [ ] not a todo\n"
+	if err := store.WriteFile("sebastian", "daily/test.md", content); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := daily.CompleteTask("sebastian", "daily/test.md", 3); err != nil {
+		t.Fatalf("CompleteTask() error = %v", err)
+	}
+	for _, line := range []int{3, 4, 5, 8, 1, 99} {
+		if err := daily.CompleteTask("sebastian", "daily/test.md", line); err == nil {
+			t.Errorf("CompleteTask(line %d) should fail", line)
+		}
+	}
+
+	result, _ := store.ReadFile("sebastian", "daily/test.md")
+	if result != "## todos\n\n- [x] open\n- [x] done\n-[ ] no space\n\n## custom notes\n- [ ] not a todo\n" {
+		t.Errorf("content = %q", result)
+	}
+}
+
 func TestDaily_ClearAllPinned(t *testing.T) {
 	daily, store, _ := setupDailyTest(t)
 
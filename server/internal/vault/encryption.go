@@ -0,0 +1,541 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// EncryptedSuffix is appended to the on-disk name of encrypted files, so tools
+// that look for *.md (qmd indexing, publishing, exports) skip them.
+const EncryptedSuffix = ".enc"
+
+// encryptionKeyFile holds a person's wrapped data key in their vault. It is
+// committed with the notes; without the passphrase it is useless.
+const encryptionKeyFile = ".encryption.json"
+
+// encryptedFileHeader starts the contents of every encrypted file.
+const encryptedFileHeader = "notes-encrypted:v1\n"
+
+// DefaultUnlockTimeout applies when SetUnlockTimeout is given no timeout.
+const DefaultUnlockTimeout = 30 * time.Minute
+
+// minPassphraseLength is the shortest accepted encryption passphrase.
+const minPassphraseLength = 8
+
+// Argon2id parameters for new key files.
+const (
+	kdfTime    = 2
+	kdfMemory  = 64 * 1024
+	kdfThreads = 4
+)
+
+// Encryption errors.
+var (
+	ErrLocked               = errors.New("encrypted folder is locked")
+	ErrWrongPassphrase      = errors.New("wrong passphrase")
+	ErrEncryptionSetUp      = errors.New("encryption is already set up")
+	ErrEncryptionNotSetUp   = errors.New("encryption is not set up")
+	ErrWeakPassphrase       = fmt.Errorf("passphrase must be at least %d characters", minPassphraseLength)
+	ErrAgentEncrypted       = errors.New("encrypted folder is not available to the agent")
+	ErrDecryptingMove       = errors.New("encrypted files cannot be moved out of their encrypted folder")
+	ErrEncryptedName        = errors.New("encrypted files are addressed by their plaintext name, without " + EncryptedSuffix)
+	errCorruptEncryptedFile = errors.New("encrypted file is corrupted")
+)
+
+// EncryptedFolders maps persons to their encrypted folders (slash-separated,
+// person-relative) and whether agent tools may use each of them.
+type EncryptedFolders map[string]map[string]bool
+
+// EncryptedFolder describes one of a person's encrypted folders.
+type EncryptedFolder struct {
+	Path  string `json:"path"`
+	Agent bool   `json:"agent"`
+}
+
+// EncryptionStatus reports a person's encryption state.
+type EncryptionStatus struct {
+	Folders []EncryptedFolder `json:"folders"`
+	// SetUp is true once a passphrase has been chosen.
+	SetUp     bool       `json:"set_up"`
+	Unlocked  bool       `json:"unlocked"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// ParseEncryptedFolders parses ENCRYPTED_FOLDERS, e.g.
+// "sebastian=health,finance:agent;petra=medical". Folders suffixed with
+// ":agent" may be used by agent tools while unlocked.
+func ParseEncryptedFolders(spec string) (EncryptedFolders, error) {
+	folders := EncryptedFolders{}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		person, paths, ok := strings.Cut(entry, "=")
+		person = strings.TrimSpace(person)
+		if !ok || person == "" {
+			return nil, fmt.Errorf("encrypted folders %q: expected person=folder,...", entry)
+		}
+		for _, folder := range strings.Split(paths, ",") {
+			folder = strings.TrimSpace(folder)
+			if folder == "" {
+				continue
+			}
+			folder, flag, hasFlag := strings.Cut(folder, ":")
+			if hasFlag && flag != "agent" {
+				return nil, fmt.Errorf("encrypted folder %q: unknown flag %q", folder, flag)
+			}
+			cleaned := filepath.ToSlash(filepath.Clean(folder))
+			if err := ValidatePath(folder); err != nil || cleaned == "." || strings.HasPrefix(cleaned, ".") ||
+				cleaned == SharedDir || strings.HasPrefix(cleaned, SharedDir+"/") {
+				return nil, fmt.Errorf("encrypted folder %q: invalid path", folder)
+			}
+			if folders[person] == nil {
+				folders[person] = map[string]bool{}
+			}
+			folders[person][cleaned] = hasFlag
+		}
+	}
+	return folders, nil
+}
+
+// match returns the encrypted folder containing path, if any.
+func (f EncryptedFolders) match(person, path string) (folder string, agent bool, ok bool) {
+	cleaned := filepath.ToSlash(filepath.Clean(path))
+	for folder, agent := range f[person] {
+		if cleaned == folder || strings.HasPrefix(cleaned, folder+"/") {
+			return folder, agent, true
+		}
+	}
+	return "", false, false
+}
+
+// unlockedKey is a person's data key while unlocked.
+type unlockedKey struct {
+	aead      cipher.AEAD
+	expiresAt time.Time
+}
+
+type keyFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Time       uint32 `json:"time"`
+	Memory     uint32 `json:"memory"`
+	Threads    uint8  `json:"threads"`
+	Salt       string `json:"salt"`
+	WrappedKey string `json:"wrapped_key"`
+}
+
+// SetEncryptedFolders replaces the encrypted folder configuration.
+func (s *Store) SetEncryptedFolders(folders EncryptedFolders) {
+	s.cryptMu.Lock()
+	defer s.cryptMu.Unlock()
+	s.encrypted = folders
+}
+
+// SetUnlockTimeout sets how long a person stays unlocked without using an
+// encrypted file. A non-positive timeout selects DefaultUnlockTimeout.
+func (s *Store) SetUnlockTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultUnlockTimeout
+	}
+	s.cryptMu.Lock()
+	defer s.cryptMu.Unlock()
+	s.unlockTimeout = timeout
+}
+
+// IsEncrypted reports whether path lies in one of the person's encrypted folders.
+func (s *Store) IsEncrypted(person, path string) bool {
+	s.cryptMu.RLock()
+	defer s.cryptMu.RUnlock()
+	_, _, ok := s.encrypted.match(person, path)
+	return ok
+}
+
+// checkEncryptedName returns ErrEncryptedName for on-disk names of encrypted
+// files. Reading or writing them directly would encrypt the ciphertext again.
+func (s *Store) checkEncryptedName(person, path string) error {
+	if strings.HasSuffix(path, EncryptedSuffix) && s.IsEncrypted(person, path) {
+		return ErrEncryptedName
+	}
+	return nil
+}
+
+// AgentCanAccess returns ErrAgentEncrypted when path lies in an encrypted folder
+// that agent tools may not use.
+func (s *Store) AgentCanAccess(person, path string) error {
+	s.cryptMu.RLock()
+	defer s.cryptMu.RUnlock()
+	if _, agent, ok := s.encrypted.match(person, path); ok && !agent {
+		return ErrAgentEncrypted
+	}
+	return nil
+}
+
+// EncryptionStatus returns the person's encrypted folders and lock state.
+func (s *Store) EncryptionStatus(person string) EncryptionStatus {
+	status := EncryptionStatus{Folders: make([]EncryptedFolder, 0)}
+	if exists, _ := s.keyFileExists(person); exists {
+		status.SetUp = true
+	}
+
+	s.cryptMu.Lock()
+	defer s.cryptMu.Unlock()
+	for folder, agent := range s.encrypted[person] {
+		status.Folders = append(status.Folders, EncryptedFolder{Path: folder, Agent: agent})
+	}
+	sort.Slice(status.Folders, func(i, j int) bool { return status.Folders[i].Path < status.Folders[j].Path })
+	if key := s.activeKey(person); key != nil {
+		status.Unlocked = true
+		expires := key.expiresAt
+		status.ExpiresAt = &expires
+	}
+	return status
+}
+
+// SetupEncryption chooses the person's passphrase, encrypts plaintext files
+// already in their encrypted folders and unlocks them. It returns the
+// person-relative paths of the files it encrypted; their plaintext stays in the
+// git history.
+func (s *Store) SetupEncryption(person, passphrase string) ([]string, error) {
+	if len(passphrase) < minPassphraseLength {
+		return nil, ErrWeakPassphrase
+	}
+	exists, err := s.keyFileExists(person)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrEncryptionSetUp
+	}
+
+	dataKey := make([]byte, 32)
+	kf := keyFile{Version: 1, KDF: "argon2id", Time: kdfTime, Memory: kdfMemory, Threads: kdfThreads}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	kf.Salt = base64.StdEncoding.EncodeToString(salt)
+	wrap, err := kf.wrappingKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(wrap, dataKey, []byte(person))
+	if err != nil {
+		return nil, err
+	}
+	kf.WrappedKey = base64.StdEncoding.EncodeToString(wrapped)
+	raw, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	keyPath, err := ResolvePath(s.rootPath, person, encryptionKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyPath, append(raw, '\n'), 0644); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	s.setKey(person, aead)
+	return s.encryptExisting(person)
+}
+
+// Unlock derives the person's data key from passphrase and keeps it in memory
+// until the unlock timeout passes without use or Lock is called.
+func (s *Store) Unlock(person, passphrase string) error {
+	keyPath, err := ResolvePath(s.rootPath, person, encryptionKeyFile)
+	if err != nil {
+		return err
+	}
+	raw, err := os.ReadFile(keyPath)
+	if os.IsNotExist(err) {
+		return ErrEncryptionNotSetUp
+	}
+	if err != nil {
+		return err
+	}
+	var kf keyFile
+	if err := json.Unmarshal(raw, &kf); err != nil {
+		return fmt.Errorf("%s: %w", encryptionKeyFile, err)
+	}
+	if kf.Version != 1 || kf.KDF != "argon2id" {
+		return fmt.Errorf("%s: unsupported version %d", encryptionKeyFile, kf.Version)
+	}
+	wrap, err := kf.wrappingKey(passphrase)
+	if err != nil {
+		return err
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(kf.WrappedKey)
+	if err != nil {
+		return fmt.Errorf("%s: %w", encryptionKeyFile, err)
+	}
+	dataKey, err := open(wrap, wrapped, []byte(person))
+	if err != nil {
+		return ErrWrongPassphrase
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+	s.setKey(person, aead)
+	return nil
+}
+
+// Lock forgets the person's data key.
+func (s *Store) Lock(person string) {
+	s.cryptMu.Lock()
+	defer s.cryptMu.Unlock()
+	delete(s.keys, person)
+}
+
+func (s *Store) setKey(person string, aead cipher.AEAD) {
+	s.cryptMu.Lock()
+	defer s.cryptMu.Unlock()
+	if s.keys == nil {
+		s.keys = map[string]*unlockedKey{}
+	}
+	s.keys[person] = &unlockedKey{aead: aead, expiresAt: time.Now().Add(s.timeout())}
+}
+
+// activeKey returns the person's unexpired key. The caller holds cryptMu.
+func (s *Store) activeKey(person string) *unlockedKey {
+	key := s.keys[person]
+	if key == nil {
+		return nil
+	}
+	if time.Now().After(key.expiresAt) {
+		delete(s.keys, person)
+		return nil
+	}
+	return key
+}
+
+// useKey returns the person's key and extends the unlock, or ErrLocked.
+func (s *Store) useKey(person string) (cipher.AEAD, error) {
+	s.cryptMu.Lock()
+	defer s.cryptMu.Unlock()
+	key := s.activeKey(person)
+	if key == nil {
+		return nil, ErrLocked
+	}
+	key.expiresAt = time.Now().Add(s.timeout())
+	return key.aead, nil
+}
+
+// timeout returns the unlock timeout. The caller holds cryptMu.
+func (s *Store) timeout() time.Duration {
+	if s.unlockTimeout <= 0 {
+		return DefaultUnlockTimeout
+	}
+	return s.unlockTimeout
+}
+
+func (s *Store) keyFileExists(person string) (bool, error) {
+	keyPath, err := ResolvePath(s.rootPath, person, encryptionKeyFile)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(keyPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// readEncrypted decrypts the file at fullPath+EncryptedSuffix. found is false
+// when there is no encrypted file.
+func (s *Store) readEncrypted(person, path, fullPath string) (content string, found bool, err error) {
+	raw, err := os.ReadFile(fullPath + EncryptedSuffix)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", true, err
+	}
+	aead, err := s.useKey(person)
+	if err != nil {
+		return "", true, err
+	}
+	encoded, ok := strings.CutPrefix(string(raw), encryptedFileHeader)
+	if !ok {
+		return "", true, errCorruptEncryptedFile
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", true, errCorruptEncryptedFile
+	}
+	plain, err := open(aead, sealed, encryptedFileAAD(person, path))
+	if err != nil {
+		return "", true, errCorruptEncryptedFile
+	}
+	return string(plain), true, nil
+}
+
+// readPlaintextInEncrypted handles a plaintext file in an encrypted folder, e.g.
+// one pulled from a device without encryption. While unlocked it is encrypted
+// and returned; otherwise reading fails instead of serving the plaintext.
+func (s *Store) readPlaintextInEncrypted(person, path, fullPath string) (string, error) {
+	if _, err := os.Stat(fullPath); err != nil {
+		return "", err
+	}
+	if _, err := s.useKey(person); err != nil {
+		if exists, statErr := s.keyFileExists(person); statErr == nil && !exists {
+			return "", ErrEncryptionNotSetUp
+		}
+		return "", err
+	}
+
+	s.strayMu.Lock()
+	defer s.strayMu.Unlock()
+	// Another reader may have encrypted it meanwhile.
+	if content, found, err := s.readEncrypted(person, path, fullPath); found || err != nil {
+		return content, err
+	}
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return "", err
+	}
+	if err := s.writeEncrypted(person, path, fullPath, string(content)); err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// writeEncrypted encrypts content to fullPath+EncryptedSuffix and removes any
+// plaintext copy at fullPath.
+func (s *Store) writeEncrypted(person, path, fullPath, content string) error {
+	aead, err := s.useKey(person)
+	if err != nil {
+		// Refuse rather than fall back to plaintext before a passphrase exists.
+		if exists, statErr := s.keyFileExists(person); statErr == nil && !exists {
+			return ErrEncryptionNotSetUp
+		}
+		return err
+	}
+	sealed, err := seal(aead, []byte(content), encryptedFileAAD(person, path))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+	body := encryptedFileHeader + base64.StdEncoding.EncodeToString(sealed) + "\n"
+	if err := os.WriteFile(fullPath+EncryptedSuffix, []byte(body), 0644); err != nil {
+		return err
+	}
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.MarkChanged()
+	return nil
+}
+
+// encryptExisting encrypts the plaintext files in the person's encrypted folders.
+func (s *Store) encryptExisting(person string) ([]string, error) {
+	s.cryptMu.RLock()
+	var folders []string
+	for folder := range s.encrypted[person] {
+		folders = append(folders, folder)
+	}
+	s.cryptMu.RUnlock()
+	sort.Strings(folders)
+
+	encrypted := make([]string, 0)
+	for _, folder := range folders {
+		root, err := ResolvePath(s.rootPath, person, filepath.FromSlash(folder))
+		if err != nil {
+			return encrypted, err
+		}
+		err = filepath.WalkDir(root, func(p string, d fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				if os.IsNotExist(walkErr) && p == root {
+					return filepath.SkipDir
+				}
+				return walkErr
+			}
+			if d.IsDir() || !d.Type().IsRegular() || strings.HasSuffix(p, EncryptedSuffix) {
+				return nil
+			}
+			rel, err := filepath.Rel(filepath.Join(s.rootPath, person), p)
+			if err != nil {
+				return err
+			}
+			content, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			if err := s.writeEncrypted(person, rel, p, string(content)); err != nil {
+				return err
+			}
+			encrypted = append(encrypted, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return encrypted, err
+		}
+	}
+	return encrypted, nil
+}
+
+// encryptedFileAAD binds a file's ciphertext to its owner and path, so files
+// cannot be swapped undetected.
+func encryptedFileAAD(person, path string) []byte {
+	return []byte(person + "/" + filepath.ToSlash(filepath.Clean(path)))
+}
+
+func (kf keyFile) wrappingKey(passphrase string) (cipher.AEAD, error) {
+	salt, err := base64.StdEncoding.DecodeString(kf.Salt)
+	if err != nil || kf.Time == 0 || kf.Memory == 0 || kf.Threads == 0 {
+		return nil, fmt.Errorf("%s: invalid key derivation parameters", encryptionKeyFile)
+	}
+	return newAEAD(argon2.IDKey([]byte(passphrase), salt, kf.Time, kf.Memory, kf.Threads, 32))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns nonce || ciphertext.
+func seal(aead cipher.AEAD, plain, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, aad), nil
+}
+
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errCorruptEncryptedFile
+	}
+	nonce, data := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, data, aad)
+}
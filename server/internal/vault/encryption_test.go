@@ -0,0 +1,275 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setupEncryptedVault(t *testing.T) (*Store, string) {
+	t.Helper()
+	store, root := setupTestVault(t)
+	folders, err := ParseEncryptedFolders("sebastian=health,finance:agent")
+	if err != nil {
+		t.Fatalf("ParseEncryptedFolders() error = %v", err)
+	}
+	store.SetEncryptedFolders(folders)
+	return store, root
+}
+
+func TestParseEncryptedFolders(t *testing.T) {
+	folders, err := ParseEncryptedFolders("sebastian=health, finance:agent ;petra=medical/2026")
+	if err != nil {
+		t.Fatalf("ParseEncryptedFolders() error = %v", err)
+	}
+	if !folders["sebastian"]["finance"] || folders["sebastian"]["health"] {
+		t.Errorf("agent flags = %v", folders["sebastian"])
+	}
+	if _, ok := folders["petra"]["medical/2026"]; !ok {
+		t.Errorf("petra folders = %v", folders["petra"])
+	}
+
+	for _, spec := range []string{"health", "=health", "sebastian=../x", "sebastian=.git", "sebastian=_shared/x", "sebastian=health:write"} {
+		if _, err := ParseEncryptedFolders(spec); err == nil {
+			t.Errorf("ParseEncryptedFolders(%q) should fail", spec)
+		}
+	}
+}
+
+func TestStore_EncryptedFolders(t *testing.T) {
+	store, root := setupEncryptedVault(t)
+
+	if err := store.WriteFile("sebastian", "health/before.md", "x"); !errors.Is(err, ErrEncryptionNotSetUp) {
+		t.Fatalf("WriteFile() before setup error = %v", err)
+	}
+	// Plaintext from before the folder was configured is encrypted by setup.
+	if err := os.MkdirAll(filepath.Join(root, "sebastian", "health"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "sebastian", "health", "before.md"), []byte("old note"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.ReadFile("sebastian", "health/before.md"); !errors.Is(err, ErrEncryptionNotSetUp) {
+		t.Fatalf("ReadFile() before setup error = %v", err)
+	}
+	if _, err := store.SetupEncryption("sebastian", "short"); !errors.Is(err, ErrWeakPassphrase) {
+		t.Fatalf("SetupEncryption(short) error = %v", err)
+	}
+	encrypted, err := store.SetupEncryption("sebastian", "correct horse")
+	if err != nil {
+		t.Fatalf("SetupEncryption() error = %v", err)
+	}
+	if len(encrypted) != 1 || encrypted[0] != "health/before.md" {
+		t.Errorf("encrypted = %v", encrypted)
+	}
+	if _, err := store.SetupEncryption("sebastian", "correct horse"); !errors.Is(err, ErrEncryptionSetUp) {
+		t.Errorf("second SetupEncryption() error = %v", err)
+	}
+
+	if err := store.WriteFile("sebastian", "health/blood.md", "ferritin 30"); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := store.AppendFile("sebastian", "health/blood.md", "\nb12 400"); err != nil {
+		t.Fatalf("AppendFile() error = %v", err)
+	}
+	got, err := store.ReadFile("sebastian", "health/blood.md")
+	if err != nil || got != "ferritin 30\nb12 400" {
+		t.Fatalf("ReadFile() = %q, %v", got, err)
+	}
+
+	// Only ciphertext reaches the disk.
+	for _, name := range []string{"before.md", "blood.md"} {
+		if _, err := os.Stat(filepath.Join(root, "sebastian", "health", name)); !os.IsNotExist(err) {
+			t.Errorf("plaintext %s left on disk: %v", name, err)
+		}
+	}
+	raw, err := os.ReadFile(filepath.Join(root, "sebastian", "health", "blood.md"+EncryptedSuffix))
+	if err != nil || strings.Contains(string(raw), "ferritin") {
+		t.Fatalf("encrypted file = %q, %v", raw, err)
+	}
+
+	entries, err := store.ListDir("sebastian", "health")
+	if err != nil || len(entries) != 2 || entries[0].Name != "before.md" || entries[1].Name != "blood.md" {
+		t.Fatalf("ListDir() = %+v, %v", entries, err)
+	}
+	if exists, _ := store.FileExists("sebastian", "health/blood.md"); !exists {
+		t.Error("FileExists() = false for encrypted file")
+	}
+
+	// Locked: reads and writes fail until unlocked again.
+	store.Lock("sebastian")
+	if _, err := store.ReadFile("sebastian", "health/blood.md"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("ReadFile() while locked error = %v", err)
+	}
+	if err := store.WriteFile("sebastian", "health/new.md", "x"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("WriteFile() while locked error = %v", err)
+	}
+	if err := store.Unlock("sebastian", "wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("Unlock(wrong) error = %v", err)
+	}
+	if err := store.Unlock("sebastian", "correct horse"); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	if got, err := store.ReadFile("sebastian", "health/before.md"); err != nil || got != "old note" {
+		t.Fatalf("ReadFile() after unlock = %q, %v", got, err)
+	}
+
+	// Plaintext appearing later, e.g. from a pull, is not served while locked and
+	// is encrypted when read unlocked.
+	stray := filepath.Join(root, "sebastian", "health", "stray.md")
+	if err := os.WriteFile(stray, []byte("pulled"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store.Lock("sebastian")
+	if _, err := store.ReadFile("sebastian", "health/stray.md"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("ReadFile(stray) while locked error = %v", err)
+	}
+	if err := store.Unlock("sebastian", "correct horse"); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	if got, err := store.ReadFile("sebastian", "health/stray.md"); err != nil || got != "pulled" {
+		t.Fatalf("ReadFile(stray) = %q, %v", got, err)
+	}
+	if _, err := os.Stat(stray); !os.IsNotExist(err) {
+		t.Errorf("stray plaintext left on disk: %v", err)
+	}
+	if _, err := os.Stat(stray + EncryptedSuffix); err != nil {
+		t.Errorf("stray file not encrypted: %v", err)
+	}
+	if _, err := store.ReadFile("sebastian", "health/missing.md"); !os.IsNotExist(err) {
+		t.Errorf("ReadFile(missing) error = %v", err)
+	}
+
+	generation := store.Generation()
+	if err := store.DeleteFile("sebastian", "health/blood.md"); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+	if exists, _ := store.FileExists("sebastian", "health/blood.md"); exists {
+		t.Error("encrypted file still exists after delete")
+	}
+	if store.Generation() == generation {
+		t.Error("Generation() unchanged after deleting an encrypted file")
+	}
+	generation = store.Generation()
+	if err := store.DeleteFile("sebastian", "health/blood.md"); err != nil {
+		t.Fatalf("second DeleteFile() error = %v", err)
+	}
+	if store.Generation() != generation {
+		t.Error("Generation() changed by deleting a missing file")
+	}
+
+	// Other persons and folders are untouched.
+	if err := store.WriteFile("petra", "health/note.md", "plain"); err != nil {
+		t.Fatalf("WriteFile(petra) error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "petra", "health", "note.md")); err != nil {
+		t.Errorf("petra's file not stored in plaintext: %v", err)
+	}
+	if err := store.Unlock("petra", "correct horse"); !errors.Is(err, ErrEncryptionNotSetUp) {
+		t.Errorf("Unlock(petra) error = %v", err)
+	}
+}
+
+func TestStore_EncryptionUnlockExpires(t *testing.T) {
+	store, _ := setupEncryptedVault(t)
+	store.SetUnlockTimeout(time.Millisecond)
+	if _, err := store.SetupEncryption("sebastian", "correct horse"); err != nil {
+		t.Fatalf("SetupEncryption() error = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if status := store.EncryptionStatus("sebastian"); status.Unlocked || !status.SetUp {
+		t.Fatalf("status after timeout = %+v", status)
+	}
+	if err := store.WriteFile("sebastian", "health/x.md", "x"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("WriteFile() after timeout error = %v", err)
+	}
+}
+
+func TestStore_AgentCanAccess(t *testing.T) {
+	store, _ := setupEncryptedVault(t)
+	if err := store.AgentCanAccess("sebastian", "health/blood.md"); !errors.Is(err, ErrAgentEncrypted) {
+		t.Errorf("AgentCanAccess(health) error = %v", err)
+	}
+	for _, path := range []string{"finance/2026.md", "healthy.md", "notes/health/x.md"} {
+		if err := store.AgentCanAccess("sebastian", path); err != nil {
+			t.Errorf("AgentCanAccess(%s) error = %v", path, err)
+		}
+	}
+	if err := store.AgentCanAccess("petra", "health/blood.md"); err != nil {
+		t.Errorf("AgentCanAccess(petra) error = %v", err)
+	}
+}
+
+func TestStore_MoveAndTrashEncryptedFile(t *testing.T) {
+	store, root := setupEncryptedVault(t)
+	if _, err := store.SetupEncryption("sebastian", "correct horse"); err != nil {
+		t.Fatalf("SetupEncryption() error = %v", err)
+	}
+	if err := store.WriteFile("sebastian", "health/blood.md", "ferritin 30"); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if err := store.MoveFile("sebastian", "health/blood.md", "notes/blood.md"); !errors.Is(err, ErrDecryptingMove) {
+		t.Fatalf("MoveFile() out of encrypted folder error = %v", err)
+	}
+
+	trashed, err := store.TrashFile("sebastian", "health/blood.md")
+	if err != nil {
+		t.Fatalf("TrashFile() error = %v", err)
+	}
+	if !strings.HasPrefix(trashed, "health/"+TrashDir+"/") {
+		t.Fatalf("trashed path = %q, want it inside the encrypted folder", trashed)
+	}
+	if _, err := os.Stat(filepath.Join(root, "sebastian", filepath.FromSlash(trashed)+EncryptedSuffix)); err != nil {
+		t.Errorf("trashed file is not encrypted: %v", err)
+	}
+	if got, err := store.ReadFile("sebastian", trashed); err != nil || got != "ferritin 30" {
+		t.Errorf("trashed file = %q, %v", got, err)
+	}
+}
+
+func TestStore_RejectsEncryptedNames(t *testing.T) {
+	store, root := setupEncryptedVault(t)
+	if _, err := store.SetupEncryption("sebastian", "correct horse"); err != nil {
+		t.Fatalf("SetupEncryption() error = %v", err)
+	}
+	if err := store.WriteFile("sebastian", "health/note.md", "ferritin 30"); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	const onDisk = "health/note.md" + EncryptedSuffix
+	if _, err := store.ReadFile("sebastian", onDisk); !errors.Is(err, ErrEncryptedName) {
+		t.Errorf("ReadFile(%s) error = %v", onDisk, err)
+	}
+	if err := store.WriteFile("sebastian", onDisk, "x"); !errors.Is(err, ErrEncryptedName) {
+		t.Errorf("WriteFile(%s) error = %v", onDisk, err)
+	}
+	if err := store.AppendFile("sebastian", onDisk, "x"); !errors.Is(err, ErrEncryptedName) {
+		t.Errorf("AppendFile(%s) error = %v", onDisk, err)
+	}
+	if err := store.DeleteFile("sebastian", onDisk); !errors.Is(err, ErrEncryptedName) {
+		t.Errorf("DeleteFile(%s) error = %v", onDisk, err)
+	}
+	if err := store.MoveFile("sebastian", onDisk, "health/other.md"); !errors.Is(err, ErrEncryptedName) {
+		t.Errorf("MoveFile(%s) error = %v", onDisk, err)
+	}
+	if err := store.MoveFile("sebastian", "health/note.md", "health/other.md"+EncryptedSuffix); !errors.Is(err, ErrEncryptedName) {
+		t.Errorf("MoveFile(to .enc) error = %v", err)
+	}
+
+	// The ciphertext is left alone and still reads under its plaintext name.
+	if _, err := os.Stat(filepath.Join(root, "sebastian", "health", "note.md"+EncryptedSuffix+EncryptedSuffix)); !os.IsNotExist(err) {
+		t.Errorf("ciphertext encrypted again: %v", err)
+	}
+	if got, err := store.ReadFile("sebastian", "health/note.md"); err != nil || got != "ferritin 30" {
+		t.Errorf("ReadFile() = %q, %v", got, err)
+	}
+
+	// Outside encrypted folders the suffix is an ordinary name.
+	if err := store.WriteFile("sebastian", "notes/key.enc", "x"); err != nil {
+		t.Errorf("WriteFile(notes/key.enc) error = %v", err)
+	}
+}
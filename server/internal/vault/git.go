@@ -3,7 +3,9 @@ package vault
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
@@ -19,8 +21,16 @@ func NewGit(vaultRoot string) *Git {
 
 // runGit executes a git command in the vault directory.
 func (g *Git) runGit(args ...string) (string, error) {
+	return g.runGitInput("", args...)
+}
+
+// runGitInput executes a git command in the vault directory with the given stdin.
+func (g *Git) runGitInput(stdin string, args ...string) (string, error) {
 	cmd := exec.Command("git", args...)
 	cmd.Dir = g.vaultRoot
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -144,6 +154,67 @@ func (g *Git) Commit(message string) (committed bool, err error) {
 	return true, nil
 }
 
+// CommitPaths stages and commits only the given vault-relative paths, leaving any
+// other pending changes untouched. Paths that no longer exist are staged as removals.
+// committed is false when none of the paths changed.
+func (g *Git) CommitPaths(message string, paths []string) (committed bool, err error) {
+	var present, missing []string
+	for _, p := range paths {
+		if _, statErr := os.Lstat(filepath.Join(g.vaultRoot, p)); statErr == nil {
+			present = append(present, p)
+		} else {
+			missing = append(missing, p)
+		}
+	}
+
+	if len(present) > 0 {
+		if _, err := g.runGitInput(literalPathspec(present), "add", "-A", "--pathspec-from-file=-", "--pathspec-file-nul"); err != nil {
+			return false, fmt.Errorf("git add failed: %w", err)
+		}
+	}
+	if len(missing) > 0 {
+		if _, err := g.runGitInput(literalPathspec(missing), "rm", "--cached", "-q", "--ignore-unmatch", "--pathspec-from-file=-", "--pathspec-file-nul"); err != nil {
+			return false, fmt.Errorf("git rm failed: %w", err)
+		}
+	}
+
+	// Only paths with staged changes may be passed to commit; others would fail to match.
+	staged, err := g.runGit("diff", "--cached", "--name-only", "--relative", "-z")
+	if err != nil {
+		return false, fmt.Errorf("git diff failed: %w", err)
+	}
+	wanted := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		wanted[filepath.ToSlash(p)] = true
+	}
+	var changed []string
+	for _, name := range strings.Split(staged, "\x00") {
+		if wanted[name] {
+			changed = append(changed, name)
+		}
+	}
+	if len(changed) == 0 {
+		return false, nil
+	}
+
+	if _, err := g.runGitInput(literalPathspec(changed), "commit", "-m", message, "--pathspec-from-file=-", "--pathspec-file-nul"); err != nil {
+		return false, fmt.Errorf("git commit failed: %w", err)
+	}
+	return true, nil
+}
+
+// literalPathspec encodes paths for --pathspec-from-file with --pathspec-file-nul.
+// Paths are marked literal so names containing glob characters match only themselves.
+func literalPathspec(paths []string) string {
+	var b strings.Builder
+	for _, p := range paths {
+		b.WriteString(":(literal)")
+		b.WriteString(filepath.ToSlash(p))
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
 // Push pushes local commits to the configured remote.
 func (g *Git) Push() error {
 	_, err := g.runGit("push")
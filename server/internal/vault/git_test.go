@@ -517,3 +517,45 @@ func TestGit_CommitAndPush_DeleteFile(t *testing.T) {
 		t.Error("Deleted file still exists in remote")
 	}
 }
+
+// TestGit_CommitPaths_OnlyCommitsGivenPaths verifies unrelated changes stay uncommitted.
+func TestGit_CommitPaths_OnlyCommitsGivenPaths(t *testing.T) {
+	git, localDir, _ := setupGitTestEnv(t)
+
+	if err := os.MkdirAll(filepath.Join(localDir, "sebastian"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sebastian/restored [1].md", "unrelated.md"} {
+		if err := os.WriteFile(filepath.Join(localDir, name), []byte("content\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Remove(filepath.Join(localDir, "README.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	committed, err := git.CommitPaths("Restore", []string{"sebastian/restored [1].md", "README.md", "sebastian/never-existed.md"})
+	if err != nil {
+		t.Fatalf("CommitPaths() error = %v", err)
+	}
+	if !committed {
+		t.Fatal("CommitPaths() committed = false, want true")
+	}
+
+	files := runGitCmd(t, localDir, "show", "--name-status", "--format=", "HEAD")
+	if !strings.Contains(files, "A\tsebastian/restored [1].md") || !strings.Contains(files, "D\tREADME.md") {
+		t.Errorf("unexpected commit contents:\n%s", files)
+	}
+	status := runGitCmd(t, localDir, "status", "--porcelain")
+	if !strings.Contains(status, "?? unrelated.md") {
+		t.Errorf("unrelated file should remain untracked, status:\n%s", status)
+	}
+
+	committed, err = git.CommitPaths("Restore again", []string{"sebastian/restored [1].md"})
+	if err != nil {
+		t.Fatalf("CommitPaths() second call error = %v", err)
+	}
+	if committed {
+		t.Error("CommitPaths() with no changes committed = true, want false")
+	}
+}
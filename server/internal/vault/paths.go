@@ -78,3 +78,18 @@ func ResolveRootPath(vaultRoot, relativePath string) (string, error) {
 
 	return fullPath, nil
 }
+
+// RelativeLink returns the slash path to the vault-relative target as seen from
+// the vault-relative directory dir, for links between notes and attachments.
+func RelativeLink(dir, target string) string {
+	if dir == "." || dir == "" {
+		return target
+	}
+	from := strings.Split(dir, "/")
+	to := strings.Split(target, "/")
+	i := 0
+	for i < len(from) && i < len(to)-1 && from[i] == to[i] {
+		i++
+	}
+	return strings.Repeat("../", len(from)-i) + strings.Join(to[i:], "/")
+}
@@ -151,3 +151,20 @@ func TestResolveRootPath(t *testing.T) {
 		})
 	}
 }
+
+func TestRelativeLink(t *testing.T) {
+	tests := []struct {
+		dir, target, want string
+	}{
+		{".", "notes/a.md", "notes/a.md"},
+		{"notes", "notes/a.md", "a.md"},
+		{"notes/sub", "notes/a.md", "../a.md"},
+		{"daily", "imports/x/img.png", "../imports/x/img.png"},
+		{"a/b", "a/b/c/d.png", "c/d.png"},
+	}
+	for _, tt := range tests {
+		if got := RelativeLink(tt.dir, tt.target); got != tt.want {
+			t.Errorf("RelativeLink(%q, %q) = %q, want %q", tt.dir, tt.target, got, tt.want)
+		}
+	}
+}
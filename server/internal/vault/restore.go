@@ -0,0 +1,329 @@
+package vault
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Restore change actions.
+const (
+	RestoreAdd    = "add"
+	RestoreModify = "modify"
+	RestoreDelete = "delete"
+)
+
+// MaxRestoreBytes bounds the total uncompressed size of a restore archive.
+const MaxRestoreBytes = 2 << 30
+
+// ErrRestoreTooLarge is returned when an archive expands beyond MaxRestoreBytes.
+var ErrRestoreTooLarge = errors.New("archive exceeds the maximum restore size")
+
+// RestoreChange describes how one file would change when a restore is applied.
+type RestoreChange struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`
+	Size   int64  `json:"size"`
+}
+
+// RestorePlan is the dry-run result of restoring a vault ZIP for one person.
+type RestorePlan struct {
+	Person    string          `json:"person"`
+	Changes   []RestoreChange `json:"changes"`
+	Unchanged int             `json:"unchanged"`
+
+	files map[string]*zip.File
+}
+
+// Counts returns the number of added, modified and deleted files.
+func (p *RestorePlan) Counts() (added, modified, deleted int) {
+	for _, c := range p.Changes {
+		switch c.Action {
+		case RestoreAdd:
+			added++
+		case RestoreModify:
+			modified++
+		case RestoreDelete:
+			deleted++
+		}
+	}
+	return added, modified, deleted
+}
+
+// CommitMessage describes the restore for the git history.
+func (p *RestorePlan) CommitMessage(source string) string {
+	if source == "" {
+		source = "backup archive"
+	}
+	added, modified, deleted := p.Counts()
+	return fmt.Sprintf("Restore %s vault from %s (%d added, %d modified, %d deleted)",
+		p.Person, source, added, modified, deleted)
+}
+
+// RootPaths returns the changed paths relative to the vault root, suitable for git.
+func (p *RestorePlan) RootPaths() []string {
+	out := make([]string, 0, len(p.Changes))
+	for _, c := range p.Changes {
+		out = append(out, filepath.ToSlash(filepath.Join(p.Person, filepath.FromSlash(c.Path))))
+	}
+	return out
+}
+
+// PlanRestore compares a ZIP of a person's vault (as produced by the vault backup
+// download) with the current files. Every entry must resolve inside the person's
+// vault. When deleteMissing is set, files absent from the archive are scheduled
+// for deletion so the vault matches the archive exactly.
+func (s *Store) PlanRestore(person string, zr *zip.Reader, deleteMissing bool) (*RestorePlan, error) {
+	plan := &RestorePlan{
+		Person:  person,
+		Changes: make([]RestoreChange, 0),
+		files:   make(map[string]*zip.File),
+	}
+
+	var total uint64
+	for _, f := range zr.File {
+		name := strings.TrimPrefix(f.Name, "./")
+		if strings.HasSuffix(name, "/") || f.FileInfo().IsDir() {
+			continue
+		}
+		if strings.Contains(name, "\\") {
+			return nil, fmt.Errorf("%s: %w", f.Name, ErrPathEscape)
+		}
+		if !f.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s: only regular files can be restored", f.Name)
+		}
+		fullPath, err := ResolvePath(s.rootPath, person, filepath.FromSlash(name))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		rel := filepath.ToSlash(filepath.Clean(filepath.FromSlash(name)))
+		if _, dup := plan.files[rel]; dup {
+			return nil, fmt.Errorf("%s: duplicate archive entry", f.Name)
+		}
+
+		total += f.UncompressedSize64
+		if total > MaxRestoreBytes {
+			return nil, ErrRestoreTooLarge
+		}
+		plan.files[rel] = f
+
+		action, err := compareRestoreFile(fullPath, f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		if action == "" {
+			plan.Unchanged++
+			continue
+		}
+		plan.Changes = append(plan.Changes, RestoreChange{Path: rel, Action: action, Size: int64(f.UncompressedSize64)})
+	}
+
+	if deleteMissing {
+		personRoot := filepath.Join(s.rootPath, person)
+		err := filepath.WalkDir(personRoot, func(path string, d fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				if os.IsNotExist(walkErr) && path == personRoot {
+					return filepath.SkipDir
+				}
+				return walkErr
+			}
+			if d.IsDir() || !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(personRoot, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if _, ok := plan.files[rel]; ok {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			plan.Changes = append(plan.Changes, RestoreChange{Path: rel, Action: RestoreDelete, Size: info.Size()})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(plan.Changes, func(i, j int) bool {
+		return plan.Changes[i].Path < plan.Changes[j].Path
+	})
+	return plan, nil
+}
+
+// compareRestoreFile returns the action needed to make fullPath match the archive entry,
+// or "" if it already does.
+func compareRestoreFile(fullPath string, f *zip.File) (string, error) {
+	info, err := os.Lstat(fullPath)
+	if os.IsNotExist(err) {
+		return RestoreAdd, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", errors.New("target exists and is not a regular file")
+	}
+	if uint64(info.Size()) != f.UncompressedSize64 {
+		return RestoreModify, nil
+	}
+
+	current, err := os.ReadFile(fullPath)
+	if err != nil {
+		return "", err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	incoming, err := io.ReadAll(io.LimitReader(rc, int64(f.UncompressedSize64)+1))
+	if err != nil {
+		return "", err
+	}
+	if bytes.Equal(current, incoming) {
+		return "", nil
+	}
+	return RestoreModify, nil
+}
+
+// ApplyRestore writes the planned changes to the person's vault. All incoming
+// files are staged next to their targets first; only then are targets swapped
+// in, keeping the replaced files until every swap succeeded. A failure at any
+// point leaves the vault as it was.
+func (s *Store) ApplyRestore(plan *RestorePlan) error {
+	swaps := make([]*restoreSwap, 0, len(plan.Changes))
+	discard := func() {
+		for _, sw := range swaps {
+			if sw.staged != "" && !sw.placed {
+				os.Remove(sw.staged)
+			}
+		}
+	}
+
+	for _, c := range plan.Changes {
+		target, err := ResolvePath(s.rootPath, plan.Person, filepath.FromSlash(c.Path))
+		if err != nil {
+			discard()
+			return fmt.Errorf("%s: %w", c.Path, err)
+		}
+		sw := &restoreSwap{target: target}
+		if c.Action != RestoreDelete {
+			f, ok := plan.files[c.Path]
+			if !ok {
+				discard()
+				return fmt.Errorf("restore plan is missing archive entry for %s", c.Path)
+			}
+			if sw.staged, err = stageRestoreFile(target, f); err != nil {
+				discard()
+				return fmt.Errorf("write %s: %w", c.Path, err)
+			}
+		}
+		swaps = append(swaps, sw)
+	}
+
+	for i, sw := range swaps {
+		if err := sw.apply(); err != nil {
+			for j := i; j >= 0; j-- {
+				swaps[j].undo()
+			}
+			discard()
+			return fmt.Errorf("restore %s: %w", plan.Changes[i].Path, err)
+		}
+	}
+	for _, sw := range swaps {
+		if sw.kept != "" {
+			os.Remove(sw.kept)
+		}
+	}
+	s.MarkChanged()
+	return nil
+}
+
+// restoreSwap replaces one file during ApplyRestore.
+type restoreSwap struct {
+	target string
+	// staged is the incoming file, empty for deletes.
+	staged string
+	// kept is where the replaced file was moved aside, empty if there was none.
+	kept   string
+	placed bool
+}
+
+// apply moves the current target aside and the staged file into its place.
+func (sw *restoreSwap) apply() error {
+	if _, err := os.Lstat(sw.target); err == nil {
+		kept := filepath.Join(filepath.Dir(sw.target), ".restore-kept-"+filepath.Base(sw.target))
+		if err := os.Rename(sw.target, kept); err != nil {
+			return err
+		}
+		sw.kept = kept
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if sw.staged == "" {
+		return nil
+	}
+	if err := os.Rename(sw.staged, sw.target); err != nil {
+		return err
+	}
+	sw.placed = true
+	return nil
+}
+
+// undo puts the replaced file back.
+func (sw *restoreSwap) undo() {
+	if sw.placed {
+		os.Remove(sw.target)
+		sw.placed = false
+	}
+	if sw.kept != "" {
+		os.Rename(sw.kept, sw.target)
+		sw.kept = ""
+	}
+}
+
+// stageRestoreFile writes an archive entry to a temporary file next to target
+// and returns its path.
+func stageRestoreFile(target string, f *zip.File) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".restore-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(tmp, io.LimitReader(rc, int64(f.UncompressedSize64))); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
@@ -0,0 +1,159 @@
+package vault
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func buildZip(t *testing.T, files map[string]string) *zip.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+func TestStore_PlanAndApplyRestore(t *testing.T) {
+	store, root := setupTestVault(t)
+	if err := store.WriteFile("sebastian", "daily/same.md", "same"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.WriteFile("sebastian", "daily/changed.md", "old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.WriteFile("sebastian", "extra.md", "not in archive"); err != nil {
+		t.Fatal(err)
+	}
+
+	zr := buildZip(t, map[string]string{
+		"daily/":           "",
+		"daily/same.md":    "same",
+		"daily/changed.md": "new",
+		"notes/added.md":   "added",
+	})
+
+	plan, err := store.PlanRestore("sebastian", zr, false)
+	if err != nil {
+		t.Fatalf("PlanRestore() error = %v", err)
+	}
+	added, modified, deleted := plan.Counts()
+	if added != 1 || modified != 1 || deleted != 0 || plan.Unchanged != 1 {
+		t.Fatalf("unexpected plan counts: added=%d modified=%d deleted=%d unchanged=%d", added, modified, deleted, plan.Unchanged)
+	}
+
+	plan, err = store.PlanRestore("sebastian", zr, true)
+	if err != nil {
+		t.Fatalf("PlanRestore(deleteMissing) error = %v", err)
+	}
+	if _, _, deleted := plan.Counts(); deleted != 1 {
+		t.Fatalf("expected extra.md to be scheduled for deletion, changes = %#v", plan.Changes)
+	}
+
+	if err := store.ApplyRestore(plan); err != nil {
+		t.Fatalf("ApplyRestore() error = %v", err)
+	}
+	if got, _ := store.ReadFile("sebastian", "daily/changed.md"); got != "new" {
+		t.Errorf("changed.md = %q, want %q", got, "new")
+	}
+	if got, _ := store.ReadFile("sebastian", "notes/added.md"); got != "added" {
+		t.Errorf("added.md = %q, want %q", got, "added")
+	}
+	if _, err := os.Stat(filepath.Join(root, "sebastian", "extra.md")); !os.IsNotExist(err) {
+		t.Errorf("extra.md should be deleted, stat err = %v", err)
+	}
+
+	wantRoot := []string{"sebastian/daily/changed.md", "sebastian/extra.md", "sebastian/notes/added.md"}
+	gotRoot := plan.RootPaths()
+	if len(gotRoot) != len(wantRoot) {
+		t.Fatalf("RootPaths() = %v, want %v", gotRoot, wantRoot)
+	}
+	for i := range wantRoot {
+		if gotRoot[i] != wantRoot[i] {
+			t.Fatalf("RootPaths() = %v, want %v", gotRoot, wantRoot)
+		}
+	}
+}
+
+func TestStore_ApplyRestoreRollsBack(t *testing.T) {
+	store, root := setupTestVault(t)
+	if err := store.WriteFile("sebastian", "daily/changed.md", "old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.WriteFile("sebastian", "extra.md", "not in archive"); err != nil {
+		t.Fatal(err)
+	}
+	zr := buildZip(t, map[string]string{
+		"daily/changed.md": "new",
+		"notes/added.md":   "added",
+	})
+	plan, err := store.PlanRestore("sebastian", zr, true)
+	if err != nil {
+		t.Fatalf("PlanRestore() error = %v", err)
+	}
+
+	// extra.md cannot be moved aside, so the restore fails after changed.md was
+	// already swapped in.
+	blocker := filepath.Join(root, "sebastian", ".restore-kept-extra.md")
+	if err := os.MkdirAll(filepath.Join(blocker, "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ApplyRestore(plan); err == nil {
+		t.Fatal("ApplyRestore() should fail")
+	}
+	if err := os.RemoveAll(blocker); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := store.ReadFile("sebastian", "daily/changed.md"); got != "old" {
+		t.Errorf("changed.md = %q, want %q", got, "old")
+	}
+	if got, _ := store.ReadFile("sebastian", "extra.md"); got != "not in archive" {
+		t.Errorf("extra.md = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(root, "sebastian", "notes", "added.md")); !os.IsNotExist(err) {
+		t.Errorf("added.md should not exist, stat err = %v", err)
+	}
+	for _, dir := range []string{"sebastian", "sebastian/daily", "sebastian/notes"} {
+		entries, _ := os.ReadDir(filepath.Join(root, dir))
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), ".restore") {
+				t.Errorf("leftover %s/%s", dir, e.Name())
+			}
+		}
+	}
+}
+
+func TestStore_PlanRestoreRejectsEscapes(t *testing.T) {
+	store, _ := setupTestVault(t)
+
+	for _, name := range []string{"../petra/notes/secret.md", "/etc/passwd", "daily/../../petra/x.md"} {
+		zr := buildZip(t, map[string]string{name: "evil"})
+		_, err := store.PlanRestore("sebastian", zr, false)
+		if err == nil {
+			t.Fatalf("PlanRestore(%q) should fail", name)
+		}
+		if !errors.Is(err, ErrPathEscape) && !errors.Is(err, ErrAbsolutePath) {
+			t.Fatalf("PlanRestore(%q) error = %v, want path validation error", name, err)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package vault
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SharedDir is the vault-root directory holding folders shared between persons.
+// Each person sees it as "_shared/" inside their own vault.
+const SharedDir = "_shared"
+
+// sharedEditsFile records who changed what inside a shared folder.
+const sharedEditsFile = ".edits.jsonl"
+
+// ErrSharedAccess is returned when a person may not read or write a shared folder.
+var ErrSharedAccess = errors.New("no access to shared folder")
+
+// SharedAccess is a person's access level to a shared folder.
+type SharedAccess string
+
+const (
+	SharedRead  SharedAccess = "read"
+	SharedWrite SharedAccess = "write"
+)
+
+// SharedFolders maps shared folder names to the persons that may use them.
+type SharedFolders map[string]map[string]SharedAccess
+
+// SharedFolder describes a shared folder visible to a person.
+type SharedFolder struct {
+	Name   string       `json:"name"`
+	Path   string       `json:"path"`
+	Access SharedAccess `json:"access"`
+}
+
+// SharedEdit attributes one change inside a shared folder to a person.
+type SharedEdit struct {
+	Time   time.Time `json:"time"`
+	Person string    `json:"person"`
+	Action string    `json:"action"`
+	Path   string    `json:"path"`
+}
+
+// ParseSharedFolders parses SHARED_FOLDERS, e.g.
+// "family=sebastian,petra;budget=petra,sebastian:read". Persons get write access
+// unless suffixed with ":read".
+func ParseSharedFolders(spec string) (SharedFolders, error) {
+	folders := SharedFolders{}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, persons, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("shared folder %q: expected name=person,...", entry)
+		}
+		if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+			return nil, fmt.Errorf("shared folder %q: invalid name", name)
+		}
+		grants := map[string]SharedAccess{}
+		for _, grant := range strings.Split(persons, ",") {
+			grant = strings.TrimSpace(grant)
+			if grant == "" {
+				continue
+			}
+			person, access, hasAccess := strings.Cut(grant, ":")
+			level := SharedWrite
+			if hasAccess {
+				level = SharedAccess(access)
+				if level != SharedRead && level != SharedWrite {
+					return nil, fmt.Errorf("shared folder %q: unknown access %q", name, access)
+				}
+			}
+			grants[person] = level
+		}
+		if len(grants) == 0 {
+			return nil, fmt.Errorf("shared folder %q: no persons", name)
+		}
+		folders[name] = grants
+	}
+	return folders, nil
+}
+
+// Access returns the person's access to a shared folder, or "" for none.
+func (f SharedFolders) Access(person, folder string) SharedAccess {
+	return f[folder][person]
+}
+
+// For lists the shared folders a person can access, sorted by name.
+func (f SharedFolders) For(person string) []SharedFolder {
+	out := make([]SharedFolder, 0)
+	for name, grants := range f {
+		if access, ok := grants[person]; ok {
+			out = append(out, SharedFolder{Name: name, Path: SharedDir + "/" + name, Access: access})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// splitSharedPath reports whether a person-relative path points into _shared and
+// returns the folder name ("" for _shared itself).
+func splitSharedPath(path string) (folder string, shared bool) {
+	parts := strings.SplitN(filepath.ToSlash(filepath.Clean(path)), "/", 3)
+	if parts[0] != SharedDir {
+		return "", false
+	}
+	if len(parts) == 1 {
+		return "", true
+	}
+	return parts[1], true
+}
+
+// SetSharedFolders replaces the shared folder access rules.
+func (s *Store) SetSharedFolders(folders SharedFolders) {
+	s.sharedMu.Lock()
+	defer s.sharedMu.Unlock()
+	s.shared = folders
+}
+
+// SharedFolders lists the shared folders a person can access.
+func (s *Store) SharedFolders(person string) []SharedFolder {
+	s.sharedMu.RLock()
+	defer s.sharedMu.RUnlock()
+	return s.shared.For(person)
+}
+
+// Resolve maps a person-relative path to an absolute path for reading. Paths under
+// _shared/ resolve to the vault-root shared folder if the person has access.
+func (s *Store) Resolve(person, path string) (string, error) {
+	return s.resolve(person, path, false)
+}
+
+func (s *Store) resolve(person, path string, write bool) (string, error) {
+	if err := ValidatePath(path); err != nil {
+		return "", err
+	}
+	folder, shared := splitSharedPath(path)
+	if !shared {
+		return ResolvePath(s.rootPath, person, path)
+	}
+	if folder == "" {
+		if write {
+			return "", ErrSharedAccess
+		}
+		return ResolveRootPath(s.rootPath, SharedDir)
+	}
+
+	s.sharedMu.RLock()
+	access := s.shared.Access(person, folder)
+	s.sharedMu.RUnlock()
+	if access == "" || (write && access != SharedWrite) {
+		return "", fmt.Errorf("%w %q", ErrSharedAccess, folder)
+	}
+	return ResolveRootPath(s.rootPath, path)
+}
+
+// recordSharedEdit appends an attribution entry when a write lands in a shared
+// folder. Writes outside _shared are ignored.
+func (s *Store) recordSharedEdit(person, path, action string) error {
+	folder, shared := splitSharedPath(path)
+	if !shared || folder == "" {
+		return nil
+	}
+	line, err := json.Marshal(SharedEdit{
+		Time:   time.Now().UTC(),
+		Person: person,
+		Action: action,
+		Path:   filepath.ToSlash(filepath.Clean(path)),
+	})
+	if err != nil {
+		return err
+	}
+	return s.AppendRootFile(filepath.Join(SharedDir, folder, sharedEditsFile), string(line)+"\n")
+}
+
+// SharedEdits returns the most recent edits in a shared folder, newest first.
+// limit <= 0 returns all of them.
+func (s *Store) SharedEdits(person, folder string, limit int) ([]SharedEdit, error) {
+	if _, err := s.Resolve(person, filepath.Join(SharedDir, folder)); err != nil {
+		return nil, err
+	}
+	fullPath, err := ResolveRootPath(s.rootPath, filepath.Join(SharedDir, folder, sharedEditsFile))
+	if err != nil {
+		return nil, err
+	}
+
+	edits := make([]SharedEdit, 0)
+	f, err := os.Open(fullPath)
+	if os.IsNotExist(err) {
+		return edits, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var edit SharedEdit
+		if err := json.Unmarshal(scanner.Bytes(), &edit); err != nil {
+			continue // Skip lines mangled by merge conflicts.
+		}
+		edits = append(edits, edit)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	if limit > 0 && len(edits) > limit {
+		edits = edits[:limit]
+	}
+	return edits, nil
+}
@@ -0,0 +1,150 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setupSharedVault(t *testing.T) (*Store, string) {
+	t.Helper()
+	store, root := setupTestVault(t)
+	folders, err := ParseSharedFolders("family=sebastian,petra; budget=petra,sebastian:read")
+	if err != nil {
+		t.Fatalf("ParseSharedFolders() error = %v", err)
+	}
+	store.SetSharedFolders(folders)
+	return store, root
+}
+
+func TestParseSharedFolders(t *testing.T) {
+	folders, err := ParseSharedFolders("family=sebastian,petra;budget=petra,sebastian:read")
+	if err != nil {
+		t.Fatalf("ParseSharedFolders() error = %v", err)
+	}
+	if got := folders.Access("sebastian", "family"); got != SharedWrite {
+		t.Errorf("family access = %q, want write", got)
+	}
+	if got := folders.Access("sebastian", "budget"); got != SharedRead {
+		t.Errorf("budget access = %q, want read", got)
+	}
+	if got := folders.Access("anna", "family"); got != "" {
+		t.Errorf("unlisted person access = %q, want none", got)
+	}
+
+	for _, spec := range []string{"family", "a/b=petra", ".git=petra", "x=petra:admin", "x="} {
+		if _, err := ParseSharedFolders(spec); err == nil {
+			t.Errorf("ParseSharedFolders(%q) should fail", spec)
+		}
+	}
+}
+
+func TestStore_SharedFolderAccess(t *testing.T) {
+	store, root := setupSharedVault(t)
+
+	if err := store.WriteFile("sebastian", "_shared/family/shopping.md", "- milk\n"); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, SharedDir, "family", "shopping.md")); err != nil {
+		t.Fatalf("shared file not at vault root: %v", err)
+	}
+	got, err := store.ReadFile("petra", "_shared/family/shopping.md")
+	if err != nil || got != "- milk\n" {
+		t.Fatalf("petra ReadFile() = %q, %v", got, err)
+	}
+
+	// Read-only grant.
+	if err := store.WriteFile("petra", "_shared/budget/2026.md", "plan"); err != nil {
+		t.Fatalf("petra WriteFile(budget) error = %v", err)
+	}
+	if _, err := store.ReadFile("sebastian", "_shared/budget/2026.md"); err != nil {
+		t.Fatalf("sebastian ReadFile(budget) error = %v", err)
+	}
+	if err := store.WriteFile("sebastian", "_shared/budget/2026.md", "x"); !errors.Is(err, ErrSharedAccess) {
+		t.Errorf("sebastian WriteFile(budget) error = %v, want ErrSharedAccess", err)
+	}
+	if err := store.DeleteFile("sebastian", "_shared/budget/2026.md"); !errors.Is(err, ErrSharedAccess) {
+		t.Errorf("sebastian DeleteFile(budget) error = %v, want ErrSharedAccess", err)
+	}
+
+	// Unknown folders and persons without a grant.
+	if _, err := store.ReadFile("anna", "_shared/family/shopping.md"); !errors.Is(err, ErrSharedAccess) {
+		t.Errorf("anna ReadFile() error = %v, want ErrSharedAccess", err)
+	}
+	if err := store.WriteFile("sebastian", "_shared/secret/x.md", "x"); !errors.Is(err, ErrSharedAccess) {
+		t.Errorf("WriteFile(unknown folder) error = %v, want ErrSharedAccess", err)
+	}
+}
+
+func TestStore_SharedListDir(t *testing.T) {
+	store, _ := setupSharedVault(t)
+
+	entries, err := store.ListDir("sebastian", ".")
+	if err != nil {
+		t.Fatalf("ListDir() error = %v", err)
+	}
+	found := false
+	for _, e := range entries {
+		if e.Name == SharedDir && e.IsDir {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("root listing %v lacks %s", entries, SharedDir)
+	}
+
+	entries, err = store.ListDir("sebastian", SharedDir)
+	if err != nil {
+		t.Fatalf("ListDir(_shared) error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Name != "budget" || entries[1].Name != "family" {
+		t.Errorf("shared listing = %v", entries)
+	}
+
+	store.SetSharedFolders(nil)
+	entries, _ = store.ListDir("sebastian", ".")
+	for _, e := range entries {
+		if e.Name == SharedDir {
+			t.Errorf("root listing shows %s without grants", SharedDir)
+		}
+	}
+}
+
+func TestStore_SharedEdits(t *testing.T) {
+	store, _ := setupSharedVault(t)
+
+	store.WriteFile("sebastian", "_shared/family/shopping.md", "- milk\n")
+	store.AppendFile("petra", "_shared/family/shopping.md", "- bread\n")
+	store.DeleteFile("petra", "_shared/family/shopping.md")
+	store.WriteFile("sebastian", "notes/private.md", "mine")
+
+	edits, err := store.SharedEdits("sebastian", "family", 0)
+	if err != nil {
+		t.Fatalf("SharedEdits() error = %v", err)
+	}
+	want := []struct{ person, action string }{{"petra", "delete"}, {"petra", "append"}, {"sebastian", "write"}}
+	if len(edits) != len(want) {
+		t.Fatalf("edits = %+v", edits)
+	}
+	for i, w := range want {
+		if edits[i].Person != w.person || edits[i].Action != w.action || edits[i].Path != "_shared/family/shopping.md" {
+			t.Errorf("edit %d = %+v, want %s %s", i, edits[i], w.person, w.action)
+		}
+	}
+
+	if edits, _ := store.SharedEdits("sebastian", "family", 1); len(edits) != 1 {
+		t.Errorf("limit ignored: %d edits", len(edits))
+	}
+	if _, err := store.SharedEdits("anna", "family", 0); !errors.Is(err, ErrSharedAccess) {
+		t.Errorf("anna SharedEdits() error = %v, want ErrSharedAccess", err)
+	}
+
+	// The edit log is hidden from listings.
+	entries, _ := store.ListDir("petra", "_shared/family")
+	for _, e := range entries {
+		if e.Name == sharedEditsFile {
+			t.Error("edit log should be hidden")
+		}
+	}
+}
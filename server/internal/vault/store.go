@@ -1,12 +1,19 @@
 package vault
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// ErrFileExists is returned when a move or import would overwrite an existing file.
+var ErrFileExists = errors.New("file already exists")
+
 // FileEntry represents a file or directory in a listing.
 type FileEntry struct {
 	Name  string `json:"name"`
@@ -17,6 +24,22 @@ type FileEntry struct {
 // Store provides file operations for the notes vault.
 type Store struct {
 	rootPath string
+
+	sharedMu sync.RWMutex
+	shared   SharedFolders
+
+	cryptMu       sync.RWMutex
+	encrypted     EncryptedFolders
+	keys          map[string]*unlockedKey
+	unlockTimeout time.Duration
+	// strayMu serializes encrypting plaintext files found in encrypted folders.
+	strayMu sync.Mutex
+
+	hookMu      sync.RWMutex
+	onChange    func(Change)
+	writeSource string
+
+	generation atomic.Uint64
 }
 
 // NewStore creates a new Store with the given root path.
@@ -29,13 +52,84 @@ func (s *Store) RootPath() string {
 	return s.rootPath
 }
 
+// Change kinds reported to the change hook.
+const (
+	// ChangeFileCreated is reported when a write creates a new file.
+	ChangeFileCreated = "file_created"
+	// ChangeDailyCreated is reported when a daily note is created.
+	ChangeDailyCreated = "daily_created"
+	// ChangeCapture is reported when an entry or todo is captured into a note.
+	ChangeCapture = "capture"
+)
+
+// Change describes a vault change reported to the change hook.
+type Change struct {
+	Kind   string
+	Person string
+	// Path is the person-relative, slash-separated file that changed.
+	Path string
+	// Source identifies who made the change, as set by SetWriteSource; empty
+	// for the person's own edits.
+	Source string
+}
+
+// SetChangeHook registers a function called after vault changes. It runs on
+// the writing goroutine and must not block.
+func (s *Store) SetChangeHook(hook func(Change)) {
+	s.hookMu.Lock()
+	defer s.hookMu.Unlock()
+	s.onChange = hook
+}
+
+// SetWriteSource sets the Source reported with the following changes; pass ""
+// to reset it. Callers hold the vault lock for writing from setting the source
+// until resetting it, so no other writer's changes are attributed to them.
+func (s *Store) SetWriteSource(source string) {
+	s.hookMu.Lock()
+	defer s.hookMu.Unlock()
+	s.writeSource = source
+}
+
+func (s *Store) notifyChange(kind, person, path string) {
+	s.hookMu.RLock()
+	hook, source := s.onChange, s.writeSource
+	s.hookMu.RUnlock()
+	if hook != nil {
+		hook(Change{Kind: kind, Person: person, Path: filepath.ToSlash(filepath.Clean(path)), Source: source})
+	}
+}
+
+// Generation changes after every write, append, delete or restore through the
+// store, and after MarkChanged. Caches of vault content compare it to notice
+// changes without walking the vault.
+func (s *Store) Generation() uint64 {
+	return s.generation.Load()
+}
+
+// MarkChanged advances Generation for changes made outside the store, such as a
+// git pull.
+func (s *Store) MarkChanged() {
+	s.generation.Add(1)
+}
+
 // ReadFile reads the content of a file within a person's vault.
 func (s *Store) ReadFile(person, path string) (string, error) {
-	fullPath, err := ResolvePath(s.rootPath, person, path)
+	if err := s.checkEncryptedName(person, path); err != nil {
+		return "", err
+	}
+	fullPath, err := s.resolve(person, path, false)
 	if err != nil {
 		return "", err
 	}
 
+	if s.IsEncrypted(person, path) {
+		content, found, err := s.readEncrypted(person, path, fullPath)
+		if found || err != nil {
+			return content, err
+		}
+		return s.readPlaintextInEncrypted(person, path, fullPath)
+	}
+
 	content, err := os.ReadFile(fullPath)
 	if err != nil {
 		return "", err
@@ -47,73 +141,182 @@ func (s *Store) ReadFile(person, path string) (string, error) {
 // WriteFile writes content to a file within a person's vault.
 // It creates parent directories if they don't exist.
 func (s *Store) WriteFile(person, path, content string) error {
-	fullPath, err := ResolvePath(s.rootPath, person, path)
-	if err != nil {
+	if err := s.checkEncryptedName(person, path); err != nil {
 		return err
 	}
-
-	// Create parent directories if needed
-	dir := filepath.Dir(fullPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	fullPath, err := s.resolve(person, path, true)
+	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(fullPath, []byte(content), 0644)
+	existed, _ := s.FileExists(person, path)
+
+	if s.IsEncrypted(person, path) {
+		if err := s.writeEncrypted(person, path, fullPath, content); err != nil {
+			return err
+		}
+	} else {
+		// Create parent directories if needed
+		dir := filepath.Dir(fullPath)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			return err
+		}
+		if err := s.recordSharedEdit(person, path, "write"); err != nil {
+			return err
+		}
+	}
+	s.MarkChanged()
+	if !existed {
+		s.notifyChange(ChangeFileCreated, person, path)
+	}
+	return nil
 }
 
 // AppendFile appends content to a file within a person's vault.
 // It creates the file and parent directories if they don't exist.
 func (s *Store) AppendFile(person, path, content string) error {
-	fullPath, err := ResolvePath(s.rootPath, person, path)
-	if err != nil {
-		return err
-	}
-
-	// Create parent directories if needed
-	dir := filepath.Dir(fullPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := s.checkEncryptedName(person, path); err != nil {
 		return err
 	}
-
-	f, err := os.OpenFile(fullPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	fullPath, err := s.resolve(person, path, true)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	_, err = f.WriteString(content)
-	return err
+	existed, _ := s.FileExists(person, path)
+
+	if s.IsEncrypted(person, path) {
+		existing, err := s.ReadFile(person, path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := s.writeEncrypted(person, path, fullPath, existing+content); err != nil {
+			return err
+		}
+	} else {
+		// Create parent directories if needed
+		dir := filepath.Dir(fullPath)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(fullPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteString(content)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.recordSharedEdit(person, path, "append"); err != nil {
+			return err
+		}
+	}
+	s.MarkChanged()
+	if !existed {
+		s.notifyChange(ChangeFileCreated, person, path)
+	}
+	return nil
 }
 
 // DeleteFile deletes a file within a person's vault.
 // It's idempotent - returns no error if the file doesn't exist.
 func (s *Store) DeleteFile(person, path string) error {
-	fullPath, err := ResolvePath(s.rootPath, person, path)
+	if err := s.checkEncryptedName(person, path); err != nil {
+		return err
+	}
+	fullPath, err := s.resolve(person, path, true)
 	if err != nil {
 		return err
 	}
 
+	removed := false
+	if s.IsEncrypted(person, path) {
+		err := os.Remove(fullPath + EncryptedSuffix)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		removed = err == nil
+	}
+
 	err = os.Remove(fullPath)
-	if os.IsNotExist(err) {
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		removed = true
+	}
+	if !removed {
 		return nil // Idempotent delete
 	}
-	return err
+	s.MarkChanged()
+	return s.recordSharedEdit(person, path, "delete")
+}
+
+// MoveFile moves a file within a person's vault. It refuses to overwrite an
+// existing file or to move an encrypted file out of its encrypted folder, and
+// re-encrypts files moved into one.
+func (s *Store) MoveFile(person, from, to string) error {
+	for _, p := range []string{from, to} {
+		if err := s.checkEncryptedName(person, p); err != nil {
+			return err
+		}
+	}
+	if _, err := s.resolve(person, from, true); err != nil {
+		return err
+	}
+	if s.IsEncrypted(person, from) && !s.IsEncrypted(person, to) {
+		return ErrDecryptingMove
+	}
+	exists, err := s.FileExists(person, to)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrFileExists
+	}
+
+	content, err := s.ReadFile(person, from)
+	if err != nil {
+		return err
+	}
+	if err := s.WriteFile(person, to, content); err != nil {
+		return err
+	}
+	return s.DeleteFile(person, from)
 }
 
 // ListDir lists the contents of a directory within a person's vault.
 // It filters out hidden files (starting with '.') and sorts entries
 // with files first, then directories, both sorted alphabetically.
+// The person's shared folders appear under a virtual "_shared" directory.
 func (s *Store) ListDir(person, path string) ([]FileEntry, error) {
-	fullPath, err := ResolvePath(s.rootPath, person, path)
+	fullPath, err := s.resolve(person, path, false)
 	if err != nil {
 		return nil, err
 	}
 
+	if folder, shared := splitSharedPath(path); shared && folder == "" {
+		entries := make([]FileEntry, 0)
+		for _, f := range s.SharedFolders(person) {
+			entries = append(entries, FileEntry{Name: f.Name, Path: filepath.FromSlash(f.Path), IsDir: true})
+		}
+		return entries, nil
+	}
+
 	entries, err := os.ReadDir(fullPath)
 	if err != nil {
 		return nil, err
 	}
 
+	isRoot := filepath.Clean(path) == "."
 	files := make([]FileEntry, 0)
 	dirs := make([]FileEntry, 0)
 	for _, entry := range entries {
@@ -122,6 +325,18 @@ func (s *Store) ListDir(person, path string) ([]FileEntry, error) {
 		if strings.HasPrefix(name, ".") {
 			continue
 		}
+		// A person's own _shared directory is shadowed by the shared folders.
+		if isRoot && name == SharedDir {
+			continue
+		}
+
+		// Encrypted files are listed under their plaintext names.
+		if !entry.IsDir() && strings.HasSuffix(name, EncryptedSuffix) {
+			plainName := strings.TrimSuffix(name, EncryptedSuffix)
+			if s.IsEncrypted(person, filepath.Join(path, plainName)) {
+				name = plainName
+			}
+		}
 
 		entryPath := path
 		if entryPath == "" || entryPath == "." {
@@ -142,6 +357,9 @@ func (s *Store) ListDir(person, path string) ([]FileEntry, error) {
 			files = append(files, fe)
 		}
 	}
+	if isRoot && len(s.SharedFolders(person)) > 0 {
+		dirs = append(dirs, FileEntry{Name: SharedDir, Path: SharedDir, IsDir: true})
+	}
 
 	// Sort files and directories case-insensitively
 	sortFunc := func(entries []FileEntry) {
@@ -158,11 +376,17 @@ func (s *Store) ListDir(person, path string) ([]FileEntry, error) {
 
 // FileExists checks if a file exists within a person's vault.
 func (s *Store) FileExists(person, path string) (bool, error) {
-	fullPath, err := ResolvePath(s.rootPath, person, path)
+	fullPath, err := s.resolve(person, path, false)
 	if err != nil {
 		return false, err
 	}
 
+	if s.IsEncrypted(person, path) {
+		if _, err := os.Stat(fullPath + EncryptedSuffix); err == nil {
+			return true, nil
+		}
+	}
+
 	_, err = os.Stat(fullPath)
 	if os.IsNotExist(err) {
 		return false, nil
@@ -1,9 +1,13 @@
 package vault
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func setupTestVault(t *testing.T) (*Store, string) {
@@ -144,6 +148,64 @@ func TestStore_DeleteFile_Idempotent(t *testing.T) {
 	}
 }
 
+func TestStore_MoveFile(t *testing.T) {
+	store, _ := setupTestVault(t)
+
+	if err := store.WriteFile("sebastian", "inbox/idea.md", "idea"); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := store.WriteFile("sebastian", "projects/taken.md", "taken"); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if err := store.MoveFile("sebastian", "inbox/idea.md", "projects/taken.md"); !errors.Is(err, ErrFileExists) {
+		t.Fatalf("MoveFile() onto existing file error = %v", err)
+	}
+	if err := store.MoveFile("sebastian", "inbox/idea.md", "projects/idea.md"); err != nil {
+		t.Fatalf("MoveFile() error = %v", err)
+	}
+	if got, err := store.ReadFile("sebastian", "projects/idea.md"); err != nil || got != "idea" {
+		t.Errorf("moved file = %q, %v", got, err)
+	}
+	if exists, _ := store.FileExists("sebastian", "inbox/idea.md"); exists {
+		t.Error("source still exists after move")
+	}
+	if err := store.MoveFile("sebastian", "inbox/missing.md", "projects/missing.md"); !os.IsNotExist(err) {
+		t.Errorf("MoveFile() of missing file error = %v", err)
+	}
+}
+
+func TestStore_TrashFile(t *testing.T) {
+	store, _ := setupTestVault(t)
+
+	if err := store.WriteFile("sebastian", "notes/old.md", "old"); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	trashed, err := store.TrashFile("sebastian", "notes/old.md")
+	if err != nil {
+		t.Fatalf("TrashFile() error = %v", err)
+	}
+	if !strings.HasPrefix(trashed, TrashDir+"/") || !strings.HasSuffix(trashed, "/notes/old.md") {
+		t.Errorf("trashed path = %q", trashed)
+	}
+	if got, err := store.ReadFile("sebastian", trashed); err != nil || got != "old" {
+		t.Errorf("trashed file = %q, %v", got, err)
+	}
+	if exists, _ := store.FileExists("sebastian", "notes/old.md"); exists {
+		t.Error("file still exists after trashing")
+	}
+
+	entries, err := store.ListDir("sebastian", ".")
+	if err != nil {
+		t.Fatalf("ListDir() error = %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Name == TrashDir {
+			t.Error("trash is listed")
+		}
+	}
+}
+
 func TestStore_ListDir(t *testing.T) {
 	store, tmpDir := setupTestVault(t)
 
@@ -246,3 +308,54 @@ func TestStore_PersonIsolation(t *testing.T) {
 		t.Errorf("Petra can read sebastian's file: error = %v", err)
 	}
 }
+
+func TestStore_ChangeHook(t *testing.T) {
+	store, _ := setupTestVault(t)
+	var changes []string
+	store.SetChangeHook(func(c Change) {
+		changes = append(changes, c.Kind+" "+c.Person+":"+c.Path+" "+c.Source)
+	})
+
+	if err := store.WriteFile("sebastian", "inbox/a.md", "one"); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := store.WriteFile("sebastian", "inbox/a.md", "two"); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	store.SetWriteSource("run-1")
+	if err := store.AppendFile("petra", "./log.md", "x"); err != nil {
+		t.Fatalf("AppendFile() error = %v", err)
+	}
+	store.SetWriteSource("")
+	if err := store.AppendFile("petra", "log.md", "y"); err != nil {
+		t.Fatalf("AppendFile() error = %v", err)
+	}
+
+	daily := NewDaily(store)
+	date := time.Date(2026, 3, 4, 9, 0, 0, 0, time.Local)
+	_, path, _, err := daily.GetOrCreateDaily("petra", date)
+	if err != nil {
+		t.Fatalf("GetOrCreateDaily() error = %v", err)
+	}
+	if _, _, _, err := daily.GetOrCreateDaily("petra", date); err != nil {
+		t.Fatalf("GetOrCreateDaily() error = %v", err)
+	}
+	if err := daily.AddTask("petra", path, "work", "ship it"); err != nil {
+		t.Fatalf("AddTask() error = %v", err)
+	}
+	if err := daily.AppendEntry("petra", path, "note", false); err != nil {
+		t.Fatalf("AppendEntry() error = %v", err)
+	}
+
+	want := []string{
+		"file_created sebastian:inbox/a.md ",
+		"file_created petra:log.md run-1",
+		"file_created petra:daily/2026-03-04.md ",
+		"daily_created petra:daily/2026-03-04.md ",
+		"capture petra:daily/2026-03-04.md ",
+		"capture petra:daily/2026-03-04.md ",
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %q, want %q", changes, want)
+	}
+}
@@ -0,0 +1,32 @@
+package vault
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TrashDir holds deleted files, like Obsidian's trash folder. It is hidden
+// from listings because its name starts with a dot.
+const TrashDir = ".trash"
+
+// TrashFile moves a file into the trash and returns its new path. Each call
+// gets a timestamped folder so earlier deletions of the same path are kept.
+// Files from an encrypted folder go to a trash inside that folder so they stay
+// encrypted.
+func (s *Store) TrashFile(person, path string) (string, error) {
+	rel := filepath.ToSlash(filepath.Clean(path))
+	root := TrashDir
+	s.cryptMu.RLock()
+	folder, _, encrypted := s.encrypted.match(person, rel)
+	s.cryptMu.RUnlock()
+	if encrypted {
+		root = folder + "/" + TrashDir
+		rel = strings.TrimPrefix(rel, folder+"/")
+	}
+	dest := root + "/" + time.Now().UTC().Format("20060102-150405.000") + "/" + rel
+	if err := s.MoveFile(person, path, dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}